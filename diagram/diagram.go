@@ -65,15 +65,23 @@
 //   - GO_UML_ROOT_DIRECTORY: Root directory for state-machine diagrams (default: ".go-uml-statemachine-parsers")
 //   - GO_UML_VALIDATION_LEVEL: Validation level ("in-progress" or "products")
 //   - GO_UML_BACKUP_ENABLED: Enable backups ("true" or "false")
+//   - GO_UML_BACKUP_RETENTION: Backups kept per diagram (0 keeps all)
 //   - GO_UML_MAX_FILE_SIZE: Maximum file size in bytes
 //   - GO_UML_DEBUG_LOGGING: Enable debug logging ("true" or "false")
+//   - GO_UML_ALLOW_MULTIPLE_BLOCKS: Allow several @startuml blocks per file ("true" or "false")
+//   - GO_UML_MAX_INCLUDE_DEPTH: Nesting limit when expanding !include directives
+//   - GO_UML_LOCK_TIMEOUT: How long to wait for a cross-process lock (e.g. "10s")
 package diagram
 
 import (
+	"io"
+
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/report"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/repository"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/service"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/validation"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/watch"
 )
 
 // Re-export key types for public API
@@ -129,6 +137,273 @@ type ValidationError = models.ValidationError
 // ValidationWarning represents a validation warning that doesn't prevent promotion.
 type ValidationWarning = models.ValidationWarning
 
+// Span identifies the source range of a validation finding.
+type Span = models.Span
+
+// Fix is a machine-applicable suggestion attached to a validation finding.
+type Fix = models.Fix
+
+// TextEdit is a single text replacement that is part of a Fix.
+type TextEdit = models.TextEdit
+
+// Block is a single @startuml ... @enduml diagram within a file.
+type Block = models.Block
+
+// Expansion is a state-machine diagram with its includes inlined.
+type Expansion = models.Expansion
+
+// DependencyGraph is the reference graph of a set of state-machine diagrams.
+type DependencyGraph = models.DependencyGraph
+
+// GraphNode identifies a state-machine diagram in a DependencyGraph.
+type GraphNode = models.GraphNode
+
+// GraphEdge is a reference from one diagram to another in a DependencyGraph.
+type GraphEdge = models.GraphEdge
+
+// DeleteOptions controls DeleteFileWithOptions.
+type DeleteOptions = models.DeleteOptions
+
+// DeleteResult describes a completed deletion and the references it broke.
+type DeleteResult = models.DeleteResult
+
+// PromoteOptions controls PromoteToProductsFileWithOptions.
+type PromoteOptions = models.PromoteOptions
+
+// UpdateOptions sets the author and message recorded with an update or revert.
+type UpdateOptions = models.UpdateOptions
+
+// Query selects state-machine diagrams by name, version and metadata.
+type Query = models.Query
+
+// QueryResult is a page of state-machine diagrams matching a Query.
+type QueryResult = models.QueryResult
+
+// QuerySortField selects the ordering of query results.
+type QuerySortField = models.QuerySortField
+
+// Query sort field constants.
+const (
+	// QuerySortByName orders by name, then version, then location.
+	QuerySortByName = models.QuerySortByName
+
+	// QuerySortByVersion orders by semantic version.
+	QuerySortByVersion = models.QuerySortByVersion
+
+	// QuerySortByCreated orders by creation time.
+	QuerySortByCreated = models.QuerySortByCreated
+
+	// QuerySortByModified orders by modification time.
+	QuerySortByModified = models.QuerySortByModified
+)
+
+// Backup describes a snapshot of a state-machine diagram taken before it changed.
+type Backup = models.Backup
+
+// Backup reason constants.
+const (
+	// BackupReasonUpdate marks a backup taken before an in-progress file was overwritten.
+	BackupReasonUpdate = models.BackupReasonUpdate
+
+	// BackupReasonDelete marks a backup taken before a file was deleted.
+	BackupReasonDelete = models.BackupReasonDelete
+
+	// BackupReasonPromote marks a backup taken before an in-progress file was promoted.
+	BackupReasonPromote = models.BackupReasonPromote
+
+	// BackupReasonRestore marks a backup taken before a restore overwrote a file.
+	BackupReasonRestore = models.BackupReasonRestore
+)
+
+// SearchQuery describes a full-text or symbol search across state-machine diagrams.
+type SearchQuery = models.SearchQuery
+
+// SearchHit is a single search match with its file, line and column.
+type SearchHit = models.SearchHit
+
+// SearchKind selects what a search hit matched.
+type SearchKind = models.SearchKind
+
+// Search kind constants.
+const (
+	// SearchKindContent matches any text on a line.
+	SearchKindContent = models.SearchKindContent
+
+	// SearchKindState matches state names.
+	SearchKindState = models.SearchKindState
+
+	// SearchKindEvent matches the event names of transitions.
+	SearchKindEvent = models.SearchKindEvent
+
+	// SearchKindNote matches note text.
+	SearchKindNote = models.SearchKindNote
+)
+
+// Revision describes one recorded version of an in-progress state-machine diagram.
+type Revision = models.Revision
+
+// Diff is the line-based difference between two revisions.
+type Diff = models.Diff
+
+// DiffLine is one line of a Diff.
+type DiffLine = models.DiffLine
+
+// DiffOp is the kind of change a DiffLine represents.
+type DiffOp = models.DiffOp
+
+// Diff operation constants.
+const (
+	// DiffEqual marks a line present in both revisions.
+	DiffEqual = models.DiffEqual
+
+	// DiffInsert marks a line only present in the newer revision.
+	DiffInsert = models.DiffInsert
+
+	// DiffDelete marks a line only present in the older revision.
+	DiffDelete = models.DiffDelete
+)
+
+// Change describes one commit of a state-machine diagram recorded by a git repository.
+type Change = models.Change
+
+// ChangeOperation is the repository operation a Change records.
+type ChangeOperation = models.ChangeOperation
+
+// Change operation constants.
+const (
+	// ChangeWrite records content being written.
+	ChangeWrite = models.ChangeWrite
+
+	// ChangeMove records a move between locations, such as a promotion.
+	ChangeMove = models.ChangeMove
+
+	// ChangeDelete records a deletion.
+	ChangeDelete = models.ChangeDelete
+
+	// ChangeMetadata records metadata being replaced.
+	ChangeMetadata = models.ChangeMetadata
+)
+
+// DiagramEntry lists a state-machine diagram without its content.
+type DiagramEntry = models.DiagramEntry
+
+// SkippedFile reports a file a listing could not include, with the reason.
+type SkippedFile = models.SkippedFile
+
+// DiagramListing holds the entries of a location and the files that were skipped.
+type DiagramListing = models.DiagramListing
+
+// ProductChecksum is the digest and optional signature recorded when a diagram is promoted to products.
+type ProductChecksum = models.ProductChecksum
+
+// KeyProvider supplies the ed25519 keys that sign products on promotion and verify them on read.
+type KeyProvider = models.KeyProvider
+
+// StaticKeyProvider is a KeyProvider holding its keys in memory.
+type StaticKeyProvider = models.StaticKeyProvider
+
+// CheckOptions controls an integrity check of the repository.
+type CheckOptions = models.CheckOptions
+
+// CheckReport is the result of an integrity check.
+type CheckReport = models.CheckReport
+
+// Finding is one inconsistency found by an integrity check.
+type Finding = models.Finding
+
+// FindingType classifies a Finding.
+type FindingType = models.FindingType
+
+// RepairMode selects what an integrity check does about its findings.
+type RepairMode = models.RepairMode
+
+// RepairAction is what an integrity check did about a finding.
+type RepairAction = models.RepairAction
+
+// Finding type constants.
+const (
+	// FindingInvalidName marks a file whose name does not follow {name}-{version}.puml.
+	FindingInvalidName = models.FindingInvalidName
+
+	// FindingOversizedFile marks a diagram larger than Config.MaxFileSize.
+	FindingOversizedFile = models.FindingOversizedFile
+
+	// FindingOrphanedMetadata marks a metadata sidecar without its diagram.
+	FindingOrphanedMetadata = models.FindingOrphanedMetadata
+
+	// FindingCorruptMetadata marks a metadata sidecar that cannot be parsed.
+	FindingCorruptMetadata = models.FindingCorruptMetadata
+
+	// FindingDuplicateVersion marks a version present in both in-progress and products.
+	FindingDuplicateVersion = models.FindingDuplicateVersion
+
+	// FindingIncompleteRecord marks a backup or revision missing its content or its record.
+	FindingIncompleteRecord = models.FindingIncompleteRecord
+
+	// FindingLeftoverTempFile marks a temp file left by an interrupted write.
+	FindingLeftoverTempFile = models.FindingLeftoverTempFile
+
+	// FindingStaleLock marks a diagram lock file that no process holds.
+	FindingStaleLock = models.FindingStaleLock
+)
+
+// Repair mode constants.
+const (
+	// RepairNone only reports the findings.
+	RepairNone = models.RepairNone
+
+	// RepairQuarantine moves every offending file to the quarantine directory.
+	RepairQuarantine = models.RepairQuarantine
+
+	// RepairFix removes redundant files and quarantines the rest.
+	RepairFix = models.RepairFix
+)
+
+// Repair action constants.
+const (
+	// RepairActionNone means the finding was not repaired.
+	RepairActionNone = models.RepairActionNone
+
+	// RepairActionRemoved means the offending files were removed.
+	RepairActionRemoved = models.RepairActionRemoved
+
+	// RepairActionQuarantined means the offending files were moved to the quarantine directory.
+	RepairActionQuarantined = models.RepairActionQuarantined
+)
+
+// DiagramResult pairs a validation result with the state-machine diagram it was produced for.
+type DiagramResult = report.DiagramResult
+
+// JUnitOptions controls how validation results are mapped to JUnit test cases.
+type JUnitOptions = report.JUnitOptions
+
+// Watcher polls the root directory for diagram files changed outside the service.
+type Watcher = watch.Watcher
+
+// WatchOptions controls the scan interval, debounce time, diagram types and locations of a Watcher.
+type WatchOptions = watch.Options
+
+// WatchEvent describes one settled change to a diagram file.
+type WatchEvent = watch.Event
+
+// WatchEventType identifies what happened to a diagram file.
+type WatchEventType = watch.EventType
+
+// Watch event type constants.
+const (
+	// WatchEventCreated means a diagram file appeared.
+	WatchEventCreated = watch.EventCreated
+
+	// WatchEventModified means the size or modification time of a diagram file changed.
+	WatchEventModified = watch.EventModified
+
+	// WatchEventDeleted means a diagram file disappeared.
+	WatchEventDeleted = watch.EventDeleted
+
+	// WatchEventMoved means a diagram file moved to another location, name or version.
+	WatchEventMoved = watch.EventMoved
+)
+
 // Config represents the configuration for the state-machine diagram system.
 type Config = models.Config
 
@@ -138,6 +413,36 @@ type Config = models.Config
 // including CRUD operations, validation, promotion, and reference resolution.
 type DiagramService = models.DiagramService
 
+// Repository defines the storage a DiagramService reads and writes state-machine diagrams through.
+type Repository = models.Repository
+
+// MemoryRepository is a Repository that keeps state-machine diagrams in memory.
+//
+// It applies the same name, version and size rules as the file system repository
+// and returns the same error types. It also keeps metadata, backups and revision
+// history, and its whole content can be saved with Snapshot and put back with Restore.
+type MemoryRepository = repository.MemoryRepository
+
+// MemorySnapshot is a point-in-time copy of the content of a MemoryRepository.
+type MemorySnapshot = repository.MemorySnapshot
+
+// ArchiveRepository is a Repository stored in a single zip or tar archive file.
+//
+// The archive holds the same in-progress/ and products/ layout as the root directory,
+// including metadata sidecars. Every write rewrites the archive atomically, and a
+// read-only repository never changes the file.
+type ArchiveRepository = repository.ArchiveRepository
+
+// ArchiveOptions controls how an archive repository is opened.
+type ArchiveOptions = repository.ArchiveOptions
+
+// GitRepository is a file system Repository that commits every change of a
+// state-machine diagram to git through the git command line.
+type GitRepository = repository.GitRepository
+
+// GitOptions controls how a GitRepository records commits.
+type GitOptions = repository.GitOptions
+
 // NewService creates a new DiagramService with default configuration.
 //
 // This is the recommended way to create a service instance for most use cases.
@@ -154,7 +459,9 @@ type DiagramService = models.DiagramService
 func NewService() (DiagramService, error) {
 	config := models.DefaultConfig()
 	repo := repository.NewFileSystemRepository(config)
-	validator := validation.NewPlantUMLValidatorWithRepository(repo)
+	validator := validation.NewPlantUMLValidatorWithRepository(repo).
+		WithMultipleBlocks(config.AllowMultipleBlocks).
+		WithMaxIncludeDepth(config.MaxIncludeDepth)
 	return service.NewService(repo, validator, config), nil
 }
 
@@ -184,7 +491,9 @@ func NewServiceWithConfig(config *Config) (DiagramService, error) {
 		config = models.DefaultConfig()
 	}
 	repo := repository.NewFileSystemRepository(config)
-	validator := validation.NewPlantUMLValidatorWithRepository(repo)
+	validator := validation.NewPlantUMLValidatorWithRepository(repo).
+		WithMultipleBlocks(config.AllowMultipleBlocks).
+		WithMaxIncludeDepth(config.MaxIncludeDepth)
 	return service.NewService(repo, validator, config), nil
 }
 
@@ -197,8 +506,12 @@ func NewServiceWithConfig(config *Config) (DiagramService, error) {
 //   - GO_UML_ROOT_DIRECTORY: Root directory for state-machine diagrams
 //   - GO_UML_VALIDATION_LEVEL: Validation level ("in-progress" or "products")
 //   - GO_UML_BACKUP_ENABLED: Enable backups ("true" or "false")
+//   - GO_UML_BACKUP_RETENTION: Backups kept per diagram (0 keeps all)
 //   - GO_UML_MAX_FILE_SIZE: Maximum file size in bytes
 //   - GO_UML_DEBUG_LOGGING: Enable debug logging ("true" or "false")
+//   - GO_UML_ALLOW_MULTIPLE_BLOCKS: Allow several @startuml blocks per file ("true" or "false")
+//   - GO_UML_MAX_INCLUDE_DEPTH: Nesting limit when expanding !include directives
+//   - GO_UML_LOCK_TIMEOUT: How long to wait for a cross-process lock (e.g. "10s")
 //
 // Returns an error if the service cannot be initialized.
 //
@@ -215,7 +528,86 @@ func NewServiceWithConfig(config *Config) (DiagramService, error) {
 func NewServiceFromEnv() (DiagramService, error) {
 	config := models.LoadConfigFromEnv()
 	repo := repository.NewFileSystemRepository(config)
-	validator := validation.NewPlantUMLValidatorWithRepository(repo)
+	validator := validation.NewPlantUMLValidatorWithRepository(repo).
+		WithMultipleBlocks(config.AllowMultipleBlocks).
+		WithMaxIncludeDepth(config.MaxIncludeDepth)
+	return service.NewService(repo, validator, config), nil
+}
+
+// NewMemoryRepository creates an empty MemoryRepository.
+//
+// Parameters:
+//   - config: Configuration providing the size limit and root directory. If nil, default configuration is used.
+//
+// Example:
+//
+//	repo := diagram.NewMemoryRepository(nil)
+//	svc, err := diagram.NewServiceWithRepository(repo, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+func NewMemoryRepository(config *Config) *MemoryRepository {
+	return repository.NewMemoryRepository(config)
+}
+
+// NewArchiveRepository opens the archive file at archivePath as a Repository.
+//
+// The format follows the file extension: .zip, .tar, or .tar.gz/.tgz. A missing
+// archive is created by the first write unless options.ReadOnly is set, in which
+// case an error is returned.
+//
+// Example:
+//
+//	repo, err := diagram.NewArchiveRepository("site-diagrams.zip", diagram.ArchiveOptions{ReadOnly: true}, nil)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	svc, err := diagram.NewServiceWithRepository(repo, nil)
+func NewArchiveRepository(archivePath string, options ArchiveOptions, config *Config) (*ArchiveRepository, error) {
+	return repository.NewArchiveRepository(archivePath, options, config)
+}
+
+// NewGitRepository creates a GitRepository on the root directory of config.
+//
+// Each WriteDiagram, MoveDiagram, DeleteDiagram and metadata change becomes one commit.
+// Its message carries the operation, name, version, location and actor as git trailers.
+// A git repository is initialized in the root directory unless the root directory is
+// already the top level of one; set options.UseEnclosingRepository to commit to a
+// repository that merely contains it. Internal areas such as locks and backups are
+// listed in the root's .gitignore. Returns an error if the git executable cannot be found.
+//
+// Example:
+//
+//	repo, err := diagram.NewGitRepository(nil, diagram.GitOptions{Actor: "release-bot"})
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	svc, err := diagram.NewServiceWithRepository(repo, nil)
+func NewGitRepository(config *Config, options GitOptions) (*GitRepository, error) {
+	return repository.NewGitRepository(config, options)
+}
+
+// NewServiceWithRepository creates a new DiagramService storing state-machine diagrams in repo.
+//
+// Use this function with a MemoryRepository in tests and short-lived tools, or with
+// a custom Repository implementation. Optional capabilities such as backups and
+// revision history are available when repo implements them.
+//
+// Parameters:
+//   - repo: Storage for state-machine diagrams. It cannot be nil.
+//   - config: Configuration settings for the service. If nil, default configuration is used.
+//
+// Returns an error if repo is nil.
+func NewServiceWithRepository(repo Repository, config *Config) (DiagramService, error) {
+	if repo == nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration, "repository cannot be nil", nil)
+	}
+	if config == nil {
+		config = models.DefaultConfig()
+	}
+	validator := validation.NewPlantUMLValidatorWithRepository(repo).
+		WithMultipleBlocks(config.AllowMultipleBlocks).
+		WithMaxIncludeDepth(config.MaxIncludeDepth)
 	return service.NewService(repo, validator, config), nil
 }
 
@@ -225,6 +617,7 @@ func NewServiceFromEnv() (DiagramService, error) {
 //   - RootDirectory: ".go-uml-statemachine-parsers"
 //   - ValidationLevel: StrictnessInProgress
 //   - BackupEnabled: false
+//   - BackupRetention: 10
 //   - MaxFileSize: 1MB
 //   - EnableDebugLogging: false
 //
@@ -251,3 +644,81 @@ func DefaultConfig() *Config {
 func LoadConfigFromEnv() *Config {
 	return models.LoadConfigFromEnv()
 }
+
+// ContentToken returns the concurrency token of state-machine diagram content.
+//
+// It is the token ReadFile stores in StateMachineDiagram.Token and the value
+// compared against the ExpectedToken of UpdateOptions, DeleteOptions and PromoteOptions.
+func ContentToken(content string) string {
+	return models.ContentToken(content)
+}
+
+// ContentDigest returns the hex-encoded SHA-256 digest of state-machine diagram content.
+//
+// It is the digest recorded in ProductChecksum when a diagram is promoted to products.
+func ContentDigest(content string) string {
+	return models.ContentDigest(content)
+}
+
+// WriteSARIF writes validation results to w as a SARIF 2.1.0 log.
+//
+// Artifact URIs are derived from config.RootDirectory so they match the files on disk.
+// Each result carries a rule id, a region from the finding's span, a partial
+// fingerprint that survives unrelated line shifts, and any attached fixes.
+//
+// Example:
+//
+//	result, _ := svc.ValidateFile(models.DiagramTypePUML, "user-auth", "1.0.0", diagram.LocationFileInProgress)
+//	diag, _ := svc.Read(models.DiagramTypePUML, "user-auth", "1.0.0", diagram.LocationFileInProgress)
+//	err := diagram.WriteSARIF(os.Stdout, config, []diagram.DiagramResult{{Diagram: diag, Result: result}})
+func WriteSARIF(w io.Writer, config *Config, results []DiagramResult) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return report.WriteSARIF(w, models.NewPathManager(config.RootDirectory), results)
+}
+
+// WriteJUnit writes validation results to w as JUnit XML.
+//
+// Each state-machine diagram becomes one test case and every error becomes a failure.
+// Warnings are listed in system-out unless opts.WarningsAsFailures is set.
+//
+// Example:
+//
+//	err := diagram.WriteJUnit(f, config, results, diagram.JUnitOptions{WarningsAsFailures: true})
+func WriteJUnit(w io.Writer, config *Config, results []DiagramResult, opts JUnitOptions) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return report.WriteJUnit(w, models.NewPathManager(config.RootDirectory), results, opts)
+}
+
+// WriteCheckstyle writes validation results to w as Checkstyle XML.
+//
+// Each state-machine diagram becomes one file element with an error element per finding.
+//
+// Example:
+//
+//	err := diagram.WriteCheckstyle(f, config, results)
+func WriteCheckstyle(w io.Writer, config *Config, results []DiagramResult) error {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	return report.WriteCheckstyle(w, models.NewPathManager(config.RootDirectory), results)
+}
+
+// NewWatcher creates a polling watcher for diagram files under config.RootDirectory.
+//
+// Files edited directly in the tree are reported as created, modified, deleted or
+// moved once they have stayed unchanged for the debounce time. Events are delivered
+// to a callback by Run or on the channel returned by Events.
+//
+// Example:
+//
+//	w := diagram.NewWatcher(config, diagram.WatchOptions{Interval: time.Second})
+//	for event := range w.Events(ctx) {
+//	    fmt.Println(event.Type, event.Name, event.Version, event.Location)
+//	}
+func NewWatcher(config *Config, options WatchOptions) *Watcher {
+	return watch.NewWatcher(config, options)
+}
@@ -54,6 +54,42 @@ func TestNewServiceWithConfig(t *testing.T) {
 	}
 }
 
+func TestNewServiceWithRepository(t *testing.T) {
+	if _, err := NewServiceWithRepository(nil, nil); err == nil {
+		t.Error("NewServiceWithRepository(nil) succeeded, want an error")
+	}
+
+	// Nothing in this test touches the file system
+	repo := NewMemoryRepository(nil)
+	svc, err := NewServiceWithRepository(repo, nil)
+	if err != nil {
+		t.Fatalf("NewServiceWithRepository() failed: %v", err)
+	}
+
+	content := "@startuml\n[*] --> Idle\nIdle --> Active : start()\n@enduml"
+	if _, err := svc.CreateFile(models.DiagramTypePUML, "memory", "1.0.0", content, LocationFileInProgress); err != nil {
+		t.Fatalf("CreateFile() failed: %v", err)
+	}
+	snapshot := repo.Snapshot()
+
+	if err := svc.PromoteToProductsFile(models.DiagramTypePUML, "memory", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() failed: %v", err)
+	}
+	if _, err := svc.ReadFile(models.DiagramTypePUML, "memory", "1.0.0", LocationFileProducts); err != nil {
+		t.Errorf("ReadFile() after promotion failed: %v", err)
+	}
+
+	if err := repo.Restore(snapshot); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if _, err := svc.ReadFile(models.DiagramTypePUML, "memory", "1.0.0", LocationFileInProgress); err != nil {
+		t.Errorf("ReadFile() after restore failed: %v", err)
+	}
+	if revisions, err := svc.ListRevisions(models.DiagramTypePUML, "memory", "1.0.0"); err != nil || len(revisions) != 1 {
+		t.Errorf("ListRevisions() = %v, %v, want the revision recorded on create", revisions, err)
+	}
+}
+
 func TestNewServiceFromEnv(t *testing.T) {
 	// Save original environment
 	originalRootDir := os.Getenv("GO_UML_ROOT_DIRECTORY")
@@ -266,6 +302,10 @@ func TestLoadConfigFromEnv(t *testing.T) {
 }
 
 func TestPublicAPIIntegration(t *testing.T) {
+	// The default root directory is relative, so keep the files this test
+	// writes, including revision history, out of the source tree
+	t.Chdir(t.TempDir())
+
 	// Test the complete workflow using the public API
 	svc, err := NewService()
 	if err != nil {
@@ -388,6 +428,10 @@ Error --> Idle : reset()
 }
 
 func TestPublicAPIErrorHandling(t *testing.T) {
+	// The default root directory is relative, so keep anything the failing
+	// calls create out of the source tree
+	t.Chdir(t.TempDir())
+
 	svc, err := NewService()
 	if err != nil {
 		t.Fatalf("NewService() failed: %v", err)
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Reasons recorded with backups
+const (
+	BackupReasonUpdate  = "update"
+	BackupReasonDelete  = "delete"
+	BackupReasonPromote = "promote"
+	BackupReasonRestore = "restore"
+)
+
+// Backup describes a snapshot of a state-machine diagram taken before it was
+// overwritten, deleted or moved
+type Backup struct {
+	ID        string // sortable timestamp identifier, unique per diagram
+	Name      string
+	Version   string
+	Location  Location // where the diagram was when the snapshot was taken
+	Reason    string   // one of the BackupReason constants
+	CreatedAt time.Time
+	Size      int64 // content size in bytes
+
+	// Checksum is the checksum a products diagram carried when the snapshot was
+	// taken; nil for in-progress snapshots and products without one
+	Checksum *ProductChecksum
+}
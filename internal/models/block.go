@@ -0,0 +1,101 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Block is a single @startuml ... @enduml diagram within a file. Files normally
+// hold one block; multi-block files are supported when Config.AllowMultipleBlocks is set.
+type Block struct {
+	ID        string // the @startuml argument, or the 1-based position when unnamed
+	Index     int    // 0-based position of the block in the file
+	StartLine int    // line of the @startuml tag
+	EndLine   int    // line of the @enduml tag, or the last line of the block when unterminated
+	Closed    bool   // whether the block ends with its own @enduml tag
+	Content   string // lines StartLine through EndLine
+}
+
+// SplitBlocks splits content into its @startuml blocks. A block runs from a
+// @startuml tag to the next @enduml tag; a @startuml tag inside an open block
+// ends that block on the previous line. Lines outside blocks are ignored.
+func SplitBlocks(content string) []Block {
+	lines := strings.Split(content, "\n")
+
+	var blocks []Block
+	open := -1 // index into blocks of the open block
+
+	closeBlock := func(endLine int, closed bool) {
+		block := &blocks[open]
+		block.EndLine = endLine
+		block.Closed = closed
+		block.Content = strings.Join(lines[block.StartLine-1:endLine], "\n")
+		open = -1
+	}
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(trimmed, "@startuml"):
+			if open != -1 {
+				closeBlock(i, false)
+			}
+			index := len(blocks)
+			id := BlockName(trimmed)
+			if id == "" {
+				id = strconv.Itoa(index + 1)
+			}
+			blocks = append(blocks, Block{ID: id, Index: index, StartLine: i + 1})
+			open = index
+
+		case strings.HasPrefix(trimmed, "@enduml"):
+			if open != -1 {
+				closeBlock(i+1, true)
+			}
+		}
+	}
+
+	if open != -1 {
+		closeBlock(len(lines), false)
+	}
+
+	return blocks
+}
+
+// BlockName returns the diagram name given on a @startuml line, either as
+// "@startuml name" or "@startuml(id=name)". It returns "" for unnamed blocks.
+func BlockName(startLine string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(startLine), "@startuml"))
+
+	if strings.HasPrefix(rest, "(") {
+		end := strings.Index(rest, ")")
+		if end == -1 {
+			return ""
+		}
+		for _, attr := range strings.Split(rest[1:end], ",") {
+			key, value, ok := strings.Cut(attr, "=")
+			if ok && strings.TrimSpace(key) == "id" {
+				return strings.Trim(strings.TrimSpace(value), `"`)
+			}
+		}
+		return ""
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.Trim(fields[0], `"`)
+}
+
+// FindBlock returns the block with the given id
+func FindBlock(content, id string) (*Block, error) {
+	for _, block := range SplitBlocks(content) {
+		if block.ID == id {
+			return &block, nil
+		}
+	}
+	return nil, NewStateMachineError(ErrorTypeFileNotFound, "block not found", nil).
+		WithContext("block", id)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestSplitBlocks(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Block
+	}{
+		{
+			name:    "single unnamed block",
+			content: "@startuml\n[*] --> Idle\n@enduml",
+			want: []Block{
+				{ID: "1", Index: 0, StartLine: 1, EndLine: 3, Closed: true, Content: "@startuml\n[*] --> Idle\n@enduml"},
+			},
+		},
+		{
+			name:    "named blocks with text between",
+			content: "@startuml first\n[*] --> A\n@enduml\n\n' notes\n@startuml(id=second)\n[*] --> B\n@enduml",
+			want: []Block{
+				{ID: "first", Index: 0, StartLine: 1, EndLine: 3, Closed: true, Content: "@startuml first\n[*] --> A\n@enduml"},
+				{ID: "second", Index: 1, StartLine: 6, EndLine: 8, Closed: true, Content: "@startuml(id=second)\n[*] --> B\n@enduml"},
+			},
+		},
+		{
+			name:    "start inside open block ends it",
+			content: "@startuml a\n[*] --> A\n@startuml b\n[*] --> B\n@enduml",
+			want: []Block{
+				{ID: "a", Index: 0, StartLine: 1, EndLine: 2, Closed: false, Content: "@startuml a\n[*] --> A"},
+				{ID: "b", Index: 1, StartLine: 3, EndLine: 5, Closed: true, Content: "@startuml b\n[*] --> B\n@enduml"},
+			},
+		},
+		{
+			name:    "unterminated last block",
+			content: "@startuml\n[*] --> A",
+			want: []Block{
+				{ID: "1", Index: 0, StartLine: 1, EndLine: 2, Closed: false, Content: "@startuml\n[*] --> A"},
+			},
+		},
+		{
+			name:    "no blocks",
+			content: "[*] --> A\n@enduml",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitBlocks(tt.content)
+			if len(got) != len(tt.want) {
+				t.Fatalf("SplitBlocks() returned %d blocks, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("SplitBlocks()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBlockName(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"@startuml", ""},
+		{"  @startuml login  ", "login"},
+		{`@startuml "quoted"`, "quoted"},
+		{"@startuml(id=checkout)", "checkout"},
+		{"@startuml(title=x, id = payment)", "payment"},
+		{"@startuml(title=x)", ""},
+		{"@startuml(id=broken", ""},
+	}
+
+	for _, tt := range tests {
+		if got := BlockName(tt.line); got != tt.want {
+			t.Errorf("BlockName(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestFindBlock(t *testing.T) {
+	content := "@startuml a\n[*] --> A\n@enduml\n@startuml\n[*] --> B\n@enduml"
+
+	block, err := FindBlock(content, "2")
+	if err != nil {
+		t.Fatalf("FindBlock() error = %v", err)
+	}
+	if block.StartLine != 4 || block.Index != 1 {
+		t.Errorf("FindBlock() = %+v", block)
+	}
+
+	_, err = FindBlock(content, "missing")
+	if err == nil || GetErrorType(err) != ErrorTypeFileNotFound {
+		t.Errorf("FindBlock() error = %v, want ErrorTypeFileNotFound", err)
+	}
+}
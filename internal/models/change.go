@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// ChangeOperation is the repository operation a Change records
+type ChangeOperation string
+
+const (
+	ChangeWrite    ChangeOperation = "write"    // content written
+	ChangeMove     ChangeOperation = "move"     // moved between locations
+	ChangeDelete   ChangeOperation = "delete"   // deleted from a location
+	ChangeMetadata ChangeOperation = "metadata" // metadata replaced
+)
+
+// Change describes one change of a state-machine diagram recorded by a
+// versioned repository, such as a git commit
+type Change struct {
+	ID           string // identifier in the underlying version control, e.g. a commit hash
+	Operation    ChangeOperation
+	Name         string
+	Version      string
+	Location     Location // location after the change
+	FromLocation Location // location before a move; equal to Location otherwise
+	Actor        string
+	Message      string
+	CommittedAt  time.Time
+}
@@ -0,0 +1,57 @@
+package models
+
+// FindingType classifies an inconsistency found by an integrity check
+type FindingType string
+
+const (
+	FindingInvalidName      FindingType = "invalid-name"       // file name does not follow {name}-{version}.puml
+	FindingOversizedFile    FindingType = "oversized-file"     // diagram larger than Config.MaxFileSize
+	FindingOrphanedMetadata FindingType = "orphaned-metadata"  // metadata sidecar without its diagram
+	FindingCorruptMetadata  FindingType = "corrupt-metadata"   // metadata sidecar that cannot be parsed
+	FindingDuplicateVersion FindingType = "duplicate-version"  // same name and version in in-progress and products
+	FindingIncompleteRecord FindingType = "incomplete-record"  // backup or revision missing its content or its record
+	FindingLeftoverTempFile FindingType = "leftover-temp-file" // temp file of an interrupted write or lock break
+	FindingStaleLock        FindingType = "stale-lock"         // diagram lock file that no process holds
+)
+
+// RepairMode selects what an integrity check does about its findings
+type RepairMode int
+
+const (
+	RepairNone       RepairMode = iota // only report the findings
+	RepairQuarantine                   // move every offending file to the quarantine directory
+	RepairFix                          // remove redundant files and quarantine the rest
+)
+
+// RepairAction is what an integrity check did about a finding
+type RepairAction string
+
+const (
+	RepairActionNone        RepairAction = ""
+	RepairActionRemoved     RepairAction = "removed"
+	RepairActionQuarantined RepairAction = "quarantined"
+)
+
+// CheckOptions controls an integrity check
+type CheckOptions struct {
+	Repair RepairMode
+}
+
+// Finding is one inconsistency found by an integrity check
+type Finding struct {
+	Type        FindingType
+	Path        string // offending file
+	Message     string
+	Name        string // diagram name, when the file belongs to one
+	Version     string // diagram version, when the file belongs to one
+	Action      RepairAction
+	RepairError string // why the repair failed, if it did
+}
+
+// CheckReport is the result of an integrity check. Findings are in scan order;
+// QuarantineDir is set when any file was quarantined.
+type CheckReport struct {
+	Findings      []Finding
+	FilesChecked  int
+	QuarantineDir string
+}
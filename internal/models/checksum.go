@@ -0,0 +1,68 @@
+package models
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+)
+
+// ProductChecksum is the tamper-evidence record of a products diagram, taken
+// when the diagram entered products
+type ProductChecksum struct {
+	Digest    string // hex-encoded SHA-256 of the content
+	KeyID     string // key the signature was made with; empty when unsigned
+	Signature []byte // ed25519 signature of SignedMessage
+	CreatedAt time.Time
+}
+
+// ContentDigest returns the hex-encoded SHA-256 digest of content
+func ContentDigest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// SignedMessage returns the bytes a products signature covers. The diagram
+// type, name and version are included so a signed diagram cannot be passed
+// off as another one.
+func (c ProductChecksum) SignedMessage(diagramType smmodels.DiagramType, name, version string) []byte {
+	return []byte(diagramType.String() + "\n" + name + "\n" + version + "\n" + c.Digest)
+}
+
+// KeyProvider supplies the ed25519 keys that sign products diagrams on
+// promotion and verify them when they are read
+type KeyProvider interface {
+	SigningKey() (keyID string, key ed25519.PrivateKey, err error)
+	PublicKey(keyID string) (ed25519.PublicKey, error)
+}
+
+// StaticKeyProvider is a KeyProvider holding its keys in memory. It signs with
+// PrivateKey under KeyID and verifies with PublicKeys or, for KeyID, with the
+// public half of PrivateKey. A provider without a PrivateKey only verifies.
+type StaticKeyProvider struct {
+	KeyID      string
+	PrivateKey ed25519.PrivateKey
+	PublicKeys map[string]ed25519.PublicKey
+}
+
+// SigningKey returns the private key and its ID
+func (p *StaticKeyProvider) SigningKey() (string, ed25519.PrivateKey, error) {
+	if len(p.PrivateKey) != ed25519.PrivateKeySize {
+		return "", nil, NewStateMachineError(ErrorTypeConfiguration, "key provider has no signing key", nil)
+	}
+	return p.KeyID, p.PrivateKey, nil
+}
+
+// PublicKey returns the public key registered under keyID
+func (p *StaticKeyProvider) PublicKey(keyID string) (ed25519.PublicKey, error) {
+	if key, ok := p.PublicKeys[keyID]; ok {
+		return key, nil
+	}
+	if keyID == p.KeyID && len(p.PrivateKey) == ed25519.PrivateKeySize {
+		return p.PrivateKey.Public().(ed25519.PublicKey), nil
+	}
+	return nil, NewStateMachineError(ErrorTypeConfiguration, "unknown signing key", nil).
+		WithContext("keyID", keyID)
+}
@@ -4,25 +4,35 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config represents the configuration for the state-machine diagram system
 type Config struct {
-	RootDirectory      string               // Default: ".go-uml-statemachine-parsers"
-	ValidationLevel    ValidationStrictness // Default validation level
-	BackupEnabled      bool                 // Whether to create backups
-	MaxFileSize        int64                // Maximum file size in bytes
-	EnableDebugLogging bool                 // Whether to enable debug logging
+	RootDirectory       string               // Default: ".go-uml-statemachine-parsers"
+	ValidationLevel     ValidationStrictness // Default validation level
+	BackupEnabled       bool                 // Whether to create backups
+	BackupRetention     int                  // Backups kept per diagram; 0 keeps all
+	MaxFileSize         int64                // Maximum file size in bytes
+	EnableDebugLogging  bool                 // Whether to enable debug logging
+	AllowMultipleBlocks bool                 // Whether files may hold several @startuml blocks
+	MaxIncludeDepth     int                  // Nesting limit when expanding !include directives
+	LockTimeout         time.Duration        // How long to wait for a cross-process lock; 0 tries once
+	KeyProvider         KeyProvider          // Signs products on promotion and verifies them; nil records digests only
 }
 
 // DefaultConfig returns a configuration with default values
 func DefaultConfig() *Config {
 	return &Config{
-		RootDirectory:      ".go-uml-statemachine-parsers",
-		ValidationLevel:    StrictnessInProgress,
-		BackupEnabled:      false,
-		MaxFileSize:        1024 * 1024, // 1MB
-		EnableDebugLogging: false,
+		RootDirectory:       ".go-uml-statemachine-parsers",
+		ValidationLevel:     StrictnessInProgress,
+		BackupEnabled:       false,
+		BackupRetention:     10,
+		MaxFileSize:         1024 * 1024, // 1MB
+		EnableDebugLogging:  false,
+		AllowMultipleBlocks: false,
+		MaxIncludeDepth:     10,
+		LockTimeout:         30 * time.Second,
 	}
 }
 
@@ -31,8 +41,12 @@ func DefaultConfig() *Config {
 // - GO_UML_ROOT_DIRECTORY: Root directory for state-machine diagrams
 // - GO_UML_VALIDATION_LEVEL: Validation level (in-progress, products)
 // - GO_UML_BACKUP_ENABLED: Whether to enable backups (true/false)
+// - GO_UML_BACKUP_RETENTION: Backups kept per diagram (0 keeps all)
 // - GO_UML_MAX_FILE_SIZE: Maximum file size in bytes
 // - GO_UML_DEBUG_LOGGING: Whether to enable debug logging (true/false)
+// - GO_UML_ALLOW_MULTIPLE_BLOCKS: Whether files may hold several @startuml blocks (true/false)
+// - GO_UML_MAX_INCLUDE_DEPTH: Nesting limit when expanding !include directives
+// - GO_UML_LOCK_TIMEOUT: How long to wait for a cross-process lock (e.g. 10s)
 func LoadConfigFromEnv() *Config {
 	config := DefaultConfig()
 
@@ -58,6 +72,13 @@ func LoadConfigFromEnv() *Config {
 		}
 	}
 
+	// Load backup retention
+	if backupRetention := os.Getenv("GO_UML_BACKUP_RETENTION"); backupRetention != "" {
+		if retention, err := strconv.Atoi(backupRetention); err == nil && retention >= 0 {
+			config.BackupRetention = retention
+		}
+	}
+
 	// Load max file size
 	if maxFileSize := os.Getenv("GO_UML_MAX_FILE_SIZE"); maxFileSize != "" {
 		if size, err := strconv.ParseInt(maxFileSize, 10, 64); err == nil && size > 0 {
@@ -72,6 +93,27 @@ func LoadConfigFromEnv() *Config {
 		}
 	}
 
+	// Load multiple blocks support
+	if multipleBlocks := os.Getenv("GO_UML_ALLOW_MULTIPLE_BLOCKS"); multipleBlocks != "" {
+		if enabled, err := strconv.ParseBool(multipleBlocks); err == nil {
+			config.AllowMultipleBlocks = enabled
+		}
+	}
+
+	// Load max include depth
+	if maxIncludeDepth := os.Getenv("GO_UML_MAX_INCLUDE_DEPTH"); maxIncludeDepth != "" {
+		if depth, err := strconv.Atoi(maxIncludeDepth); err == nil && depth > 0 {
+			config.MaxIncludeDepth = depth
+		}
+	}
+
+	// Load lock timeout
+	if lockTimeout := os.Getenv("GO_UML_LOCK_TIMEOUT"); lockTimeout != "" {
+		if timeout, err := time.ParseDuration(lockTimeout); err == nil && timeout >= 0 {
+			config.LockTimeout = timeout
+		}
+	}
+
 	return config
 }
 
@@ -90,12 +132,24 @@ func (c *Config) MergeWithEnv() *Config {
 	if os.Getenv("GO_UML_BACKUP_ENABLED") != "" {
 		c.BackupEnabled = envConfig.BackupEnabled
 	}
+	if os.Getenv("GO_UML_BACKUP_RETENTION") != "" {
+		c.BackupRetention = envConfig.BackupRetention
+	}
 	if os.Getenv("GO_UML_MAX_FILE_SIZE") != "" {
 		c.MaxFileSize = envConfig.MaxFileSize
 	}
 	if os.Getenv("GO_UML_DEBUG_LOGGING") != "" {
 		c.EnableDebugLogging = envConfig.EnableDebugLogging
 	}
+	if os.Getenv("GO_UML_ALLOW_MULTIPLE_BLOCKS") != "" {
+		c.AllowMultipleBlocks = envConfig.AllowMultipleBlocks
+	}
+	if os.Getenv("GO_UML_MAX_INCLUDE_DEPTH") != "" {
+		c.MaxIncludeDepth = envConfig.MaxIncludeDepth
+	}
+	if os.Getenv("GO_UML_LOCK_TIMEOUT") != "" {
+		c.LockTimeout = envConfig.LockTimeout
+	}
 
 	return c
 }
@@ -3,6 +3,7 @@ package models
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -347,3 +348,51 @@ func TestMergeWithEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfigFromEnv_BackupRetention(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected int
+	}{
+		{name: "default", env: "", expected: 10},
+		{name: "custom", env: "3", expected: 3},
+		{name: "keep all", env: "0", expected: 0},
+		{name: "negative ignored", env: "-1", expected: 10},
+		{name: "invalid ignored", env: "many", expected: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GO_UML_BACKUP_RETENTION", tt.env)
+
+			if got := LoadConfigFromEnv().BackupRetention; got != tt.expected {
+				t.Errorf("BackupRetention = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnv_LockTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      string
+		expected time.Duration
+	}{
+		{name: "default", env: "", expected: 30 * time.Second},
+		{name: "custom", env: "5s", expected: 5 * time.Second},
+		{name: "try once", env: "0", expected: 0},
+		{name: "negative ignored", env: "-1s", expected: 30 * time.Second},
+		{name: "invalid ignored", env: "soon", expected: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GO_UML_LOCK_TIMEOUT", tt.env)
+
+			if got := LoadConfigFromEnv().LockTimeout; got != tt.expected {
+				t.Errorf("LockTimeout = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
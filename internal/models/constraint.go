@@ -0,0 +1,234 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// partialVersionRegex matches versions with omitted or wildcard components such as "1", "1.2", "1.x" or "1.2.*"
+var partialVersionRegex = regexp.MustCompile(`^(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?$`)
+
+// comparator is a single "op version" condition of a version constraint
+type comparator struct {
+	op      string // one of "=", ">", ">=", "<", "<="
+	version Version
+}
+
+// matches reports whether v satisfies the comparator
+func (c comparator) matches(v Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp == 0
+	}
+}
+
+// VersionConstraint is a semantic version range such as "^1.2", "~1.2.3",
+// "1.x" or ">=1.2.0,<2.0.0". Comma-separated terms must all hold.
+type VersionConstraint struct {
+	raw         string
+	comparators []comparator
+}
+
+// String returns the constraint as written
+func (c VersionConstraint) String() string {
+	return c.raw
+}
+
+// IsVersionConstraint reports whether s is written as a version range rather than
+// an exact version. It does not check that the range is well formed.
+func IsVersionConstraint(s string) bool {
+	if _, err := ParseVersion(s); err == nil {
+		return false
+	}
+	return strings.ContainsAny(s, "^~*<>=,") || partialVersionRegex.MatchString(s)
+}
+
+// ParseConstraint parses a version constraint. Supported terms are caret ranges
+// ("^1.2.3"), tilde ranges ("~1.2"), wildcards ("1.2.x", "1.*", "*"), partial
+// versions ("1.2"), comparisons (">=1.2.0", "<2.0.0") and exact versions.
+func ParseConstraint(s string) (VersionConstraint, error) {
+	constraint := VersionConstraint{raw: s}
+	if strings.TrimSpace(s) == "" {
+		return VersionConstraint{}, fmt.Errorf("empty version constraint")
+	}
+
+	for _, term := range strings.Split(s, ",") {
+		comparators, err := parseConstraintTerm(strings.TrimSpace(term))
+		if err != nil {
+			return VersionConstraint{}, fmt.Errorf("invalid version constraint %q: %w", s, err)
+		}
+		constraint.comparators = append(constraint.comparators, comparators...)
+	}
+
+	return constraint, nil
+}
+
+// parseConstraintTerm expands a single constraint term into comparators
+func parseConstraintTerm(term string) ([]comparator, error) {
+	switch {
+	case term == "":
+		return nil, fmt.Errorf("empty term")
+	case strings.HasPrefix(term, "^"):
+		return caretRange(term[1:])
+	case strings.HasPrefix(term, "~"):
+		return tildeRange(term[1:])
+	}
+
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(term, op) {
+			v, err := ParseVersion(term[len(op):])
+			if err != nil {
+				return nil, err
+			}
+			return []comparator{{op: op, version: v}}, nil
+		}
+	}
+
+	if v, err := ParseVersion(term); err == nil {
+		return []comparator{{op: "=", version: v}}, nil
+	}
+	// Partial and wildcard versions cover the same versions as the tilde range
+	return tildeRange(term)
+}
+
+// partialVersion holds the numeric components of a partial version and how many were given
+type partialVersion struct {
+	major, minor, patch int
+	pre                 string
+	parts               int
+}
+
+// parsePartial parses a full or partial version, stopping at the first wildcard component
+func parsePartial(s string) (partialVersion, error) {
+	if v, err := ParseVersion(s); err == nil {
+		return partialVersion{major: v.Major, minor: v.Minor, patch: v.Patch, pre: v.Pre, parts: 3}, nil
+	}
+
+	matches := partialVersionRegex.FindStringSubmatch(s)
+	if matches == nil {
+		return partialVersion{}, fmt.Errorf("invalid version: %s", s)
+	}
+
+	var p partialVersion
+	components := []*int{&p.major, &p.minor, &p.patch}
+	for i, component := range matches[1:] {
+		if component == "" || strings.ContainsAny(component, "xX*") {
+			break
+		}
+		n, err := strconv.Atoi(component)
+		if err != nil {
+			return partialVersion{}, fmt.Errorf("invalid version component: %s", component)
+		}
+		*components[i] = n
+		p.parts++
+	}
+	return p, nil
+}
+
+// lower returns the smallest version matched by the partial version
+func (p partialVersion) lower() Version {
+	return Version{Major: p.major, Minor: p.minor, Patch: p.patch, Pre: p.pre}
+}
+
+// rangeFrom returns comparators for lower <= v < upper, or lower <= v when upper is nil
+func rangeFrom(lower Version, upper *Version) []comparator {
+	comparators := []comparator{{op: ">=", version: lower}}
+	if upper != nil {
+		comparators = append(comparators, comparator{op: "<", version: *upper})
+	}
+	return comparators
+}
+
+// caretRange allows changes that do not modify the left-most non-zero component
+func caretRange(s string) ([]comparator, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var upper Version
+	switch {
+	case p.parts == 0:
+		return rangeFrom(Version{}, nil), nil
+	case p.major > 0 || p.parts == 1:
+		upper = Version{Major: p.major + 1}
+	case p.minor > 0 || p.parts == 2:
+		upper = Version{Minor: p.minor + 1}
+	default:
+		upper = Version{Patch: p.patch + 1}
+	}
+	return rangeFrom(p.lower(), &upper), nil
+}
+
+// tildeRange allows patch-level changes when a minor version is given, minor-level changes otherwise
+func tildeRange(s string) ([]comparator, error) {
+	p, err := parsePartial(s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.parts {
+	case 0:
+		return rangeFrom(Version{}, nil), nil
+	case 1:
+		return rangeFrom(p.lower(), &Version{Major: p.major + 1}), nil
+	default:
+		return rangeFrom(p.lower(), &Version{Major: p.major, Minor: p.minor + 1}), nil
+	}
+}
+
+// Matches reports whether v satisfies every term of the constraint. Pre-release
+// versions only match when a term names a pre-release of the same major.minor.patch.
+func (c VersionConstraint) Matches(v Version) bool {
+	if len(c.comparators) == 0 {
+		return false
+	}
+
+	for _, cmp := range c.comparators {
+		if !cmp.matches(v) {
+			return false
+		}
+	}
+
+	if v.Pre == "" {
+		return true
+	}
+	for _, cmp := range c.comparators {
+		bound := cmp.version
+		if bound.Pre != "" && bound.Major == v.Major && bound.Minor == v.Minor && bound.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// Latest returns the highest of versions that satisfies the constraint.
+// Strings that are not valid versions are ignored.
+func (c VersionConstraint) Latest(versions []string) (string, bool) {
+	var best Version
+	var bestString string
+	found := false
+
+	for _, s := range versions {
+		v, err := ParseVersion(s)
+		if err != nil || !c.Matches(v) {
+			continue
+		}
+		if !found || v.Compare(best) > 0 {
+			best, bestString, found = v, s, true
+		}
+	}
+
+	return bestString, found
+}
@@ -0,0 +1,95 @@
+package models
+
+import "testing"
+
+func TestIsVersionConstraint(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"1.2.3", false},
+		{"1.2.3-beta", false},
+		{"invalid.version", false},
+		{"^1.2", true},
+		{"~1.2.3", true},
+		{"1.x", true},
+		{"1.2", true},
+		{"*", true},
+		{">=1.0.0,<2.0.0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := IsVersionConstraint(tt.input); got != tt.want {
+				t.Errorf("IsVersionConstraint(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionConstraint_Matches(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"^1.2.3", []string{"1.2.3", "1.9.0"}, []string{"1.2.2", "2.0.0", "1.3.0-beta"}},
+		{"^1.2", []string{"1.2.0", "1.99.99"}, []string{"1.1.9", "2.0.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.2.3", []string{"1.2.3", "1.2.9"}, []string{"1.3.0", "1.2.2"}},
+		{"~1", []string{"1.0.0", "1.9.9"}, []string{"2.0.0"}},
+		{"1.2.x", []string{"1.2.0", "1.2.7"}, []string{"1.3.0"}},
+		{"1.2", []string{"1.2.5"}, []string{"1.3.0"}},
+		{"*", []string{"0.0.1", "9.9.9"}, []string{"1.0.0-rc.1"}},
+		{">=1.0.0,<1.5.0", []string{"1.0.0", "1.4.9"}, []string{"1.5.0", "0.9.0"}},
+		{">1.0.0-alpha", []string{"1.0.0-beta", "1.0.0"}, []string{"1.0.0-alpha", "1.1.0-beta"}},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if err != nil {
+				t.Fatalf("ParseConstraint(%q) error = %v", tt.constraint, err)
+			}
+			for _, s := range tt.matches {
+				v, _ := ParseVersion(s)
+				if !c.Matches(v) {
+					t.Errorf("%q should match %s", tt.constraint, s)
+				}
+			}
+			for _, s := range tt.rejects {
+				v, _ := ParseVersion(s)
+				if c.Matches(v) {
+					t.Errorf("%q should not match %s", tt.constraint, s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseConstraint_Invalid(t *testing.T) {
+	for _, input := range []string{"", "^", "^a.b", ">=1.2", "~1.2.3.4", "1.0.0,"} {
+		if _, err := ParseConstraint(input); err == nil {
+			t.Errorf("ParseConstraint(%q) should fail", input)
+		}
+	}
+}
+
+func TestVersionConstraint_Latest(t *testing.T) {
+	c, err := ParseConstraint("^1.2")
+	if err != nil {
+		t.Fatalf("ParseConstraint() error = %v", err)
+	}
+
+	versions := []string{"1.1.0", "1.2.0", "1.10.0", "1.9.3", "2.0.0", "1.11.0-beta", "not-a-version"}
+	got, ok := c.Latest(versions)
+	if !ok || got != "1.10.0" {
+		t.Errorf("Latest() = %q, %v, want 1.10.0", got, ok)
+	}
+
+	if _, ok := c.Latest([]string{"2.0.0", "1.0.0"}); ok {
+		t.Error("Latest() should report no match")
+	}
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DiffOp is the kind of change a diff line represents
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffInsert
+	DiffDelete
+)
+
+// String returns the string representation of DiffOp
+func (op DiffOp) String() string {
+	switch op {
+	case DiffEqual:
+		return "equal"
+	case DiffInsert:
+		return "insert"
+	case DiffDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
+
+// DiffLine is one line of a line-based diff
+type DiffLine struct {
+	Op      DiffOp
+	Text    string
+	OldLine int // 1-based line in the old content, 0 for inserted lines
+	NewLine int // 1-based line in the new content, 0 for deleted lines
+}
+
+// Diff is the line-based difference between two versions of some content
+type Diff struct {
+	FromLabel string
+	ToLabel   string
+	Lines     []DiffLine
+}
+
+// DiffContent computes the shortest line diff from old to new content
+func DiffContent(fromLabel, toLabel, oldContent, newContent string) *Diff {
+	return &Diff{
+		FromLabel: fromLabel,
+		ToLabel:   toLabel,
+		Lines:     diffLines(splitContentLines(oldContent), splitContentLines(newContent)),
+	}
+}
+
+// splitContentLines splits content into lines without line terminators
+func splitContentLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
+
+// HasChanges reports whether the diff contains any inserted or deleted lines
+func (d *Diff) HasChanges() bool {
+	for _, line := range d.Lines {
+		if line.Op != DiffEqual {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the number of inserted and deleted lines
+func (d *Diff) Stats() (inserted, deleted int) {
+	for _, line := range d.Lines {
+		switch line.Op {
+		case DiffInsert:
+			inserted++
+		case DiffDelete:
+			deleted++
+		}
+	}
+	return inserted, deleted
+}
+
+// Unified renders the diff in unified format with the given number of context
+// lines around each change. It returns "" when nothing changed.
+func (d *Diff) Unified(context int) string {
+	if !d.HasChanges() {
+		return ""
+	}
+	if context < 0 {
+		context = 0
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", d.FromLabel, d.ToLabel)
+
+	for i := 0; i < len(d.Lines); {
+		if d.Lines[i].Op == DiffEqual {
+			i++
+			continue
+		}
+
+		// Grow the hunk while the next change is close enough to share context
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < len(d.Lines); j++ {
+			if d.Lines[j].Op == DiffEqual {
+				continue
+			}
+			if j-end > 2*context {
+				break
+			}
+			end = j
+		}
+		end = min(end+context+1, len(d.Lines))
+
+		d.writeHunk(&sb, start, end)
+		i = end
+	}
+
+	return sb.String()
+}
+
+// writeHunk writes the lines [start, end) as one unified hunk
+func (d *Diff) writeHunk(sb *strings.Builder, start, end int) {
+	oldBefore, newBefore := 0, 0
+	for _, line := range d.Lines[:start] {
+		if line.Op != DiffInsert {
+			oldBefore++
+		}
+		if line.Op != DiffDelete {
+			newBefore++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+	for _, line := range d.Lines[start:end] {
+		if line.Op != DiffInsert {
+			oldCount++
+		}
+		if line.Op != DiffDelete {
+			newCount++
+		}
+	}
+
+	// An empty range is reported at the line before it, as diff(1) does
+	oldStart, newStart := oldBefore, newBefore
+	if oldCount > 0 {
+		oldStart++
+	}
+	if newCount > 0 {
+		newStart++
+	}
+
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+	for _, line := range d.Lines[start:end] {
+		prefix := " "
+		switch line.Op {
+		case DiffInsert:
+			prefix = "+"
+		case DiffDelete:
+			prefix = "-"
+		}
+		sb.WriteString(prefix + line.Text + "\n")
+	}
+}
+
+// diffLines computes a shortest edit script between a and b with Myers' algorithm
+func diffLines(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+
+	// Find the length of the shortest edit script, remembering the furthest
+	// reaching x on every diagonal k before each step d
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down: insert from b
+			} else {
+				x = v[offset+k-1] + 1 // move right: delete from a
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edits
+	var reversed []DiffLine
+	x, y := n, m
+	for d := len(trace) - 1; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, DiffLine{Op: DiffEqual, Text: a[x-1], OldLine: x, NewLine: y})
+			x--
+			y--
+		}
+		if x == prevX {
+			reversed = append(reversed, DiffLine{Op: DiffInsert, Text: b[prevY], NewLine: prevY + 1})
+		} else {
+			reversed = append(reversed, DiffLine{Op: DiffDelete, Text: a[prevX], OldLine: prevX + 1})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		reversed = append(reversed, DiffLine{Op: DiffEqual, Text: a[x-1], OldLine: x, NewLine: y})
+		x--
+		y--
+	}
+
+	lines := make([]DiffLine, len(reversed))
+	for i, line := range reversed {
+		lines[len(reversed)-1-i] = line
+	}
+	return lines
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+// render formats diff lines compactly as " a", "+b", "-c"
+func render(lines []DiffLine) string {
+	var parts []string
+	for _, line := range lines {
+		prefix := " "
+		switch line.Op {
+		case DiffInsert:
+			prefix = "+"
+		case DiffDelete:
+			prefix = "-"
+		}
+		parts = append(parts, prefix+line.Text)
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestDiffContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{name: "identical", old: "a\nb\n", new: "a\nb\n", want: " a| b"},
+		{name: "both empty", old: "", new: "", want: ""},
+		{name: "from empty", old: "", new: "a\nb", want: "+a|+b"},
+		{name: "to empty", old: "a\nb", new: "", want: "-a|-b"},
+		{name: "insert", old: "a\nc", new: "a\nb\nc", want: " a|+b| c"},
+		{name: "delete", old: "a\nb\nc", new: "a\nc", want: " a|-b| c"},
+		{name: "replace", old: "a\nb\nc", new: "a\nx\nc", want: " a|-b|+x| c"},
+		{name: "crlf is ignored", old: "a\r\nb\r\n", new: "a\nb\n", want: " a| b"},
+		{name: "classic", old: "A\nB\nC\nA\nB\nB\nA", new: "C\nB\nA\nB\nA\nC", want: "-A|-B| C|+B| A| B|-B| A|+C"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffContent("old", "new", tt.old, tt.new)
+			if got := render(diff.Lines); got != tt.want {
+				t.Errorf("DiffContent() = %q, want %q", got, tt.want)
+			}
+
+			// Applying the diff must reproduce both sides
+			var oldLines, newLines []string
+			for _, line := range diff.Lines {
+				if line.Op != DiffInsert {
+					oldLines = append(oldLines, line.Text)
+					if line.OldLine != len(oldLines) {
+						t.Errorf("OldLine = %d, want %d", line.OldLine, len(oldLines))
+					}
+				}
+				if line.Op != DiffDelete {
+					newLines = append(newLines, line.Text)
+					if line.NewLine != len(newLines) {
+						t.Errorf("NewLine = %d, want %d", line.NewLine, len(newLines))
+					}
+				}
+			}
+			if strings.Join(oldLines, "\n") != strings.Join(splitContentLines(tt.old), "\n") ||
+				strings.Join(newLines, "\n") != strings.Join(splitContentLines(tt.new), "\n") {
+				t.Errorf("diff does not reproduce its inputs: %q", render(diff.Lines))
+			}
+		})
+	}
+}
+
+func TestDiff_Unified(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n"
+	diff := DiffContent("rev 1", "rev 2", old, new)
+
+	want := `--- rev 1
++++ rev 2
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -10,1 +10,2 @@
+ 10
++11
+`
+	if got := diff.Unified(1); got != want {
+		t.Errorf("Unified(1) =\n%s\nwant\n%s", got, want)
+	}
+
+	// Enough context merges the two changes into one hunk
+	if got := diff.Unified(4); strings.Count(got, "@@ ") != 1 || strings.Split(got, "\n")[2] != "@@ -1,10 +1,11 @@" {
+		t.Errorf("Unified(4) =\n%s", got)
+	}
+
+	inserted, deleted := diff.Stats()
+	if inserted != 2 || deleted != 1 {
+		t.Errorf("Stats() = %d, %d, want 2, 1", inserted, deleted)
+	}
+
+	if got := DiffContent("a", "b", "x\n", "x\n").Unified(3); got != "" {
+		t.Errorf("Unified() without changes = %q, want empty", got)
+	}
+	if got := DiffContent("a", "b", "", "x\n").Unified(0); !strings.Contains(got, "@@ -0,0 +1,1 @@") {
+		t.Errorf("Unified() from empty = %q", got)
+	}
+}
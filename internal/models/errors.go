@@ -23,6 +23,7 @@ const (
 	ErrorTypeTimeout
 	ErrorTypeNetwork
 	ErrorTypeCorruption
+	ErrorTypeDependencyConflict
 )
 
 // String returns the string representation of ErrorType
@@ -52,6 +53,8 @@ func (et ErrorType) String() string {
 		return "network"
 	case ErrorTypeCorruption:
 		return "corruption"
+	case ErrorTypeDependencyConflict:
+		return "dependency_conflict"
 	default:
 		return "unknown"
 	}
@@ -293,6 +293,7 @@ func TestEnhancedErrorType_String(t *testing.T) {
 		{ErrorTypeTimeout, "timeout"},
 		{ErrorTypeNetwork, "network"},
 		{ErrorTypeCorruption, "corruption"},
+		{ErrorTypeDependencyConflict, "dependency_conflict"},
 	}
 
 	for _, tt := range tests {
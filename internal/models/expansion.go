@@ -0,0 +1,9 @@
+package models
+
+// Expansion is a state-machine diagram with its !include and !includesub
+// directives inlined, transitively
+type Expansion struct {
+	Diagram   *StateMachineDiagram // copy of the root diagram holding the expanded content
+	Includes  []Reference          // every inlined reference, in the order it was first inlined
+	SourceMap SourceMap            // maps expanded lines to lines of the root diagram
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextEdit replaces the text covered by Span with NewText. A zero-width span
+// inserts NewText at that position.
+type TextEdit struct {
+	Span    Span
+	NewText string
+}
+
+// Fix is a machine-applicable suggestion that resolves a validation finding
+type Fix struct {
+	Code        string // Code of the finding the fix resolves
+	Description string
+	Edits       []TextEdit
+}
+
+// ApplyFixes applies the edits of all fixes to content and returns the result.
+// Edits are applied against the original content, so their spans must not
+// overlap; overlapping or out-of-range edits return an ErrorTypeValidation error.
+func ApplyFixes(content string, fixes []Fix) (string, error) {
+	type offsetEdit struct {
+		start, end int
+		text       string
+	}
+
+	lineStarts := []int{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+
+	var edits []offsetEdit
+	for _, fix := range fixes {
+		for _, edit := range fix.Edits {
+			start, err := spanOffset(content, lineStarts, edit.Span.StartLine, edit.Span.StartColumn)
+			if err != nil {
+				return "", err.WithContext("fix", fix.Code)
+			}
+			end, err := spanOffset(content, lineStarts, edit.Span.EndLine, edit.Span.EndColumn)
+			if err != nil {
+				return "", err.WithContext("fix", fix.Code)
+			}
+			if end < start {
+				return "", NewStateMachineError(ErrorTypeValidation, "edit span ends before it starts", nil).
+					WithContext("fix", fix.Code)
+			}
+			edits = append(edits, offsetEdit{start: start, end: end, text: edit.NewText})
+		}
+	}
+
+	// Stable sort keeps insertions at the same position in the order given
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].start < edits[j].start
+	})
+
+	var builder strings.Builder
+	pos := 0
+	for i, edit := range edits {
+		if i > 0 && edit.start < edits[i-1].end {
+			return "", NewStateMachineError(ErrorTypeValidation, "fix edits overlap", nil).
+				WithContext("offset", edit.start)
+		}
+		builder.WriteString(content[pos:edit.start])
+		builder.WriteString(edit.text)
+		pos = edit.end
+	}
+	builder.WriteString(content[pos:])
+
+	return builder.String(), nil
+}
+
+// spanOffset converts a 1-based line and character column to a byte offset in content.
+// A column one past the end of a line addresses the end of that line.
+func spanOffset(content string, lineStarts []int, line, column int) (int, *StateMachineError) {
+	if line < 1 || line > len(lineStarts) || column < 1 {
+		return 0, NewStateMachineError(ErrorTypeValidation, "edit position is out of range", nil).
+			WithContext("line", line).
+			WithContext("column", column)
+	}
+
+	lineEnd := len(content)
+	if line < len(lineStarts) {
+		lineEnd = lineStarts[line] - 1
+	}
+	text := strings.TrimSuffix(content[lineStarts[line-1]:lineEnd], "\r")
+
+	offset := 0
+	for col := 1; col < column; col++ {
+		if offset >= len(text) {
+			return 0, NewStateMachineError(ErrorTypeValidation, "edit position is out of range", nil).
+				WithContext("line", line).
+				WithContext("column", column)
+		}
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		offset += size
+	}
+
+	return lineStarts[line-1] + offset, nil
+}
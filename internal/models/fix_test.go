@@ -0,0 +1,120 @@
+package models
+
+import (
+	"testing"
+)
+
+func TestApplyFixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		fixes   []Fix
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no fixes",
+			content: "@startuml\n@enduml",
+			want:    "@startuml\n@enduml",
+		},
+		{
+			name:    "insert at start",
+			content: "[*] --> Idle\n@enduml",
+			fixes: []Fix{{Code: "MISSING_START", Edits: []TextEdit{
+				{Span: PointSpan(1, 1), NewText: "@startuml\n"},
+			}}},
+			want: "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:    "append at end of line",
+			content: "@startuml\n[*] --> Idle",
+			fixes: []Fix{{Code: "MISSING_END", Edits: []TextEdit{
+				{Span: PointSpan(2, 13), NewText: "\n@enduml"},
+			}}},
+			want: "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:    "replace on crlf line",
+			content: "@startuml\r\nBad State --> Idle\r\n@enduml",
+			fixes: []Fix{{Code: "INVALID_STATE_NAME", Edits: []TextEdit{
+				{Span: Span{StartLine: 2, StartColumn: 1, EndLine: 2, EndColumn: 10}, NewText: "Bad_State"},
+			}}},
+			want: "@startuml\r\nBad_State --> Idle\r\n@enduml",
+		},
+		{
+			name:    "multiple fixes applied against original positions",
+			content: "A B --> C D",
+			fixes: []Fix{
+				{Code: "INVALID_STATE_NAME", Edits: []TextEdit{
+					{Span: Span{StartLine: 1, StartColumn: 9, EndLine: 1, EndColumn: 12}, NewText: "C_D"},
+				}},
+				{Code: "INVALID_STATE_NAME", Edits: []TextEdit{
+					{Span: Span{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 4}, NewText: "A_B"},
+				}},
+			},
+			want: "A_B --> C_D",
+		},
+		{
+			name:    "multi-byte columns",
+			content: "État --> Prêt à partir",
+			fixes: []Fix{{Code: "INVALID_STATE_NAME", Edits: []TextEdit{
+				{Span: Span{StartLine: 1, StartColumn: 10, EndLine: 1, EndColumn: 23}, NewText: "Pret"},
+			}}},
+			want: "État --> Pret",
+		},
+		{
+			name:    "overlapping edits",
+			content: "abcdef",
+			fixes: []Fix{
+				{Code: "ONE", Edits: []TextEdit{{Span: Span{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 4}, NewText: "x"}}},
+				{Code: "TWO", Edits: []TextEdit{{Span: Span{StartLine: 1, StartColumn: 3, EndLine: 1, EndColumn: 5}, NewText: "y"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "line out of range",
+			content: "abc",
+			fixes:   []Fix{{Code: "BAD", Edits: []TextEdit{{Span: PointSpan(3, 1), NewText: "x"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "column out of range",
+			content: "abc",
+			fixes:   []Fix{{Code: "BAD", Edits: []TextEdit{{Span: PointSpan(1, 6), NewText: "x"}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyFixes(tt.content, tt.fixes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyFixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if GetErrorType(err) != ErrorTypeValidation {
+					t.Errorf("ApplyFixes() error type = %v, want %v", GetErrorType(err), ErrorTypeValidation)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("ApplyFixes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationResult_Fixes(t *testing.T) {
+	result := &ValidationResult{IsValid: true}
+	result.AddErrorWithSpan("E", "error", PointSpan(1, 1), Fix{Code: "E"})
+	result.AddWarningWithSpan("W", "warning", PointSpan(2, 1), Fix{Code: "W"})
+	result.AddWarning("PLAIN", "no fix", 3, 1)
+
+	fixes := result.Fixes()
+	if len(fixes) != 2 {
+		t.Fatalf("Fixes() length = %d, want 2", len(fixes))
+	}
+	if fixes[0].Code != "E" || fixes[1].Code != "W" {
+		t.Errorf("Fixes() = %+v, want error fix followed by warning fix", fixes)
+	}
+}
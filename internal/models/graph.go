@@ -0,0 +1,429 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// GraphNode identifies a state-machine diagram in a dependency graph
+type GraphNode struct {
+	Name     string
+	Version  string
+	Location Location
+}
+
+// String returns the node as "name-version (location)"
+func (n GraphNode) String() string {
+	return fmt.Sprintf("%s-%s (%s)", n.Name, n.Version, n.Location.String())
+}
+
+// GraphEdge is a reference from one diagram to another
+type GraphEdge struct {
+	From      GraphNode
+	To        GraphNode
+	Reference Reference
+}
+
+// DependencyGraph is the reference graph of a set of state-machine diagrams.
+// References always point at products; a reference whose target is not part
+// of the graph is listed in Missing instead of Edges.
+type DependencyGraph struct {
+	nodes   []GraphNode
+	known   map[GraphNode]bool
+	forward map[GraphNode][]GraphEdge
+	reverse map[GraphNode][]GraphEdge
+	missing []GraphEdge
+
+	productVersions map[string][]string
+}
+
+// NewDependencyGraph builds the graph of the given diagrams from their parsed
+// References. Version-range references resolve to the highest matching product
+// in the set.
+func NewDependencyGraph(diagrams []StateMachineDiagram) *DependencyGraph {
+	g := &DependencyGraph{
+		known:   make(map[GraphNode]bool),
+		forward: make(map[GraphNode][]GraphEdge),
+		reverse: make(map[GraphNode][]GraphEdge),
+	}
+
+	productVersions := make(map[string][]string)
+	g.productVersions = productVersions
+	for _, d := range diagrams {
+		node := GraphNode{Name: d.Name, Version: d.Version, Location: d.Location}
+		if g.known[node] {
+			continue
+		}
+		g.known[node] = true
+		g.nodes = append(g.nodes, node)
+		if d.Location == LocationFileProducts {
+			productVersions[d.Name] = append(productVersions[d.Name], d.Version)
+		}
+	}
+	sortNodes(g.nodes)
+
+	for _, d := range diagrams {
+		from := GraphNode{Name: d.Name, Version: d.Version, Location: d.Location}
+		for _, ref := range d.References {
+			if ref.Type != ReferenceTypeProduct {
+				continue
+			}
+
+			version := ref.Version
+			if ref.IsRange() {
+				if constraint, err := ParseConstraint(ref.Constraint); err == nil {
+					version, _ = constraint.Latest(productVersions[ref.Name])
+				}
+			}
+
+			edge := GraphEdge{
+				From:      from,
+				To:        GraphNode{Name: ref.Name, Version: version, Location: LocationFileProducts},
+				Reference: ref,
+			}
+			if version == "" || !g.known[edge.To] {
+				g.missing = append(g.missing, edge)
+				continue
+			}
+			g.forward[from] = append(g.forward[from], edge)
+			g.reverse[edge.To] = append(g.reverse[edge.To], edge)
+		}
+	}
+
+	return g
+}
+
+// sortNodes orders nodes by name, version and location
+func sortNodes(nodes []GraphNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if c := compareVersions(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+		return a.Location < b.Location
+	})
+}
+
+// Nodes returns every diagram in the graph
+func (g *DependencyGraph) Nodes() []GraphNode {
+	return append([]GraphNode(nil), g.nodes...)
+}
+
+// Has reports whether the node is part of the graph
+func (g *DependencyGraph) Has(node GraphNode) bool {
+	return g.known[node]
+}
+
+// Edges returns every resolved reference in the graph
+func (g *DependencyGraph) Edges() []GraphEdge {
+	var edges []GraphEdge
+	for _, node := range g.nodes {
+		edges = append(edges, g.forward[node]...)
+	}
+	return edges
+}
+
+// Missing returns the references whose target is not part of the graph
+func (g *DependencyGraph) Missing() []GraphEdge {
+	return append([]GraphEdge(nil), g.missing...)
+}
+
+// Dependencies returns the diagrams node references directly
+func (g *DependencyGraph) Dependencies(node GraphNode) []GraphNode {
+	return uniqueTargets(g.forward[node], func(e GraphEdge) GraphNode { return e.To })
+}
+
+// Dependents returns the diagrams that reference node directly
+func (g *DependencyGraph) Dependents(node GraphNode) []GraphNode {
+	return uniqueTargets(g.reverse[node], func(e GraphEdge) GraphNode { return e.From })
+}
+
+// BrokenBy returns the diagrams with a reference that would no longer resolve
+// if node were removed. A version-range reference that another product version
+// still satisfies is not broken.
+func (g *DependencyGraph) BrokenBy(node GraphNode) []GraphNode {
+	var remaining []string
+	for _, version := range g.productVersions[node.Name] {
+		if version != node.Version {
+			remaining = append(remaining, version)
+		}
+	}
+
+	var broken []GraphEdge
+	for _, edge := range g.reverse[node] {
+		if edge.Reference.IsRange() {
+			if constraint, err := ParseConstraint(edge.Reference.Constraint); err == nil {
+				if _, ok := constraint.Latest(remaining); ok {
+					continue
+				}
+			}
+		}
+		broken = append(broken, edge)
+	}
+	return uniqueTargets(broken, func(e GraphEdge) GraphNode { return e.From })
+}
+
+// TransitiveDependencies returns every diagram reachable from node, excluding node itself
+func (g *DependencyGraph) TransitiveDependencies(node GraphNode) []GraphNode {
+	return g.reachable(node, g.Dependencies)
+}
+
+// TransitiveDependents returns every diagram that reaches node, excluding node itself
+func (g *DependencyGraph) TransitiveDependents(node GraphNode) []GraphNode {
+	return g.reachable(node, g.Dependents)
+}
+
+// uniqueTargets returns the distinct nodes selected from edges in sorted order
+func uniqueTargets(edges []GraphEdge, pick func(GraphEdge) GraphNode) []GraphNode {
+	seen := make(map[GraphNode]bool)
+	var nodes []GraphNode
+	for _, e := range edges {
+		n := pick(e)
+		if !seen[n] {
+			seen[n] = true
+			nodes = append(nodes, n)
+		}
+	}
+	sortNodes(nodes)
+	return nodes
+}
+
+// reachable walks the graph from start using next and returns the visited nodes
+func (g *DependencyGraph) reachable(start GraphNode, next func(GraphNode) []GraphNode) []GraphNode {
+	seen := map[GraphNode]bool{start: true}
+	queue := []GraphNode{start}
+	var nodes []GraphNode
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, n := range next(current) {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			nodes = append(nodes, n)
+			queue = append(queue, n)
+		}
+	}
+
+	sortNodes(nodes)
+	return nodes
+}
+
+// TopologicalOrder returns the diagrams ordered so that every diagram comes
+// after the diagrams it references. It fails when the graph has cycles.
+func (g *DependencyGraph) TopologicalOrder() ([]GraphNode, error) {
+	pending := make(map[GraphNode]int, len(g.nodes))
+	for _, node := range g.nodes {
+		pending[node] = len(g.Dependencies(node))
+	}
+
+	var ready, order []GraphNode
+	for _, node := range g.nodes {
+		if pending[node] == 0 {
+			ready = append(ready, node)
+		}
+	}
+
+	for len(ready) > 0 {
+		node := ready[0]
+		ready = ready[1:]
+		order = append(order, node)
+
+		var unblocked []GraphNode
+		for _, dependent := range g.Dependents(node) {
+			pending[dependent]--
+			if pending[dependent] == 0 {
+				unblocked = append(unblocked, dependent)
+			}
+		}
+		ready = append(ready, unblocked...)
+		sortNodes(ready)
+	}
+
+	if len(order) != len(g.nodes) {
+		cycles := g.Cycles()
+		err := NewStateMachineError(ErrorTypeReferenceResolution, "dependency graph contains cycles", nil).
+			WithContext("cycle_count", len(cycles))
+		if len(cycles) > 0 {
+			err = err.WithContext("cycle", formatCycle(cycles[0]))
+		}
+		return nil, err
+	}
+	return order, nil
+}
+
+// Cycles returns every elementary cycle of the graph. Each cycle starts at its
+// smallest node and ends with that node again, e.g. [a, b, a].
+//
+// Cycles are searched with Johnson's algorithm, only inside strongly connected
+// components that can hold one, so an acyclic graph costs polynomial time.
+func (g *DependencyGraph) Cycles() [][]GraphNode {
+	index := make(map[GraphNode]int, len(g.nodes))
+	for i, node := range g.nodes {
+		index[node] = i
+	}
+
+	// Nodes outside every cyclic component can never be on a cycle
+	cyclic := make(map[GraphNode]bool)
+	for _, component := range g.components(func(node GraphNode) bool { return g.known[node] }) {
+		if len(component) > 1 || g.hasSelfLoop(component[0]) {
+			for _, node := range component {
+				cyclic[node] = true
+			}
+		}
+	}
+
+	var cycles [][]GraphNode
+	for i, start := range g.nodes {
+		if !cyclic[start] {
+			continue
+		}
+
+		// Only nodes ordered from start on are searched so each cycle is found once, from its smallest node
+		later := func(node GraphNode) bool { return cyclic[node] && index[node] >= i }
+		for _, component := range g.components(later) {
+			if !slices.Contains(component, start) {
+				continue
+			}
+			if len(component) > 1 || g.hasSelfLoop(start) {
+				members := make(map[GraphNode]bool, len(component))
+				for _, node := range component {
+					members[node] = true
+				}
+				cycles = append(cycles, g.circuits(start, members)...)
+			}
+			break
+		}
+	}
+
+	return cycles
+}
+
+// hasSelfLoop reports whether node references itself
+func (g *DependencyGraph) hasSelfLoop(node GraphNode) bool {
+	return slices.Contains(g.Dependencies(node), node)
+}
+
+// components returns the strongly connected components of the subgraph of
+// nodes accepted by include, using Tarjan's algorithm
+func (g *DependencyGraph) components(include func(GraphNode) bool) [][]GraphNode {
+	index := make(map[GraphNode]int)
+	lowlink := make(map[GraphNode]int)
+	onStack := make(map[GraphNode]bool)
+	var stack []GraphNode
+	var components [][]GraphNode
+
+	var connect func(node GraphNode)
+	connect = func(node GraphNode) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range g.Dependencies(node) {
+			if !include(next) {
+				continue
+			}
+			if _, seen := index[next]; !seen {
+				connect(next)
+				lowlink[node] = min(lowlink[node], lowlink[next])
+			} else if onStack[next] {
+				lowlink[node] = min(lowlink[node], index[next])
+			}
+		}
+
+		if lowlink[node] == index[node] {
+			var component []GraphNode
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component = append(component, top)
+				if top == node {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range g.nodes {
+		if _, seen := index[node]; !seen && include(node) {
+			connect(node)
+		}
+	}
+	return components
+}
+
+// circuits returns the cycles through start within one strongly connected
+// component. A node is blocked while it is on the path or cannot reach start,
+// so no dead end is explored twice.
+func (g *DependencyGraph) circuits(start GraphNode, members map[GraphNode]bool) [][]GraphNode {
+	var cycles [][]GraphNode
+	var path []GraphNode
+	blocked := make(map[GraphNode]bool)
+	blockedBy := make(map[GraphNode]map[GraphNode]bool)
+
+	var unblock func(node GraphNode)
+	unblock = func(node GraphNode) {
+		blocked[node] = false
+		for waiting := range blockedBy[node] {
+			delete(blockedBy[node], waiting)
+			if blocked[waiting] {
+				unblock(waiting)
+			}
+		}
+	}
+
+	var circuit func(node GraphNode) bool
+	circuit = func(node GraphNode) bool {
+		found := false
+		path = append(path, node)
+		blocked[node] = true
+
+		for _, next := range g.Dependencies(node) {
+			switch {
+			case !members[next]:
+			case next == start:
+				cycles = append(cycles, append(append([]GraphNode(nil), path...), start))
+				found = true
+			case !blocked[next] && circuit(next):
+				found = true
+			}
+		}
+
+		if found {
+			unblock(node)
+		} else {
+			for _, next := range g.Dependencies(node) {
+				if members[next] {
+					if blockedBy[next] == nil {
+						blockedBy[next] = make(map[GraphNode]bool)
+					}
+					blockedBy[next][node] = true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return found
+	}
+	circuit(start)
+
+	return cycles
+}
+
+// formatCycle renders a cycle as "a-1.0.0 -> b-1.0.0 -> a-1.0.0"
+func formatCycle(cycle []GraphNode) string {
+	parts := make([]string, len(cycle))
+	for i, node := range cycle {
+		parts[i] = node.Name + "-" + node.Version
+	}
+	return strings.Join(parts, " -> ")
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// graphDiagram returns a diagram with product references to the given name-version pairs
+func graphDiagram(name, version string, location Location, refs ...[2]string) StateMachineDiagram {
+	d := StateMachineDiagram{Name: name, Version: version, Location: location}
+	for _, r := range refs {
+		ref := Reference{Name: r[0], Version: r[1], Type: ReferenceTypeProduct}
+		if IsVersionConstraint(r[1]) {
+			ref.Constraint, ref.Version = r[1], ""
+		}
+		d.References = append(d.References, ref)
+	}
+	return d
+}
+
+func product(name, version string) GraphNode {
+	return GraphNode{Name: name, Version: version, Location: LocationFileProducts}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	app := GraphNode{Name: "app", Version: "1.0.0", Location: LocationFileInProgress}
+	g := NewDependencyGraph([]StateMachineDiagram{
+		graphDiagram("app", "1.0.0", LocationFileInProgress, [2]string{"auth", "^1.0"}, [2]string{"billing", "1.0.0"}),
+		graphDiagram("auth", "1.0.0", LocationFileProducts),
+		graphDiagram("auth", "1.2.0", LocationFileProducts, [2]string{"base", "1.0.0"}),
+		graphDiagram("billing", "1.0.0", LocationFileProducts, [2]string{"base", "1.0.0"}, [2]string{"ghost", "1.0.0"}),
+		graphDiagram("base", "1.0.0", LocationFileProducts),
+	})
+
+	if got, want := g.Dependencies(app), []GraphNode{product("auth", "1.2.0"), product("billing", "1.0.0")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependencies() = %v, want %v", got, want)
+	}
+	if got, want := g.Dependents(product("base", "1.0.0")), []GraphNode{product("auth", "1.2.0"), product("billing", "1.0.0")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Dependents() = %v, want %v", got, want)
+	}
+	if got, want := g.TransitiveDependencies(app), []GraphNode{product("auth", "1.2.0"), product("base", "1.0.0"), product("billing", "1.0.0")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TransitiveDependencies() = %v, want %v", got, want)
+	}
+	if got, want := g.TransitiveDependents(product("base", "1.0.0")), []GraphNode{app, product("auth", "1.2.0"), product("billing", "1.0.0")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TransitiveDependents() = %v, want %v", got, want)
+	}
+
+	missing := g.Missing()
+	if len(missing) != 1 || missing[0].To.Name != "ghost" {
+		t.Errorf("Missing() = %v, want the ghost reference", missing)
+	}
+
+	order, err := g.TopologicalOrder()
+	if err != nil {
+		t.Fatalf("TopologicalOrder() error = %v", err)
+	}
+	position := make(map[GraphNode]int)
+	for i, node := range order {
+		position[node] = i
+	}
+	if len(order) != len(g.Nodes()) {
+		t.Fatalf("TopologicalOrder() = %v, want every node", order)
+	}
+	for _, edge := range g.Edges() {
+		if position[edge.To] > position[edge.From] {
+			t.Errorf("%v should come before %v", edge.To, edge.From)
+		}
+	}
+	if len(g.Cycles()) != 0 {
+		t.Errorf("Cycles() = %v, want none", g.Cycles())
+	}
+}
+
+func TestDependencyGraph_Cycles(t *testing.T) {
+	g := NewDependencyGraph([]StateMachineDiagram{
+		graphDiagram("a", "1.0.0", LocationFileProducts, [2]string{"b", "1.0.0"}),
+		graphDiagram("b", "1.0.0", LocationFileProducts, [2]string{"c", "1.0.0"}, [2]string{"a", "1.0.0"}),
+		graphDiagram("c", "1.0.0", LocationFileProducts, [2]string{"a", "1.0.0"}),
+		graphDiagram("d", "1.0.0", LocationFileProducts, [2]string{"d", "1.0.0"}),
+	})
+
+	want := [][]GraphNode{
+		{product("a", "1.0.0"), product("b", "1.0.0"), product("a", "1.0.0")},
+		{product("a", "1.0.0"), product("b", "1.0.0"), product("c", "1.0.0"), product("a", "1.0.0")},
+		{product("d", "1.0.0"), product("d", "1.0.0")},
+	}
+	if got := g.Cycles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Cycles() = %v, want %v", got, want)
+	}
+
+	_, err := g.TopologicalOrder()
+	if GetErrorType(err) != ErrorTypeReferenceResolution {
+		t.Fatalf("TopologicalOrder() error = %v, want ErrorTypeReferenceResolution", err)
+	}
+	if ctx := err.(*StateMachineError).Context["cycle"]; ctx != "a-1.0.0 -> b-1.0.0 -> a-1.0.0" {
+		t.Errorf("cycle context = %v", ctx)
+	}
+}
+
+func TestDependencyGraph_Cycles_WideGraph(t *testing.T) {
+	// Every node references the next two, so the number of paths grows
+	// exponentially while the only cycle sits at the end of the chain
+	const size = 100
+	var diagrams []StateMachineDiagram
+	for i := 0; i < size; i++ {
+		var refs [][2]string
+		for _, next := range []int{i + 1, i + 2} {
+			if next < size {
+				refs = append(refs, [2]string{fmt.Sprintf("n%03d", next), "1.0.0"})
+			}
+		}
+		if i == size-1 {
+			refs = append(refs, [2]string{"loop", "1.0.0"})
+		}
+		diagrams = append(diagrams, graphDiagram(fmt.Sprintf("n%03d", i), "1.0.0", LocationFileProducts, refs...))
+	}
+	diagrams = append(diagrams, graphDiagram("loop", "1.0.0", LocationFileProducts, [2]string{"loop", "1.0.0"}))
+	g := NewDependencyGraph(diagrams)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := g.TopologicalOrder()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if ctx := err.(*StateMachineError).Context["cycle_count"]; ctx != 1 {
+			t.Errorf("cycle_count = %v, want 1", ctx)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("TopologicalOrder() of a wide graph did not finish")
+	}
+}
+
+func TestDependencyGraph_BrokenBy(t *testing.T) {
+	g := NewDependencyGraph([]StateMachineDiagram{
+		graphDiagram("exact", "1.0.0", LocationFileInProgress, [2]string{"base", "1.1.0"}),
+		graphDiagram("ranged", "1.0.0", LocationFileProducts, [2]string{"base", "^1.0"}),
+		graphDiagram("pinned", "1.0.0", LocationFileProducts, [2]string{"base", "~1.1.0"}),
+		graphDiagram("base", "1.0.0", LocationFileProducts),
+		graphDiagram("base", "1.1.0", LocationFileProducts),
+	})
+
+	broken := g.BrokenBy(product("base", "1.1.0"))
+	want := []GraphNode{{Name: "exact", Version: "1.0.0", Location: LocationFileInProgress}, product("pinned", "1.0.0")}
+	if !reflect.DeepEqual(broken, want) {
+		t.Errorf("BrokenBy() = %v, want %v", broken, want)
+	}
+
+	if broken := g.BrokenBy(product("base", "1.0.0")); len(broken) != 0 {
+		t.Errorf("BrokenBy() = %v, want none", broken)
+	}
+}
@@ -19,29 +19,135 @@ type Repository interface {
 	DirectoryExists(path string) (bool, error)
 }
 
+// BlockRepository is implemented by repositories that can address individual
+// @startuml blocks of a multi-block file
+type BlockRepository interface {
+	ListBlocks(diagramType smmodels.DiagramType, name, version string, location Location) ([]Block, error)
+	ReadBlock(diagramType smmodels.DiagramType, name, version string, location Location, blockID string) (*Block, error)
+}
+
+// MetadataRepository is implemented by repositories that persist diagram
+// metadata separately from the diagram content
+type MetadataRepository interface {
+	ReadMetadata(diagramType smmodels.DiagramType, name, version string, location Location) (*Metadata, error)
+	WriteMetadata(diagramType smmodels.DiagramType, name, version string, location Location, metadata Metadata) error
+}
+
+// BackupRepository is implemented by repositories that can snapshot diagrams
+// and restore them later. Backups are listed oldest first.
+type BackupRepository interface {
+	CreateBackup(diagramType smmodels.DiagramType, name, version string, location Location, reason string) (*Backup, error)
+	ListBackups(diagramType smmodels.DiagramType, name, version string) ([]Backup, error)
+	ReadBackup(diagramType smmodels.DiagramType, name, version, id string) (*StateMachineDiagram, *Backup, error)
+	PruneBackups(diagramType smmodels.DiagramType, name, version string, keep int) error
+}
+
+// LockRepository is implemented by repositories whose storage may be shared by
+// several processes. A diagram lock covers one name and version in every
+// location; the root lock excludes every diagram lock. Locks are not reentrant,
+// and the returned function releases the lock.
+type LockRepository interface {
+	LockDiagram(diagramType smmodels.DiagramType, name, version string) (unlock func(), err error)
+	LockRoot() (unlock func(), err error)
+}
+
+// RevisionRepository is implemented by repositories that keep the revision
+// history of in-progress diagrams. Revisions are listed oldest first.
+type RevisionRepository interface {
+	AddRevision(diag *StateMachineDiagram, author, message string) (*Revision, error)
+	ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]Revision, error)
+	ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*StateMachineDiagram, *Revision, error)
+}
+
+// ListingRepository is implemented by repositories that can list diagrams
+// without reading their content, reporting the files they leave out
+type ListingRepository interface {
+	ListEntries(diagramType smmodels.DiagramType, location Location) (*DiagramListing, error)
+}
+
+// ChangeLogRepository is implemented by repositories that record every change
+// of a diagram in version control. Changes are listed oldest first.
+type ChangeLogRepository interface {
+	ListChanges(diagramType smmodels.DiagramType, name, version string) ([]Change, error)
+}
+
+// ChecksumRepository is implemented by repositories that store the checksums
+// recorded for products diagrams. ReadChecksum fails with ErrorTypeFileNotFound
+// when no checksum was recorded.
+type ChecksumRepository interface {
+	WriteChecksum(diagramType smmodels.DiagramType, name, version string, checksum ProductChecksum) error
+	ReadChecksum(diagramType smmodels.DiagramType, name, version string) (*ProductChecksum, error)
+	DeleteChecksum(diagramType smmodels.DiagramType, name, version string) error
+}
+
+// IntegrityRepository is implemented by repositories that can scan their
+// storage for inconsistencies and repair them
+type IntegrityRepository interface {
+	CheckIntegrity(options CheckOptions) (*CheckReport, error)
+}
+
 // Validator interface defines the contract for state-machine diagram validation
 type Validator interface {
 	Validate(diagram *StateMachineDiagram, strictness ValidationStrictness) (*ValidationResult, error)
 	ValidateReferences(diagram *StateMachineDiagram) (*ValidationResult, error)
 }
 
+// IncludeExpander is implemented by validators that can inline the !include
+// directives of a state-machine diagram
+type IncludeExpander interface {
+	ExpandIncludes(diagram *StateMachineDiagram) (*Expansion, *ValidationResult, error)
+}
+
 // DiagramService interface defines the contract for business operations
 type DiagramService interface {
 	// File CRUD operations
 	CreateFile(diagramType smmodels.DiagramType, name, version string, content string, location Location) (*StateMachineDiagram, error)
+	CreateFileWithMetadata(diagramType smmodels.DiagramType, name, version string, content string, location Location, metadata Metadata) (*StateMachineDiagram, error) // Create with author, tags and description
 	ReadFile(diagramType smmodels.DiagramType, name, version string, location Location) (*StateMachineDiagram, error)
 	UpdateInProgressFile(diag *StateMachineDiagram) error
+	UpdateInProgressFileWithOptions(diag *StateMachineDiagram, opts UpdateOptions) error // Update and record the author and message of the revision
 	DeleteFile(diagramType smmodels.DiagramType, name, version string, location Location) error
+	DeleteFileWithOptions(diagramType smmodels.DiagramType, name, version string, location Location, opts DeleteOptions) (*DeleteResult, error) // Delete with control over referenced products
+
+	// Revision history of in-progress files, available when the repository supports it
+	ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]Revision, error)
+	ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*StateMachineDiagram, *Revision, error)
+	DiffRevisions(diagramType smmodels.DiagramType, name, version string, from, to int) (*Diff, error)
+	RevertToRevision(diagramType smmodels.DiagramType, name, version string, number int, opts UpdateOptions) (*StateMachineDiagram, error)
+
+	// Version control history of every location, available when the repository records one
+	ListChanges(diagramType smmodels.DiagramType, name, version string) ([]Change, error)
+
+	// Backup operations, available when the repository supports backups
+	ListBackups(diagramType smmodels.DiagramType, name, version string) ([]Backup, error)
+	RestoreBackup(diagramType smmodels.DiagramType, name, version, backupID string) (*StateMachineDiagram, error)
+
+	// Integrity check of the whole repository, available when the repository supports it
+	CheckIntegrity(options CheckOptions) (*CheckReport, error)
 
 	// cache close
 	CloseCache() error
 
 	// Business operations
-	PromoteToProductsFile(diagramType smmodels.DiagramType, name, version string) error // Move from in-progress to products
-	PromoteToCache(diagramType smmodels.DiagramType, name, version string) error        // Move from products file to operational cache
+	PromoteToProductsFile(diagramType smmodels.DiagramType, name, version string) error                                 // Move from in-progress to products
+	PromoteToProductsFileWithOptions(diagramType smmodels.DiagramType, name, version string, opts PromoteOptions) error // Promote and record the actor
+	PromoteToCache(diagramType smmodels.DiagramType, name, version string) error                                        // Move from products file to operational cache
 	ValidateFile(diagramType smmodels.DiagramType, name, version string, location Location) (*ValidationResult, error)
 	ListAllFiles(diagramType smmodels.DiagramType, location Location) ([]StateMachineDiagram, error)
+	ListFileEntries(diagramType smmodels.DiagramType, location Location) (*DiagramListing, error)                                             // List without content, reporting skipped files
+	QueryFiles(diagramType smmodels.DiagramType, query Query) (*QueryResult, error)                                                           // Filter, sort and paginate across locations
+	SearchFiles(diagramType smmodels.DiagramType, query SearchQuery) ([]SearchHit, error)                                                     // Full-text and symbol search with file, line and column
+	ApplyFixes(diagramType smmodels.DiagramType, name, version string, fixes []Fix) (*ValidationResult, error)                                // Apply fixes to an in-progress file and re-validate it
+	ApplyFixesWithOptions(diagramType smmodels.DiagramType, name, version string, fixes []Fix, opts UpdateOptions) (*ValidationResult, error) // Apply fixes only if the content still has opts.ExpectedToken
+
+	// Block operations for multi-block files
+	ListBlocks(diagramType smmodels.DiagramType, name, version string, location Location) ([]Block, error)
+	ReadBlock(diagramType smmodels.DiagramType, name, version string, location Location, blockID string) (*Block, error)
+	ValidateBlock(diagramType smmodels.DiagramType, name, version string, location Location, blockID string) (*ValidationResult, error)
 
 	// Reference operations
 	ResolveFileReferences(diagram *StateMachineDiagram) error
+	ExpandFile(diagramType smmodels.DiagramType, name, version string, location Location) (*Expansion, *ValidationResult, error) // Inline includes transitively
+	ValidateExpandedFile(diagramType smmodels.DiagramType, name, version string, location Location) (*ValidationResult, error)   // Validate with includes inlined
+	BuildDependencyGraph(diagramType smmodels.DiagramType) (*DependencyGraph, error)                                             // Reference graph across both locations
 }
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+)
+
+// DiagramEntry describes a state-machine diagram without its content
+type DiagramEntry struct {
+	Name        string
+	Version     string
+	Location    Location
+	DiagramType smmodels.DiagramType
+	Size        int64 // content size in bytes
+	CreatedAt   time.Time
+	ModifiedAt  time.Time
+}
+
+// SkippedFile is a file in a location directory that a listing left out
+type SkippedFile struct {
+	Path   string
+	Reason string
+}
+
+// DiagramListing is the content of one location: the state-machine diagrams
+// in file name order and the files that could not be listed as diagrams
+type DiagramListing struct {
+	Entries []DiagramEntry
+	Skipped []SkippedFile
+}
+
+// ListVersions returns the versions of a diagram name in a location. It lists
+// entries when repo is a ListingRepository, so no content has to be read.
+func ListVersions(repo Repository, diagramType smmodels.DiagramType, location Location, name string) ([]string, error) {
+	var versions []string
+	if listingRepo, ok := repo.(ListingRepository); ok {
+		listing, err := listingRepo.ListEntries(diagramType, location)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range listing.Entries {
+			if entry.Name == name {
+				versions = append(versions, entry.Version)
+			}
+		}
+		return versions, nil
+	}
+
+	diagrams, err := repo.ListDiagrams(diagramType, location)
+	if err != nil {
+		return nil, err
+	}
+	for _, diag := range diagrams {
+		if diag.Name == name {
+			versions = append(versions, diag.Version)
+		}
+	}
+	return versions, nil
+}
@@ -0,0 +1,42 @@
+package models
+
+// DeleteOptions controls DeleteFileWithOptions
+type DeleteOptions struct {
+	// Force deletes a products file even when other diagrams reference it.
+	// The diagrams left with unresolvable references are returned in DeleteResult.
+	Force bool
+
+	// ExpectedToken, when set, makes the deletion fail with ErrorTypeFileConflict
+	// if the stored content no longer has this token
+	ExpectedToken string
+}
+
+// DeleteResult describes a completed deletion
+type DeleteResult struct {
+	Deleted GraphNode
+	Broken  []GraphNode // dependents whose references no longer resolve, only set when forced
+}
+
+// PromoteOptions controls PromoteToProductsFileWithOptions
+type PromoteOptions struct {
+	// Actor is recorded as Metadata.PromotedBy
+	Actor string
+
+	// ExpectedToken, when set, makes the promotion fail with ErrorTypeFileConflict
+	// if the in-progress content no longer has this token
+	ExpectedToken string
+}
+
+// UpdateOptions controls UpdateInProgressFileWithOptions, RevertToRevision and ApplyFixesWithOptions
+type UpdateOptions struct {
+	// Author is recorded with the revision; it defaults to Metadata.Author
+	Author string
+
+	// Message describes the change and is recorded with the revision
+	Message string
+
+	// ExpectedToken, when set, makes the update fail with ErrorTypeFileConflict
+	// if the stored content no longer has this token. UpdateInProgressFileWithOptions
+	// falls back to the Token of the diagram being written.
+	ExpectedToken string
+}
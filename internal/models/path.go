@@ -15,6 +15,9 @@ const (
 
 	// PlantUMLExtension is the file extension for PlantUML files
 	PlantUMLExtension = ".puml"
+
+	// MetadataExtension is the file extension for metadata sidecar files
+	MetadataExtension = ".meta.json"
 )
 
 // PathManager provides utilities for managing directory paths and file names
@@ -59,6 +62,72 @@ func (pm *PathManager) GetDiagramFilePathWithDiagramType(name, version string, l
 	return filepath.Join(dirPath, fmt.Sprintf("%s-%s%s", name, version, PlantUMLExtension))
 }
 
+// GetMetadataFilePathWithDiagramType returns the path of the metadata sidecar stored next to a state-machine diagram
+func (pm *PathManager) GetMetadataFilePathWithDiagramType(name, version string, location Location, diagramType smmodels.DiagramType) string {
+	dirPath := pm.GetLocationWithDiagramTypePath(location, diagramType)
+	return filepath.Join(dirPath, fmt.Sprintf("%s-%s%s", name, version, MetadataExtension))
+}
+
+// GetBackupRootWithDiagramType returns the directory holding the backups of every state-machine diagram of a type
+func (pm *PathManager) GetBackupRootWithDiagramType(diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.rootDir, "backups", diagramType.String())
+}
+
+// GetBackupPathWithDiagramType returns the directory holding the backups of a state-machine diagram
+func (pm *PathManager) GetBackupPathWithDiagramType(name, version string, diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.GetBackupRootWithDiagramType(diagramType), fmt.Sprintf("%s-%s", name, version))
+}
+
+// GetHistoryRootWithDiagramType returns the directory holding the revisions of every state-machine diagram of a type
+func (pm *PathManager) GetHistoryRootWithDiagramType(diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.rootDir, "history", diagramType.String())
+}
+
+// GetHistoryPathWithDiagramType returns the directory holding the revisions of a state-machine diagram
+func (pm *PathManager) GetHistoryPathWithDiagramType(name, version string, diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.GetHistoryRootWithDiagramType(diagramType), fmt.Sprintf("%s-%s", name, version))
+}
+
+// GetManifestPathWithDiagramType returns the manifest caching the listing of a location
+func (pm *PathManager) GetManifestPathWithDiagramType(location Location, diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.GetIndexPath(), diagramType.String(), location.String()+".json")
+}
+
+// GetChecksumRootWithDiagramType returns the directory holding the checksums of every products state-machine diagram of a type
+func (pm *PathManager) GetChecksumRootWithDiagramType(diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.rootDir, "checksums", diagramType.String())
+}
+
+// GetChecksumPathWithDiagramType returns the checksum record of a products state-machine diagram
+func (pm *PathManager) GetChecksumPathWithDiagramType(name, version string, diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.GetChecksumRootWithDiagramType(diagramType), fmt.Sprintf("%s-%s.json", name, version))
+}
+
+// GetIndexPath returns the directory holding the listing manifests
+func (pm *PathManager) GetIndexPath() string {
+	return filepath.Join(pm.rootDir, "index")
+}
+
+// GetQuarantinePath returns the directory integrity checks move offending files to
+func (pm *PathManager) GetQuarantinePath() string {
+	return filepath.Join(pm.rootDir, "quarantine")
+}
+
+// GetLocksPath returns the directory holding the cross-process lock files
+func (pm *PathManager) GetLocksPath() string {
+	return filepath.Join(pm.rootDir, "locks")
+}
+
+// GetRootLockPath returns the lock file guarding the whole root directory
+func (pm *PathManager) GetRootLockPath() string {
+	return filepath.Join(pm.GetLocksPath(), "root.lock")
+}
+
+// GetLockPathWithDiagramType returns the lock file guarding one state-machine diagram in every location
+func (pm *PathManager) GetLockPathWithDiagramType(name, version string, diagramType smmodels.DiagramType) string {
+	return filepath.Join(pm.GetLocksPath(), diagramType.String(), fmt.Sprintf("%s-%s.lock", name, version))
+}
+
 // PathInfo contains parsed information from a path
 type PathInfo struct {
 	Name     string
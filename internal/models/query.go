@@ -0,0 +1,219 @@
+package models
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QuerySortField selects the ordering of query results
+type QuerySortField int
+
+const (
+	QuerySortByName QuerySortField = iota // name, then version, then location
+	QuerySortByVersion
+	QuerySortByCreated
+	QuerySortByModified
+)
+
+// String returns the string representation of QuerySortField
+func (f QuerySortField) String() string {
+	switch f {
+	case QuerySortByName:
+		return "name"
+	case QuerySortByVersion:
+		return "version"
+	case QuerySortByCreated:
+		return "created"
+	case QuerySortByModified:
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
+
+// Query selects state-machine diagrams by name, version and metadata.
+// Zero-valued fields do not filter.
+type Query struct {
+	Locations         []Location // empty searches in-progress and products
+	NamePrefix        string
+	NameGlob          string   // shell pattern matched against the name, e.g. "auth-*"
+	VersionConstraint string   // semantic version constraint, e.g. "^1.2"
+	Tags              []string // every tag must be present
+	Author            string   // compared case-insensitively
+
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+
+	SortBy     QuerySortField
+	Descending bool
+	Offset     int
+	Limit      int // 0 returns every match after Offset
+}
+
+// QueryResult is a page of matching state-machine diagrams
+type QueryResult struct {
+	Diagrams []StateMachineDiagram
+	Total    int // matches before pagination
+	Offset   int
+	Limit    int
+}
+
+// HasMore reports whether matches exist beyond this page
+func (r *QueryResult) HasMore() bool {
+	return r.Offset+len(r.Diagrams) < r.Total
+}
+
+// SearchLocations returns the locations the query covers
+func (q Query) SearchLocations() []Location {
+	if len(q.Locations) == 0 {
+		return []Location{LocationFileInProgress, LocationFileProducts}
+	}
+	return q.Locations
+}
+
+// Validate checks the query for malformed patterns, constraints and paging values
+func (q Query) Validate() error {
+	if q.NameGlob != "" {
+		if _, err := path.Match(q.NameGlob, ""); err != nil {
+			return NewStateMachineError(ErrorTypeValidation, "invalid name pattern", err).
+				WithContext("nameGlob", q.NameGlob)
+		}
+	}
+	if q.VersionConstraint != "" {
+		if _, err := ParseConstraint(q.VersionConstraint); err != nil {
+			return NewStateMachineError(ErrorTypeValidation, "invalid version constraint", err).
+				WithContext("versionConstraint", q.VersionConstraint)
+		}
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return NewStateMachineError(ErrorTypeValidation, "offset and limit cannot be negative", nil).
+			WithContext("offset", q.Offset).
+			WithContext("limit", q.Limit)
+	}
+	return nil
+}
+
+// Matches reports whether a diagram satisfies every filter of the query.
+// The query must be valid.
+func (q Query) Matches(diag StateMachineDiagram) bool {
+	if q.NamePrefix != "" && !strings.HasPrefix(diag.Name, q.NamePrefix) {
+		return false
+	}
+	if q.NameGlob != "" {
+		if ok, _ := path.Match(q.NameGlob, diag.Name); !ok {
+			return false
+		}
+	}
+	if q.VersionConstraint != "" {
+		constraint, _ := ParseConstraint(q.VersionConstraint)
+		version, err := ParseVersion(diag.Version)
+		if err != nil || !constraint.Matches(version) {
+			return false
+		}
+	}
+	if q.Author != "" && !strings.EqualFold(diag.Metadata.Author, q.Author) {
+		return false
+	}
+	for _, tag := range q.Tags {
+		if !hasTag(diag.Metadata.Tags, tag) {
+			return false
+		}
+	}
+	return inRange(diag.Metadata.CreatedAt, q.CreatedAfter, q.CreatedBefore) &&
+		inRange(diag.Metadata.ModifiedAt, q.ModifiedAfter, q.ModifiedBefore)
+}
+
+// hasTag reports whether tags contains tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether t lies within [after, before]; zero bounds are open
+func inRange(t, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	if !before.IsZero() && t.After(before) {
+		return false
+	}
+	return true
+}
+
+// Apply filters, sorts and paginates diagrams
+func (q Query) Apply(diagrams []StateMachineDiagram) (*QueryResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	var matches []StateMachineDiagram
+	for _, diag := range diagrams {
+		if q.Matches(diag) {
+			matches = append(matches, diag)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if q.Descending {
+			return q.less(matches[j], matches[i])
+		}
+		return q.less(matches[i], matches[j])
+	})
+
+	result := &QueryResult{Total: len(matches), Offset: q.Offset, Limit: q.Limit}
+	if q.Offset >= len(matches) {
+		result.Diagrams = []StateMachineDiagram{}
+		return result, nil
+	}
+	end := len(matches)
+	// Compared with the remaining length so a huge Limit cannot overflow
+	if q.Limit > 0 && q.Limit < len(matches)-q.Offset {
+		end = q.Offset + q.Limit
+	}
+	result.Diagrams = matches[q.Offset:end]
+	return result, nil
+}
+
+// less orders two diagrams by the sort field, breaking ties by name, version and location
+func (q Query) less(a, b StateMachineDiagram) bool {
+	switch q.SortBy {
+	case QuerySortByVersion:
+		if c := compareVersions(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+	case QuerySortByCreated:
+		if !a.Metadata.CreatedAt.Equal(b.Metadata.CreatedAt) {
+			return a.Metadata.CreatedAt.Before(b.Metadata.CreatedAt)
+		}
+	case QuerySortByModified:
+		if !a.Metadata.ModifiedAt.Equal(b.Metadata.ModifiedAt) {
+			return a.Metadata.ModifiedAt.Before(b.Metadata.ModifiedAt)
+		}
+	}
+
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	if c := compareVersions(a.Version, b.Version); c != 0 {
+		return c < 0
+	}
+	return a.Location < b.Location
+}
+
+// compareVersions compares semantic versions, falling back to string order for invalid ones
+func compareVersions(a, b string) int {
+	av, aerr := ParseVersion(a)
+	bv, berr := ParseVersion(b)
+	if aerr == nil && berr == nil {
+		return av.Compare(bv)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func queryDiagrams() []StateMachineDiagram {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	return []StateMachineDiagram{
+		{Name: "auth-login", Version: "1.2.0", Location: LocationFileProducts, Metadata: Metadata{Author: "Jane", Tags: []string{"auth", "core"}, CreatedAt: day(1), ModifiedAt: day(5)}},
+		{Name: "auth-login", Version: "1.10.0", Location: LocationFileInProgress, Metadata: Metadata{Author: "jane", Tags: []string{"auth"}, CreatedAt: day(3), ModifiedAt: day(3)}},
+		{Name: "auth-mfa", Version: "2.0.0", Location: LocationFileProducts, Metadata: Metadata{Author: "john", Tags: []string{"auth", "core"}, CreatedAt: day(2), ModifiedAt: day(9)}},
+		{Name: "billing", Version: "0.1.0", Location: LocationFileInProgress, Metadata: Metadata{Author: "john", CreatedAt: day(4), ModifiedAt: day(4)}},
+	}
+}
+
+// names returns the name-version pairs of a result page
+func names(result *QueryResult) []string {
+	var out []string
+	for _, d := range result.Diagrams {
+		out = append(out, d.Name+"-"+d.Version)
+	}
+	return out
+}
+
+func TestQuery_Apply(t *testing.T) {
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+		total int
+	}{
+		{name: "everything sorted by name", query: Query{}, want: []string{"auth-login-1.2.0", "auth-login-1.10.0", "auth-mfa-2.0.0", "billing-0.1.0"}, total: 4},
+		{name: "prefix", query: Query{NamePrefix: "auth-"}, want: []string{"auth-login-1.2.0", "auth-login-1.10.0", "auth-mfa-2.0.0"}, total: 3},
+		{name: "glob", query: Query{NameGlob: "*-m?a"}, want: []string{"auth-mfa-2.0.0"}, total: 1},
+		{name: "version range", query: Query{VersionConstraint: "^1.2"}, want: []string{"auth-login-1.2.0", "auth-login-1.10.0"}, total: 2},
+		{name: "tags", query: Query{Tags: []string{"auth", "core"}}, want: []string{"auth-login-1.2.0", "auth-mfa-2.0.0"}, total: 2},
+		{name: "author ignores case", query: Query{Author: "JANE"}, want: []string{"auth-login-1.2.0", "auth-login-1.10.0"}, total: 2},
+		{name: "created range", query: Query{CreatedAfter: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), CreatedBefore: time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)}, want: []string{"auth-login-1.10.0", "auth-mfa-2.0.0"}, total: 2},
+		{name: "modified descending", query: Query{SortBy: QuerySortByModified, Descending: true}, want: []string{"auth-mfa-2.0.0", "auth-login-1.2.0", "billing-0.1.0", "auth-login-1.10.0"}, total: 4},
+		{name: "version sort", query: Query{SortBy: QuerySortByVersion}, want: []string{"billing-0.1.0", "auth-login-1.2.0", "auth-login-1.10.0", "auth-mfa-2.0.0"}, total: 4},
+		{name: "page", query: Query{Offset: 1, Limit: 2}, want: []string{"auth-login-1.10.0", "auth-mfa-2.0.0"}, total: 4},
+		{name: "page past the end", query: Query{Offset: 10}, want: nil, total: 4},
+		{name: "limit near max int", query: Query{Offset: 1, Limit: math.MaxInt}, want: []string{"auth-login-1.10.0", "auth-mfa-2.0.0", "billing-0.1.0"}, total: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.query.Apply(queryDiagrams())
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			got := names(result)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Apply() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Apply() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if result.Total != tt.total {
+				t.Errorf("Total = %d, want %d", result.Total, tt.total)
+			}
+		})
+	}
+}
+
+func TestQuery_HasMore(t *testing.T) {
+	result, err := Query{Limit: 3}.Apply(queryDiagrams())
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !result.HasMore() {
+		t.Error("HasMore() should be true for the first of two pages")
+	}
+
+	result, _ = Query{Offset: 3, Limit: 3}.Apply(queryDiagrams())
+	if result.HasMore() {
+		t.Error("HasMore() should be false for the last page")
+	}
+}
+
+func TestQuery_Validate(t *testing.T) {
+	for _, q := range []Query{{NameGlob: "[a"}, {VersionConstraint: "^a.b"}, {Offset: -1}, {Limit: -5}} {
+		if err := q.Validate(); GetErrorType(err) != ErrorTypeValidation {
+			t.Errorf("Validate(%+v) error = %v, want validation error", q, err)
+		}
+	}
+	if err := (Query{NameGlob: "auth-*", VersionConstraint: "~1.0"}).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Revision describes one saved state of an in-progress state-machine diagram.
+// Revisions are numbered from 1 in the order they were saved.
+type Revision struct {
+	Number    int
+	Name      string
+	Version   string
+	CreatedAt time.Time
+	Author    string
+	Message   string
+	Size      int64 // content size in bytes
+}
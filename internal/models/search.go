@@ -0,0 +1,350 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// SearchKind selects what a search hit matched
+type SearchKind int
+
+const (
+	SearchKindContent SearchKind = iota // any text on a line
+	SearchKindState
+	SearchKindEvent
+	SearchKindNote
+)
+
+// String returns the string representation of SearchKind
+func (k SearchKind) String() string {
+	switch k {
+	case SearchKindContent:
+		return "content"
+	case SearchKindState:
+		return "state"
+	case SearchKindEvent:
+		return "event"
+	case SearchKindNote:
+		return "note"
+	default:
+		return "unknown"
+	}
+}
+
+// SearchQuery describes a full-text or symbol search. Zero-valued fields do not filter.
+type SearchQuery struct {
+	Term          string
+	Kinds         []SearchKind // empty searches every kind
+	Locations     []Location   // empty searches in-progress and products
+	CaseSensitive bool
+	Exact         bool // state and event names must equal Term; content and notes always match substrings
+	Limit         int  // 0 returns every hit
+}
+
+// SearchHit is a single match of a search
+type SearchHit struct {
+	File   GraphNode
+	Kind   SearchKind
+	Line   int    // 1-based line of the match
+	Column int    // 1-based character column of the match
+	Text   string // the symbol for state and event hits, the trimmed line otherwise
+}
+
+// symbol is a state, event or note occurrence within a diagram
+type symbol struct {
+	kind   SearchKind
+	text   string
+	line   int
+	column int
+}
+
+// indexedDiagram holds the searchable lines and symbols of one diagram
+type indexedDiagram struct {
+	lines   []string
+	symbols []symbol
+}
+
+// SearchIndex is an in-memory index of the content, state names, event names
+// and note text of state-machine diagrams. It is safe for concurrent use and is
+// kept current by adding and removing diagrams as they change.
+type SearchIndex struct {
+	mu       sync.RWMutex
+	diagrams map[GraphNode]*indexedDiagram
+	names    map[string]map[GraphNode]bool // lower-cased state and event name -> diagrams using it
+}
+
+// NewSearchIndex creates an empty search index
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		diagrams: make(map[GraphNode]*indexedDiagram),
+		names:    make(map[string]map[GraphNode]bool),
+	}
+}
+
+// Add indexes a diagram, replacing any earlier version of the same file
+func (idx *SearchIndex) Add(diag StateMachineDiagram) {
+	node := GraphNode{Name: diag.Name, Version: diag.Version, Location: diag.Location}
+	lines := strings.Split(diag.Content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	indexed := &indexedDiagram{lines: lines, symbols: extractSymbols(lines)}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(node)
+	idx.diagrams[node] = indexed
+	for _, sym := range indexed.symbols {
+		if sym.kind == SearchKindNote {
+			continue
+		}
+		key := strings.ToLower(sym.text)
+		if idx.names[key] == nil {
+			idx.names[key] = make(map[GraphNode]bool)
+		}
+		idx.names[key][node] = true
+	}
+}
+
+// Remove drops a diagram from the index
+func (idx *SearchIndex) Remove(name, version string, location Location) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(GraphNode{Name: name, Version: version, Location: location})
+}
+
+// remove drops a diagram; the caller must hold the write lock
+func (idx *SearchIndex) remove(node GraphNode) {
+	indexed, ok := idx.diagrams[node]
+	if !ok {
+		return
+	}
+	for _, sym := range indexed.symbols {
+		key := strings.ToLower(sym.text)
+		delete(idx.names[key], node)
+		if len(idx.names[key]) == 0 {
+			delete(idx.names, key)
+		}
+	}
+	delete(idx.diagrams, node)
+}
+
+// Len returns the number of indexed diagrams
+func (idx *SearchIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.diagrams)
+}
+
+// Search returns the hits for the query ordered by file, line and column
+func (idx *SearchIndex) Search(q SearchQuery) ([]SearchHit, error) {
+	if strings.TrimSpace(q.Term) == "" {
+		return nil, NewStateMachineError(ErrorTypeValidation, "search term cannot be empty", nil)
+	}
+	if q.Limit < 0 {
+		return nil, NewStateMachineError(ErrorTypeValidation, "limit cannot be negative", nil).
+			WithContext("limit", q.Limit)
+	}
+
+	kinds := make(map[SearchKind]bool)
+	for _, kind := range q.Kinds {
+		kinds[kind] = true
+	}
+	wants := func(kind SearchKind) bool { return len(kinds) == 0 || kinds[kind] }
+
+	locations := make(map[Location]bool)
+	for _, location := range q.Locations {
+		locations[location] = true
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	// Exact symbol-only searches only need the diagrams that define the name
+	candidates := make([]GraphNode, 0, len(idx.diagrams))
+	if q.Exact && !wants(SearchKindContent) && !wants(SearchKindNote) {
+		for node := range idx.names[strings.ToLower(q.Term)] {
+			candidates = append(candidates, node)
+		}
+	} else {
+		for node := range idx.diagrams {
+			candidates = append(candidates, node)
+		}
+	}
+	sortNodes(candidates)
+
+	var hits []SearchHit
+	for _, node := range candidates {
+		if len(locations) > 0 && !locations[node.Location] {
+			continue
+		}
+		hits = append(hits, idx.diagrams[node].search(node, q, wants)...)
+	}
+
+	if q.Limit > 0 && len(hits) > q.Limit {
+		hits = hits[:q.Limit]
+	}
+	return hits, nil
+}
+
+// search returns the hits of one diagram ordered by line and column
+func (d *indexedDiagram) search(node GraphNode, q SearchQuery, wants func(SearchKind) bool) []SearchHit {
+	var hits []SearchHit
+	if wants(SearchKindContent) {
+		for i, line := range d.lines {
+			for _, col := range findAll(line, q.Term, q.CaseSensitive) {
+				hits = append(hits, SearchHit{File: node, Kind: SearchKindContent, Line: i + 1, Column: col, Text: strings.TrimSpace(line)})
+			}
+		}
+	}
+	for _, sym := range d.symbols {
+		if wants(sym.kind) && symbolMatches(sym, q) {
+			hits = append(hits, SearchHit{File: node, Kind: sym.kind, Line: sym.line, Column: sym.column, Text: sym.text})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		if a.Column != b.Column {
+			return a.Column < b.Column
+		}
+		return a.Kind < b.Kind
+	})
+	return hits
+}
+
+// symbolMatches reports whether a symbol satisfies the term of the query
+func symbolMatches(sym symbol, q SearchQuery) bool {
+	if q.Exact && sym.kind != SearchKindNote {
+		if q.CaseSensitive {
+			return sym.text == q.Term
+		}
+		return strings.EqualFold(sym.text, q.Term)
+	}
+	return len(findAll(sym.text, q.Term, q.CaseSensitive)) > 0
+}
+
+// findAll returns the 1-based character columns of every occurrence of term in line
+func findAll(line, term string, caseSensitive bool) []int {
+	haystack, needle := line, term
+	if !caseSensitive {
+		haystack, needle = strings.ToLower(line), strings.ToLower(term)
+		if len(haystack) != len(line) {
+			// Lower-casing changed byte lengths, so offsets would not map back to line
+			haystack, needle = line, term
+		}
+	}
+
+	var columns []int
+	for from := 0; from <= len(haystack)-len(needle); {
+		i := strings.Index(haystack[from:], needle)
+		if i == -1 {
+			break
+		}
+		offset := from + i
+		columns = append(columns, utf8.RuneCountInString(line[:offset])+1)
+		from = offset + len(needle)
+	}
+	return columns
+}
+
+var (
+	// stateDeclRegex matches "state Name", "state \"Label\" as Name" and "state Name {"
+	stateDeclRegex = regexp.MustCompile(`^state\s+(?:"[^"]*"\s+as\s+)?([A-Za-z_][\w-]*)`)
+	// transitionLineRegex matches "From --> To : Event [guard] / action" with any arrow style
+	transitionLineRegex = regexp.MustCompile(`^(\[\*\]|[A-Za-z_][\w-]*)\s*-+(?:[a-z]+-+)?>\s*(\[\*\]|[A-Za-z_][\w-]*)\s*(?::(.*))?$`)
+	// stateDescRegex matches "Name : description"
+	stateDescRegex = regexp.MustCompile(`^([A-Za-z_][\w-]*)\s*:`)
+	// noteLineRegex matches single-line notes, capturing the text after the colon
+	noteLineRegex = regexp.MustCompile(`^note\s+(?:left|right|top|bottom)(?:\s+of\s+[\w-]+)?\s*:(.*)$`)
+	// nonStateKeywords start lines that look like "Name : text" but do not describe a state
+	nonStateKeywords = map[string]bool{"title": true, "caption": true, "header": true, "footer": true, "legend": true, "skinparam": true, "scale": true}
+	// noteFloatRegex matches floating notes such as note "text" as N1
+	noteFloatRegex = regexp.MustCompile(`^note\s+"([^"]*)"`)
+)
+
+// extractSymbols finds the states, events and note text of PlantUML lines
+func extractSymbols(lines []string) []symbol {
+	var symbols []symbol
+	inNote := false
+
+	// add records text found at byte offset from within line
+	add := func(kind SearchKind, text string, line int, from int) {
+		text = strings.TrimSpace(text)
+		if text == "" || text == "[*]" {
+			return
+		}
+		raw := lines[line-1]
+		offset := strings.Index(raw[from:], text)
+		if offset == -1 {
+			offset = 0
+		}
+		symbols = append(symbols, symbol{
+			kind:   kind,
+			text:   text,
+			line:   line,
+			column: utf8.RuneCountInString(raw[:from+offset]) + 1,
+		})
+	}
+
+	for i, raw := range lines {
+		lineNum := i + 1
+		trimmed := strings.TrimSpace(raw)
+		lead := strings.Index(raw, trimmed)
+		if trimmed == "" || strings.HasPrefix(trimmed, "'") {
+			continue
+		}
+
+		if inNote {
+			if strings.EqualFold(trimmed, "end note") || strings.EqualFold(trimmed, "endnote") {
+				inNote = false
+				continue
+			}
+			add(SearchKindNote, trimmed, lineNum, lead)
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "note ") {
+			if m := noteLineRegex.FindStringSubmatchIndex(trimmed); m != nil {
+				add(SearchKindNote, trimmed[m[2]:m[3]], lineNum, lead+m[2])
+			} else if m := noteFloatRegex.FindStringSubmatchIndex(trimmed); m != nil {
+				add(SearchKindNote, trimmed[m[2]:m[3]], lineNum, lead+m[2])
+			} else {
+				inNote = true
+			}
+			continue
+		}
+
+		if m := stateDeclRegex.FindStringSubmatchIndex(trimmed); m != nil {
+			add(SearchKindState, trimmed[m[2]:m[3]], lineNum, lead+m[2])
+			continue
+		}
+
+		if m := transitionLineRegex.FindStringSubmatchIndex(trimmed); m != nil {
+			add(SearchKindState, trimmed[m[2]:m[3]], lineNum, lead+m[2])
+			add(SearchKindState, trimmed[m[4]:m[5]], lineNum, lead+m[4])
+			if m[6] != -1 {
+				label := trimmed[m[6]:m[7]]
+				if cut := strings.IndexAny(label, "[/"); cut != -1 {
+					label = label[:cut]
+				}
+				add(SearchKindEvent, label, lineNum, lead+m[6])
+			}
+			continue
+		}
+
+		if m := stateDescRegex.FindStringSubmatchIndex(trimmed); m != nil && !nonStateKeywords[strings.ToLower(trimmed[m[2]:m[3]])] {
+			add(SearchKindState, trimmed[m[2]:m[3]], lineNum, lead+m[2])
+		}
+	}
+
+	return symbols
+}
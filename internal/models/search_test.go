@@ -0,0 +1,120 @@
+package models
+
+import "testing"
+
+const searchContent = `@startuml
+state "Awaiting payment" as Pending
+[*] --> Pending
+Pending --> Paid : PaymentReceived
+Pending --> Cancelled : PaymentTimeout [retries > 3] / notify
+Paid : charge captured
+note right of Pending : waits for the PSP callback
+note left of Paid
+  settle within one day
+end note
+@enduml`
+
+func searchIndex() *SearchIndex {
+	idx := NewSearchIndex()
+	idx.Add(StateMachineDiagram{Name: "checkout", Version: "1.0.0", Location: LocationFileProducts, Content: searchContent})
+	idx.Add(StateMachineDiagram{Name: "refund", Version: "1.0.0", Location: LocationFileInProgress, Content: "@startuml\n[*] --> Open\nOpen --> Closed : paymenttimeout\n@enduml"})
+	return idx
+}
+
+func TestSearchIndex_Symbols(t *testing.T) {
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []SearchHit
+	}{
+		{
+			name:  "exact event",
+			query: SearchQuery{Term: "PaymentTimeout", Kinds: []SearchKind{SearchKindEvent}, Exact: true, CaseSensitive: true},
+			want:  []SearchHit{{Kind: SearchKindEvent, Line: 5, Column: 25, Text: "PaymentTimeout"}},
+		},
+		{
+			name:  "exact event ignoring case",
+			query: SearchQuery{Term: "PaymentTimeout", Kinds: []SearchKind{SearchKindEvent}, Exact: true},
+			want: []SearchHit{
+				{Kind: SearchKindEvent, Line: 5, Column: 25, Text: "PaymentTimeout"},
+				{Kind: SearchKindEvent, Line: 3, Column: 19, Text: "paymenttimeout"},
+			},
+		},
+		{
+			name:  "state declared with a label",
+			query: SearchQuery{Term: "Pending", Kinds: []SearchKind{SearchKindState}, Exact: true, Limit: 2},
+			want: []SearchHit{
+				{Kind: SearchKindState, Line: 2, Column: 29, Text: "Pending"},
+				{Kind: SearchKindState, Line: 3, Column: 9, Text: "Pending"},
+			},
+		},
+		{
+			name:  "state description",
+			query: SearchQuery{Term: "Paid", Kinds: []SearchKind{SearchKindState}, Exact: true, Locations: []Location{LocationFileProducts}},
+			want: []SearchHit{
+				{Kind: SearchKindState, Line: 4, Column: 13, Text: "Paid"},
+				{Kind: SearchKindState, Line: 6, Column: 1, Text: "Paid"},
+			},
+		},
+		{
+			name:  "note text",
+			query: SearchQuery{Term: "settle", Kinds: []SearchKind{SearchKindNote}},
+			want:  []SearchHit{{Kind: SearchKindNote, Line: 9, Column: 3, Text: "settle within one day"}},
+		},
+		{
+			name:  "content",
+			query: SearchQuery{Term: "psp", Kinds: []SearchKind{SearchKindContent}},
+			want:  []SearchHit{{Kind: SearchKindContent, Line: 7, Column: 39, Text: "note right of Pending : waits for the PSP callback"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits, err := searchIndex().Search(tt.query)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if len(hits) != len(tt.want) {
+				t.Fatalf("Search() = %+v, want %+v", hits, tt.want)
+			}
+			for i, want := range tt.want {
+				got := hits[i]
+				if got.Kind != want.Kind || got.Line != want.Line || got.Column != want.Column || got.Text != want.Text {
+					t.Errorf("hit %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchIndex_AddRemove(t *testing.T) {
+	idx := searchIndex()
+	query := SearchQuery{Term: "Closed", Kinds: []SearchKind{SearchKindState}, Exact: true}
+
+	hits, _ := idx.Search(query)
+	if len(hits) != 1 || hits[0].File.Name != "refund" {
+		t.Fatalf("Search() = %+v, want the refund diagram", hits)
+	}
+
+	idx.Add(StateMachineDiagram{Name: "refund", Version: "1.0.0", Location: LocationFileInProgress, Content: "[*] --> Open"})
+	if hits, _ := idx.Search(query); len(hits) != 0 {
+		t.Errorf("Search() after update = %+v, want no hits", hits)
+	}
+
+	idx.Remove("checkout", "1.0.0", LocationFileProducts)
+	if idx.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", idx.Len())
+	}
+	if hits, _ := idx.Search(SearchQuery{Term: "Pending"}); len(hits) != 0 {
+		t.Errorf("Search() after remove = %+v, want no hits", hits)
+	}
+}
+
+func TestSearchIndex_InvalidQuery(t *testing.T) {
+	idx := searchIndex()
+	for _, q := range []SearchQuery{{Term: " "}, {Term: "x", Limit: -1}} {
+		if _, err := idx.Search(q); GetErrorType(err) != ErrorTypeValidation {
+			t.Errorf("Search(%+v) error = %v, want validation error", q, err)
+		}
+	}
+}
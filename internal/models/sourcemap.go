@@ -0,0 +1,114 @@
+package models
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// SourceMap ties the lines of derived content, such as preprocessed or
+// include-expanded text, to the lines of the content it was produced from
+type SourceMap struct {
+	Sources  []int  // original 1-based line for each derived line
+	Verbatim []bool // whether the derived line is an unmodified copy of its original line
+}
+
+// Add records the origin of the next derived line
+func (m *SourceMap) Add(source int, verbatim bool) {
+	m.Sources = append(m.Sources, source)
+	m.Verbatim = append(m.Verbatim, verbatim)
+}
+
+// SourceLine returns the original line a derived 1-based line was produced from.
+// Out-of-range lines are clamped to the first or last derived line.
+func (m *SourceMap) SourceLine(line int) int {
+	if len(m.Sources) == 0 {
+		return line
+	}
+	if line < 1 {
+		line = 1
+	}
+	if line > len(m.Sources) {
+		line = len(m.Sources)
+	}
+	return m.Sources[line-1]
+}
+
+// IsVerbatim reports whether a derived 1-based line is an unmodified copy of its
+// original line, so that columns on it are valid in the original content
+func (m *SourceMap) IsVerbatim(line int) bool {
+	if line < 1 || line > len(m.Verbatim) {
+		return false
+	}
+	return m.Verbatim[line-1]
+}
+
+// MapResult moves findings reported against derived content back to original.
+// Findings on verbatim lines keep their columns; findings on derived lines cover
+// the whole original line. Fixes are kept only when every edit lands on a verbatim line.
+func (m *SourceMap) MapResult(vr *ValidationResult, original string) {
+	lines := strings.Split(original, "\n")
+
+	// lineSpan covers the trimmed text of original lines start through end
+	lineSpan := func(start, end int) Span {
+		span := Span{StartLine: start, StartColumn: 1, EndLine: end, EndColumn: 1}
+		if start >= 1 && start <= len(lines) {
+			text := strings.TrimSuffix(lines[start-1], "\r")
+			lead := len(text) - len(strings.TrimLeft(text, " \t"))
+			span.StartColumn = utf8.RuneCountInString(text[:lead]) + 1
+		}
+		if end >= 1 && end <= len(lines) {
+			text := strings.TrimRight(strings.TrimSuffix(lines[end-1], "\r"), " \t")
+			span.EndColumn = utf8.RuneCountInString(text) + 1
+		}
+		return span
+	}
+
+	mapSpan := func(span Span) Span {
+		if m.IsVerbatim(span.StartLine) && m.IsVerbatim(span.EndLine) {
+			span.StartLine = m.SourceLine(span.StartLine)
+			span.EndLine = m.SourceLine(span.EndLine)
+			return span
+		}
+
+		start := m.SourceLine(span.StartLine)
+		end := m.SourceLine(span.EndLine)
+		if end < start {
+			end = start
+		}
+		return lineSpan(start, end)
+	}
+
+	mapFixes := func(fixes []Fix) []Fix {
+		var mapped []Fix
+		for _, fix := range fixes {
+			applicable := true
+			edits := make([]TextEdit, len(fix.Edits))
+			for i, edit := range fix.Edits {
+				if !m.IsVerbatim(edit.Span.StartLine) || !m.IsVerbatim(edit.Span.EndLine) {
+					applicable = false
+					break
+				}
+				edit.Span = mapSpan(edit.Span)
+				edits[i] = edit
+			}
+			if applicable {
+				fix.Edits = edits
+				mapped = append(mapped, fix)
+			}
+		}
+		return mapped
+	}
+
+	for i := range vr.Errors {
+		e := &vr.Errors[i]
+		span := mapSpan(e.Span())
+		e.Line, e.Column, e.EndLine, e.EndColumn = span.StartLine, span.StartColumn, span.EndLine, span.EndColumn
+		e.Fixes = mapFixes(e.Fixes)
+	}
+	for i := range vr.Warnings {
+		w := &vr.Warnings[i]
+		span := mapSpan(w.Span())
+		w.Line, w.Column, w.EndLine, w.EndColumn = span.StartLine, span.StartColumn, span.EndLine, span.EndColumn
+		w.Fixes = mapFixes(w.Fixes)
+	}
+}
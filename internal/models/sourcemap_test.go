@@ -0,0 +1,72 @@
+package models
+
+import "testing"
+
+func TestSourceMap_SourceLine(t *testing.T) {
+	var m SourceMap
+	m.Add(1, true)
+	m.Add(3, false)
+	m.Add(3, false)
+
+	tests := []struct {
+		line int
+		want int
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 3},
+		{3, 3},
+		{10, 3},
+	}
+	for _, tt := range tests {
+		if got := m.SourceLine(tt.line); got != tt.want {
+			t.Errorf("SourceLine(%d) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+
+	if !m.IsVerbatim(1) || m.IsVerbatim(2) || m.IsVerbatim(4) {
+		t.Error("IsVerbatim() returned unexpected values")
+	}
+
+	var empty SourceMap
+	if got := empty.SourceLine(5); got != 5 {
+		t.Errorf("Empty SourceLine(5) = %d, want 5", got)
+	}
+}
+
+func TestSourceMap_MapResult(t *testing.T) {
+	original := "@startuml\n  $call()\nA --> B\n@enduml"
+
+	// Derived content: line 2 of the original expanded into two lines
+	var m SourceMap
+	m.Add(1, true)
+	m.Add(2, false)
+	m.Add(2, false)
+	m.Add(3, true)
+	m.Add(4, true)
+
+	fix := Fix{Code: "X", Edits: []TextEdit{{Span: PointSpan(2, 1), NewText: "y"}}}
+	keep := Fix{Code: "Y", Edits: []TextEdit{{Span: Span{StartLine: 4, StartColumn: 1, EndLine: 4, EndColumn: 2}, NewText: "C"}}}
+
+	vr := &ValidationResult{}
+	vr.AddErrorWithSpan("DERIVED", "on derived line", Span{StartLine: 3, StartColumn: 4, EndLine: 3, EndColumn: 6}, fix)
+	vr.AddWarningWithSpan("VERBATIM", "on verbatim line", Span{StartLine: 4, StartColumn: 1, EndLine: 4, EndColumn: 2}, keep)
+
+	m.MapResult(vr, original)
+
+	wantDerived := Span{StartLine: 2, StartColumn: 3, EndLine: 2, EndColumn: 10}
+	if vr.Errors[0].Span() != wantDerived {
+		t.Errorf("Derived span = %+v, want %+v", vr.Errors[0].Span(), wantDerived)
+	}
+	if len(vr.Errors[0].Fixes) != 0 {
+		t.Errorf("Fixes on derived lines should be dropped, got %+v", vr.Errors[0].Fixes)
+	}
+
+	wantVerbatim := Span{StartLine: 3, StartColumn: 1, EndLine: 3, EndColumn: 2}
+	if vr.Warnings[0].Span() != wantVerbatim {
+		t.Errorf("Verbatim span = %+v, want %+v", vr.Warnings[0].Span(), wantVerbatim)
+	}
+	if len(vr.Warnings[0].Fixes) != 1 || vr.Warnings[0].Fixes[0].Edits[0].Span != wantVerbatim {
+		t.Errorf("Verbatim fixes = %+v", vr.Warnings[0].Fixes)
+	}
+}
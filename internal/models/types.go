@@ -1,6 +1,8 @@
 package models
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
@@ -52,20 +54,65 @@ type StateMachineDiagram struct {
 	Location    Location
 	DiagramType smmodels.DiagramType
 	Metadata    Metadata
+	Token       string // ContentToken of the content as last read or written by the service
+}
+
+// ContentToken returns the concurrency token of diagram content. Two reads of
+// a file return the same token only when its content did not change in between.
+func ContentToken(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
 }
 
 // Reference represents a reference to another state-machine diagram
 type Reference struct {
-	Name    string
-	Version string // always required for product references
-	Type    ReferenceType
-	Path    string
+	Name       string
+	Version    string // always required for product references; the resolved version for constraint references
+	Constraint string // version range as written (e.g. "^1.2"), empty for exact references
+	Type       ReferenceType
+	Path       string
+	Line       int    // line of the !include directive in the referencing content, 0 if unknown
+	Sub        string // block id for "!include path!id", sub-part name for "!includesub path!name"
+	SubPart    bool   // whether the reference is an !includesub of a tagged sub-part
+}
+
+// IsRange reports whether the reference names a version constraint instead of an exact version
+func (r Reference) IsRange() bool {
+	return r.Constraint != ""
 }
 
 // Metadata contains additional information about the state-machine diagram
 type Metadata struct {
-	CreatedAt  time.Time
-	ModifiedAt time.Time
-	Author     string
-	Tags       []string
+	CreatedAt   time.Time
+	ModifiedAt  time.Time
+	Author      string
+	Tags        []string
+	Description string
+	PromotedAt  time.Time // zero until the diagram is promoted to products
+	PromotedBy  string
+}
+
+// Merge returns m with every unset field taken from existing, so a diagram
+// written without metadata keeps what was stored for it. ModifiedAt is not
+// merged. Tags are unset only when nil; an empty slice clears them.
+func (m Metadata) Merge(existing Metadata) Metadata {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = existing.CreatedAt
+	}
+	if m.Author == "" {
+		m.Author = existing.Author
+	}
+	if m.Tags == nil {
+		m.Tags = existing.Tags
+	}
+	if m.Description == "" {
+		m.Description = existing.Description
+	}
+	if m.PromotedAt.IsZero() {
+		m.PromotedAt = existing.PromotedAt
+	}
+	if m.PromotedBy == "" {
+		m.PromotedBy = existing.PromotedBy
+	}
+	return m
 }
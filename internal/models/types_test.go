@@ -190,3 +190,16 @@ func TestMetadata_NilTags(t *testing.T) {
 		t.Errorf("Metadata.Tags = %v, want nil", metadata.Tags)
 	}
 }
+
+func TestContentToken(t *testing.T) {
+	a := ContentToken("@startuml\n[*] --> Idle\n@enduml")
+	if a != ContentToken("@startuml\n[*] --> Idle\n@enduml") {
+		t.Error("ContentToken() differs for the same content")
+	}
+	if a == ContentToken("@startuml\n[*] --> Active\n@enduml") {
+		t.Error("ContentToken() is the same for different content")
+	}
+	if len(a) != 64 {
+		t.Errorf("ContentToken() length = %d, want 64", len(a))
+	}
+}
@@ -20,23 +20,54 @@ func (vs ValidationStrictness) String() string {
 	}
 }
 
+// Span identifies a range of source text. Lines and columns are 1-based and
+// columns count characters. EndColumn is exclusive: it points one past the last
+// character covered by the span.
+type Span struct {
+	StartLine   int
+	StartColumn int
+	EndLine     int
+	EndColumn   int
+}
+
+// PointSpan returns a zero-width span at the given line and column
+func PointSpan(line, column int) Span {
+	return Span{StartLine: line, StartColumn: column, EndLine: line, EndColumn: column}
+}
+
 // ValidationError represents a validation error
 type ValidationError struct {
-	Code     string
-	Message  string
-	Line     int
-	Column   int
-	Severity string
-	Context  map[string]any
+	Code      string
+	Message   string
+	Line      int // start line
+	Column    int // start column
+	EndLine   int
+	EndColumn int // exclusive
+	Severity  string
+	Context   map[string]any
+	Fixes     []Fix // Optional machine-applicable fixes
+}
+
+// Span returns the source span covered by the error
+func (ve ValidationError) Span() Span {
+	return Span{StartLine: ve.Line, StartColumn: ve.Column, EndLine: ve.EndLine, EndColumn: ve.EndColumn}
 }
 
 // ValidationWarning represents a validation warning
 type ValidationWarning struct {
-	Code    string
-	Message string
-	Line    int
-	Column  int
-	Context map[string]any
+	Code      string
+	Message   string
+	Line      int // start line
+	Column    int // start column
+	EndLine   int
+	EndColumn int // exclusive
+	Context   map[string]any
+	Fixes     []Fix // Optional machine-applicable fixes
+}
+
+// Span returns the source span covered by the warning
+func (vw ValidationWarning) Span() Span {
+	return Span{StartLine: vw.Line, StartColumn: vw.Column, EndLine: vw.EndLine, EndColumn: vw.EndColumn}
 }
 
 // ValidationResult contains validation outcomes
@@ -56,26 +87,87 @@ func (vr *ValidationResult) HasWarnings() bool {
 	return len(vr.Warnings) > 0
 }
 
-// AddError adds a validation error
+// AddError adds a validation error at a single position
 func (vr *ValidationResult) AddError(code, message string, line, column int) {
+	vr.AddErrorWithSpan(code, message, PointSpan(line, column))
+}
+
+// AddErrorWithSpan adds a validation error covering a source span, with optional fixes
+func (vr *ValidationResult) AddErrorWithSpan(code, message string, span Span, fixes ...Fix) {
 	vr.Errors = append(vr.Errors, ValidationError{
-		Code:     code,
-		Message:  message,
-		Line:     line,
-		Column:   column,
-		Severity: "error",
-		Context:  make(map[string]any),
+		Code:      code,
+		Message:   message,
+		Line:      span.StartLine,
+		Column:    span.StartColumn,
+		EndLine:   span.EndLine,
+		EndColumn: span.EndColumn,
+		Severity:  "error",
+		Context:   make(map[string]any),
+		Fixes:     fixes,
 	})
 	vr.IsValid = false
 }
 
-// AddWarning adds a validation warning
+// AddWarning adds a validation warning at a single position
 func (vr *ValidationResult) AddWarning(code, message string, line, column int) {
+	vr.AddWarningWithSpan(code, message, PointSpan(line, column))
+}
+
+// AddWarningWithSpan adds a validation warning covering a source span, with optional fixes
+func (vr *ValidationResult) AddWarningWithSpan(code, message string, span Span, fixes ...Fix) {
 	vr.Warnings = append(vr.Warnings, ValidationWarning{
-		Code:    code,
-		Message: message,
-		Line:    line,
-		Column:  column,
-		Context: make(map[string]any),
+		Code:      code,
+		Message:   message,
+		Line:      span.StartLine,
+		Column:    span.StartColumn,
+		EndLine:   span.EndLine,
+		EndColumn: span.EndColumn,
+		Context:   make(map[string]any),
+		Fixes:     fixes,
 	})
 }
+
+// Fixes returns every fix attached to the result's errors and warnings
+func (vr *ValidationResult) Fixes() []Fix {
+	var fixes []Fix
+	for _, err := range vr.Errors {
+		fixes = append(fixes, err.Fixes...)
+	}
+	for _, warning := range vr.Warnings {
+		fixes = append(fixes, warning.Fixes...)
+	}
+	return fixes
+}
+
+// ShiftLines moves every finding and fix edit in the result down by delta lines.
+// It is used to map findings for a part of a file back to whole-file lines.
+func (vr *ValidationResult) ShiftLines(delta int) {
+	if delta == 0 {
+		return
+	}
+
+	shiftFixes := func(fixes []Fix) []Fix {
+		shifted := make([]Fix, len(fixes))
+		for i, fix := range fixes {
+			shifted[i] = fix
+			shifted[i].Edits = make([]TextEdit, len(fix.Edits))
+			for j, edit := range fix.Edits {
+				edit.Span.StartLine += delta
+				edit.Span.EndLine += delta
+				shifted[i].Edits[j] = edit
+			}
+		}
+		return shifted
+	}
+
+	for i := range vr.Errors {
+		vr.Errors[i].Line += delta
+		vr.Errors[i].EndLine += delta
+		vr.Errors[i].Fixes = shiftFixes(vr.Errors[i].Fixes)
+	}
+	for i := range vr.Warnings {
+		vr.Warnings[i].Line += delta
+		vr.Warnings[i].EndLine += delta
+		vr.Warnings[i].Fixes = shiftFixes(vr.Warnings[i].Fixes)
+	}
+}
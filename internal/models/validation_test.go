@@ -370,3 +370,72 @@ func TestValidationResult_AddWarningKeepsIsValidTrue(t *testing.T) {
 		t.Error("ValidationResult.IsValid should remain true after adding warning")
 	}
 }
+
+func TestValidationResult_AddErrorWithSpan(t *testing.T) {
+	result := &ValidationResult{IsValid: true}
+
+	span := Span{StartLine: 2, StartColumn: 3, EndLine: 4, EndColumn: 9}
+	result.AddErrorWithSpan("SPAN_ERROR", "Spanning error", span)
+
+	if len(result.Errors) != 1 {
+		t.Fatalf("ValidationResult.Errors length = %v, want 1", len(result.Errors))
+	}
+	if got := result.Errors[0].Span(); got != span {
+		t.Errorf("ValidationError.Span() = %+v, want %+v", got, span)
+	}
+	if result.IsValid {
+		t.Error("ValidationResult.IsValid should be false after adding error")
+	}
+}
+
+func TestValidationResult_AddWarningWithSpan(t *testing.T) {
+	result := &ValidationResult{IsValid: true}
+
+	span := Span{StartLine: 5, StartColumn: 1, EndLine: 5, EndColumn: 12}
+	result.AddWarningWithSpan("SPAN_WARNING", "Spanning warning", span)
+
+	if len(result.Warnings) != 1 {
+		t.Fatalf("ValidationResult.Warnings length = %v, want 1", len(result.Warnings))
+	}
+	if got := result.Warnings[0].Span(); got != span {
+		t.Errorf("ValidationWarning.Span() = %+v, want %+v", got, span)
+	}
+	if !result.IsValid {
+		t.Error("ValidationResult.IsValid should remain true after adding warning")
+	}
+}
+
+func TestValidationResult_AddErrorPointSpan(t *testing.T) {
+	result := &ValidationResult{IsValid: true}
+	result.AddError("POINT", "Point error", 3, 15)
+
+	want := PointSpan(3, 15)
+	if got := result.Errors[0].Span(); got != want {
+		t.Errorf("ValidationError.Span() = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidationResult_ShiftLines(t *testing.T) {
+	result := &ValidationResult{}
+	result.AddErrorWithSpan("MISSING_END", "missing", PointSpan(3, 5), Fix{
+		Code:  "MISSING_END",
+		Edits: []TextEdit{{Span: PointSpan(3, 5), NewText: "\n@enduml"}},
+	})
+	result.AddWarningWithSpan("NO_INITIAL_STATE", "no initial", Span{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 10})
+
+	original := result.Errors[0].Fixes
+	result.ShiftLines(10)
+
+	if got := result.Errors[0].Span(); got != PointSpan(13, 5) {
+		t.Errorf("Error span = %+v, want line 13", got)
+	}
+	if got := result.Errors[0].Fixes[0].Edits[0].Span; got != PointSpan(13, 5) {
+		t.Errorf("Fix span = %+v, want line 13", got)
+	}
+	if got := result.Warnings[0].Span(); got.StartLine != 11 || got.EndLine != 11 {
+		t.Errorf("Warning span = %+v, want line 11", got)
+	}
+	if original[0].Edits[0].Span.StartLine != 3 {
+		t.Error("ShiftLines should not modify fixes shared with the caller")
+	}
+}
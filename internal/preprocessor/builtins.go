@@ -0,0 +1,161 @@
+package preprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// builtinFunc implements a %builtin function over evaluated arguments
+type builtinFunc func(e *evaluator, args []string) (string, error)
+
+// builtins is the set of supported PlantUML builtin functions
+var builtins map[string]builtinFunc
+
+func init() {
+	builtins = map[string]builtinFunc{
+		"%true":  func(e *evaluator, args []string) (string, error) { return "1", nil },
+		"%false": func(e *evaluator, args []string) (string, error) { return "0", nil },
+		"%not": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%not", args, 1); err != nil {
+				return "", err
+			}
+			return boolString(!truthy(args[0])), nil
+		},
+		"%boolval": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%boolval", args, 1); err != nil {
+				return "", err
+			}
+			return boolString(truthy(args[0])), nil
+		},
+		"%strlen": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%strlen", args, 1); err != nil {
+				return "", err
+			}
+			return strconv.Itoa(utf8.RuneCountInString(args[0])), nil
+		},
+		"%upper": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%upper", args, 1); err != nil {
+				return "", err
+			}
+			return strings.ToUpper(args[0]), nil
+		},
+		"%lower": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%lower", args, 1); err != nil {
+				return "", err
+			}
+			return strings.ToLower(args[0]), nil
+		},
+		"%string": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%string", args, 1); err != nil {
+				return "", err
+			}
+			return args[0], nil
+		},
+		"%intval": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%intval", args, 1); err != nil {
+				return "", err
+			}
+			n, ok := toInt(args[0])
+			if !ok {
+				return "", fmt.Errorf("%%intval: '%s' is not an integer", args[0])
+			}
+			return strconv.Itoa(n), nil
+		},
+		"%substr": func(e *evaluator, args []string) (string, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return "", fmt.Errorf("%%substr expects 2 or 3 arguments")
+			}
+			runes := []rune(args[0])
+			start, ok := toInt(args[1])
+			if !ok || start < 0 {
+				return "", fmt.Errorf("%%substr: invalid start '%s'", args[1])
+			}
+			if start > len(runes) {
+				return "", nil
+			}
+			end := len(runes)
+			if len(args) == 3 {
+				length, ok := toInt(args[2])
+				if !ok || length < 0 {
+					return "", fmt.Errorf("%%substr: invalid length '%s'", args[2])
+				}
+				end = min(start+length, len(runes))
+			}
+			return string(runes[start:end]), nil
+		},
+		"%strpos": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%strpos", args, 2); err != nil {
+				return "", err
+			}
+			idx := strings.Index(args[0], args[1])
+			if idx == -1 {
+				return "-1", nil
+			}
+			return strconv.Itoa(utf8.RuneCountInString(args[0][:idx])), nil
+		},
+		"%splitstr": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%splitstr", args, 2); err != nil {
+				return "", err
+			}
+			data, err := json.Marshal(strings.Split(args[0], args[1]))
+			if err != nil {
+				return "", err
+			}
+			return string(data), nil
+		},
+		"%size": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%size", args, 1); err != nil {
+				return "", err
+			}
+			if items, err := jsonArray(args[0]); err == nil {
+				return strconv.Itoa(len(items)), nil
+			}
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal([]byte(args[0]), &object); err == nil {
+				return strconv.Itoa(len(object)), nil
+			}
+			return strconv.Itoa(utf8.RuneCountInString(args[0])), nil
+		},
+		"%variable_exists": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%variable_exists", args, 1); err != nil {
+				return "", err
+			}
+			_, ok := e.f.lookup(variableName(args[0]))
+			return boolString(ok), nil
+		},
+		"%get_variable_value": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%get_variable_value", args, 1); err != nil {
+				return "", err
+			}
+			value, _ := e.f.lookup(variableName(args[0]))
+			return value, nil
+		},
+		"%function_exists": func(e *evaluator, args []string) (string, error) {
+			if err := arity("%function_exists", args, 1); err != nil {
+				return "", err
+			}
+			_, isFunction := e.p.functions[args[0]]
+			_, isBuiltin := builtins[args[0]]
+			return boolString(isFunction || isBuiltin), nil
+		},
+	}
+}
+
+// arity checks the number of arguments passed to a builtin
+func arity(name string, args []string, want int) error {
+	if len(args) != want {
+		return fmt.Errorf("%s expects %d argument(s), got %d", name, want, len(args))
+	}
+	return nil
+}
+
+// variableName adds the $ prefix to a variable name given without it
+func variableName(name string) string {
+	if strings.HasPrefix(name, "$") {
+		return name
+	}
+	return "$" + name
+}
@@ -0,0 +1,486 @@
+package preprocessor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind classifies expression tokens
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenString
+	tokenNumber
+	tokenJSON
+	tokenIdent
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+// token is a lexical element of an expression
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits an expression into tokens
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string in '%s'", expr)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: expr[i+1 : i+1+end]})
+			i += end + 2
+
+		case c == '[' || c == '{':
+			end := matchBracket(expr, i)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated JSON value in '%s'", expr)
+			}
+			tokens = append(tokens, token{kind: tokenJSON, text: expr[i : end+1]})
+			i = end + 1
+
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(expr) && expr[end] >= '0' && expr[end] <= '9' {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: expr[i:end]})
+			i = end
+
+		case (c == '$' || c == '%') && i+1 < len(expr) && isIdentStart(expr[i+1]), isIdentStart(c):
+			end := i + 1
+			for end < len(expr) && isIdentPart(expr[end]) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: expr[i:end]})
+			i = end
+
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "("})
+			i++
+
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")"})
+			i++
+
+		case c == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ","})
+			i++
+
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "+", "-", "*", "/", "%"} {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character '%c' in '%s'", c, expr)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// matchBracket returns the index of the bracket closing the JSON value at open, or -1
+func matchBracket(s string, open int) int {
+	depth := 0
+	inString := false
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// evaluator evaluates an expression in a frame using recursive descent.
+// All values are strings; arithmetic and comparisons treat integer strings as numbers.
+type evaluator struct {
+	p      *processor
+	f      *frame
+	line   int
+	tokens []token
+	pos    int
+}
+
+// eval evaluates expr in frame f
+func (p *processor) eval(expr string, line int, f *frame) (string, error) {
+	tokens, err := tokenize(strings.TrimSpace(expr))
+	if err != nil {
+		return "", err
+	}
+	if len(tokens) == 1 {
+		return "", fmt.Errorf("empty expression")
+	}
+
+	e := &evaluator{p: p, f: f, line: line, tokens: tokens}
+	value, err := e.or()
+	if err != nil {
+		return "", err
+	}
+	if e.peek().kind != tokenEOF {
+		return "", fmt.Errorf("unexpected '%s' in '%s'", e.peek().text, strings.TrimSpace(expr))
+	}
+	return value, nil
+}
+
+func (e *evaluator) peek() token {
+	return e.tokens[e.pos]
+}
+
+func (e *evaluator) next() token {
+	t := e.tokens[e.pos]
+	if t.kind != tokenEOF {
+		e.pos++
+	}
+	return t
+}
+
+// acceptOp consumes the next token if it is one of the given operators
+func (e *evaluator) acceptOp(ops ...string) (string, bool) {
+	t := e.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			e.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (e *evaluator) or() (string, error) {
+	left, err := e.and()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, ok := e.acceptOp("||"); !ok {
+			return left, nil
+		}
+		right, err := e.and()
+		if err != nil {
+			return "", err
+		}
+		left = boolString(truthy(left) || truthy(right))
+	}
+}
+
+func (e *evaluator) and() (string, error) {
+	left, err := e.comparison()
+	if err != nil {
+		return "", err
+	}
+	for {
+		if _, ok := e.acceptOp("&&"); !ok {
+			return left, nil
+		}
+		right, err := e.comparison()
+		if err != nil {
+			return "", err
+		}
+		left = boolString(truthy(left) && truthy(right))
+	}
+}
+
+func (e *evaluator) comparison() (string, error) {
+	left, err := e.additive()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := e.acceptOp("==", "!=", "<=", ">=", "<", ">")
+		if !ok {
+			return left, nil
+		}
+		right, err := e.additive()
+		if err != nil {
+			return "", err
+		}
+		left = boolString(compare(left, right, op))
+	}
+}
+
+func (e *evaluator) additive() (string, error) {
+	left, err := e.multiplicative()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := e.acceptOp("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := e.multiplicative()
+		if err != nil {
+			return "", err
+		}
+
+		l, lok := toInt(left)
+		r, rok := toInt(right)
+		switch {
+		case lok && rok && op == "+":
+			left = strconv.Itoa(l + r)
+		case lok && rok:
+			left = strconv.Itoa(l - r)
+		case op == "+":
+			left = left + right
+		default:
+			return "", fmt.Errorf("cannot subtract non-numeric values '%s' and '%s'", left, right)
+		}
+	}
+}
+
+func (e *evaluator) multiplicative() (string, error) {
+	left, err := e.unary()
+	if err != nil {
+		return "", err
+	}
+	for {
+		op, ok := e.acceptOp("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := e.unary()
+		if err != nil {
+			return "", err
+		}
+
+		l, lok := toInt(left)
+		r, rok := toInt(right)
+		if !lok || !rok {
+			return "", fmt.Errorf("operator %s requires numeric values", op)
+		}
+		if op != "*" && r == 0 {
+			return "", fmt.Errorf("division by zero")
+		}
+		switch op {
+		case "*":
+			left = strconv.Itoa(l * r)
+		case "/":
+			left = strconv.Itoa(l / r)
+		default:
+			left = strconv.Itoa(l % r)
+		}
+	}
+}
+
+func (e *evaluator) unary() (string, error) {
+	if op, ok := e.acceptOp("!", "-"); ok {
+		value, err := e.unary()
+		if err != nil {
+			return "", err
+		}
+		if op == "!" {
+			return boolString(!truthy(value)), nil
+		}
+		n, ok := toInt(value)
+		if !ok {
+			return "", fmt.Errorf("cannot negate non-numeric value '%s'", value)
+		}
+		return strconv.Itoa(-n), nil
+	}
+	return e.primary()
+}
+
+func (e *evaluator) primary() (string, error) {
+	t := e.next()
+	switch t.kind {
+	case tokenString, tokenNumber, tokenJSON:
+		return t.text, nil
+
+	case tokenLParen:
+		value, err := e.or()
+		if err != nil {
+			return "", err
+		}
+		if e.next().kind != tokenRParen {
+			return "", fmt.Errorf("missing closing parenthesis")
+		}
+		return value, nil
+
+	case tokenIdent:
+		if e.peek().kind == tokenLParen {
+			args, err := e.arguments()
+			if err != nil {
+				return "", err
+			}
+			return e.call(t.text, args)
+		}
+
+		switch {
+		case strings.HasPrefix(t.text, "$"):
+			value, ok := e.f.lookup(t.text)
+			if !ok {
+				return "", fmt.Errorf("undefined variable %s", t.text)
+			}
+			return value, nil
+		case strings.HasPrefix(t.text, "%"):
+			return "", fmt.Errorf("builtin %s must be called", t.text)
+		default:
+			// Bare words are legacy macros or plain text
+			if m, ok := e.p.defines[t.text]; ok && len(m.params) == 0 {
+				return m.body, nil
+			}
+			return t.text, nil
+		}
+
+	case tokenEOF:
+		return "", fmt.Errorf("unexpected end of expression")
+
+	default:
+		return "", fmt.Errorf("unexpected '%s'", t.text)
+	}
+}
+
+// arguments reads a parenthesized argument list and returns the source text of
+// each argument, so that callables can evaluate them in the caller frame
+func (e *evaluator) arguments() ([]string, error) {
+	e.next() // (
+
+	var args []string
+	var current []string
+	depth := 0
+	for {
+		t := e.next()
+		switch {
+		case t.kind == tokenEOF:
+			return nil, fmt.Errorf("missing closing parenthesis in call")
+		case t.kind == tokenLParen:
+			depth++
+		case t.kind == tokenRParen && depth > 0:
+			depth--
+		case t.kind == tokenRParen:
+			if len(current) > 0 || len(args) > 0 {
+				args = append(args, strings.Join(current, " "))
+			}
+			return args, nil
+		case t.kind == tokenComma && depth == 0:
+			args = append(args, strings.Join(current, " "))
+			current = nil
+			continue
+		}
+		current = append(current, tokenSource(t))
+	}
+}
+
+// tokenSource returns source text that tokenizes back to t
+func tokenSource(t token) string {
+	if t.kind == tokenString {
+		if strings.Contains(t.text, `"`) {
+			return "'" + t.text + "'"
+		}
+		return `"` + t.text + `"`
+	}
+	return t.text
+}
+
+// call invokes a user function or builtin with unevaluated argument expressions
+func (e *evaluator) call(name string, args []string) (string, error) {
+	if fn, ok := e.p.functions[name]; ok {
+		return e.p.callFunction(fn, strings.Join(args, ", "), e.line, e.f)
+	}
+
+	builtin, ok := builtins[name]
+	if !ok {
+		return "", fmt.Errorf("unknown function %s", name)
+	}
+
+	values := make([]string, len(args))
+	for i, arg := range args {
+		value, err := e.p.eval(arg, e.line, e.f)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+	return builtin(e, values)
+}
+
+// compare compares two values numerically when both are integers, otherwise as strings
+func compare(left, right, op string) bool {
+	l, lok := toInt(left)
+	r, rok := toInt(right)
+
+	var cmp int
+	if lok && rok {
+		cmp = l - r
+	} else {
+		cmp = strings.Compare(left, right)
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return cmp >= 0
+	}
+}
+
+// toInt parses an integer value
+func toInt(value string) (int, bool) {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	return n, err == nil
+}
+
+// truthy reports whether a value counts as true in a condition
+func truthy(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "0", "false":
+		return false
+	default:
+		return true
+	}
+}
+
+// boolString converts a boolean to the preprocessor's integer representation
+func boolString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
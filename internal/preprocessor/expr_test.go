@@ -0,0 +1,66 @@
+package preprocessor
+
+import "testing"
+
+func TestEval(t *testing.T) {
+	p := &processor{
+		global:     &frame{vars: map[string]string{"$n": "5", "$s": "Idle"}},
+		defines:    map[string]macro{"NAME": {body: "macro"}},
+		procedures: map[string]*callable{},
+		functions:  map[string]*callable{},
+		result:     &Result{},
+	}
+
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{expr: `1 + 2 * 3`, want: "7"},
+		{expr: `(1 + 2) * 3`, want: "9"},
+		{expr: `-$n + 1`, want: "-4"},
+		{expr: `$n % 3`, want: "2"},
+		{expr: `$s + "State"`, want: "IdleState"},
+		{expr: `$n > 3 && $s == "Idle"`, want: "1"},
+		{expr: `$n < 3 || !%true()`, want: "0"},
+		{expr: `10 > 9`, want: "1"},
+		{expr: `"b" > "a"`, want: "1"},
+		{expr: `NAME`, want: "macro"},
+		{expr: `%strlen($s)`, want: "4"},
+		{expr: `%substr("abcdef", 1, 3)`, want: "bcd"},
+		{expr: `%strpos("abc", "c")`, want: "2"},
+		{expr: `%size(["a", "b"])`, want: "2"},
+		{expr: `%size({"a": 1})`, want: "1"},
+		{expr: `%variable_exists("n")`, want: "1"},
+		{expr: `%function_exists("%upper")`, want: "1"},
+		{expr: `%upper(%lower("MiXeD"))`, want: "MIXED"},
+		{expr: `$missing`, wantErr: true},
+		{expr: `%nope()`, wantErr: true},
+		{expr: `1 / 0`, wantErr: true},
+		{expr: `"a" - 1`, wantErr: true},
+		{expr: `(1 + 2`, wantErr: true},
+		{expr: `"open`, wantErr: true},
+		{expr: `1 2`, wantErr: true},
+		{expr: ``, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := p.eval(tt.expr, 1, p.global)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eval(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("eval(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruthy(t *testing.T) {
+	for value, want := range map[string]bool{"": false, "0": false, "false": false, "1": true, "Idle": true} {
+		if got := truthy(value); got != want {
+			t.Errorf("truthy(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,806 @@
+// Package preprocessor expands PlantUML preprocessor directives such as
+// variables, conditionals, procedures, functions and loops. The expanded text
+// comes with a source map that ties every output line back to the line of the
+// original content it was produced from.
+package preprocessor
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+const (
+	// maxCallDepth limits procedure and function recursion
+	maxCallDepth = 64
+
+	// maxLoopIterations limits the number of iterations of a single !while loop
+	maxLoopIterations = 1000
+)
+
+// Diagnostic codes reported by Process
+const (
+	CodeError        = "PREPROCESSOR_ERROR"     // an expression or directive could not be evaluated
+	CodeUnterminated = "UNTERMINATED_DIRECTIVE" // a block directive has no closing directive
+	CodeUnmatched    = "UNMATCHED_DIRECTIVE"    // a closing directive has no opening directive
+)
+
+// procedureCallRegex matches a line that consists of a single procedure call
+var procedureCallRegex = regexp.MustCompile(`^(\$?[A-Za-z_][A-Za-z0-9_]*)\s*\((.*)\)$`)
+
+// Diagnostic is a problem found while expanding directives
+type Diagnostic struct {
+	Code    string
+	Message string
+	Line    int // 1-based line in the original content
+}
+
+// Result holds the expanded content and its source map
+type Result struct {
+	Content     string
+	Diagnostics []Diagnostic
+	SourceMap   models.SourceMap
+
+	lines   []string
+	changed bool
+}
+
+// LineCount returns the number of expanded lines
+func (r *Result) LineCount() int {
+	return len(r.lines)
+}
+
+// Changed reports whether expansion altered the content in any way
+func (r *Result) Changed() bool {
+	return r.changed
+}
+
+// srcLine is a line of directive source together with its original line number
+type srcLine struct {
+	text string
+	line int
+}
+
+// callable is a user-defined !procedure or !function
+type callable struct {
+	name   string
+	params []param
+	body   []srcLine
+}
+
+// param is a parameter of a callable with an optional default expression
+type param struct {
+	name       string
+	def        string
+	hasDefault bool
+}
+
+// macro is a legacy !define macro, optionally with parameters
+type macro struct {
+	params []string
+	body   string
+}
+
+// frame is a variable scope and the output context of the lines executed in it
+type frame struct {
+	vars     map[string]string
+	parent   *frame
+	origin   int  // when > 0, emitted lines map to this original line
+	expanded bool // emitted lines are produced by a loop or procedure
+	isFunc   bool // function bodies produce no output
+}
+
+// lookup finds a variable in the frame or its parents
+func (f *frame) lookup(name string) (string, bool) {
+	for cur := f; cur != nil; cur = cur.parent {
+		if value, ok := cur.vars[name]; ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// root returns the global frame
+func (f *frame) root() *frame {
+	cur := f
+	for cur.parent != nil {
+		cur = cur.parent
+	}
+	return cur
+}
+
+// processor holds the state of a single Process call
+type processor struct {
+	global     *frame
+	defines    map[string]macro
+	procedures map[string]*callable
+	functions  map[string]*callable
+	depth      int
+	result     *Result
+}
+
+// Process expands the preprocessor directives in content. Directives it does
+// not evaluate, such as !include, !theme or !pragma, are kept in the output.
+func Process(content string) *Result {
+	rawLines := strings.Split(content, "\n")
+	lines := make([]srcLine, len(rawLines))
+	for i, text := range rawLines {
+		lines[i] = srcLine{text: strings.TrimSuffix(text, "\r"), line: i + 1}
+	}
+
+	p := &processor{
+		global:     &frame{vars: make(map[string]string)},
+		defines:    make(map[string]macro),
+		procedures: make(map[string]*callable),
+		functions:  make(map[string]*callable),
+		result:     &Result{},
+	}
+
+	p.exec(lines, p.global)
+
+	r := p.result
+	if len(r.lines) == 0 {
+		// Keep at least one line so that positions remain addressable
+		r.lines = []string{""}
+		r.SourceMap.Add(1, false)
+	}
+	if len(r.lines) != len(lines) {
+		r.changed = true
+	}
+	r.Content = strings.Join(r.lines, "\n")
+	return r
+}
+
+// emit appends an output line
+func (p *processor) emit(text string, source int, verbatim bool) {
+	p.result.lines = append(p.result.lines, text)
+	p.result.SourceMap.Add(source, verbatim)
+	if !verbatim {
+		p.result.changed = true
+	}
+}
+
+// diagnose records a diagnostic
+func (p *processor) diagnose(code string, line int, format string, args ...any) {
+	p.result.Diagnostics = append(p.result.Diagnostics, Diagnostic{
+		Code:    code,
+		Message: fmt.Sprintf(format, args...),
+		Line:    line,
+	})
+}
+
+// directive splits a trimmed line into its directive keyword and arguments.
+// Variable assignments return the keyword "assign". Non-directive lines return "".
+func directive(trimmed string) (string, string) {
+	if !strings.HasPrefix(trimmed, "!") {
+		return "", ""
+	}
+
+	body := trimmed[1:]
+	if strings.HasPrefix(body, "$") {
+		return "assign", body
+	}
+
+	keyword, rest := splitWord(body)
+	if keyword == "unquoted" || keyword == "final" {
+		keyword, rest = splitWord(rest)
+	}
+	return strings.ToLower(keyword), rest
+}
+
+// splitWord splits off the leading run of letters and underscores
+func splitWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	end := 0
+	for end < len(s) && (isLetter(s[end]) || s[end] == '_') {
+		end++
+	}
+	return s[:end], strings.TrimSpace(s[end:])
+}
+
+// exec runs lines in frame f. It reports whether a !return was executed and its value.
+func (p *processor) exec(lines []srcLine, f *frame) (bool, string) {
+	for i := 0; i < len(lines); i++ {
+		ln := lines[i]
+		keyword, rest := directive(strings.TrimSpace(ln.text))
+
+		switch keyword {
+		case "":
+			if !f.isFunc {
+				p.emitText(ln, f)
+			}
+
+		case "assign", "local", "global":
+			p.assign(keyword, rest, ln.line, f)
+
+		case "if", "ifdef", "ifndef":
+			branches, end := p.matchIf(lines, i)
+			for _, b := range branches {
+				if b.kind != "else" && !p.condition(b.kind, b.expr, lines[b.start].line, f) {
+					continue
+				}
+				if returned, value := p.exec(lines[b.start+1:b.end], f); returned {
+					return true, value
+				}
+				break
+			}
+			i = end
+
+		case "elseif", "else", "endif", "endfor", "endwhile", "endprocedure", "endfunction", "enddefinelong":
+			p.diagnose(CodeUnmatched, ln.line, "!%s without a matching opening directive", keyword)
+
+		case "foreach":
+			end := p.matchEnd(lines, i, "foreach", "endfor")
+			if returned, value := p.foreach(rest, lines[i+1:end], ln.line, f); returned {
+				return true, value
+			}
+			i = end
+
+		case "while":
+			end := p.matchEnd(lines, i, "while", "endwhile")
+			if returned, value := p.while(rest, lines[i+1:end], ln.line, f); returned {
+				return true, value
+			}
+			i = end
+
+		case "procedure":
+			end := p.matchEnd(lines, i, "procedure", "endprocedure")
+			p.define(p.procedures, rest, lines[i+1:end], ln.line)
+			i = end
+
+		case "function":
+			// One-line form: !function $f($a) !return expr
+			if idx := strings.Index(rest, "!return"); idx != -1 {
+				body := []srcLine{{text: strings.TrimSpace(rest[idx:]), line: ln.line}}
+				p.define(p.functions, rest[:idx], body, ln.line)
+				continue
+			}
+			end := p.matchEnd(lines, i, "function", "endfunction")
+			p.define(p.functions, rest, lines[i+1:end], ln.line)
+			i = end
+
+		case "return":
+			if !f.isFunc {
+				p.diagnose(CodeError, ln.line, "!return outside of a function")
+				continue
+			}
+			value, err := p.eval(rest, ln.line, f)
+			if err != nil {
+				p.diagnose(CodeError, ln.line, "%v", err)
+			}
+			return true, value
+
+		case "define":
+			p.defineMacro(rest)
+
+		case "undef":
+			name, _ := splitWord(rest)
+			delete(p.defines, name)
+
+		case "assert":
+			expr, message, _ := strings.Cut(rest, ":")
+			value, err := p.eval(expr, ln.line, f)
+			if err != nil {
+				p.diagnose(CodeError, ln.line, "%v", err)
+			} else if !truthy(value) {
+				if message = strings.TrimSpace(message); message == "" {
+					message = "assertion failed: " + strings.TrimSpace(expr)
+				}
+				p.diagnose(CodeError, ln.line, "%s", message)
+			}
+
+		case "log", "dump_memory":
+			// Diagnostic output only; nothing to expand
+
+		default:
+			// Directives handled by PlantUML itself (!include, !theme, !pragma, ...)
+			if !f.isFunc {
+				p.emitText(ln, f)
+			}
+		}
+	}
+	return false, ""
+}
+
+// emitText substitutes variables, functions and macros in a text line and emits
+// it, expanding the line instead when it is a procedure call
+func (p *processor) emitText(ln srcLine, f *frame) {
+	origin := ln.line
+	if f.origin > 0 {
+		origin = f.origin
+	}
+
+	trimmed := strings.TrimSpace(ln.text)
+	if matches := procedureCallRegex.FindStringSubmatch(trimmed); matches != nil {
+		if proc, ok := p.procedures[matches[1]]; ok {
+			p.callProcedure(proc, matches[2], ln.line, origin, f)
+			return
+		}
+	}
+
+	text, err := p.substitute(ln.text, ln.line, f)
+	if err != nil {
+		p.diagnose(CodeError, ln.line, "%v", err)
+		text = ln.text
+	}
+
+	p.emit(text, origin, !f.expanded && text == ln.text)
+}
+
+// assign handles !$var = expr, !$var ?= expr, !local and !global assignments
+func (p *processor) assign(keyword, rest string, line int, f *frame) {
+	name, expr, ok := strings.Cut(rest, "=")
+	if !ok {
+		p.diagnose(CodeError, line, "invalid assignment '%s'", rest)
+		return
+	}
+
+	name = strings.TrimSpace(name)
+	conditional := strings.HasSuffix(name, "?")
+	name = strings.TrimSpace(strings.TrimSuffix(name, "?"))
+	if !strings.HasPrefix(name, "$") {
+		p.diagnose(CodeError, line, "invalid variable name '%s'", name)
+		return
+	}
+
+	if conditional {
+		if _, exists := f.lookup(name); exists {
+			return
+		}
+	}
+
+	value, err := p.eval(expr, line, f)
+	if err != nil {
+		p.diagnose(CodeError, line, "%v", err)
+		return
+	}
+
+	target := f
+	switch {
+	case keyword == "global":
+		target = f.root()
+	case keyword == "local":
+		// keep the current frame
+	default:
+		if _, local := f.vars[name]; !local {
+			if _, global := f.root().vars[name]; global {
+				target = f.root()
+			}
+		}
+	}
+	target.vars[name] = value
+}
+
+// ifBranch is one branch of an !if chain; lines[start] is its directive line
+// and lines[start+1:end] its body
+type ifBranch struct {
+	kind  string
+	expr  string
+	start int
+	end   int
+}
+
+// matchIf collects the branches of the !if chain starting at lines[i] and
+// returns them with the index of the closing !endif
+func (p *processor) matchIf(lines []srcLine, i int) ([]ifBranch, int) {
+	keyword, rest := directive(strings.TrimSpace(lines[i].text))
+	branches := []ifBranch{{kind: keyword, expr: rest, start: i}}
+
+	depth := 0
+	for j := i + 1; j < len(lines); j++ {
+		kw, args := directive(strings.TrimSpace(lines[j].text))
+		switch kw {
+		case "if", "ifdef", "ifndef":
+			depth++
+		case "elseif", "else":
+			if depth == 0 {
+				branches[len(branches)-1].end = j
+				branches = append(branches, ifBranch{kind: kw, expr: args, start: j})
+			}
+		case "endif":
+			if depth == 0 {
+				branches[len(branches)-1].end = j
+				return branches, j
+			}
+			depth--
+		}
+	}
+
+	p.diagnose(CodeUnterminated, lines[i].line, "!%s without a matching !endif", keyword)
+	branches[len(branches)-1].end = len(lines)
+	return branches, len(lines)
+}
+
+// matchEnd returns the index of the directive closing the block opened at lines[i]
+func (p *processor) matchEnd(lines []srcLine, i int, open, close string) int {
+	depth := 0
+	for j := i + 1; j < len(lines); j++ {
+		switch kw, _ := directive(strings.TrimSpace(lines[j].text)); kw {
+		case open:
+			depth++
+		case close:
+			if depth == 0 {
+				return j
+			}
+			depth--
+		}
+	}
+
+	p.diagnose(CodeUnterminated, lines[i].line, "!%s without a matching !%s", open, close)
+	return len(lines)
+}
+
+// condition evaluates the condition of an !if, !elseif, !ifdef or !ifndef branch
+func (p *processor) condition(kind, expr string, line int, f *frame) bool {
+	switch kind {
+	case "ifdef", "ifndef":
+		name := strings.TrimSpace(expr)
+		_, isDefine := p.defines[name]
+		_, isVar := f.lookup(name)
+		defined := isDefine || isVar
+		return defined == (kind == "ifdef")
+	default:
+		value, err := p.eval(expr, line, f)
+		if err != nil {
+			p.diagnose(CodeError, line, "%v", err)
+			return false
+		}
+		return truthy(value)
+	}
+}
+
+// foreach runs body once for every element of a JSON array
+func (p *processor) foreach(rest string, body []srcLine, line int, f *frame) (bool, string) {
+	name, expr, ok := strings.Cut(rest, " in ")
+	name = strings.TrimSpace(name)
+	if !ok || !strings.HasPrefix(name, "$") {
+		p.diagnose(CodeError, line, "invalid !foreach '%s'", rest)
+		return false, ""
+	}
+
+	value, err := p.eval(expr, line, f)
+	if err != nil {
+		p.diagnose(CodeError, line, "%v", err)
+		return false, ""
+	}
+	items, err := jsonArray(value)
+	if err != nil {
+		p.diagnose(CodeError, line, "!foreach requires a JSON array: %v", err)
+		return false, ""
+	}
+
+	loop := *f
+	loop.expanded = true
+	for _, item := range items {
+		f.vars[name] = item
+		if returned, value := p.exec(body, &loop); returned {
+			return true, value
+		}
+	}
+	return false, ""
+}
+
+// while runs body as long as the condition holds
+func (p *processor) while(expr string, body []srcLine, line int, f *frame) (bool, string) {
+	loop := *f
+	loop.expanded = true
+	for iteration := 0; ; iteration++ {
+		if iteration == maxLoopIterations {
+			p.diagnose(CodeError, line, "!while loop exceeded %d iterations", maxLoopIterations)
+			return false, ""
+		}
+		value, err := p.eval(expr, line, f)
+		if err != nil {
+			p.diagnose(CodeError, line, "%v", err)
+			return false, ""
+		}
+		if !truthy(value) {
+			return false, ""
+		}
+		if returned, value := p.exec(body, &loop); returned {
+			return true, value
+		}
+	}
+}
+
+// define registers a procedure or function from its signature and body
+func (p *processor) define(table map[string]*callable, signature string, body []srcLine, line int) {
+	signature = strings.TrimSpace(signature)
+	open := strings.Index(signature, "(")
+	if open == -1 || !strings.HasSuffix(signature, ")") {
+		p.diagnose(CodeError, line, "invalid signature '%s'", signature)
+		return
+	}
+
+	c := &callable{name: strings.TrimSpace(signature[:open]), body: body}
+	for _, arg := range splitArgs(signature[open+1 : len(signature)-1]) {
+		name, def, hasDefault := strings.Cut(arg, "=")
+		c.params = append(c.params, param{
+			name:       strings.TrimSpace(name),
+			def:        strings.TrimSpace(def),
+			hasDefault: hasDefault,
+		})
+	}
+	table[c.name] = c
+}
+
+// defineMacro registers a legacy !define macro
+func (p *processor) defineMacro(rest string) {
+	name, body := splitWord(rest)
+	if name == "" {
+		return
+	}
+
+	m := macro{body: body}
+	if strings.HasPrefix(body, "(") {
+		if end := strings.Index(body, ")"); end != -1 {
+			for _, arg := range splitArgs(body[1:end]) {
+				m.params = append(m.params, strings.TrimSpace(arg))
+			}
+			m.body = strings.TrimSpace(body[end+1:])
+		}
+	}
+	p.defines[name] = m
+}
+
+// bind evaluates call arguments in the caller frame and binds them to parameters
+func (p *processor) bind(c *callable, args string, line int, caller *frame) (map[string]string, error) {
+	values := splitArgs(args)
+	if len(values) > len(c.params) {
+		return nil, fmt.Errorf("too many arguments in call to %s", c.name)
+	}
+
+	vars := make(map[string]string, len(c.params))
+	for i, prm := range c.params {
+		var (
+			value string
+			err   error
+		)
+		switch {
+		case i < len(values):
+			value, err = p.eval(values[i], line, caller)
+		case prm.hasDefault:
+			value, err = p.eval(prm.def, line, p.global)
+		default:
+			return nil, fmt.Errorf("missing argument %s in call to %s", prm.name, c.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		vars[prm.name] = value
+	}
+	return vars, nil
+}
+
+// callProcedure expands a procedure body; its output maps to the call's origin line
+func (p *processor) callProcedure(c *callable, args string, line, origin int, caller *frame) {
+	if p.depth >= maxCallDepth {
+		p.diagnose(CodeError, line, "maximum call depth exceeded in %s", c.name)
+		return
+	}
+
+	vars, err := p.bind(c, args, line, caller)
+	if err != nil {
+		p.diagnose(CodeError, line, "%v", err)
+		return
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	p.exec(c.body, &frame{vars: vars, parent: p.global, origin: origin, expanded: true})
+	p.result.changed = true
+}
+
+// callFunction runs a function body and returns the value of its !return
+func (p *processor) callFunction(c *callable, args string, line int, caller *frame) (string, error) {
+	if p.depth >= maxCallDepth {
+		return "", fmt.Errorf("maximum call depth exceeded in %s", c.name)
+	}
+
+	vars, err := p.bind(c, args, line, caller)
+	if err != nil {
+		return "", err
+	}
+
+	p.depth++
+	defer func() { p.depth-- }()
+	_, value := p.exec(c.body, &frame{vars: vars, parent: p.global, isFunc: true})
+	return value, nil
+}
+
+// substitute replaces variables, function calls, builtin calls and macros in text
+func (p *processor) substitute(text string, line int, f *frame) (string, error) {
+	var builder strings.Builder
+
+	for i := 0; i < len(text); {
+		c := text[i]
+		if (c != '$' && c != '%') || i+1 >= len(text) || !isIdentStart(text[i+1]) {
+			builder.WriteByte(c)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && isIdentPart(text[end]) {
+			end++
+		}
+		name := text[i:end]
+
+		// Calls: $function(...) or %builtin(...)
+		if end < len(text) && text[end] == '(' {
+			close := matchParen(text, end)
+			if close != -1 {
+				if fn, ok := p.functions[name]; ok {
+					value, err := p.callFunction(fn, text[end+1:close], line, f)
+					if err != nil {
+						return "", err
+					}
+					builder.WriteString(value)
+					i = close + 1
+					continue
+				}
+				if _, ok := builtins[name]; ok {
+					value, err := p.eval(text[i:close+1], line, f)
+					if err != nil {
+						return "", err
+					}
+					builder.WriteString(value)
+					i = close + 1
+					continue
+				}
+			}
+		}
+
+		if value, ok := f.lookup(name); ok && c == '$' {
+			builder.WriteString(value)
+		} else {
+			builder.WriteString(name)
+		}
+		i = end
+	}
+
+	return p.expandMacros(builder.String()), nil
+}
+
+// expandMacros replaces legacy !define macros in text, longest names first
+func (p *processor) expandMacros(text string) string {
+	if len(p.defines) == 0 {
+		return text
+	}
+
+	names := make([]string, 0, len(p.defines))
+	for name := range p.defines {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if len(names[i]) != len(names[j]) {
+			return len(names[i]) > len(names[j])
+		}
+		return names[i] < names[j]
+	})
+
+	for _, name := range names {
+		m := p.defines[name]
+		if len(m.params) == 0 {
+			re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+			text = re.ReplaceAllLiteralString(text, m.body)
+			continue
+		}
+
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\s*\(`)
+		for {
+			loc := re.FindStringIndex(text)
+			if loc == nil {
+				break
+			}
+			close := matchParen(text, loc[1]-1)
+			if close == -1 {
+				break
+			}
+			body := m.body
+			args := splitArgs(text[loc[1]:close])
+			for i, prm := range m.params {
+				value := ""
+				if i < len(args) {
+					value = strings.TrimSpace(args[i])
+				}
+				body = regexp.MustCompile(`\b`+regexp.QuoteMeta(prm)+`\b`).ReplaceAllLiteralString(body, value)
+			}
+			text = text[:loc[0]] + body + text[close+1:]
+		}
+	}
+	return text
+}
+
+// jsonArray decodes a JSON array into its elements. String elements are
+// returned as-is, other elements in their JSON form.
+func jsonArray(value string) ([]string, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(value), &raw); err != nil {
+		return nil, err
+	}
+
+	items := make([]string, len(raw))
+	for i, item := range raw {
+		var s string
+		if err := json.Unmarshal(item, &s); err == nil {
+			items[i] = s
+		} else {
+			items[i] = string(item)
+		}
+	}
+	return items, nil
+}
+
+// splitArgs splits a comma-separated argument list, ignoring commas inside
+// quotes, parentheses and brackets. An empty list returns no arguments.
+func splitArgs(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+
+	var args []string
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[' || c == '{':
+			depth++
+		case c == ')' || c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(args, strings.TrimSpace(s[start:]))
+}
+
+// matchParen returns the index of the parenthesis closing the one at open, or -1
+func matchParen(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentStart(c byte) bool {
+	return isLetter(c) || c == '_'
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9'
+}
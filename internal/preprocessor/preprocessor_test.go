@@ -0,0 +1,188 @@
+package preprocessor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        string
+		wantSources []int
+		wantCodes   []string
+	}{
+		{
+			name:        "no directives is identity",
+			content:     "@startuml\n[*] --> Idle\n@enduml",
+			want:        "@startuml\n[*] --> Idle\n@enduml",
+			wantSources: []int{1, 2, 3},
+		},
+		{
+			name:        "variables",
+			content:     "@startuml\n!$start = \"Idle\"\n[*] --> $start\n@enduml",
+			want:        "@startuml\n[*] --> Idle\n@enduml",
+			wantSources: []int{1, 3, 4},
+		},
+		{
+			name:        "conditional assignment keeps first value",
+			content:     "!$a = \"x\"\n!$a ?= \"y\"\n$a",
+			want:        "x",
+			wantSources: []int{3},
+		},
+		{
+			name:        "if elseif else",
+			content:     "!$mode = 2\n!if $mode == 1\nOne\n!elseif $mode == 2\nTwo\n!else\nOther\n!endif",
+			want:        "Two",
+			wantSources: []int{5},
+		},
+		{
+			name:        "nested if",
+			content:     "!if %true()\n!if %false()\nA\n!else\nB\n!endif\n!endif",
+			want:        "B",
+			wantSources: []int{5},
+		},
+		{
+			name:        "ifdef and ifndef",
+			content:     "!define DEBUG\n!ifdef DEBUG\nDebug\n!endif\n!ifndef DEBUG\nRelease\n!endif",
+			want:        "Debug",
+			wantSources: []int{3},
+		},
+		{
+			name:        "procedure",
+			content:     "!procedure $edge($from, $to=\"Done\")\n$from --> $to\n!endprocedure\n$edge(\"A\", \"B\")\n$edge(\"B\")",
+			want:        "A --> B\nB --> Done",
+			wantSources: []int{4, 5},
+		},
+		{
+			name:        "function",
+			content:     "!function $double($n)\n!return $n * 2\n!endfunction\nState$double(21)",
+			want:        "State42",
+			wantSources: []int{4},
+		},
+		{
+			name:        "one-line function",
+			content:     "!function $name($s) !return %upper($s)\n$name(\"idle\")",
+			want:        "IDLE",
+			wantSources: []int{2},
+		},
+		{
+			name:        "foreach",
+			content:     "!foreach $s in [\"A\", \"B\"]\n[*] --> $s\n!endfor",
+			want:        "[*] --> A\n[*] --> B",
+			wantSources: []int{2, 2},
+		},
+		{
+			name:        "foreach over splitstr",
+			content:     "!foreach $s in %splitstr(\"A,B\", \",\")\n$s\n!endfor",
+			want:        "A\nB",
+			wantSources: []int{2, 2},
+		},
+		{
+			name:        "while",
+			content:     "!$i = 0\n!while $i < 2\nS$i\n!$i = $i + 1\n!endwhile",
+			want:        "S0\nS1",
+			wantSources: []int{3, 3},
+		},
+		{
+			name:        "legacy define macros",
+			content:     "!define START Idle\n!define EDGE(a,b) a --> b\n[*] --> START\nEDGE(START, Done)",
+			want:        "[*] --> Idle\nIdle --> Done",
+			wantSources: []int{3, 4},
+		},
+		{
+			name:        "unhandled directives are kept",
+			content:     "@startuml\n!include products/a-1.0.0/a-1.0.0.puml\n!theme plain\n@enduml",
+			want:        "@startuml\n!include products/a-1.0.0/a-1.0.0.puml\n!theme plain\n@enduml",
+			wantSources: []int{1, 2, 3, 4},
+		},
+		{
+			name:        "unterminated if",
+			content:     "!if %true()\nA",
+			want:        "A",
+			wantSources: []int{2},
+			wantCodes:   []string{CodeUnterminated},
+		},
+		{
+			name:        "unmatched endif",
+			content:     "A\n!endif",
+			want:        "A",
+			wantSources: []int{1},
+			wantCodes:   []string{CodeUnmatched},
+		},
+		{
+			name:        "undefined variable in condition",
+			content:     "!if $missing == 1\nA\n!endif\nB",
+			want:        "B",
+			wantSources: []int{4},
+			wantCodes:   []string{CodeError},
+		},
+		{
+			name:        "failed assertion",
+			content:     "!assert 1 == 2 : numbers differ\nA",
+			want:        "A",
+			wantSources: []int{2},
+			wantCodes:   []string{CodeError},
+		},
+		{
+			name:        "runaway recursion",
+			content:     "!procedure $loop()\n$loop()\n!endprocedure\n$loop()",
+			want:        "",
+			wantSources: []int{1},
+			wantCodes:   []string{CodeError},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Process(tt.content)
+
+			if result.Content != tt.want {
+				t.Errorf("Content = %q, want %q", result.Content, tt.want)
+			}
+
+			var sources []int
+			for line := 1; line <= result.LineCount(); line++ {
+				sources = append(sources, result.SourceMap.SourceLine(line))
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("Sources = %v, want %v", sources, tt.wantSources)
+			}
+
+			var codes []string
+			for _, d := range result.Diagnostics {
+				codes = append(codes, d.Code)
+			}
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Errorf("Diagnostics = %+v, want codes %v", result.Diagnostics, tt.wantCodes)
+			}
+		})
+	}
+}
+
+func TestProcess_Verbatim(t *testing.T) {
+	result := Process("@startuml\n!$s = \"Idle\"\n[*] --> $s\nIdle --> Done\r\n@enduml")
+
+	if !result.Changed() {
+		t.Error("Changed() should be true when directives were expanded")
+	}
+
+	want := []bool{true, false, true, true}
+	for i, verbatim := range want {
+		if got := result.SourceMap.IsVerbatim(i + 1); got != verbatim {
+			t.Errorf("IsVerbatim(%d) = %v, want %v", i+1, got, verbatim)
+		}
+	}
+
+	if Process("@startuml\nA\n@enduml").Changed() {
+		t.Error("Changed() should be false for content without directives")
+	}
+}
+
+func TestProcess_LoopLinesAreNotVerbatim(t *testing.T) {
+	result := Process("!foreach $s in [\"A\"]\nFixed\n!endfor")
+	if result.SourceMap.IsVerbatim(1) {
+		t.Error("Lines emitted by loops should not be verbatim")
+	}
+}
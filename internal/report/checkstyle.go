@@ -0,0 +1,64 @@
+package report
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// checkstyleVersion is the Checkstyle format version written to the root element
+const checkstyleVersion = "4.3"
+
+// CheckstyleReport is the root element of a Checkstyle XML report
+type CheckstyleReport struct {
+	XMLName xml.Name         `xml:"checkstyle"`
+	Version string           `xml:"version,attr"`
+	Files   []CheckstyleFile `xml:"file"`
+}
+
+// CheckstyleFile lists the findings of one state-machine diagram file
+type CheckstyleFile struct {
+	Name   string            `xml:"name,attr"`
+	Errors []CheckstyleError `xml:"error"`
+}
+
+// CheckstyleError is a single finding
+type CheckstyleError struct {
+	Line     int    `xml:"line,attr"`
+	Column   int    `xml:"column,attr,omitempty"`
+	Severity string `xml:"severity,attr"`
+	Message  string `xml:"message,attr"`
+	Source   string `xml:"source,attr"`
+}
+
+// NewCheckstyleReport builds a Checkstyle report with one file element per
+// state-machine diagram. Diagrams without findings are listed with no errors.
+func NewCheckstyleReport(pm *models.PathManager, results []DiagramResult) *CheckstyleReport {
+	report := &CheckstyleReport{Version: checkstyleVersion}
+
+	for _, dr := range results {
+		if dr.Diagram == nil {
+			continue
+		}
+
+		file := CheckstyleFile{Name: diagramPath(pm, dr.Diagram)}
+		for _, f := range findings(dr.Result) {
+			file.Errors = append(file.Errors, CheckstyleError{
+				Line:     f.Span.StartLine,
+				Column:   f.Span.StartColumn,
+				Severity: f.Level,
+				Message:  f.Message,
+				Source:   toolName + "." + f.Code,
+			})
+		}
+		report.Files = append(report.Files, file)
+	}
+
+	return report
+}
+
+// WriteCheckstyle writes the results to w as an indented Checkstyle XML document
+func WriteCheckstyle(w io.Writer, pm *models.PathManager, results []DiagramResult) error {
+	return writeXML(w, "WriteCheckstyle", NewCheckstyleReport(pm, results))
+}
@@ -0,0 +1,56 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestNewCheckstyleReport(t *testing.T) {
+	clean := DiagramResult{
+		Diagram: &models.StateMachineDiagram{Name: "clean", Version: "2.0.0", Location: models.LocationFileProducts},
+		Result:  &models.ValidationResult{IsValid: true},
+	}
+
+	report := NewCheckstyleReport(models.NewPathManager("diagrams"), []DiagramResult{newTestResult(), clean})
+
+	if len(report.Files) != 2 {
+		t.Fatalf("Files length = %d, want 2", len(report.Files))
+	}
+
+	file := report.Files[0]
+	if file.Name != "diagrams/in-progress/puml/user-auth-1.0.0.puml" {
+		t.Errorf("File name = %s", file.Name)
+	}
+	if len(file.Errors) != 3 {
+		t.Fatalf("Errors length = %d, want 3", len(file.Errors))
+	}
+
+	want := CheckstyleError{Line: 2, Column: 10, Severity: "warning", Message: "State name should follow naming conventions", Source: toolName + ".INVALID_STATE_NAME"}
+	if file.Errors[1] != want {
+		t.Errorf("Errors[1] = %+v, want %+v", file.Errors[1], want)
+	}
+	if file.Errors[0].Severity != "error" {
+		t.Errorf("Errors[0].Severity = %s, want error", file.Errors[0].Severity)
+	}
+	if len(report.Files[1].Errors) != 0 {
+		t.Errorf("Clean file should have no errors, got %d", len(report.Files[1].Errors))
+	}
+}
+
+func TestWriteCheckstyle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCheckstyle(&buf, models.NewPathManager("diagrams"), []DiagramResult{newTestResult()}); err != nil {
+		t.Fatalf("WriteCheckstyle() error = %v", err)
+	}
+
+	var decoded CheckstyleReport
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteCheckstyle() produced invalid XML: %v", err)
+	}
+	if decoded.Version != checkstyleVersion || len(decoded.Files) != 1 || len(decoded.Files[0].Errors) != 3 {
+		t.Errorf("Decoded report = %+v", decoded)
+	}
+}
@@ -0,0 +1,126 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// JUnitOptions controls how validation results are mapped to JUnit test cases
+type JUnitOptions struct {
+	// WarningsAsFailures reports each warning as a failure. When false, warnings
+	// are listed in the test case's system-out and the test case still passes.
+	WarningsAsFailures bool
+}
+
+// JUnitTestSuites is the root element of a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite groups the test cases of one validation run
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase is the validation outcome of a single state-machine diagram
+type JUnitTestCase struct {
+	Name      string         `xml:"name,attr"`
+	ClassName string         `xml:"classname,attr"`
+	File      string         `xml:"file,attr,omitempty"`
+	Failures  []JUnitFailure `xml:"failure"`
+	SystemOut string         `xml:"system-out,omitempty"`
+}
+
+// JUnitFailure is a single finding reported as a test failure
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// NewJUnitReport builds a JUnit report with one test case per state-machine diagram.
+// Every error becomes a failure; warnings become failures only if opts asks for it.
+func NewJUnitReport(pm *models.PathManager, results []DiagramResult, opts JUnitOptions) *JUnitTestSuites {
+	suite := JUnitTestSuite{Name: toolName, TestCases: []JUnitTestCase{}}
+
+	for _, dr := range results {
+		if dr.Diagram == nil {
+			continue
+		}
+
+		path := diagramPath(pm, dr.Diagram)
+		testCase := JUnitTestCase{
+			Name:      dr.Diagram.Name + "-" + dr.Diagram.Version,
+			ClassName: toolName + "." + dr.Diagram.Location.String(),
+			File:      path,
+		}
+
+		var warnings []string
+		for _, f := range findings(dr.Result) {
+			detail := fmt.Sprintf("%s:%d:%d: %s: %s", path, f.Span.StartLine, f.Span.StartColumn, f.Code, f.Message)
+			if f.Level == "warning" && !opts.WarningsAsFailures {
+				warnings = append(warnings, "warning: "+detail)
+				continue
+			}
+			testCase.Failures = append(testCase.Failures, JUnitFailure{
+				Message: f.Message,
+				Type:    f.Code,
+				Text:    detail,
+			})
+		}
+		testCase.SystemOut = strings.Join(warnings, "\n")
+
+		suite.Tests++
+		if len(testCase.Failures) > 0 {
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	return &JUnitTestSuites{
+		Name:     toolName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []JUnitTestSuite{suite},
+	}
+}
+
+// WriteJUnit writes the results to w as an indented JUnit XML document
+func WriteJUnit(w io.Writer, pm *models.PathManager, results []DiagramResult, opts JUnitOptions) error {
+	return writeXML(w, "WriteJUnit", NewJUnitReport(pm, results, opts))
+}
+
+// writeXML writes v to w with an XML header, wrapping failures in a StateMachineError
+func writeXML(w io.Writer, operation string, v any) error {
+	wrap := func(err error) error {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to write XML report", err).
+			WithOperation(operation).
+			WithComponent("report")
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return wrap(err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return wrap(err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return wrap(err)
+	}
+	return nil
+}
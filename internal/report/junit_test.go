@@ -0,0 +1,90 @@
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestNewJUnitReport(t *testing.T) {
+	clean := DiagramResult{
+		Diagram: &models.StateMachineDiagram{Name: "clean", Version: "2.0.0", Location: models.LocationFileProducts},
+		Result:  &models.ValidationResult{IsValid: true},
+	}
+
+	tests := []struct {
+		name             string
+		opts             JUnitOptions
+		wantFailures     int
+		wantSystemOut    bool
+		wantSuiteFailure int
+	}{
+		{
+			name:             "warnings in system-out",
+			opts:             JUnitOptions{},
+			wantFailures:     1,
+			wantSystemOut:    true,
+			wantSuiteFailure: 1,
+		},
+		{
+			name:             "warnings as failures",
+			opts:             JUnitOptions{WarningsAsFailures: true},
+			wantFailures:     3,
+			wantSystemOut:    false,
+			wantSuiteFailure: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := NewJUnitReport(models.NewPathManager("diagrams"), []DiagramResult{newTestResult(), clean}, tt.opts)
+
+			if report.Tests != 2 {
+				t.Errorf("Tests = %d, want 2", report.Tests)
+			}
+			if report.Failures != tt.wantSuiteFailure {
+				t.Errorf("Failures = %d, want %d", report.Failures, tt.wantSuiteFailure)
+			}
+
+			cases := report.Suites[0].TestCases
+			if cases[0].Name != "user-auth-1.0.0" || cases[0].ClassName != toolName+".in-progress" {
+				t.Errorf("Test case = %s / %s", cases[0].ClassName, cases[0].Name)
+			}
+			if len(cases[0].Failures) != tt.wantFailures {
+				t.Errorf("Failures length = %d, want %d", len(cases[0].Failures), tt.wantFailures)
+			}
+			if (cases[0].SystemOut != "") != tt.wantSystemOut {
+				t.Errorf("SystemOut = %q", cases[0].SystemOut)
+			}
+			if cases[0].Failures[0].Type != "MISSING_END" ||
+				!strings.Contains(cases[0].Failures[0].Text, "user-auth-1.0.0.puml:3:13") {
+				t.Errorf("First failure = %+v", cases[0].Failures[0])
+			}
+			if len(cases[1].Failures) != 0 {
+				t.Errorf("Clean diagram should have no failures, got %d", len(cases[1].Failures))
+			}
+		})
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, models.NewPathManager("diagrams"), []DiagramResult{newTestResult()}, JUnitOptions{}); err != nil {
+		t.Fatalf("WriteJUnit() error = %v", err)
+	}
+
+	if !strings.HasPrefix(buf.String(), xml.Header) {
+		t.Error("WriteJUnit() output should start with the XML header")
+	}
+
+	var decoded JUnitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJUnit() produced invalid XML: %v", err)
+	}
+	if len(decoded.Suites) != 1 || len(decoded.Suites[0].TestCases) != 1 {
+		t.Errorf("Decoded report = %+v", decoded)
+	}
+}
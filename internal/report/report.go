@@ -0,0 +1,73 @@
+// Package report serializes state-machine diagram validation results into
+// formats understood by code-scanning dashboards and CI systems.
+package report
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/validation"
+)
+
+const (
+	// toolName is the name reported for the validator in generated reports
+	toolName = "go-uml-statemachine-parsers"
+
+	// toolInformationURI points at the project home page
+	toolInformationURI = "https://github.com/kengibson1111/go-uml-statemachine-parsers"
+)
+
+// DiagramResult pairs a validation result with the state-machine diagram it was produced for
+type DiagramResult struct {
+	Diagram *models.StateMachineDiagram
+	Result  *models.ValidationResult
+}
+
+// finding is a severity-tagged view over a ValidationError or ValidationWarning
+type finding struct {
+	Code    string
+	Message string
+	Level   string // "error" or "warning"
+	Span    models.Span
+	Fixes   []models.Fix
+}
+
+// findings flattens a result into errors followed by warnings
+func findings(result *models.ValidationResult) []finding {
+	if result == nil {
+		return nil
+	}
+
+	var all []finding
+	for _, e := range result.Errors {
+		all = append(all, finding{Code: e.Code, Message: e.Message, Level: "error", Span: e.Span(), Fixes: e.Fixes})
+	}
+	for _, w := range result.Warnings {
+		all = append(all, finding{Code: w.Code, Message: w.Message, Level: "warning", Span: w.Span(), Fixes: w.Fixes})
+	}
+	return all
+}
+
+// diagramPath returns the slash-separated file path of a diagram as laid out by the PathManager
+func diagramPath(pm *models.PathManager, diag *models.StateMachineDiagram) string {
+	path := pm.GetDiagramFilePathWithDiagramType(diag.Name, diag.Version, diag.Location, diag.DiagramType)
+	return filepath.ToSlash(path)
+}
+
+// lineText returns the trimmed text of a 1-based line of content
+func lineText(content string, line int) string {
+	lines := strings.Split(content, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+	return strings.TrimSpace(lines[line-1])
+}
+
+// ruleFor returns catalog metadata for a code, synthesizing a rule for unknown codes
+func ruleFor(code, level string) validation.Rule {
+	if rule, ok := validation.LookupRule(code); ok {
+		return rule
+	}
+	return validation.Rule{Code: code, Name: code, Description: code, DefaultSeverity: level}
+}
@@ -0,0 +1,283 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/validation"
+)
+
+const (
+	// SARIFVersion is the SARIF specification version produced by WriteSARIF
+	SARIFVersion = "2.1.0"
+
+	// SARIFSchema is the JSON schema URI of the produced SARIF log
+	SARIFSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+	// sarifFingerprintKey names the partial fingerprint computed for every result
+	sarifFingerprintKey = "diagramFindingHash/v1"
+)
+
+// SARIFLog is the top-level SARIF 2.1.0 document
+type SARIFLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun describes one invocation of the validator
+type SARIFRun struct {
+	Tool      SARIFTool       `json:"tool"`
+	Artifacts []SARIFArtifact `json:"artifacts,omitempty"`
+	Results   []SARIFResult   `json:"results"`
+}
+
+// SARIFTool describes the tool that produced the results
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver describes the validator and the rules it can report
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri,omitempty"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule carries the metadata of a finding code
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name,omitempty"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+// SARIFConfiguration holds the default level of a rule
+type SARIFConfiguration struct {
+	Level string `json:"level"`
+}
+
+// SARIFMessage is a plain-text SARIF message
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFArtifact describes a diagram file referenced by results
+type SARIFArtifact struct {
+	Location SARIFArtifactLocation `json:"location"`
+}
+
+// SARIFArtifactLocation identifies a file by URI
+type SARIFArtifactLocation struct {
+	URI   string `json:"uri"`
+	Index *int   `json:"index,omitempty"`
+}
+
+// SARIFResult is a single validation finding
+type SARIFResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             SARIFMessage      `json:"message"`
+	Locations           []SARIFLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Fixes               []SARIFFix        `json:"fixes,omitempty"`
+}
+
+// SARIFLocation is the location of a finding
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation `json:"physicalLocation"`
+}
+
+// SARIFPhysicalLocation points at a region of a file
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           SARIFRegion           `json:"region"`
+}
+
+// SARIFRegion is a span of text. EndColumn is exclusive, matching models.Span.
+type SARIFRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// SARIFFix is a proposed fix for a finding
+type SARIFFix struct {
+	Description     SARIFMessage          `json:"description"`
+	ArtifactChanges []SARIFArtifactChange `json:"artifactChanges"`
+}
+
+// SARIFArtifactChange groups the replacements made to one file
+type SARIFArtifactChange struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Replacements     []SARIFReplacement    `json:"replacements"`
+}
+
+// SARIFReplacement replaces a region with new text
+type SARIFReplacement struct {
+	DeletedRegion   SARIFRegion   `json:"deletedRegion"`
+	InsertedContent *SARIFMessage `json:"insertedContent,omitempty"`
+}
+
+// NewSARIFLog builds a SARIF log for the given results. File paths are derived
+// from pm so that they match the repository layout on disk.
+func NewSARIFLog(pm *models.PathManager, results []DiagramResult) *SARIFLog {
+	// Index all catalog rules plus any unknown codes present in the results
+	ruleList := validation.Rules()
+	known := make(map[string]bool, len(ruleList))
+	for _, rule := range ruleList {
+		known[rule.Code] = true
+	}
+	for _, dr := range results {
+		for _, f := range findings(dr.Result) {
+			if !known[f.Code] {
+				known[f.Code] = true
+				ruleList = append(ruleList, ruleFor(f.Code, f.Level))
+			}
+		}
+	}
+	sort.Slice(ruleList, func(i, j int) bool {
+		return ruleList[i].Code < ruleList[j].Code
+	})
+
+	ruleIndex := make(map[string]int, len(ruleList))
+	sarifRules := make([]SARIFRule, 0, len(ruleList))
+	for i, rule := range ruleList {
+		ruleIndex[rule.Code] = i
+		sarifRules = append(sarifRules, SARIFRule{
+			ID:                   rule.Code,
+			Name:                 rule.Name,
+			ShortDescription:     SARIFMessage{Text: rule.Description},
+			DefaultConfiguration: SARIFConfiguration{Level: rule.DefaultSeverity},
+		})
+	}
+
+	run := SARIFRun{
+		Tool: SARIFTool{Driver: SARIFDriver{
+			Name:           toolName,
+			InformationURI: toolInformationURI,
+			Rules:          sarifRules,
+		}},
+		Results: []SARIFResult{},
+	}
+
+	artifactIndex := make(map[string]int)
+	for _, dr := range results {
+		if dr.Diagram == nil {
+			continue
+		}
+
+		uri := sarifURI(diagramPath(pm, dr.Diagram))
+		index, ok := artifactIndex[uri]
+		if !ok {
+			index = len(run.Artifacts)
+			artifactIndex[uri] = index
+			run.Artifacts = append(run.Artifacts, SARIFArtifact{Location: SARIFArtifactLocation{URI: uri}})
+		}
+
+		// occurrences disambiguates identical findings on identical lines in one file
+		occurrences := make(map[string]int)
+		for _, f := range findings(dr.Result) {
+			location := SARIFArtifactLocation{URI: uri, Index: &index}
+			text := lineText(dr.Diagram.Content, f.Span.StartLine)
+
+			key := f.Code + "\x00" + text
+			occurrence := occurrences[key]
+			occurrences[key]++
+
+			run.Results = append(run.Results, SARIFResult{
+				RuleID:    f.Code,
+				RuleIndex: ruleIndex[f.Code],
+				Level:     f.Level,
+				Message:   SARIFMessage{Text: f.Message},
+				Locations: []SARIFLocation{{PhysicalLocation: SARIFPhysicalLocation{
+					ArtifactLocation: location,
+					Region:           sarifRegion(f.Span),
+				}}},
+				PartialFingerprints: map[string]string{
+					sarifFingerprintKey: fingerprint(f.Code, uri, text, occurrence),
+				},
+				Fixes: sarifFixes(location, f.Fixes),
+			})
+		}
+	}
+
+	return &SARIFLog{
+		Version: SARIFVersion,
+		Schema:  SARIFSchema,
+		Runs:    []SARIFRun{run},
+	}
+}
+
+// WriteSARIF writes the results to w as an indented SARIF 2.1.0 document
+func WriteSARIF(w io.Writer, pm *models.PathManager, results []DiagramResult) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(NewSARIFLog(pm, results)); err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to write SARIF report", err).
+			WithOperation("WriteSARIF").
+			WithComponent("report")
+	}
+	return nil
+}
+
+// fingerprint hashes the parts of a finding that stay the same when unrelated
+// lines are added or removed: the rule, the file and the text of the flagged line
+func fingerprint(code, uri, text string, occurrence int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", code, uri, text, occurrence)))
+	return hex.EncodeToString(sum[:])
+}
+
+// sarifURI converts a file path to a SARIF artifact URI
+func sarifURI(path string) string {
+	if filepath.IsAbs(filepath.FromSlash(path)) {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path // Windows drive paths
+		}
+		return "file://" + path
+	}
+	return strings.TrimPrefix(path, "./")
+}
+
+// sarifRegion converts a span to a SARIF region
+func sarifRegion(span models.Span) SARIFRegion {
+	region := SARIFRegion{
+		StartLine:   span.StartLine,
+		StartColumn: span.StartColumn,
+		EndLine:     span.EndLine,
+		EndColumn:   span.EndColumn,
+	}
+	if region.StartLine < 1 {
+		region.StartLine = 1
+	}
+	return region
+}
+
+// sarifFixes converts validation fixes to SARIF fixes
+func sarifFixes(location SARIFArtifactLocation, fixes []models.Fix) []SARIFFix {
+	var result []SARIFFix
+	for _, fix := range fixes {
+		change := SARIFArtifactChange{ArtifactLocation: location}
+		for _, edit := range fix.Edits {
+			replacement := SARIFReplacement{DeletedRegion: sarifRegion(edit.Span)}
+			if edit.NewText != "" {
+				replacement.InsertedContent = &SARIFMessage{Text: edit.NewText}
+			}
+			change.Replacements = append(change.Replacements, replacement)
+		}
+		result = append(result, SARIFFix{
+			Description:     SARIFMessage{Text: fix.Description},
+			ArtifactChanges: []SARIFArtifactChange{change},
+		})
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func newTestResult() DiagramResult {
+	diag := &models.StateMachineDiagram{
+		Name:        "user-auth",
+		Version:     "1.0.0",
+		Location:    models.LocationFileInProgress,
+		DiagramType: smmodels.DiagramTypePUML,
+		Content:     "@startuml\nIdle --> Bad State\n[*] --> Idle",
+	}
+
+	result := &models.ValidationResult{IsValid: true}
+	result.AddErrorWithSpan("MISSING_END", "Missing @enduml tag", models.PointSpan(3, 13), models.Fix{
+		Code:        "MISSING_END",
+		Description: "Append @enduml at the end of the file",
+		Edits:       []models.TextEdit{{Span: models.PointSpan(3, 13), NewText: "\n@enduml"}},
+	})
+	result.AddWarningWithSpan("INVALID_STATE_NAME", "State name should follow naming conventions",
+		models.Span{StartLine: 2, StartColumn: 10, EndLine: 2, EndColumn: 19})
+	result.AddWarning("CUSTOM_CHECK", "Custom finding", 1, 1)
+
+	return DiagramResult{Diagram: diag, Result: result}
+}
+
+func TestNewSARIFLog(t *testing.T) {
+	pm := models.NewPathManager("diagrams")
+	log := NewSARIFLog(pm, []DiagramResult{newTestResult()})
+
+	if log.Version != SARIFVersion {
+		t.Errorf("Version = %s, want %s", log.Version, SARIFVersion)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("Runs length = %d, want 1", len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != toolName {
+		t.Errorf("Driver.Name = %s, want %s", run.Tool.Driver.Name, toolName)
+	}
+	if len(run.Artifacts) != 1 || run.Artifacts[0].Location.URI != "diagrams/in-progress/puml/user-auth-1.0.0.puml" {
+		t.Errorf("Artifacts = %+v", run.Artifacts)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Results length = %d, want 3", len(run.Results))
+	}
+
+	// Every result must point at the rule it was reported for
+	for _, result := range run.Results {
+		rule := run.Tool.Driver.Rules[result.RuleIndex]
+		if rule.ID != result.RuleID {
+			t.Errorf("Result %s has ruleIndex pointing at %s", result.RuleID, rule.ID)
+		}
+		if result.PartialFingerprints[sarifFingerprintKey] == "" {
+			t.Errorf("Result %s is missing a fingerprint", result.RuleID)
+		}
+	}
+
+	missingEnd := run.Results[0]
+	if missingEnd.Level != "error" {
+		t.Errorf("MISSING_END level = %s, want error", missingEnd.Level)
+	}
+	if len(missingEnd.Fixes) != 1 || missingEnd.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "\n@enduml" {
+		t.Errorf("MISSING_END fixes = %+v", missingEnd.Fixes)
+	}
+
+	invalidName := run.Results[1]
+	want := SARIFRegion{StartLine: 2, StartColumn: 10, EndLine: 2, EndColumn: 19}
+	if invalidName.Locations[0].PhysicalLocation.Region != want {
+		t.Errorf("INVALID_STATE_NAME region = %+v, want %+v", invalidName.Locations[0].PhysicalLocation.Region, want)
+	}
+	if invalidName.Level != "warning" {
+		t.Errorf("INVALID_STATE_NAME level = %s, want warning", invalidName.Level)
+	}
+
+	// Unknown codes get synthesized rule metadata
+	found := false
+	for _, rule := range run.Tool.Driver.Rules {
+		if rule.ID == "CUSTOM_CHECK" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected synthesized rule for CUSTOM_CHECK")
+	}
+}
+
+func TestNewSARIFLog_StableFingerprints(t *testing.T) {
+	pm := models.NewPathManager("diagrams")
+	first := NewSARIFLog(pm, []DiagramResult{newTestResult()}).Runs[0].Results[1]
+
+	// Shift the flagged line down by inserting a comment above it
+	shifted := newTestResult()
+	shifted.Diagram.Content = "@startuml\n' comment\nIdle --> Bad State\n[*] --> Idle"
+	shifted.Result.Warnings[0].Line = 3
+	shifted.Result.Warnings[0].EndLine = 3
+	second := NewSARIFLog(pm, []DiagramResult{shifted}).Runs[0].Results[1]
+
+	if first.PartialFingerprints[sarifFingerprintKey] != second.PartialFingerprints[sarifFingerprintKey] {
+		t.Error("Fingerprint should not change when the flagged line moves")
+	}
+}
+
+func TestNewSARIFLog_DuplicateFindingsHaveDistinctFingerprints(t *testing.T) {
+	dr := newTestResult()
+	dr.Result.Warnings = dr.Result.Warnings[:1]
+	dr.Result.Warnings = append(dr.Result.Warnings, dr.Result.Warnings[0])
+
+	results := NewSARIFLog(models.NewPathManager("diagrams"), []DiagramResult{dr}).Runs[0].Results
+	if results[1].PartialFingerprints[sarifFingerprintKey] == results[2].PartialFingerprints[sarifFingerprintKey] {
+		t.Error("Identical findings on the same line should have distinct fingerprints")
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSARIF(&buf, models.NewPathManager("diagrams"), []DiagramResult{newTestResult()}); err != nil {
+		t.Fatalf("WriteSARIF() error = %v", err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteSARIF() produced invalid JSON: %v", err)
+	}
+	if decoded["version"] != "2.1.0" {
+		t.Errorf("version = %v, want 2.1.0", decoded["version"])
+	}
+	if !strings.Contains(buf.String(), `"$schema"`) {
+		t.Error("WriteSARIF() output should include $schema")
+	}
+}
+
+func TestSarifURI(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"diagrams/in-progress/puml/a-1.0.0.puml", "diagrams/in-progress/puml/a-1.0.0.puml"},
+		{"./diagrams/a.puml", "diagrams/a.puml"},
+		{"/srv/diagrams/a.puml", "file:///srv/diagrams/a.puml"},
+	}
+
+	for _, tt := range tests {
+		if got := sarifURI(tt.path); got != tt.want {
+			t.Errorf("sarifURI(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
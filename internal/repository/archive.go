@@ -0,0 +1,519 @@
+package repository
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// archiveFormat is the container format of an archive file
+type archiveFormat int
+
+const (
+	archiveZip archiveFormat = iota
+	archiveTar
+	archiveTarGzip
+)
+
+// archiveFormatFor picks the container format from the archive file extension
+func archiveFormatFor(archivePath string) (archiveFormat, error) {
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, nil
+	case strings.HasSuffix(lower, ".tar"):
+		return archiveTar, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGzip, nil
+	default:
+		return 0, models.NewStateMachineError(models.ErrorTypeConfiguration, "unsupported archive format", nil).
+			WithContext("archivePath", archivePath).
+			WithContext("supportedExtensions", ".zip, .tar, .tar.gz, .tgz")
+	}
+}
+
+// archiveEntry is a file stored in an archive
+type archiveEntry struct {
+	name     string // slash-separated path relative to the root directory
+	data     []byte
+	modified time.Time
+}
+
+// ArchiveOptions controls how an archive repository is opened
+type ArchiveOptions struct {
+	ReadOnly bool // Reject every write; the archive file is never rewritten
+}
+
+// ArchiveRepository implements the Repository interface on top of a single zip
+// or tar archive. The archive holds the same layout as the root directory:
+// in-progress/ and products/ with their metadata sidecars, so an archive of a
+// root directory's content can be opened as is.
+//
+// The archive is loaded into memory when it is opened. Every write replaces the
+// whole archive file atomically, so readers of the file never see a partial
+// archive. Entries the repository does not manage, such as backups and
+// history, are kept unchanged when the archive is rewritten.
+type ArchiveRepository struct {
+	mu          sync.RWMutex
+	archivePath string
+	format      archiveFormat
+	options     ArchiveOptions
+	config      *models.Config
+	mem         *MemoryRepository
+	extra       []archiveEntry
+	loadedInfo  os.FileInfo // archive file as last loaded or saved, nil if it did not exist
+	files       fileOps
+}
+
+// NewArchiveRepository opens the archive at archivePath. The format follows the
+// file extension: .zip, .tar, or .tar.gz/.tgz. A missing archive is created by
+// the first write unless the repository is read-only.
+func NewArchiveRepository(archivePath string, options ArchiveOptions, config *models.Config) (*ArchiveRepository, error) {
+	if config == nil {
+		config = models.DefaultConfig()
+	}
+
+	format, err := archiveFormatFor(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ArchiveRepository{
+		archivePath: archivePath,
+		format:      format,
+		options:     options,
+		config:      config,
+		mem:         NewMemoryRepository(config),
+		files:       osFileOps(),
+	}
+
+	info, err := os.Stat(archivePath)
+	if os.IsNotExist(err) {
+		if options.ReadOnly {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "archive not found", err).
+				WithContext("archivePath", archivePath)
+		}
+		return r, nil
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to get archive info", err).
+			WithContext("archivePath", archivePath)
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	r.loadedInfo = info
+	return r, nil
+}
+
+// ReadOnly reports whether the repository rejects writes
+func (r *ArchiveRepository) ReadOnly() bool {
+	return r.options.ReadOnly
+}
+
+// load reads every entry of the archive into memory
+func (r *ArchiveRepository) load() error {
+	data, err := os.ReadFile(r.archivePath)
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read archive", err).
+			WithContext("archivePath", r.archivePath)
+	}
+
+	var entries []archiveEntry
+	switch r.format {
+	case archiveZip:
+		entries, err = readZipEntries(data)
+	case archiveTar:
+		entries, err = readTarEntries(bytes.NewReader(data))
+	case archiveTarGzip:
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+			entries, err = readTarEntries(gz)
+		}
+	}
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeCorruption, "failed to read archive", err).
+			WithContext("archivePath", r.archivePath)
+	}
+
+	// Diagrams are loaded first so that their sidecars can be applied to them
+	pathManager := models.NewPathManager(r.config.RootDirectory)
+	var sidecars []archiveEntry
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.name, models.MetadataExtension) {
+			sidecars = append(sidecars, entry)
+			continue
+		}
+		diagramType, location, info, ok := parseArchiveName(pathManager, entry.name, models.PlantUMLExtension)
+		if !ok {
+			r.extra = append(r.extra, entry)
+			continue
+		}
+		diag := &models.StateMachineDiagram{
+			Name:        info.Name,
+			Version:     info.Version,
+			Content:     string(entry.data),
+			Location:    location,
+			DiagramType: diagramType,
+			Metadata:    models.Metadata{CreatedAt: entry.modified, ModifiedAt: entry.modified},
+		}
+		if err := r.mem.WriteDiagram(diag); err != nil {
+			return models.WrapError(err, models.GetErrorType(err), "failed to load state-machine diagram from archive").
+				WithContext("archivePath", r.archivePath).
+				WithContext("entry", entry.name)
+		}
+	}
+
+	for _, entry := range sidecars {
+		diagramType, location, info, ok := parseArchiveName(pathManager, entry.name, models.MetadataExtension)
+		if !ok {
+			r.extra = append(r.extra, entry)
+			continue
+		}
+		var sidecar metadataSidecar
+		if err := json.Unmarshal(entry.data, &sidecar); err != nil {
+			return models.NewStateMachineError(models.ErrorTypeCorruption, "failed to parse metadata file", err).
+				WithContext("archivePath", r.archivePath).
+				WithContext("entry", entry.name)
+		}
+		if err := r.mem.WriteMetadata(diagramType, info.Name, info.Version, location, sidecar.metadata()); err != nil {
+			// A sidecar without its diagram is kept as is rather than lost
+			r.extra = append(r.extra, entry)
+		}
+	}
+
+	return nil
+}
+
+// parseArchiveName recognizes {location}/{type}/{name}-{version}{extension} entries
+func parseArchiveName(pathManager *models.PathManager, name, extension string) (smmodels.DiagramType, models.Location, *models.PathInfo, bool) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 || !strings.HasSuffix(parts[2], extension) {
+		return 0, 0, nil, false
+	}
+
+	var location models.Location
+	switch parts[0] {
+	case "in-progress":
+		location = models.LocationFileInProgress
+	case "products":
+		location = models.LocationFileProducts
+	default:
+		return 0, 0, nil, false
+	}
+	if parts[1] != smmodels.DiagramTypePUML.String() {
+		return 0, 0, nil, false
+	}
+
+	fileName := strings.TrimSuffix(parts[2], extension) + models.PlantUMLExtension
+	info, err := pathManager.ParseFileName(smmodels.DiagramTypePUML, fileName)
+	if err != nil {
+		return 0, 0, nil, false
+	}
+	return smmodels.DiagramTypePUML, location, info, true
+}
+
+// cleanArchiveName validates an entry name and returns it in slash-separated
+// form relative to the archive root. Absolute names and names escaping the
+// root are rejected so that they are never written back.
+func cleanArchiveName(name string) (string, error) {
+	cleaned := path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", models.NewStateMachineError(models.ErrorTypeCorruption, "archive entry escapes the archive root", nil).
+			WithContext("entry", name)
+	}
+	return strings.TrimPrefix(cleaned, "./"), nil
+}
+
+// readZipEntries returns the regular files of a zip archive
+func readZipEntries(data []byte) ([]archiveEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []archiveEntry
+	for _, file := range reader.File {
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		name, err := cleanArchiveName(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archiveEntry{name: name, data: content, modified: file.Modified})
+	}
+	return entries, nil
+}
+
+// readTarEntries returns the regular files of a tar stream
+func readTarEntries(r io.Reader) ([]archiveEntry, error) {
+	reader := tar.NewReader(r)
+
+	var entries []archiveEntry
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name, err := cleanArchiveName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, archiveEntry{name: name, data: content, modified: header.ModTime})
+	}
+}
+
+// update applies a write to the in-memory content and saves the archive. When
+// the save fails the in-memory content is rolled back, so memory and file agree.
+func (r *ArchiveRepository) update(write func() error) error {
+	if r.options.ReadOnly {
+		return models.NewStateMachineError(models.ErrorTypePermission, "archive repository is read-only", nil).
+			WithContext("archivePath", r.archivePath)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := r.mem.Snapshot()
+	if err := write(); err != nil {
+		return err
+	}
+	if err := r.save(); err != nil {
+		r.mem.Restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+// save rewrites the archive file with the current content. It refuses to
+// overwrite an archive another process changed since it was loaded.
+func (r *ArchiveRepository) save() error {
+	info, err := os.Stat(r.archivePath)
+	if err != nil && !os.IsNotExist(err) {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to get archive info", err).
+			WithContext("archivePath", r.archivePath)
+	}
+	if changed := (info == nil) != (r.loadedInfo == nil) ||
+		(info != nil && (!info.ModTime().Equal(r.loadedInfo.ModTime()) || info.Size() != r.loadedInfo.Size())); changed {
+		return models.NewStateMachineError(models.ErrorTypeFileConflict, "archive was changed by another process", nil).
+			WithContext("archivePath", r.archivePath)
+	}
+
+	data, err := r.encode()
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to encode archive", err).
+			WithContext("archivePath", r.archivePath)
+	}
+	if dir := filepath.Dir(r.archivePath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to create archive directory", err).
+				WithContext("archivePath", r.archivePath)
+		}
+	}
+	if err := r.files.writeFile(r.archivePath, data, 0644); err != nil {
+		return models.WrapError(err, models.ErrorTypeFileSystem, "failed to write archive").
+			WithContext("archivePath", r.archivePath)
+	}
+
+	if info, err = os.Stat(r.archivePath); err == nil {
+		r.loadedInfo = info
+	}
+	return nil
+}
+
+// entries returns every file of the archive in name order
+func (r *ArchiveRepository) entries() ([]archiveEntry, error) {
+	pathManager := models.NewPathManager(".")
+	entries := slices.Clone(r.extra)
+
+	r.mem.mu.RLock()
+	defer r.mem.mu.RUnlock()
+
+	for key, stored := range r.mem.state.diagrams {
+		diagramPath := pathManager.GetDiagramFilePathWithDiagramType(key.name, key.version, key.location, key.diagramType)
+		metaPath := pathManager.GetMetadataFilePathWithDiagramType(key.name, key.version, key.location, key.diagramType)
+
+		sidecar, err := json.MarshalIndent(newMetadataSidecar(stored.metadata), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries,
+			archiveEntry{name: filepath.ToSlash(diagramPath), data: []byte(stored.content), modified: stored.metadata.ModifiedAt},
+			archiveEntry{name: filepath.ToSlash(metaPath), data: sidecar, modified: stored.metadata.ModifiedAt},
+		)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// encode serializes the archive in its container format
+func (r *ArchiveRepository) encode() ([]byte, error) {
+	entries, err := r.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch r.format {
+	case archiveZip:
+		err = writeZipEntries(&buf, entries)
+	case archiveTar:
+		err = writeTarEntries(&buf, entries)
+	case archiveTarGzip:
+		gz := gzip.NewWriter(&buf)
+		if err = writeTarEntries(gz, entries); err == nil {
+			err = gz.Close()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeZipEntries writes entries as a zip archive
+func writeZipEntries(w io.Writer, entries []archiveEntry) error {
+	writer := zip.NewWriter(w)
+	for _, entry := range entries {
+		f, err := writer.CreateHeader(&zip.FileHeader{Name: entry.name, Method: zip.Deflate, Modified: entry.modified})
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// writeTarEntries writes entries as a tar stream
+func writeTarEntries(w io.Writer, entries []archiveEntry) error {
+	writer := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     entry.name,
+			Mode:     0644,
+			Size:     int64(len(entry.data)),
+			ModTime:  entry.modified,
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := writer.Write(entry.data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// ReadDiagram reads a state-machine diagram from the archive
+func (r *ArchiveRepository) ReadDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.ReadDiagram(diagramType, name, version, location)
+}
+
+// WriteDiagram stores a state-machine diagram and rewrites the archive
+func (r *ArchiveRepository) WriteDiagram(diag *models.StateMachineDiagram) error {
+	return r.update(func() error { return r.mem.WriteDiagram(diag) })
+}
+
+// Exists checks if a state-machine diagram exists in the archive
+func (r *ArchiveRepository) Exists(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.Exists(diagramType, name, version, location)
+}
+
+// CreateDirectory records a directory. Archives store files only, so empty
+// directories are not kept when the archive is rewritten.
+func (r *ArchiveRepository) CreateDirectory(path string) error {
+	if r.options.ReadOnly {
+		return models.NewStateMachineError(models.ErrorTypePermission, "archive repository is read-only", nil).
+			WithContext("archivePath", r.archivePath)
+	}
+	return r.mem.CreateDirectory(path)
+}
+
+// DirectoryExists checks if a directory was created or holds a state-machine diagram
+func (r *ArchiveRepository) DirectoryExists(path string) (bool, error) {
+	return r.mem.DirectoryExists(path)
+}
+
+// MoveDiagram moves a state-machine diagram and its metadata and rewrites the archive
+func (r *ArchiveRepository) MoveDiagram(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+	return r.update(func() error { return r.mem.MoveDiagram(diagramType, name, version, from, to) })
+}
+
+// DeleteDiagram deletes a state-machine diagram and its metadata and rewrites the archive
+func (r *ArchiveRepository) DeleteDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+	return r.update(func() error { return r.mem.DeleteDiagram(diagramType, name, version, location) })
+}
+
+// ListDiagrams lists all state-machine diagrams in a location
+func (r *ArchiveRepository) ListDiagrams(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.ListDiagrams(diagramType, location)
+}
+
+// ListBlocks returns the @startuml blocks of a state-machine diagram
+func (r *ArchiveRepository) ListBlocks(diagramType smmodels.DiagramType, name, version string, location models.Location) ([]models.Block, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.ListBlocks(diagramType, name, version, location)
+}
+
+// ReadBlock reads a single @startuml block of a state-machine diagram by id
+func (r *ArchiveRepository) ReadBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.Block, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.ReadBlock(diagramType, name, version, location, blockID)
+}
+
+// ReadMetadata returns the metadata of a state-machine diagram
+func (r *ArchiveRepository) ReadMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Metadata, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.mem.ReadMetadata(diagramType, name, version, location)
+}
+
+// WriteMetadata replaces the metadata of a state-machine diagram and rewrites the archive
+func (r *ArchiveRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	return r.update(func() error { return r.mem.WriteMetadata(diagramType, name, version, location, metadata) })
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// writeTestZip creates a zip archive holding files
+func writeTestZip(t *testing.T, archivePath string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	writer := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveRepository_RoundTrip(t *testing.T) {
+	for _, ext := range []string{".zip", ".tar", ".tar.gz"} {
+		t.Run(ext, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "diagrams"+ext)
+
+			repo, err := NewArchiveRepository(archivePath, ArchiveOptions{}, nil)
+			if err != nil {
+				t.Fatalf("NewArchiveRepository() error = %v", err)
+			}
+			diag := memoryTestDiagram("door", "1.0.0")
+			if err := repo.WriteDiagram(diag); err != nil {
+				t.Fatalf("WriteDiagram() error = %v", err)
+			}
+			if err := repo.WriteDiagram(memoryTestDiagram("window", "1.0.0")); err != nil {
+				t.Fatalf("WriteDiagram() error = %v", err)
+			}
+			if err := repo.MoveDiagram(smmodels.DiagramTypePUML, "window", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts); err != nil {
+				t.Fatalf("MoveDiagram() error = %v", err)
+			}
+
+			reopened, err := NewArchiveRepository(archivePath, ArchiveOptions{ReadOnly: true}, nil)
+			if err != nil {
+				t.Fatalf("NewArchiveRepository() of the saved archive error = %v", err)
+			}
+			got, err := reopened.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+			if err != nil {
+				t.Fatalf("ReadDiagram() error = %v", err)
+			}
+			if got.Content != diag.Content || got.Metadata.Author != "jane" || len(got.Metadata.Tags) != 1 {
+				t.Errorf("ReadDiagram() = %+v, want the content and metadata that were written", got)
+			}
+			if exists, _ := reopened.Exists(smmodels.DiagramTypePUML, "window", "1.0.0", models.LocationFileProducts); !exists {
+				t.Error("moved diagram missing from the products location after reopening")
+			}
+		})
+	}
+}
+
+func TestArchiveRepository_ReadOnly(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := NewArchiveRepository(filepath.Join(dir, "missing.zip"), ArchiveOptions{ReadOnly: true}, nil); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("NewArchiveRepository() of a missing read-only archive error = %v, want not found", err)
+	}
+	if _, err := NewArchiveRepository(filepath.Join(dir, "diagrams.rar"), ArchiveOptions{}, nil); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("NewArchiveRepository() of an unknown format error = %v, want configuration", err)
+	}
+
+	archivePath := filepath.Join(dir, "diagrams.zip")
+	writeTestZip(t, archivePath, map[string]string{
+		"in-progress/puml/door-1.0.0.puml": "@startuml\n[*] --> Closed\n@enduml",
+	})
+	before, _ := os.ReadFile(archivePath)
+
+	repo, err := NewArchiveRepository(archivePath, ArchiveOptions{ReadOnly: true}, nil)
+	if err != nil {
+		t.Fatalf("NewArchiveRepository() error = %v", err)
+	}
+	if !repo.ReadOnly() {
+		t.Error("ReadOnly() = false, want true")
+	}
+	if diagrams, _ := repo.ListDiagrams(smmodels.DiagramTypePUML, models.LocationFileInProgress); len(diagrams) != 1 {
+		t.Errorf("ListDiagrams() = %+v, want the archived diagram", diagrams)
+	}
+
+	if err := repo.WriteDiagram(memoryTestDiagram("window", "1.0.0")); models.GetErrorType(err) != models.ErrorTypePermission {
+		t.Errorf("WriteDiagram() on a read-only archive error = %v, want permission", err)
+	}
+	if err := repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); models.GetErrorType(err) != models.ErrorTypePermission {
+		t.Errorf("DeleteDiagram() on a read-only archive error = %v, want permission", err)
+	}
+	if after, _ := os.ReadFile(archivePath); string(after) != string(before) {
+		t.Error("read-only archive was rewritten")
+	}
+}
+
+func TestArchiveRepository_KeepsUnmanagedEntries(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "diagrams.zip")
+	writeTestZip(t, archivePath, map[string]string{
+		"in-progress/puml/door-1.0.0.puml":    "@startuml\n[*] --> Closed\n@enduml",
+		"history/puml/door-1.0.0/000001.json": `{"size":1}`,
+		"README.txt":                          "shipped diagrams",
+	})
+
+	repo, err := NewArchiveRepository(archivePath, ArchiveOptions{}, nil)
+	if err != nil {
+		t.Fatalf("NewArchiveRepository() error = %v", err)
+	}
+	if err := repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); err != nil {
+		t.Fatalf("DeleteDiagram() error = %v", err)
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("failed to open rewritten archive: %v", err)
+	}
+	defer reader.Close()
+	names := map[string]bool{}
+	for _, f := range reader.File {
+		names[f.Name] = true
+	}
+	if !names["README.txt"] || !names["history/puml/door-1.0.0/000001.json"] || names["in-progress/puml/door-1.0.0.puml"] {
+		t.Errorf("rewritten archive entries = %v, want the unmanaged entries kept and the diagram removed", names)
+	}
+}
+
+func TestArchiveRepository_SaveFailures(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "diagrams.zip")
+	repo, err := NewArchiveRepository(archivePath, ArchiveOptions{}, nil)
+	if err != nil {
+		t.Fatalf("NewArchiveRepository() error = %v", err)
+	}
+	if err := repo.WriteDiagram(memoryTestDiagram("door", "1.0.0")); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	// A failed save leaves both the archive and the in-memory content unchanged
+	repo.files = failingOps("rename")
+	if err := repo.WriteDiagram(memoryTestDiagram("window", "1.0.0")); err == nil {
+		t.Fatal("WriteDiagram() with a failing save succeeded")
+	}
+	if exists, _ := repo.Exists(smmodels.DiagramTypePUML, "window", "1.0.0", models.LocationFileInProgress); exists {
+		t.Error("diagram from the failed write is still visible")
+	}
+	repo.files = osFileOps()
+
+	// Another process replaced the archive after it was loaded
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(archivePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.WriteDiagram(memoryTestDiagram("window", "1.0.0")); models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Errorf("WriteDiagram() over a changed archive error = %v, want conflict", err)
+	}
+}
+
+func TestArchiveRepository_UnsafeEntry(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "diagrams.zip")
+	writeTestZip(t, archivePath, map[string]string{"../escape.puml": "@startuml\n@enduml"})
+
+	if _, err := NewArchiveRepository(archivePath, ArchiveOptions{}, nil); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("NewArchiveRepository() with an escaping entry error = %v, want corruption", err)
+	}
+}
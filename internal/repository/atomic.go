@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/logging"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// tempFile is the part of *os.File used while writing a temp file
+type tempFile interface {
+	io.Writer
+	Name() string
+	Chmod(mode os.FileMode) error
+	Sync() error
+	Close() error
+}
+
+// fileOps holds the file system calls made by atomic writes so tests can inject
+// a failure at any step
+type fileOps struct {
+	createTemp func(dir, pattern string) (tempFile, error)
+	rename     func(oldpath, newpath string) error
+	remove     func(name string) error
+	syncDir    func(dir string) error
+
+	// logger reports failures that happen after a change is already applied
+	logger *logging.Logger
+}
+
+// osFileOps returns fileOps backed by the os package
+func osFileOps() fileOps {
+	return fileOps{
+		createTemp: func(dir, pattern string) (tempFile, error) {
+			return os.CreateTemp(dir, pattern)
+		},
+		rename:  os.Rename,
+		remove:  os.Remove,
+		syncDir: syncDir,
+		logger:  logging.NewDefaultLogger(),
+	}
+}
+
+// syncDir flushes a directory entry change, such as a rename, to disk. Windows
+// cannot open directories for syncing and persists renames on its own.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// writeFile replaces filePath with data without ever exposing a partially written
+// file. The data goes to a temp file in the same directory, which is synced and
+// renamed over filePath before the directory itself is synced. On failure the
+// temp file is removed and any existing file at filePath is left untouched, so
+// an error always means nothing changed. Once the rename has happened the new
+// content is live; a failed directory sync is then only logged, since the
+// write cannot be undone and the caller must not treat it as failed.
+func (o fileOps) writeFile(filePath string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(filePath)
+
+	tmp, err := o.createTemp(dir, "."+filepath.Base(filePath)+".tmp-*")
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to create temp file", err).
+			WithContext("filePath", filePath)
+	}
+	tmpPath := tmp.Name()
+
+	// fail discards the temp file and reports the step that failed
+	fail := func(step string, err error) error {
+		tmp.Close()
+		o.remove(tmpPath)
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to "+step, err).
+			WithContext("filePath", filePath).
+			WithContext("tempPath", tmpPath)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		return fail("write temp file", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		return fail("set temp file permissions", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail("sync temp file", err)
+	}
+	if err := tmp.Close(); err != nil {
+		o.remove(tmpPath)
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to close temp file", err).
+			WithContext("filePath", filePath).
+			WithContext("tempPath", tmpPath)
+	}
+	if err := o.rename(tmpPath, filePath); err != nil {
+		o.remove(tmpPath)
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to replace file", err).
+			WithContext("filePath", filePath).
+			WithContext("tempPath", tmpPath)
+	}
+
+	// The new content is in place; syncing the directory makes the rename durable
+	if err := o.syncDir(dir); err != nil {
+		o.logger.WithFields(map[string]any{
+			"filePath": filePath,
+			"dir":      dir,
+		}).WithError(err).Warn("File replaced but its directory could not be synced; the change may not survive a crash")
+	}
+	return nil
+}
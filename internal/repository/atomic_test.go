@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// failingFile wraps a temp file and fails the named step
+type failingFile struct {
+	*os.File
+	step string
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *failingFile) Write(p []byte) (int, error) {
+	if f.step == "write" {
+		// Simulate a full disk after part of the data
+		n, _ := f.File.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.File.Write(p)
+}
+
+func (f *failingFile) Chmod(mode os.FileMode) error {
+	if f.step == "chmod" {
+		return errInjected
+	}
+	return f.File.Chmod(mode)
+}
+
+func (f *failingFile) Sync() error {
+	if f.step == "sync" {
+		return errInjected
+	}
+	return f.File.Sync()
+}
+
+func (f *failingFile) Close() error {
+	err := f.File.Close()
+	if f.step == "close" {
+		return errInjected
+	}
+	return err
+}
+
+// failingOps returns fileOps that fail at step
+func failingOps(step string) fileOps {
+	ops := osFileOps()
+	ops.createTemp = func(dir, pattern string) (tempFile, error) {
+		if step == "create" {
+			return nil, errInjected
+		}
+		f, err := os.CreateTemp(dir, pattern)
+		if err != nil {
+			return nil, err
+		}
+		return &failingFile{File: f, step: step}, nil
+	}
+	ops.rename = func(oldpath, newpath string) error {
+		if step == "rename" {
+			return errInjected
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	ops.syncDir = func(dir string) error {
+		if step == "syncDir" {
+			return errInjected
+		}
+		return syncDir(dir)
+	}
+	return ops
+}
+
+// tempFiles returns the leftover temp files in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir() error = %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestFileOps_WriteFile(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "door-1.0.0.puml")
+
+	if err := osFileOps().writeFile(filePath, []byte("original"), 0644); err != nil {
+		t.Fatalf("writeFile() error = %v", err)
+	}
+	if err := osFileOps().writeFile(filePath, []byte("replaced"), 0644); err != nil {
+		t.Fatalf("writeFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil || string(data) != "replaced" {
+		t.Errorf("content = %q, %v, want %q", data, err, "replaced")
+	}
+	if leftover := tempFiles(t, dir); len(leftover) != 0 {
+		t.Errorf("leftover temp files = %v", leftover)
+	}
+}
+
+func TestFileOps_WriteFile_Failures(t *testing.T) {
+	for _, step := range []string{"create", "write", "chmod", "sync", "close", "rename"} {
+		t.Run(step, func(t *testing.T) {
+			dir := t.TempDir()
+			filePath := filepath.Join(dir, "door-1.0.0.puml")
+			if err := os.WriteFile(filePath, []byte("original"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := failingOps(step).writeFile(filePath, []byte("replacement content"), 0644)
+			if !errors.Is(err, errInjected) || models.GetErrorType(err) != models.ErrorTypeFileSystem {
+				t.Fatalf("writeFile() error = %v, want injected file system error", err)
+			}
+
+			data, _ := os.ReadFile(filePath)
+			if string(data) != "original" {
+				t.Errorf("content after failed %s = %q, want the original", step, data)
+			}
+			if leftover := tempFiles(t, dir); len(leftover) != 0 {
+				t.Errorf("leftover temp files = %v", leftover)
+			}
+		})
+	}
+}
+
+func TestFileOps_WriteFile_SyncDirFailure(t *testing.T) {
+	dir := t.TempDir()
+	filePath := filepath.Join(dir, "door-1.0.0.puml")
+
+	// The rename has happened and cannot be undone, so the write is reported as
+	// done; an error would tell the caller that nothing changed
+	if err := failingOps("syncDir").writeFile(filePath, []byte("replaced"), 0644); err != nil {
+		t.Fatalf("writeFile() error = %v, want the applied write to succeed", err)
+	}
+	if data, _ := os.ReadFile(filePath); string(data) != "replaced" {
+		t.Errorf("content = %q, want %q", data, "replaced")
+	}
+}
+
+func TestFileSystemRepository_WriteDiagram_SyncDirFailure(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	diag.Metadata.Author = "jane"
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	// Content and sidecar are both renamed into place before the sync fails
+	helper.repo.files = failingOps("syncDir")
+	diag.Content = "@startuml\n[*] --> Replaced\n@enduml"
+	diag.Metadata.Author = "john"
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() with a failing directory sync error = %v, want success", err)
+	}
+	read, err := helper.repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadDiagram() error = %v", err)
+	}
+	if read.Content != diag.Content || read.Metadata.Author != "john" {
+		t.Errorf("diagram after a failing directory sync = %q by %q, want the new write", read.Content, read.Metadata.Author)
+	}
+}
+
+func TestFileSystemRepository_DeleteDiagram_SidecarFailure(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	// Once the diagram is removed the delete has happened, whatever the sidecar does
+	ops := osFileOps()
+	ops.remove = func(name string) error {
+		if strings.HasSuffix(name, models.MetadataExtension) {
+			return errInjected
+		}
+		return os.Remove(name)
+	}
+	helper.repo.files = ops
+	if err := helper.repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); err != nil {
+		t.Fatalf("DeleteDiagram() with a failing sidecar removal error = %v, want success", err)
+	}
+	if exists, _ := helper.repo.Exists(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); exists {
+		t.Error("diagram still exists after DeleteDiagram() succeeded")
+	}
+}
+
+func TestFileSystemRepository_WriteDiagram_Atomic(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	original := diag.Content
+
+	for _, step := range []string{"write", "sync", "rename"} {
+		helper.repo.files = failingOps(step)
+		diag.Content = "@startuml\n[*] --> Broken\n@enduml"
+		if err := helper.repo.WriteDiagram(diag); err == nil {
+			t.Fatalf("WriteDiagram() with failing %s succeeded", step)
+		}
+
+		read, err := helper.repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+		if err != nil {
+			t.Fatalf("ReadDiagram() error = %v", err)
+		}
+		if read.Content != original {
+			t.Errorf("content after failed %s = %q, want the original", step, read.Content)
+		}
+	}
+
+	// A failed sidecar write leaves the previous metadata readable
+	helper.repo.files = osFileOps()
+	if err := helper.repo.WriteMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.Metadata{Author: "jane"}); err != nil {
+		t.Fatalf("WriteMetadata() error = %v", err)
+	}
+	helper.repo.files = failingOps("write")
+	if err := helper.repo.WriteMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.Metadata{Author: "john"}); err == nil {
+		t.Fatal("WriteMetadata() with failing write succeeded")
+	}
+	metadata, err := helper.repo.ReadMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil || metadata.Author != "jane" {
+		t.Errorf("ReadMetadata() = %+v, %v, want the previous author", metadata, err)
+	}
+}
+
+func TestFileSystemRepository_WriteDiagram_SidecarFailure(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	diag.Metadata.Author = "jane"
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	original := diag.Content
+
+	// Only the sidecar rename fails, after the content is already in place
+	ops := osFileOps()
+	ops.rename = func(oldpath, newpath string) error {
+		if strings.HasSuffix(newpath, models.MetadataExtension) {
+			return errInjected
+		}
+		return os.Rename(oldpath, newpath)
+	}
+	helper.repo.files = ops
+
+	diag.Content = "@startuml\n[*] --> Replaced\n@enduml"
+	diag.Metadata.Author = "john"
+	if err := helper.repo.WriteDiagram(diag); err == nil {
+		t.Fatal("WriteDiagram() with a failing sidecar write succeeded")
+	}
+	read, err := helper.repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadDiagram() error = %v", err)
+	}
+	if read.Content != original || read.Metadata.Author != "jane" {
+		t.Errorf("diagram after failed sidecar write = %q by %q, want the original", read.Content, read.Metadata.Author)
+	}
+
+	// A new diagram whose sidecar cannot be written is not left behind
+	gate := helper.CreateTestDiagram("gate", "1.0.0", models.LocationFileInProgress)
+	gate.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(gate); err == nil {
+		t.Fatal("WriteDiagram() of a new diagram with a failing sidecar write succeeded")
+	}
+	if exists, _ := helper.repo.Exists(smmodels.DiagramTypePUML, "gate", "1.0.0", models.LocationFileInProgress); exists {
+		t.Error("new diagram kept after its sidecar write failed")
+	}
+}
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+const (
+	// backupIDFormat formats backup IDs so that they sort chronologically
+	backupIDFormat = "20060102T150405.000000000Z"
+
+	// backupRecordExtension is the extension of the record written next to each backup
+	backupRecordExtension = ".json"
+)
+
+// backupIDRegex matches IDs produced with backupIDFormat
+var backupIDRegex = regexp.MustCompile(`^\d{8}T\d{6}\.\d{9}Z$`)
+
+// backupRecord is the on-disk description of a backup. The content is stored
+// in {id}.puml and the record in {id}.json; a backup without a record is incomplete.
+type backupRecord struct {
+	Location  string          `json:"location"`
+	Reason    string          `json:"reason"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Size      int64           `json:"size"`
+	Metadata  metadataSidecar `json:"metadata"`
+	Checksum  *checksumRecord `json:"checksum,omitempty"`
+}
+
+// parseLocation converts the string form of a location back to a Location
+func parseLocation(s string) (models.Location, bool) {
+	for _, location := range []models.Location{models.LocationFileInProgress, models.LocationFileProducts} {
+		if location.String() == s {
+			return location, true
+		}
+	}
+	return 0, false
+}
+
+// CreateBackup snapshots the content and metadata of a state-machine diagram
+func (r *FileSystemRepository) CreateBackup(diagramType smmodels.DiagramType, name, version string, location models.Location, reason string) (*models.Backup, error) {
+	diag, err := r.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// A products snapshot keeps the checksum so a restore can be verified
+	var checksum *checksumRecord
+	if location == models.LocationFileProducts {
+		recorded, err := r.ReadChecksum(diagramType, name, version)
+		if err != nil && models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+			return nil, err
+		}
+		if recorded != nil {
+			checksum = (*checksumRecord)(recorded)
+		}
+	}
+
+	backupDir := r.pathManager.GetBackupPathWithDiagramType(name, version, diagramType)
+	if err := r.CreateDirectory(backupDir); err != nil {
+		return nil, err
+	}
+
+	// Pick an unused ID; two backups in the same nanosecond get consecutive IDs
+	now := time.Now().UTC()
+	id := now.Format(backupIDFormat)
+	for {
+		if _, err := os.Stat(filepath.Join(backupDir, id+backupRecordExtension)); os.IsNotExist(err) {
+			break
+		}
+		now = now.Add(time.Nanosecond)
+		id = now.Format(backupIDFormat)
+	}
+
+	contentPath := filepath.Join(backupDir, id+models.PlantUMLExtension)
+	if err := r.files.writeFile(contentPath, []byte(diag.Content), 0644); err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to write backup").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("backupPath", contentPath)
+	}
+
+	record := backupRecord{
+		Location:  location.String(),
+		Reason:    reason,
+		CreatedAt: now,
+		Size:      int64(len(diag.Content)),
+		Metadata:  newMetadataSidecar(diag.Metadata),
+		Checksum:  checksum,
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to encode backup record", err).
+			WithContext("backupPath", contentPath)
+	}
+	recordPath := filepath.Join(backupDir, id+backupRecordExtension)
+	if err := r.files.writeFile(recordPath, data, 0644); err != nil {
+		os.Remove(contentPath)
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to write backup record").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("recordPath", recordPath)
+	}
+
+	r.logger.WithFields(map[string]any{
+		"name":     name,
+		"version":  version,
+		"location": location.String(),
+		"reason":   reason,
+		"backupID": id,
+	}).Debug("Backup created")
+
+	return record.backup(id, name, version), nil
+}
+
+// backup converts a record to its public form
+func (rec backupRecord) backup(id, name, version string) *models.Backup {
+	location, _ := parseLocation(rec.Location)
+	return &models.Backup{
+		ID:        id,
+		Name:      name,
+		Version:   version,
+		Location:  location,
+		Reason:    rec.Reason,
+		CreatedAt: rec.CreatedAt,
+		Size:      rec.Size,
+		Checksum:  (*models.ProductChecksum)(rec.Checksum),
+	}
+}
+
+// ListBackups returns the backups of a state-machine diagram, oldest first
+func (r *FileSystemRepository) ListBackups(diagramType smmodels.DiagramType, name, version string) ([]models.Backup, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	backupDir := r.pathManager.GetBackupPathWithDiagramType(name, version, diagramType)
+	entries, err := os.ReadDir(backupDir)
+	if os.IsNotExist(err) {
+		return []models.Backup{}, nil
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read backup directory", err).
+			WithContext("backupDir", backupDir)
+	}
+
+	backups := []models.Backup{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), backupRecordExtension)
+		if !ok || entry.IsDir() || !backupIDRegex.MatchString(id) {
+			continue
+		}
+		record, err := r.readBackupRecord(backupDir, id)
+		if err != nil {
+			// Skip unreadable records rather than hiding every other backup
+			r.logger.WithError(err).Warn("Skipping unreadable backup record")
+			continue
+		}
+		backups = append(backups, *record.backup(id, name, version))
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].ID < backups[j].ID })
+	return backups, nil
+}
+
+// ReadBackup returns the diagram stored in a backup together with the backup description
+func (r *FileSystemRepository) ReadBackup(diagramType smmodels.DiagramType, name, version, id string) (*models.StateMachineDiagram, *models.Backup, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, nil, err
+	}
+	if !backupIDRegex.MatchString(id) {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "invalid backup id", nil).
+			WithContext("backupID", id)
+	}
+
+	backupDir := r.pathManager.GetBackupPathWithDiagramType(name, version, diagramType)
+	record, err := r.readBackupRecord(backupDir, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentPath := filepath.Join(backupDir, id+models.PlantUMLExtension)
+	content, err := os.ReadFile(contentPath)
+	if err != nil {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeCorruption, "backup content is missing", err).
+			WithContext("backupID", id).
+			WithContext("backupPath", contentPath)
+	}
+
+	backup := record.backup(id, name, version)
+	diag := &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     string(content),
+		Location:    backup.Location,
+		DiagramType: diagramType,
+		Metadata:    record.Metadata.metadata(),
+	}
+	return diag, backup, nil
+}
+
+// PruneBackups deletes the oldest backups of a state-machine diagram so that at
+// most keep remain. A keep of 0 or less keeps every backup.
+func (r *FileSystemRepository) PruneBackups(diagramType smmodels.DiagramType, name, version string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := r.ListBackups(diagramType, name, version)
+	if err != nil {
+		return err
+	}
+
+	backupDir := r.pathManager.GetBackupPathWithDiagramType(name, version, diagramType)
+	for _, backup := range backups[:max(len(backups)-keep, 0)] {
+		// Remove the record first so a partial removal never lists a backup without content
+		recordPath := filepath.Join(backupDir, backup.ID+backupRecordExtension)
+		if err := os.Remove(recordPath); err != nil && !os.IsNotExist(err) {
+			return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to remove backup", err).
+				WithContext("recordPath", recordPath)
+		}
+		os.Remove(filepath.Join(backupDir, backup.ID+models.PlantUMLExtension))
+	}
+	return nil
+}
+
+// readBackupRecord loads the record of a backup
+func (r *FileSystemRepository) readBackupRecord(backupDir, id string) (backupRecord, error) {
+	recordPath := filepath.Join(backupDir, id+backupRecordExtension)
+	data, err := os.ReadFile(recordPath)
+	if os.IsNotExist(err) {
+		return backupRecord{}, models.NewStateMachineError(models.ErrorTypeFileNotFound, "backup not found", err).
+			WithContext("backupID", id)
+	} else if err != nil {
+		return backupRecord{}, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read backup record", err).
+			WithContext("recordPath", recordPath)
+	}
+
+	var record backupRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return backupRecord{}, models.NewStateMachineError(models.ErrorTypeCorruption, "failed to parse backup record", err).
+			WithContext("recordPath", recordPath)
+	}
+	if _, ok := parseLocation(record.Location); !ok {
+		return backupRecord{}, models.NewStateMachineError(models.ErrorTypeCorruption, "backup record has an unknown location", nil).
+			WithContext("recordPath", recordPath).
+			WithContext("location", record.Location)
+	}
+	return record, nil
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestFileSystemRepository_Backups(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	diag.Metadata.Author = "jane"
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	original := diag.Content
+
+	first, err := helper.repo.CreateBackup(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.BackupReasonUpdate)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if first.Size != int64(len(original)) || first.Reason != models.BackupReasonUpdate || first.Location != models.LocationFileInProgress {
+		t.Errorf("CreateBackup() = %+v", first)
+	}
+
+	diag.Content = "@startuml\n[*] --> Open\n@enduml"
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	second, err := helper.repo.CreateBackup(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.BackupReasonDelete)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+
+	backups, err := helper.repo.ListBackups(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 || backups[0].ID != first.ID || backups[1].ID != second.ID {
+		t.Fatalf("ListBackups() = %+v, want the two backups oldest first", backups)
+	}
+
+	restored, backup, err := helper.repo.ReadBackup(smmodels.DiagramTypePUML, "door", "1.0.0", first.ID)
+	if err != nil {
+		t.Fatalf("ReadBackup() error = %v", err)
+	}
+	if restored.Content != original || restored.Metadata.Author != "jane" || backup.ID != first.ID {
+		t.Errorf("ReadBackup() = %+v, %+v", restored, backup)
+	}
+
+	if err := helper.repo.PruneBackups(smmodels.DiagramTypePUML, "door", "1.0.0", 1); err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	backups, _ = helper.repo.ListBackups(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if len(backups) != 1 || backups[0].ID != second.ID {
+		t.Errorf("ListBackups() after prune = %+v, want only the newest", backups)
+	}
+	if _, err := os.Stat(filepath.Join(helper.repo.pathManager.GetBackupPathWithDiagramType("door", "1.0.0", smmodels.DiagramTypePUML), first.ID+models.PlantUMLExtension)); !os.IsNotExist(err) {
+		t.Errorf("pruned backup content still exists: %v", err)
+	}
+}
+
+func TestFileSystemRepository_Backups_ProductChecksum(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileProducts)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	checksum := models.ProductChecksum{Digest: models.ContentDigest(diag.Content), KeyID: "release", Signature: []byte{1, 2, 3}}
+	if err := helper.repo.WriteChecksum(smmodels.DiagramTypePUML, "door", "1.0.0", checksum); err != nil {
+		t.Fatalf("WriteChecksum() error = %v", err)
+	}
+
+	created, err := helper.repo.CreateBackup(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts, models.BackupReasonDelete)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	// The backup keeps the checksum after the product and its checksum are gone
+	if err := helper.repo.DeleteChecksum(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("DeleteChecksum() error = %v", err)
+	}
+	_, backup, err := helper.repo.ReadBackup(smmodels.DiagramTypePUML, "door", "1.0.0", created.ID)
+	if err != nil {
+		t.Fatalf("ReadBackup() error = %v", err)
+	}
+	if backup.Checksum == nil || backup.Checksum.Digest != checksum.Digest || backup.Checksum.KeyID != "release" || string(backup.Checksum.Signature) != string(checksum.Signature) {
+		t.Errorf("ReadBackup() checksum = %+v, want %+v", backup.Checksum, checksum)
+	}
+}
+
+func TestFileSystemRepository_Backups_Errors(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	backups, err := helper.repo.ListBackups(smmodels.DiagramTypePUML, "none", "1.0.0")
+	if err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups() without backups = %v, %v, want empty", backups, err)
+	}
+
+	if _, err := helper.repo.CreateBackup(smmodels.DiagramTypePUML, "none", "1.0.0", models.LocationFileInProgress, models.BackupReasonUpdate); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("CreateBackup() of a missing diagram error = %v, want file not found", err)
+	}
+
+	if _, _, err := helper.repo.ReadBackup(smmodels.DiagramTypePUML, "none", "1.0.0", "../../secret"); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadBackup() with a path id error = %v, want validation error", err)
+	}
+	if _, _, err := helper.repo.ReadBackup(smmodels.DiagramTypePUML, "none", "1.0.0", "20240101T000000.000000000Z"); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadBackup() of a missing backup error = %v, want file not found", err)
+	}
+
+	// A corrupt record is skipped by listing and reported by reading
+	backupDir := helper.repo.pathManager.GetBackupPathWithDiagramType("none", "1.0.0", smmodels.DiagramTypePUML)
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(backupDir, "20240101T000000.000000000Z.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if backups, err := helper.repo.ListBackups(smmodels.DiagramTypePUML, "none", "1.0.0"); err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups() with a corrupt record = %v, %v, want empty", backups, err)
+	}
+	if _, _, err := helper.repo.ReadBackup(smmodels.DiagramTypePUML, "none", "1.0.0", "20240101T000000.000000000Z"); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadBackup() of a corrupt record error = %v, want corruption", err)
+	}
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// ListBlocks returns the @startuml blocks of a state-machine diagram file
+func (r *FileSystemRepository) ListBlocks(diagramType smmodels.DiagramType, name, version string, location models.Location) ([]models.Block, error) {
+	diag, err := r.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.SplitBlocks(diag.Content), nil
+}
+
+// ReadBlock reads a single @startuml block of a state-machine diagram file by id
+func (r *FileSystemRepository) ReadBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.Block, error) {
+	if blockID == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "block id cannot be empty", nil).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+
+	diag, err := r.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := models.FindBlock(diag.Content, blockID)
+	if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "block not found in state-machine diagram").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("block", blockID)
+	}
+
+	return block, nil
+}
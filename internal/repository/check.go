@@ -0,0 +1,429 @@
+package repository
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// checkedDiagramTypes are the diagram types an integrity check scans
+var checkedDiagramTypes = []smmodels.DiagramType{smmodels.DiagramTypePUML}
+
+// checkItem is a finding together with the files its repair acts on
+type checkItem struct {
+	finding   models.Finding
+	paths     []string // the offending file followed by the files that go with it
+	removable bool     // a fix removes the files instead of quarantining them
+	lockFile  bool     // an unused lock file, removed only while locked
+}
+
+// integrityCheck collects the findings of one CheckIntegrity call
+type integrityCheck struct {
+	repo  *FileSystemRepository
+	items []*checkItem
+	files int
+}
+
+// add records a finding
+func (c *integrityCheck) add(findingType models.FindingType, message string, paths ...string) *checkItem {
+	item := &checkItem{
+		finding: models.Finding{Type: findingType, Path: paths[0], Message: message},
+		paths:   paths,
+	}
+	c.items = append(c.items, item)
+	return item
+}
+
+// addLeftover records a temp file left behind by an interrupted write
+func (c *integrityCheck) addLeftover(path string) {
+	c.add(models.FindingLeftoverTempFile, "temp file left by an interrupted write", path).removable = true
+}
+
+// isLeftover reports whether a file name is that of a temp file written by fileOps.writeFile
+func isLeftover(fileName string) bool {
+	return strings.HasPrefix(fileName, ".") && strings.Contains(fileName, ".tmp-")
+}
+
+// readDirIfExists lists a directory, treating a missing one as empty
+func readDirIfExists(dir string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read directory", err).
+			WithContext("dir", dir)
+	}
+	return entries, nil
+}
+
+// CheckIntegrity scans the whole root directory for inconsistencies: badly
+// named or oversized diagrams, orphaned or corrupt metadata sidecars, versions
+// present in both locations, incomplete backups and revisions, temp files of
+// interrupted writes and stale locks. With options.Repair set each finding is
+// then repaired. Callers should hold the root lock so no write runs meanwhile.
+func (r *FileSystemRepository) CheckIntegrity(options models.CheckOptions) (*models.CheckReport, error) {
+	c := &integrityCheck{repo: r}
+
+	for _, diagramType := range checkedDiagramTypes {
+		if err := c.checkLocations(diagramType); err != nil {
+			return nil, err
+		}
+		if err := c.checkRecords(r.pathManager.GetBackupRootWithDiagramType(diagramType), "backup", backupIDRegex.MatchString); err != nil {
+			return nil, err
+		}
+		if err := c.checkRecords(r.pathManager.GetHistoryRootWithDiagramType(diagramType), "revision", isRevisionFileName); err != nil {
+			return nil, err
+		}
+		if err := c.checkLeftovers(r.pathManager.GetChecksumRootWithDiagramType(diagramType)); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.checkLeftovers(r.pathManager.GetIndexPath()); err != nil {
+		return nil, err
+	}
+	if err := c.checkLocks(); err != nil {
+		return nil, err
+	}
+
+	report := &models.CheckReport{Findings: []models.Finding{}, FilesChecked: c.files}
+	if options.Repair != models.RepairNone && len(c.items) > 0 {
+		quarantineDir := filepath.Join(r.pathManager.GetQuarantinePath(), time.Now().UTC().Format(backupIDFormat))
+		for _, item := range c.items {
+			r.repair(item, options.Repair, quarantineDir)
+			if item.finding.Action == models.RepairActionQuarantined {
+				report.QuarantineDir = quarantineDir
+			}
+		}
+	}
+	for _, item := range c.items {
+		report.Findings = append(report.Findings, item.finding)
+	}
+
+	r.logger.WithFields(map[string]any{
+		"filesChecked": report.FilesChecked,
+		"findings":     len(report.Findings),
+	}).Info("Integrity check finished")
+	return report, nil
+}
+
+// checkLocations checks the diagrams and sidecars of both locations and the
+// versions they have in common
+func (c *integrityCheck) checkLocations(diagramType smmodels.DiagramType) error {
+	// Valid diagrams by file name, kept to find versions present in both locations
+	valid := make(map[models.Location]map[string]*models.PathInfo)
+	var inProgress []string
+
+	for _, location := range []models.Location{models.LocationFileInProgress, models.LocationFileProducts} {
+		dir := c.repo.pathManager.GetLocationWithDiagramTypePath(location, diagramType)
+		entries, err := readDirIfExists(dir)
+		if err != nil {
+			return err
+		}
+
+		names := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			names[entry.Name()] = true
+		}
+		valid[location] = make(map[string]*models.PathInfo)
+
+		for _, entry := range entries {
+			fileName := entry.Name()
+			filePath := filepath.Join(dir, fileName)
+
+			switch {
+			case isLeftover(fileName):
+				c.addLeftover(filePath)
+			case strings.HasPrefix(fileName, "."):
+				// Hidden files belong to other tools
+			case entry.IsDir():
+				c.add(models.FindingInvalidName, "directory inside a location", filePath)
+			case strings.HasSuffix(fileName, models.MetadataExtension):
+				c.files++
+				c.checkSidecar(filePath, names[strings.TrimSuffix(fileName, models.MetadataExtension)+models.PlantUMLExtension])
+			default:
+				c.files++
+				paths := []string{filePath}
+				metaName := strings.TrimSuffix(fileName, models.PlantUMLExtension) + models.MetadataExtension
+				if names[metaName] {
+					paths = append(paths, filepath.Join(dir, metaName))
+				}
+
+				pathInfo, err := c.repo.pathManager.ParseFileName(diagramType, fileName)
+				if err != nil {
+					c.add(models.FindingInvalidName, skipReason(err), paths...)
+					continue
+				}
+				info, err := entry.Info()
+				if err != nil {
+					return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to get file info", err).
+						WithContext("filePath", filePath)
+				}
+				if info.Size() > c.repo.config.MaxFileSize {
+					item := c.add(models.FindingOversizedFile, "file size exceeds maximum allowed", paths...)
+					item.finding.Name, item.finding.Version = pathInfo.Name, pathInfo.Version
+					continue
+				}
+
+				valid[location][fileName] = pathInfo
+				if location == models.LocationFileInProgress {
+					inProgress = append(inProgress, fileName)
+				}
+			}
+		}
+	}
+
+	// The products copy is the released one, so the in-progress copy is the one repaired
+	for _, fileName := range inProgress {
+		pathInfo, ok := valid[models.LocationFileProducts][fileName]
+		if !ok {
+			continue
+		}
+		if err := c.checkDuplicate(diagramType, pathInfo.Name, pathInfo.Version); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSidecar checks that a metadata sidecar has a diagram and can be parsed
+func (c *integrityCheck) checkSidecar(metaPath string, hasDiagram bool) {
+	if !hasDiagram {
+		c.add(models.FindingOrphanedMetadata, "metadata sidecar without a state-machine diagram", metaPath)
+		return
+	}
+
+	data, err := os.ReadFile(metaPath)
+	if err == nil {
+		var sidecar metadataSidecar
+		err = json.Unmarshal(data, &sidecar)
+	}
+	if err != nil {
+		c.add(models.FindingCorruptMetadata, "failed to read metadata sidecar: "+err.Error(), metaPath)
+	}
+}
+
+// checkDuplicate reports a version present in both locations. When both copies
+// are identical the in-progress one is redundant and a fix removes it.
+func (c *integrityCheck) checkDuplicate(diagramType smmodels.DiagramType, name, version string) error {
+	pm := c.repo.pathManager
+	inProgressPath := pm.GetDiagramFilePathWithDiagramType(name, version, models.LocationFileInProgress, diagramType)
+	productsPath := pm.GetDiagramFilePathWithDiagramType(name, version, models.LocationFileProducts, diagramType)
+
+	inProgressContent, err := os.ReadFile(inProgressPath)
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read state-machine diagram file", err).
+			WithContext("filePath", inProgressPath)
+	}
+	productsContent, err := os.ReadFile(productsPath)
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read state-machine diagram file", err).
+			WithContext("filePath", productsPath)
+	}
+
+	paths := []string{inProgressPath}
+	metaPath := pm.GetMetadataFilePathWithDiagramType(name, version, models.LocationFileInProgress, diagramType)
+	if _, err := os.Stat(metaPath); err == nil {
+		paths = append(paths, metaPath)
+	}
+
+	identical := bytes.Equal(inProgressContent, productsContent)
+	message := "version also in products with different content"
+	if identical {
+		message = "version also in products with identical content"
+	}
+	item := c.add(models.FindingDuplicateVersion, message, paths...)
+	item.finding.Name, item.finding.Version = name, version
+	item.removable = identical
+	return nil
+}
+
+// isRevisionFileName reports whether base is the zero-padded number of a revision
+func isRevisionFileName(base string) bool {
+	number, err := strconv.Atoi(base)
+	return err == nil && number >= 1 && base == revisionFileName(number)
+}
+
+// checkRecords checks that every backup or revision under root has both its
+// {id}.puml content and its {id}.json record
+func (c *integrityCheck) checkRecords(root, kind string, validID func(string) bool) error {
+	diagramDirs, err := readDirIfExists(root)
+	if err != nil {
+		return err
+	}
+
+	for _, diagramDir := range diagramDirs {
+		if !diagramDir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, diagramDir.Name())
+		entries, err := readDirIfExists(dir)
+		if err != nil {
+			return err
+		}
+
+		var ids []string
+		contents := make(map[string]bool)
+		records := make(map[string]bool)
+		for _, entry := range entries {
+			fileName := entry.Name()
+			if isLeftover(fileName) {
+				c.addLeftover(filepath.Join(dir, fileName))
+				continue
+			}
+			if entry.IsDir() {
+				continue
+			}
+
+			id, isContent := strings.CutSuffix(fileName, models.PlantUMLExtension)
+			if !isContent {
+				var isRecord bool
+				if id, isRecord = strings.CutSuffix(fileName, ".json"); !isRecord {
+					continue
+				}
+			}
+			if !validID(id) {
+				continue
+			}
+
+			c.files++
+			if !contents[id] && !records[id] {
+				ids = append(ids, id)
+			}
+			if isContent {
+				contents[id] = true
+			} else {
+				records[id] = true
+			}
+		}
+
+		for _, id := range ids {
+			var item *checkItem
+			switch {
+			case !records[id]:
+				item = c.add(models.FindingIncompleteRecord, kind+" has no record", filepath.Join(dir, id+models.PlantUMLExtension))
+			case !contents[id]:
+				item = c.add(models.FindingIncompleteRecord, kind+" record has no content", filepath.Join(dir, id+".json"))
+			default:
+				continue
+			}
+			if pathInfo, err := c.repo.pathManager.ParseDirectoryName(diagramDir.Name()); err == nil {
+				item.finding.Name, item.finding.Version = pathInfo.Name, pathInfo.Version
+			}
+		}
+	}
+	return nil
+}
+
+// checkLeftovers reports the temp files under root and its subdirectories
+func (c *integrityCheck) checkLeftovers(root string) error {
+	entries, err := readDirIfExists(root)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(root, entry.Name())
+		if entry.IsDir() {
+			if err := c.checkLeftovers(path); err != nil {
+				return err
+			}
+		} else if isLeftover(entry.Name()) {
+			c.addLeftover(path)
+		}
+	}
+	return nil
+}
+
+// checkLocks reports diagram lock files that no process holds, left by a
+// holder that died before releasing them, and the leftovers of earlier lock
+// breaks. The root lock file is shared by every locker and is always kept.
+func (c *integrityCheck) checkLocks() error {
+	locksPath := c.repo.pathManager.GetLocksPath()
+	if err := c.checkLeftovers(locksPath); err != nil {
+		return err
+	}
+
+	for _, diagramType := range checkedDiagramTypes {
+		dir := filepath.Join(locksPath, diagramType.String())
+		entries, err := readDirIfExists(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".lock") {
+				continue
+			}
+			lockPath := filepath.Join(dir, entry.Name())
+			unused, err := c.repo.lockFileUnused(lockPath, false)
+			if err != nil {
+				return err
+			}
+			if unused {
+				item := c.add(models.FindingStaleLock, "lock file left by a process that is no longer running", lockPath)
+				item.removable = true
+				item.lockFile = true
+			}
+		}
+	}
+	return nil
+}
+
+// repair applies the repair mode to one finding, recording what was done
+func (r *FileSystemRepository) repair(item *checkItem, mode models.RepairMode, quarantineDir string) {
+	opLogger := r.logger.WithFields(map[string]any{
+		"finding": string(item.finding.Type),
+		"path":    item.finding.Path,
+	})
+
+	// Lock files are only removed while locked, so a process taking the lock
+	// meanwhile keeps it; moving one to quarantine would not be safe
+	if item.lockFile {
+		if _, err := r.lockFileUnused(item.finding.Path, true); err != nil {
+			opLogger.WithError(err).Warn("Failed to remove lock file during repair")
+			item.finding.RepairError = err.Error()
+			return
+		}
+		item.finding.Action = models.RepairActionRemoved
+		return
+	}
+
+	if mode == models.RepairFix && item.removable {
+		for _, path := range item.paths {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				opLogger.WithError(err).Warn("Failed to remove file during repair")
+				item.finding.RepairError = err.Error()
+				return
+			}
+		}
+		item.finding.Action = models.RepairActionRemoved
+		return
+	}
+
+	for _, path := range item.paths {
+		if err := r.quarantine(path, quarantineDir); err != nil {
+			opLogger.WithError(err).Warn("Failed to quarantine file during repair")
+			item.finding.RepairError = err.Error()
+			return
+		}
+	}
+	item.finding.Action = models.RepairActionQuarantined
+}
+
+// quarantine moves a file under quarantineDir, keeping its path relative to the root
+func (r *FileSystemRepository) quarantine(path, quarantineDir string) error {
+	rel, err := filepath.Rel(r.pathManager.GetRootPath(), path)
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(quarantineDir, rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, dest)
+}
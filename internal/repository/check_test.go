@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// writeCheckFile creates a file below the root directory of a test repository
+func writeCheckFile(t *testing.T, helper *TestHelper, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(helper.tempDir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// seedInconsistencies plants one file for every kind of finding next to a healthy diagram
+func seedInconsistencies(t *testing.T, helper *TestHelper) {
+	t.Helper()
+	healthy := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	healthy.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(healthy); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	content := "@startuml\n[*] --> Closed\n@enduml"
+	writeCheckFile(t, helper, "in-progress/puml/notes.puml", content)
+	writeCheckFile(t, helper, "in-progress/puml/gone-1.0.0.meta.json", `{"author":"jane"}`)
+	writeCheckFile(t, helper, "in-progress/puml/window-1.0.0.puml", content)
+	writeCheckFile(t, helper, "in-progress/puml/window-1.0.0.meta.json", "{not json")
+	writeCheckFile(t, helper, "in-progress/puml/gate-1.0.0.puml", content)
+	writeCheckFile(t, helper, "products/puml/gate-1.0.0.puml", content)
+	writeCheckFile(t, helper, "in-progress/puml/fence-1.0.0.puml", content+"\n' draft")
+	writeCheckFile(t, helper, "products/puml/fence-1.0.0.puml", content)
+	writeCheckFile(t, helper, "products/puml/.door-1.0.0.puml.tmp-123", "partial")
+	writeCheckFile(t, helper, "history/puml/door-1.0.0/000001.puml", content)
+
+	host, _ := os.Hostname()
+	lockPath := helper.repo.pathManager.GetLockPathWithDiagramType("door", "1.0.0", smmodels.DiagramTypePUML)
+	writeLockFile(t, lockPath, lockOwner{PID: 1 << 30, Host: host, AcquiredAt: time.Now()})
+}
+
+func TestFileSystemRepository_CheckIntegrity(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	report, err := helper.repo.CheckIntegrity(models.CheckOptions{})
+	if err != nil || len(report.Findings) != 0 {
+		t.Fatalf("CheckIntegrity() of an empty root = %+v, %v, want no findings", report, err)
+	}
+
+	seedInconsistencies(t, helper)
+	report, err = helper.repo.CheckIntegrity(models.CheckOptions{})
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+
+	want := map[models.FindingType]int{
+		models.FindingInvalidName:      1,
+		models.FindingOrphanedMetadata: 1,
+		models.FindingCorruptMetadata:  1,
+		models.FindingDuplicateVersion: 2,
+		models.FindingIncompleteRecord: 1,
+		models.FindingLeftoverTempFile: 1,
+		models.FindingStaleLock:        1,
+	}
+	got := map[models.FindingType]int{}
+	for _, finding := range report.Findings {
+		got[finding.Type]++
+		if finding.Action != models.RepairActionNone {
+			t.Errorf("finding %+v was repaired without a repair mode", finding)
+		}
+	}
+	for findingType, count := range want {
+		if got[findingType] != count {
+			t.Errorf("%s findings = %d, want %d in %+v", findingType, got[findingType], count, report.Findings)
+		}
+	}
+	if report.FilesChecked == 0 || report.QuarantineDir != "" {
+		t.Errorf("CheckIntegrity() = %+v, want files counted and nothing quarantined", report)
+	}
+
+	// A file over MaxFileSize is only reported once it is too large
+	helper.repo.config.MaxFileSize = 10
+	report, _ = helper.repo.CheckIntegrity(models.CheckOptions{})
+	oversized := 0
+	for _, finding := range report.Findings {
+		if finding.Type == models.FindingOversizedFile {
+			oversized++
+		}
+	}
+	if oversized == 0 {
+		t.Errorf("CheckIntegrity() with a small MaxFileSize = %+v, want oversized files", report.Findings)
+	}
+}
+
+func TestFileSystemRepository_CheckIntegrity_Fix(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+	seedInconsistencies(t, helper)
+
+	report, err := helper.repo.CheckIntegrity(models.CheckOptions{Repair: models.RepairFix})
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	for _, finding := range report.Findings {
+		if finding.Action == models.RepairActionNone || finding.RepairError != "" {
+			t.Errorf("finding %+v was not repaired", finding)
+		}
+	}
+	if report.QuarantineDir == "" {
+		t.Fatal("CheckIntegrity() did not report its quarantine directory")
+	}
+
+	// The identical in-progress copy is removed; the differing one is kept aside
+	if _, err := os.Stat(filepath.Join(helper.tempDir, "in-progress/puml/gate-1.0.0.puml")); !os.IsNotExist(err) {
+		t.Error("identical duplicate was not removed")
+	}
+	if _, err := os.Stat(filepath.Join(report.QuarantineDir, "in-progress/puml/fence-1.0.0.puml")); err != nil {
+		t.Errorf("differing duplicate was not quarantined: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(report.QuarantineDir, "in-progress/puml/window-1.0.0.meta.json")); err != nil {
+		t.Errorf("corrupt sidecar was not quarantined: %v", err)
+	}
+	if exists, _ := helper.repo.Exists(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); !exists {
+		t.Error("healthy diagram was touched by the repair")
+	}
+
+	report, err = helper.repo.CheckIntegrity(models.CheckOptions{})
+	if err != nil || len(report.Findings) != 0 {
+		t.Errorf("CheckIntegrity() after the repair = %+v, %v, want no findings", report.Findings, err)
+	}
+}
+
+func TestFileSystemRepository_CheckIntegrity_Quarantine(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+	writeCheckFile(t, helper, "in-progress/puml/gate-1.0.0.puml", "@startuml\n@enduml")
+	writeCheckFile(t, helper, "in-progress/puml/gate-1.0.0.meta.json", `{"author":"jane"}`)
+	writeCheckFile(t, helper, "products/puml/gate-1.0.0.puml", "@startuml\n@enduml")
+
+	// Quarantine keeps even a redundant copy, together with its sidecar
+	report, err := helper.repo.CheckIntegrity(models.CheckOptions{Repair: models.RepairQuarantine})
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].Action != models.RepairActionQuarantined || report.Findings[0].Name != "gate" {
+		t.Fatalf("CheckIntegrity() = %+v, want the duplicate quarantined", report.Findings)
+	}
+	for _, rel := range []string{"in-progress/puml/gate-1.0.0.puml", "in-progress/puml/gate-1.0.0.meta.json"} {
+		if _, err := os.Stat(filepath.Join(report.QuarantineDir, rel)); err != nil {
+			t.Errorf("%s was not quarantined: %v", rel, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// checksumRecord is the on-disk format of the checksum of a products diagram
+type checksumRecord struct {
+	Digest    string    `json:"digest"`
+	KeyID     string    `json:"keyId,omitempty"`
+	Signature []byte    `json:"signature,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// validateChecksumKey checks the name and version a checksum is stored under
+func (r *FileSystemRepository) validateChecksumKey(name, version string) error {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return err
+	}
+	if version == "" {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name)
+	}
+	return nil
+}
+
+// WriteChecksum records the checksum of a products diagram under {root}/checksums,
+// replacing any earlier one
+func (r *FileSystemRepository) WriteChecksum(diagramType smmodels.DiagramType, name, version string, checksum models.ProductChecksum) error {
+	if err := r.validateChecksumKey(name, version); err != nil {
+		return err
+	}
+
+	checksumPath := r.pathManager.GetChecksumPathWithDiagramType(name, version, diagramType)
+	data, err := json.MarshalIndent(checksumRecord(checksum), "", "  ")
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to encode checksum", err).
+			WithContext("checksumPath", checksumPath)
+	}
+	if err := r.CreateDirectory(filepath.Dir(checksumPath)); err != nil {
+		return err
+	}
+	if err := r.files.writeFile(checksumPath, data, 0644); err != nil {
+		return models.WrapError(err, models.ErrorTypeFileSystem, "failed to write checksum").
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	return nil
+}
+
+// ReadChecksum returns the checksum recorded for a products diagram
+func (r *FileSystemRepository) ReadChecksum(diagramType smmodels.DiagramType, name, version string) (*models.ProductChecksum, error) {
+	if err := r.validateChecksumKey(name, version); err != nil {
+		return nil, err
+	}
+
+	checksumPath := r.pathManager.GetChecksumPathWithDiagramType(name, version, diagramType)
+	data, err := os.ReadFile(checksumPath)
+	if os.IsNotExist(err) {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "no checksum recorded", nil).
+			WithContext("name", name).
+			WithContext("version", version)
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read checksum", err).
+			WithContext("checksumPath", checksumPath)
+	}
+
+	var record checksumRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeCorruption, "failed to parse checksum", err).
+			WithContext("checksumPath", checksumPath)
+	}
+	checksum := models.ProductChecksum(record)
+	return &checksum, nil
+}
+
+// DeleteChecksum removes the checksum of a products diagram, if any
+func (r *FileSystemRepository) DeleteChecksum(diagramType smmodels.DiagramType, name, version string) error {
+	if err := r.validateChecksumKey(name, version); err != nil {
+		return err
+	}
+
+	checksumPath := r.pathManager.GetChecksumPathWithDiagramType(name, version, diagramType)
+	if err := os.Remove(checksumPath); err != nil && !os.IsNotExist(err) {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to delete checksum", err).
+			WithContext("checksumPath", checksumPath)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// checksumStore is the checksum part shared by the repositories under test
+type checksumStore interface {
+	WriteChecksum(diagramType smmodels.DiagramType, name, version string, checksum models.ProductChecksum) error
+	ReadChecksum(diagramType smmodels.DiagramType, name, version string) (*models.ProductChecksum, error)
+	DeleteChecksum(diagramType smmodels.DiagramType, name, version string) error
+}
+
+func TestChecksums(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	repos := map[string]checksumStore{
+		"filesystem": helper.repo,
+		"memory":     NewMemoryRepository(nil),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			if _, err := repo.ReadChecksum(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+				t.Errorf("ReadChecksum() before any write error = %v, want not found", err)
+			}
+
+			want := models.ProductChecksum{
+				Digest:    models.ContentDigest("@startuml\n@enduml"),
+				KeyID:     "release",
+				Signature: []byte{1, 2, 3},
+				CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+			}
+			if err := repo.WriteChecksum(smmodels.DiagramTypePUML, "door", "1.0.0", want); err != nil {
+				t.Fatalf("WriteChecksum() error = %v", err)
+			}
+			got, err := repo.ReadChecksum(smmodels.DiagramTypePUML, "door", "1.0.0")
+			if err != nil {
+				t.Fatalf("ReadChecksum() error = %v", err)
+			}
+			if got.Digest != want.Digest || got.KeyID != want.KeyID || string(got.Signature) != string(want.Signature) || !got.CreatedAt.Equal(want.CreatedAt) {
+				t.Errorf("ReadChecksum() = %+v, want %+v", got, want)
+			}
+
+			if err := repo.DeleteChecksum(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+				t.Fatalf("DeleteChecksum() error = %v", err)
+			}
+			if err := repo.DeleteChecksum(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+				t.Errorf("DeleteChecksum() of a missing checksum error = %v", err)
+			}
+			if _, err := repo.ReadChecksum(smmodels.DiagramTypePUML, "door", ""); models.GetErrorType(err) != models.ErrorTypeValidation {
+				t.Errorf("ReadChecksum() without version error = %v, want validation error", err)
+			}
+		})
+	}
+}
+
+func TestFileSystemRepository_ReadChecksum_Corrupt(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	checksumPath := helper.repo.pathManager.GetChecksumPathWithDiagramType("door", "1.0.0", smmodels.DiagramTypePUML)
+	writeCheckFile(t, helper, "checksums/puml/door-1.0.0.json", "{not json")
+	if _, err := os.Stat(checksumPath); err != nil {
+		t.Fatalf("checksum written outside its path: %v", err)
+	}
+	if _, err := helper.repo.ReadChecksum(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadChecksum() of an unparsable record error = %v, want corruption", err)
+	}
+}
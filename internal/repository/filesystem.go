@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"os"
+	"time"
 
 	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/logging"
@@ -14,6 +15,7 @@ type FileSystemRepository struct {
 	pathManager *models.PathManager
 	config      *models.Config
 	logger      *logging.Logger
+	files       fileOps
 }
 
 // NewFileSystemRepository creates a new FileSystemRepository
@@ -47,7 +49,9 @@ func NewFileSystemRepository(config *models.Config) *FileSystemRepository {
 		pathManager: pathManager,
 		config:      config,
 		logger:      logger,
+		files:       osFileOps(),
 	}
+	repo.files.logger = logger
 
 	repo.logger.WithField("rootDirectory", config.RootDirectory).Info("FileSystemRepository initialized")
 	return repo
@@ -156,6 +160,18 @@ func (r *FileSystemRepository) ReadDiagram(diagramType smmodels.DiagramType, nam
 		return nil, infoErr
 	}
 
+	// Load the metadata sidecar, falling back to the file modification time for files written without one
+	metadata, found, err := r.readSidecar(diagramType, name, version, location)
+	if err != nil {
+		opLogger.WithError(err).Warn("Failed to read metadata sidecar, using file timestamps")
+	}
+	if !found {
+		metadata = models.Metadata{
+			CreatedAt:  fileInfo.ModTime(),
+			ModifiedAt: fileInfo.ModTime(),
+		}
+	}
+
 	// Create StateMachineDiagram object
 	opLogger.Debug("Creating state-machine diagram object")
 	diag := &models.StateMachineDiagram{
@@ -164,11 +180,7 @@ func (r *FileSystemRepository) ReadDiagram(diagramType smmodels.DiagramType, nam
 		Content:     string(content),
 		Location:    location,
 		DiagramType: diagramType,
-		Metadata: models.Metadata{
-			ModifiedAt: fileInfo.ModTime(),
-			// CreatedAt and Author would need additional metadata storage
-			CreatedAt: fileInfo.ModTime(), // Using ModTime as fallback
-		},
+		Metadata:    metadata,
 	}
 
 	// TODO: Parse references from content (will be implemented in validation layer)
@@ -215,12 +227,45 @@ func (r *FileSystemRepository) WriteDiagram(diag *models.StateMachineDiagram) er
 		return fmt.Errorf("failed to create directory for state-machine diagram: %w", err)
 	}
 
-	// Write the file
-	if err := os.WriteFile(filePath, []byte(diag.Content), 0644); err != nil {
+	// Keep the previous content so a failed sidecar write can put it back
+	previous, readErr := os.ReadFile(filePath)
+	existed := readErr == nil
+
+	// Write the file through a temp file so a failed write never leaves a truncated diagram
+	if err := r.files.writeFile(filePath, []byte(diag.Content), 0644); err != nil {
 		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to write state-machine diagram file", err).
 			WithContext("filePath", filePath)
 	}
 
+	// Keep the stored metadata, such as the author and creation time, for
+	// every field the caller does not supply
+	metadata := diag.Metadata
+	if existing, found, _ := r.readSidecar(diag.DiagramType, diag.Name, diag.Version, diag.Location); found {
+		metadata = metadata.Merge(existing)
+	}
+	now := time.Now()
+	if metadata.CreatedAt.IsZero() {
+		metadata.CreatedAt = now
+	}
+	if metadata.ModifiedAt.IsZero() {
+		metadata.ModifiedAt = now
+	}
+
+	// An error must mean nothing changed, so the content is rolled back when its metadata cannot follow
+	if err := r.writeSidecar(diag.DiagramType, diag.Name, diag.Version, diag.Location, metadata); err != nil {
+		var rollbackErr error
+		if existed {
+			rollbackErr = r.files.writeFile(filePath, previous, 0644)
+		} else {
+			rollbackErr = r.files.remove(filePath)
+		}
+		if rollbackErr != nil {
+			return models.WrapError(err, models.ErrorTypeFileSystem, "failed to write metadata file").
+				WithContext("filePath", filePath).
+				WithContext("rollbackError", rollbackErr.Error())
+		}
+		return err
+	}
 	return nil
 }
 
@@ -347,13 +392,51 @@ func (r *FileSystemRepository) MoveDiagram(diagramType smmodels.DiagramType, nam
 		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
-	// Move the file
-	if err := os.Rename(sourceFilePath, destFilePath); err != nil {
+	// Move the file without replacing one another process created since the check above
+	if err := moveNoReplace(sourceFilePath, destFilePath); os.IsExist(err) {
+		return models.NewStateMachineError(models.ErrorTypeFileConflict, "destination file already exists", err).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", to.String()).
+			WithContext("destFilePath", destFilePath)
+	} else if err != nil {
 		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to move state-machine diagram file", err).
 			WithContext("sourceFilePath", sourceFilePath).
 			WithContext("destFilePath", destFilePath)
 	}
 
+	// Move the metadata sidecar with it, putting the diagram back if that fails
+	sourceMetaPath := r.pathManager.GetMetadataFilePathWithDiagramType(name, version, from, diagramType)
+	destMetaPath := r.pathManager.GetMetadataFilePathWithDiagramType(name, version, to, diagramType)
+	if _, err := os.Stat(sourceMetaPath); err == nil {
+		if err := os.Rename(sourceMetaPath, destMetaPath); err != nil {
+			if rollbackErr := os.Rename(destFilePath, sourceFilePath); rollbackErr != nil {
+				r.logger.WithError(rollbackErr).Error("Failed to restore state-machine diagram after metadata move failure")
+			}
+			return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to move metadata file", err).
+				WithContext("sourceMetaPath", sourceMetaPath).
+				WithContext("destMetaPath", destMetaPath)
+		}
+	}
+
+	return nil
+}
+
+// moveNoReplace moves src to dst unless dst exists. A hard link makes the check
+// and the move a single step; file systems without hard links fall back to a
+// rename, which relies on the caller's lock to keep dst from appearing.
+func moveNoReplace(src, dst string) error {
+	err := os.Link(src, dst)
+	if os.IsExist(err) {
+		return err
+	}
+	if err != nil {
+		return os.Rename(src, dst)
+	}
+	if err := os.Remove(src); err != nil {
+		os.Remove(dst)
+		return err
+	}
 	return nil
 }
 
@@ -386,11 +469,18 @@ func (r *FileSystemRepository) DeleteDiagram(diagramType smmodels.DiagramType, n
 	}
 
 	// Remove the file
-	if err := os.Remove(filePath); err != nil {
+	if err := r.files.remove(filePath); err != nil {
 		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to delete state-machine diagram file", err).
 			WithContext("filePath", filePath)
 	}
 
+	// The diagram is gone, so a sidecar that cannot be removed is only logged; an
+	// error must mean nothing changed, and CheckIntegrity reports the orphan
+	metaPath := r.pathManager.GetMetadataFilePathWithDiagramType(name, version, location, diagramType)
+	if err := r.files.remove(metaPath); err != nil && !os.IsNotExist(err) {
+		r.logger.WithField("metaPath", metaPath).WithError(err).Warn("State-machine diagram deleted but its metadata file could not be removed")
+	}
+
 	return nil
 }
 
@@ -434,7 +524,9 @@ func (r *FileSystemRepository) ListDiagrams(diagramType smmodels.DiagramType, lo
 		// Try to read the state-machine diagram
 		diag, err := r.ReadDiagram(diagramType, pathInfo.Name, pathInfo.Version, location)
 		if err != nil {
-			// Skip state-machine diagrams that can't be read, but continue processing others
+			// Skip state-machine diagrams that can't be read, but continue processing others;
+			// ListEntries reports them explicitly
+			r.logger.WithField("fileName", entry.Name()).WithError(err).Warn("Skipping unreadable state-machine diagram")
 			continue
 		}
 
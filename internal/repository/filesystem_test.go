@@ -909,3 +909,41 @@ func TestFileSystemRepository_Integration(t *testing.T) {
 		t.Error("State-machine diagram should not exist after delete")
 	}
 }
+
+func TestFileSystemRepository_Blocks(t *testing.T) {
+	th := NewTestHelper(t)
+	defer th.Cleanup()
+
+	diag := th.CreateTestDiagram("multi", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	diag.Content = "@startuml login\n[*] --> Idle\n@enduml\n\n@startuml logout\n[*] --> Done\n@enduml"
+	if err := th.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	blocks, err := th.repo.ListBlocks(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ListBlocks() error = %v", err)
+	}
+	if len(blocks) != 2 || blocks[0].ID != "login" || blocks[1].ID != "logout" {
+		t.Errorf("ListBlocks() = %+v", blocks)
+	}
+
+	block, err := th.repo.ReadBlock(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress, "logout")
+	if err != nil {
+		t.Fatalf("ReadBlock() error = %v", err)
+	}
+	if block.StartLine != 5 || block.Content != "@startuml logout\n[*] --> Done\n@enduml" {
+		t.Errorf("ReadBlock() = %+v", block)
+	}
+
+	_, err = th.repo.ReadBlock(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress, "missing")
+	if models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadBlock() missing block error = %v, want ErrorTypeFileNotFound", err)
+	}
+
+	_, err = th.repo.ReadBlock(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress, "")
+	if models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadBlock() empty id error = %v, want ErrorTypeValidation", err)
+	}
+}
@@ -0,0 +1,536 @@
+package repository
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// GitOptions controls how a GitRepository records commits
+type GitOptions struct {
+	Actor       string // Recorded in every commit; when empty the diagram metadata names the actor
+	AuthorName  string // Commit author and committer; when empty git's own configuration is used
+	AuthorEmail string
+	GitPath     string // git executable; "git" from PATH when empty
+
+	// UseEnclosingRepository commits into a git repository whose work tree
+	// merely contains the root directory, such as the project the root sits
+	// in. By default only a repository whose top level is the root directory
+	// is used, and one is initialized there when there is none.
+	UseEnclosingRepository bool
+}
+
+// gitIgnoredAreas are the root directory entries holding internal state rather
+// than diagrams; the .gitignore written in the root directory lists them
+var gitIgnoredAreas = []string{"/backups/", "/checksums/", "/history/", "/index/", "/locks/", "/quarantine/"}
+
+// GitRepository is a FileSystemRepository that commits every change of a
+// state-machine diagram to the git repository of the root directory.
+// Writes, moves, deletes and metadata changes each become one commit whose
+// message carries the operation, name, version, location and actor as git
+// trailers. Only diagram and metadata files are committed; backups, revision
+// history, checksums, manifests and lock files are listed in .gitignore.
+type GitRepository struct {
+	*FileSystemRepository
+	options  GitOptions
+	gitPath  string
+	workTree string
+	mu       sync.Mutex // keeps each commit to the change that produced it
+}
+
+// NewGitRepository creates a GitRepository on the root directory of config.
+// When the root directory is not the top level of a git work tree a repository
+// is initialized in it, unless options.UseEnclosingRepository allows using the
+// repository that contains it. The git executable must be available.
+func NewGitRepository(config *models.Config, options GitOptions) (*GitRepository, error) {
+	if config == nil {
+		config = models.DefaultConfig()
+	}
+
+	name := options.GitPath
+	if name == "" {
+		name = "git"
+	}
+	gitPath, err := exec.LookPath(name)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration, "git executable not found", err).
+			WithContext("gitPath", name)
+	}
+
+	r := &GitRepository{
+		FileSystemRepository: NewFileSystemRepository(config),
+		options:              options,
+		gitPath:              gitPath,
+		workTree:             config.RootDirectory,
+	}
+	if r.workTree == "" {
+		r.workTree = models.RootDirectoryName
+	}
+
+	if err := r.FileSystemRepository.CreateDirectory(r.workTree); err != nil {
+		return nil, err
+	}
+
+	// A repository that only encloses the root directory belongs to someone
+	// else, so it is not committed to without consent
+	topLevel, owned := r.topLevel()
+	switch {
+	case owned:
+	case topLevel != "" && options.UseEnclosingRepository:
+		r.logger.WithField("repository", topLevel).Info("Committing state-machine diagrams to the enclosing git repository")
+	default:
+		if _, err := r.git("init", "-q"); err != nil {
+			return nil, err
+		}
+		r.logger.WithFields(map[string]any{
+			"workTree":  r.workTree,
+			"enclosing": topLevel,
+		}).Info("Initialized git repository for state-machine diagrams")
+		owned = true
+	}
+
+	if err := r.writeIgnoreFile(owned); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// topLevel returns the top level of the git work tree holding the root
+// directory, or "" when there is none, and whether it is the root directory
+func (r *GitRepository) topLevel() (string, bool) {
+	out, err := r.git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", false
+	}
+	topLevel := filepath.FromSlash(strings.TrimSpace(out))
+
+	topInfo, err := os.Stat(topLevel)
+	if err != nil {
+		return topLevel, false
+	}
+	rootInfo, err := os.Stat(r.workTree)
+	if err != nil {
+		return topLevel, false
+	}
+	return topLevel, os.SameFile(topInfo, rootInfo)
+}
+
+// writeIgnoreFile adds the internal areas of the root directory to its
+// .gitignore so they are neither committed nor reported as untracked. The
+// file is committed only to a repository owned by the root directory.
+func (r *GitRepository) writeIgnoreFile(commit bool) error {
+	ignorePath := filepath.Join(r.workTree, ".gitignore")
+	data, err := os.ReadFile(ignorePath)
+	if err != nil && !os.IsNotExist(err) {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read .gitignore", err).
+			WithContext("ignorePath", ignorePath)
+	}
+
+	existing := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var missing []string
+	for _, area := range gitIgnoredAreas {
+		if !slices.Contains(existing, area) {
+			missing = append(missing, area)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += strings.Join(missing, "\n") + "\n"
+	if err := r.files.writeFile(ignorePath, []byte(content), 0644); err != nil {
+		return models.WrapError(err, models.ErrorTypeFileSystem, "failed to write .gitignore").
+			WithContext("ignorePath", ignorePath)
+	}
+
+	if !commit {
+		return nil
+	}
+	if _, err := r.gitRetrying("add", "--", ".gitignore"); err != nil {
+		r.logger.WithError(err).Warn("Failed to stage .gitignore")
+		return nil
+	}
+	if _, err := r.gitRetrying("commit", "-q", "-m", "Ignore internal state-machine repository files", "--", ".gitignore"); err != nil {
+		r.logger.WithError(err).Warn("Failed to commit .gitignore")
+	}
+	return nil
+}
+
+// gitError is a failed git command
+type gitError struct {
+	exitCode int
+	stderr   string
+	err      error
+}
+
+func (e *gitError) Error() string {
+	if e.stderr != "" {
+		return fmt.Sprintf("%v: %s", e.err, e.stderr)
+	}
+	return e.err.Error()
+}
+
+func (e *gitError) Unwrap() error {
+	return e.err
+}
+
+// git runs a git command in the root directory and returns its standard output
+func (r *GitRepository) git(args ...string) (string, error) {
+	cmd := exec.Command(r.gitPath, args...)
+	cmd.Dir = r.workTree
+	// Paths are passed as literal pathspecs so a name is never read as a glob
+	cmd.Env = append(os.Environ(), "GIT_LITERAL_PATHSPECS=1")
+	if r.options.AuthorName != "" {
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_NAME="+r.options.AuthorName, "GIT_COMMITTER_NAME="+r.options.AuthorName)
+	}
+	if r.options.AuthorEmail != "" {
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_EMAIL="+r.options.AuthorEmail, "GIT_COMMITTER_EMAIL="+r.options.AuthorEmail)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		gitErr := &gitError{exitCode: -1, stderr: strings.TrimSpace(stderr.String()), err: err}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			gitErr.exitCode = exitErr.ExitCode()
+		}
+		return "", models.NewStateMachineError(models.ErrorTypeFileSystem, "git command failed", gitErr).
+			WithContext("command", "git "+strings.Join(args, " ")).
+			WithContext("workTree", r.workTree)
+	}
+	return stdout.String(), nil
+}
+
+// gitRetrying runs a git command that needs the index, retrying up to
+// Config.LockTimeout while another git process holds the index lock
+func (r *GitRepository) gitRetrying(args ...string) (string, error) {
+	deadline := time.Now().Add(r.config.LockTimeout)
+	for {
+		out, err := r.git(args...)
+		if err == nil || !strings.Contains(err.Error(), "index.lock") || !time.Now().Before(deadline) {
+			return out, err
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// gitExitCode returns the exit code of a failed git command, or -1
+func gitExitCode(err error) int {
+	var gitErr *gitError
+	if errors.As(err, &gitErr) {
+		return gitErr.exitCode
+	}
+	return -1
+}
+
+// diagramPaths returns the work tree paths of the content and metadata of a
+// state-machine diagram in the given locations
+func diagramPaths(diagramType smmodels.DiagramType, name, version string, locations ...models.Location) []string {
+	pathManager := models.NewPathManager(".")
+	var paths []string
+	for _, location := range locations {
+		paths = append(paths,
+			filepath.ToSlash(pathManager.GetDiagramFilePathWithDiagramType(name, version, location, diagramType)),
+			filepath.ToSlash(pathManager.GetMetadataFilePathWithDiagramType(name, version, location, diagramType)))
+	}
+	return paths
+}
+
+// gitChange describes the change about to be committed
+type gitChange struct {
+	operation   models.ChangeOperation
+	diagramType smmodels.DiagramType
+	name        string
+	version     string
+	from        models.Location
+	to          models.Location
+	actor       string
+}
+
+// message builds the commit message; the fields are git trailers so that
+// ListChanges and git interpret-trailers can read them back
+func (c gitChange) message() string {
+	var subject string
+	switch c.operation {
+	case models.ChangeWrite:
+		subject = fmt.Sprintf("Write %s-%s in %s", c.name, c.version, c.to)
+	case models.ChangeMove:
+		subject = fmt.Sprintf("Move %s-%s from %s to %s", c.name, c.version, c.from, c.to)
+	case models.ChangeDelete:
+		subject = fmt.Sprintf("Delete %s-%s from %s", c.name, c.version, c.to)
+	case models.ChangeMetadata:
+		subject = fmt.Sprintf("Update metadata of %s-%s in %s", c.name, c.version, c.to)
+	}
+
+	trailers := []string{
+		"Operation: " + string(c.operation),
+		"Diagram-Type: " + c.diagramType.String(),
+		"Name: " + c.name,
+		"Version: " + c.version,
+	}
+	if c.operation == models.ChangeMove {
+		trailers = append(trailers, "From-Location: "+c.from.String())
+	}
+	trailers = append(trailers, "Location: "+c.to.String())
+	if c.actor != "" {
+		trailers = append(trailers, "Actor: "+strings.Join(strings.Fields(c.actor), " "))
+	}
+
+	return subject + "\n\n" + strings.Join(trailers, "\n") + "\n"
+}
+
+// commit records the files of a change in one commit. Commit failures are
+// logged rather than returned because the change itself has already been made.
+func (r *GitRepository) commit(change gitChange) {
+	opLogger := r.logger.WithFields(map[string]any{
+		"operation": string(change.operation),
+		"name":      change.name,
+		"version":   change.version,
+		"location":  change.to.String(),
+	})
+	if err := r.commitChange(change); err != nil {
+		opLogger.WithError(err).Warn("Failed to commit state-machine diagram change to git")
+	}
+}
+
+// commitChange stages and commits only the paths of a change, leaving anything
+// else staged in the work tree alone
+func (r *GitRepository) commitChange(change gitChange) error {
+	candidates := diagramPaths(change.diagramType, change.name, change.version, change.from, change.to)
+
+	// Only paths that exist or are tracked can be staged; a deleted sidecar may be neither
+	tracked, err := r.git(append([]string{"ls-files", "-z", "--"}, candidates...)...)
+	if err != nil {
+		return err
+	}
+	trackedPaths := strings.Split(tracked, "\x00")
+
+	var paths []string
+	for _, path := range candidates {
+		if slices.Contains(paths, path) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(r.workTree, filepath.FromSlash(path))); err == nil || slices.Contains(trackedPaths, path) {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	if _, err := r.gitRetrying(append([]string{"add", "-A", "--"}, paths...)...); err != nil {
+		return err
+	}
+	// Rewriting identical content leaves nothing to commit
+	if _, err := r.git(append([]string{"diff", "--cached", "--quiet", "--"}, paths...)...); err == nil {
+		return nil
+	} else if gitExitCode(err) != 1 {
+		return err
+	}
+	_, err = r.gitRetrying(append([]string{"commit", "-q", "-m", change.message(), "--"}, paths...)...)
+	return err
+}
+
+// actorFor picks the actor recorded for a change: the configured actor, else
+// whoever the metadata names as the last to promote or author the diagram
+func (r *GitRepository) actorFor(metadata *models.Metadata) string {
+	if r.options.Actor != "" {
+		return r.options.Actor
+	}
+	if metadata == nil {
+		return ""
+	}
+	if metadata.PromotedBy != "" {
+		return metadata.PromotedBy
+	}
+	return metadata.Author
+}
+
+// storedActor returns the actor named by the stored metadata of a diagram
+func (r *GitRepository) storedActor(diagramType smmodels.DiagramType, name, version string, location models.Location) string {
+	metadata, err := r.FileSystemRepository.ReadMetadata(diagramType, name, version, location)
+	if err != nil {
+		metadata = nil
+	}
+	return r.actorFor(metadata)
+}
+
+// WriteDiagram writes a state-machine diagram and commits it
+func (r *GitRepository) WriteDiagram(diag *models.StateMachineDiagram) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.FileSystemRepository.WriteDiagram(diag); err != nil {
+		return err
+	}
+	r.commit(gitChange{
+		operation:   models.ChangeWrite,
+		diagramType: diag.DiagramType,
+		name:        diag.Name,
+		version:     diag.Version,
+		from:        diag.Location,
+		to:          diag.Location,
+		actor:       r.actorFor(&diag.Metadata),
+	})
+	return nil
+}
+
+// MoveDiagram moves a state-machine diagram between locations and commits the move
+func (r *GitRepository) MoveDiagram(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	actor := r.storedActor(diagramType, name, version, from)
+	if err := r.FileSystemRepository.MoveDiagram(diagramType, name, version, from, to); err != nil {
+		return err
+	}
+	r.commit(gitChange{
+		operation:   models.ChangeMove,
+		diagramType: diagramType,
+		name:        name,
+		version:     version,
+		from:        from,
+		to:          to,
+		actor:       actor,
+	})
+	return nil
+}
+
+// DeleteDiagram deletes a state-machine diagram and commits the deletion
+func (r *GitRepository) DeleteDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	actor := r.storedActor(diagramType, name, version, location)
+	if err := r.FileSystemRepository.DeleteDiagram(diagramType, name, version, location); err != nil {
+		return err
+	}
+	r.commit(gitChange{
+		operation:   models.ChangeDelete,
+		diagramType: diagramType,
+		name:        name,
+		version:     version,
+		from:        location,
+		to:          location,
+		actor:       actor,
+	})
+	return nil
+}
+
+// WriteMetadata replaces the metadata of a state-machine diagram and commits it
+func (r *GitRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.FileSystemRepository.WriteMetadata(diagramType, name, version, location, metadata); err != nil {
+		return err
+	}
+	r.commit(gitChange{
+		operation:   models.ChangeMetadata,
+		diagramType: diagramType,
+		name:        name,
+		version:     version,
+		from:        location,
+		to:          location,
+		actor:       r.actorFor(&metadata),
+	})
+	return nil
+}
+
+// Field separators of the git log format used by ListChanges
+const (
+	gitFieldSeparator  = "\x1f"
+	gitRecordSeparator = "\x1e"
+)
+
+// ListChanges returns the commits touching a state-machine diagram in any
+// location, oldest first. Commits made outside this repository have no
+// trailers; they are reported with their subject and author.
+func (r *GitRepository) ListChanges(diagramType smmodels.DiagramType, name, version string) ([]models.Change, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name)
+	}
+
+	changes := []models.Change{}
+	if _, err := r.git("rev-parse", "--verify", "-q", "HEAD"); err != nil {
+		// Nothing has been committed yet
+		return changes, nil
+	}
+
+	paths := diagramPaths(diagramType, name, version, models.LocationFileInProgress, models.LocationFileProducts)
+	format := "--format=" + strings.Join([]string{"%H", "%cI", "%an", "%B"}, "%x1f") + "%x1e"
+	out, err := r.git(append([]string{"log", "--reverse", format, "--"}, paths...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range strings.Split(out, gitRecordSeparator) {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), gitFieldSeparator, 4)
+		if len(fields) != 4 {
+			continue
+		}
+		changes = append(changes, parseGitChange(fields[0], fields[1], fields[2], fields[3], name, version))
+	}
+	return changes, nil
+}
+
+// parseGitChange converts one git log record into a Change
+func parseGitChange(hash, committed, author, body, name, version string) models.Change {
+	change := models.Change{
+		ID:      hash,
+		Name:    name,
+		Version: version,
+		Actor:   author,
+	}
+	change.CommittedAt, _ = time.Parse(time.RFC3339, committed)
+
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	change.Message = lines[0]
+
+	fromSet := false
+	for _, line := range lines[1:] {
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Operation":
+			change.Operation = models.ChangeOperation(value)
+		case "Location":
+			if location, ok := parseLocation(value); ok {
+				change.Location = location
+			}
+		case "From-Location":
+			if location, ok := parseLocation(value); ok {
+				change.FromLocation = location
+				fromSet = true
+			}
+		case "Actor":
+			change.Actor = value
+		}
+	}
+	if !fromSet {
+		change.FromLocation = change.Location
+	}
+	return change
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// newTestGitRepository creates a GitRepository in a fresh temp directory,
+// skipping the test when git is not installed
+func newTestGitRepository(t *testing.T, options GitOptions) *GitRepository {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	options.AuthorName = "Test"
+	options.AuthorEmail = "test@example.com"
+	repo, err := NewGitRepository(&models.Config{
+		RootDirectory: root,
+		MaxFileSize:   1024 * 1024,
+	}, options)
+	if err != nil {
+		t.Fatalf("NewGitRepository() error = %v", err)
+	}
+	return repo
+}
+
+func TestGitRepository_CommitsChanges(t *testing.T) {
+	repo := newTestGitRepository(t, GitOptions{})
+
+	diag := memoryTestDiagram("door", "1.0.0")
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	diag.Content = "@startuml\n[*] --> Open\n@enduml"
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	if err := repo.MoveDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts); err != nil {
+		t.Fatalf("MoveDiagram() error = %v", err)
+	}
+	metadata, err := repo.ReadMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	metadata.PromotedBy = "release-bot"
+	if err := repo.WriteMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts, *metadata); err != nil {
+		t.Fatalf("WriteMetadata() error = %v", err)
+	}
+	if err := repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteDiagram() error = %v", err)
+	}
+
+	changes, err := repo.ListChanges(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+
+	want := []struct {
+		op       models.ChangeOperation
+		from, to models.Location
+		actor    string
+	}{
+		{models.ChangeWrite, models.LocationFileInProgress, models.LocationFileInProgress, "jane"},
+		{models.ChangeWrite, models.LocationFileInProgress, models.LocationFileInProgress, "jane"},
+		{models.ChangeMove, models.LocationFileInProgress, models.LocationFileProducts, "jane"},
+		{models.ChangeMetadata, models.LocationFileProducts, models.LocationFileProducts, "release-bot"},
+		{models.ChangeDelete, models.LocationFileProducts, models.LocationFileProducts, "release-bot"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("ListChanges() = %+v, want %d changes", changes, len(want))
+	}
+	for i, w := range want {
+		got := changes[i]
+		if got.Operation != w.op || got.FromLocation != w.from || got.Location != w.to || got.Actor != w.actor {
+			t.Errorf("change %d = %+v, want %s from %v to %v by %s", i, got, w.op, w.from, w.to, w.actor)
+		}
+		if got.ID == "" || got.CommittedAt.IsZero() || got.Message == "" {
+			t.Errorf("change %d is missing its commit details: %+v", i, got)
+		}
+	}
+}
+
+func TestGitRepository_SkipsUnchangedContent(t *testing.T) {
+	repo := newTestGitRepository(t, GitOptions{Actor: "ci"})
+
+	diag := memoryTestDiagram("door", "1.0.0")
+	// Fixed timestamps keep the metadata sidecar identical between writes
+	diag.Metadata.CreatedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	diag.Metadata.ModifiedAt = diag.Metadata.CreatedAt
+	for i := 0; i < 2; i++ {
+		if err := repo.WriteDiagram(diag); err != nil {
+			t.Fatalf("WriteDiagram() error = %v", err)
+		}
+	}
+
+	changes, err := repo.ListChanges(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	if len(changes) != 1 || changes[0].Actor != "ci" {
+		t.Errorf("ListChanges() = %+v, want one change by the configured actor", changes)
+	}
+}
+
+func TestGitRepository_LeavesOtherFilesAlone(t *testing.T) {
+	repo := newTestGitRepository(t, GitOptions{})
+
+	// A file staged by someone else must not end up in the diagram commit
+	notes := filepath.Join(repo.workTree, "notes.txt")
+	if err := os.WriteFile(notes, []byte("draft"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.git("add", "notes.txt"); err != nil {
+		t.Fatalf("git add error = %v", err)
+	}
+
+	if err := repo.WriteDiagram(memoryTestDiagram("door", "1.0.0")); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	committed, err := repo.git("show", "--name-only", "--format=", "HEAD")
+	if err != nil {
+		t.Fatalf("git show error = %v", err)
+	}
+	if strings.Contains(committed, "notes.txt") || !strings.Contains(committed, "door-1.0.0.puml") {
+		t.Errorf("committed files = %q, want only the diagram files", committed)
+	}
+	status, _ := repo.git("status", "--porcelain", "notes.txt")
+	if !strings.HasPrefix(status, "A ") {
+		t.Errorf("notes.txt status = %q, want it still staged", status)
+	}
+}
+
+// gitIn runs git in dir and returns its trimmed output
+func gitIn(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s error = %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitRepository_EnclosingRepository(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	options := GitOptions{AuthorName: "Test", AuthorEmail: "test@example.com"}
+	newRoot := func(options GitOptions) (*GitRepository, string) {
+		project := t.TempDir()
+		gitIn(t, project, "init", "-q")
+		repo, err := NewGitRepository(&models.Config{
+			RootDirectory: filepath.Join(project, "diagrams"),
+			MaxFileSize:   1024 * 1024,
+		}, options)
+		if err != nil {
+			t.Fatalf("NewGitRepository() error = %v", err)
+		}
+		if err := repo.WriteDiagram(memoryTestDiagram("door", "1.0.0")); err != nil {
+			t.Fatalf("WriteDiagram() error = %v", err)
+		}
+		return repo, project
+	}
+
+	// By default the project the root sits in gets no commits; the root has its own repository
+	repo, project := newRoot(options)
+	if out, err := exec.Command("git", "-C", project, "rev-parse", "--verify", "-q", "HEAD").CombinedOutput(); err == nil {
+		t.Errorf("enclosing repository has commits: %s", out)
+	}
+	if _, owned := repo.topLevel(); !owned {
+		t.Error("root directory is not the top level of its own repository")
+	}
+
+	// Internal areas are ignored, so they never show up as untracked
+	lockPath := repo.pathManager.GetRootLockPath()
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if status := gitIn(t, repo.workTree, "status", "--porcelain"); status != "" {
+		t.Errorf("git status of the root = %q, want clean", status)
+	}
+
+	// Opting in commits only the diagram files to the enclosing repository
+	repo, project = newRoot(GitOptions{AuthorName: options.AuthorName, AuthorEmail: options.AuthorEmail, UseEnclosingRepository: true})
+	committed := gitIn(t, project, "log", "--name-only", "--format=")
+	if committed != "diagrams/in-progress/puml/door-1.0.0.meta.json\ndiagrams/in-progress/puml/door-1.0.0.puml" {
+		t.Errorf("files committed to the enclosing repository = %q, want only the diagram files", committed)
+	}
+	if _, err := os.Stat(filepath.Join(repo.workTree, ".git")); !os.IsNotExist(err) {
+		t.Errorf("repository initialized in the root despite opting in, stat error = %v", err)
+	}
+}
+
+func TestGitRepository_Errors(t *testing.T) {
+	if _, err := NewGitRepository(&models.Config{RootDirectory: t.TempDir()}, GitOptions{GitPath: "no-such-git-executable"}); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("NewGitRepository() without git error = %v, want configuration error", err)
+	}
+
+	repo := newTestGitRepository(t, GitOptions{})
+	changes, err := repo.ListChanges(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil || len(changes) != 0 {
+		t.Errorf("ListChanges() before any commit = %+v, %v, want none", changes, err)
+	}
+	if _, err := repo.ListChanges(smmodels.DiagramTypePUML, "door", ""); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ListChanges() without version error = %v, want validation error", err)
+	}
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// manifestFormatVersion is bumped whenever the manifest layout changes, which
+// makes older manifests be rebuilt
+const manifestFormatVersion = 1
+
+// manifest caches what listing a location needs to know about each diagram,
+// keyed by file name. An entry is only trusted while the size and modification
+// time of the diagram file and its sidecar still match.
+type manifest struct {
+	FormatVersion int                      `json:"formatVersion"`
+	Entries       map[string]manifestEntry `json:"entries"`
+}
+
+// manifestEntry is the cached listing of one diagram file
+type manifestEntry struct {
+	Size        int64     `json:"size"`
+	FileModTime time.Time `json:"fileModTime"`
+	MetaSize    int64     `json:"metaSize"` // -1 when there is no sidecar
+	MetaModTime time.Time `json:"metaModTime"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ModifiedAt  time.Time `json:"modifiedAt"`
+}
+
+// matches reports whether the entry still describes the files on disk
+func (e manifestEntry) matches(info, metaInfo fs.FileInfo) bool {
+	if e.Size != info.Size() || !e.FileModTime.Equal(info.ModTime()) {
+		return false
+	}
+	if metaInfo == nil {
+		return e.MetaSize == -1
+	}
+	return e.MetaSize == metaInfo.Size() && e.MetaModTime.Equal(metaInfo.ModTime())
+}
+
+// ListEntries lists the state-machine diagrams of a location without reading
+// their content. Names, versions and sizes come from the directory itself;
+// timestamps come from a manifest under {root}/index that is rebuilt for any
+// file changed since it was written. Files that cannot be listed are reported
+// in Skipped instead of being dropped.
+func (r *FileSystemRepository) ListEntries(diagramType smmodels.DiagramType, location models.Location) (*models.DiagramListing, error) {
+	listing := &models.DiagramListing{Entries: []models.DiagramEntry{}, Skipped: []models.SkippedFile{}}
+
+	locationPath := r.pathManager.GetLocationWithDiagramTypePath(location, diagramType)
+	dirEntries, err := os.ReadDir(locationPath)
+	if os.IsNotExist(err) {
+		return listing, nil
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read location directory", err).
+			WithContext("locationPath", locationPath)
+	}
+
+	// Sidecars are matched to their diagrams by name
+	sidecars := make(map[string]fs.FileInfo)
+	for _, dirEntry := range dirEntries {
+		if base, ok := strings.CutSuffix(dirEntry.Name(), models.MetadataExtension); ok && !dirEntry.IsDir() {
+			if info, err := dirEntry.Info(); err == nil {
+				sidecars[base] = info
+			}
+		}
+	}
+
+	cached, valid := r.readManifest(diagramType, location)
+	fresh := make(map[string]manifestEntry)
+	dirty := !valid
+
+	for _, dirEntry := range dirEntries {
+		fileName := dirEntry.Name()
+		filePath := filepath.Join(locationPath, fileName)
+
+		// Directories, sidecars and hidden temp files of atomic writes are not diagrams
+		if dirEntry.IsDir() || strings.HasSuffix(fileName, models.MetadataExtension) || strings.HasPrefix(fileName, ".") {
+			continue
+		}
+
+		pathInfo, err := r.pathManager.ParseFileName(diagramType, fileName)
+		if err != nil {
+			listing.Skipped = append(listing.Skipped, models.SkippedFile{Path: filePath, Reason: skipReason(err)})
+			continue
+		}
+
+		info, err := dirEntry.Info()
+		if err != nil {
+			listing.Skipped = append(listing.Skipped, models.SkippedFile{Path: filePath, Reason: "failed to get file info: " + err.Error()})
+			continue
+		}
+		if info.Size() > r.config.MaxFileSize {
+			listing.Skipped = append(listing.Skipped, models.SkippedFile{Path: filePath, Reason: "file size exceeds maximum allowed"})
+			continue
+		}
+
+		metaInfo := sidecars[strings.TrimSuffix(fileName, models.PlantUMLExtension)]
+		entry, ok := cached.Entries[fileName]
+		if !ok || !entry.matches(info, metaInfo) {
+			entry = r.manifestEntryFor(diagramType, pathInfo.Name, pathInfo.Version, location, info, metaInfo)
+			dirty = true
+		}
+		fresh[fileName] = entry
+
+		listing.Entries = append(listing.Entries, models.DiagramEntry{
+			Name:        pathInfo.Name,
+			Version:     pathInfo.Version,
+			Location:    location,
+			DiagramType: diagramType,
+			Size:        entry.Size,
+			CreatedAt:   entry.CreatedAt,
+			ModifiedAt:  entry.ModifiedAt,
+		})
+	}
+
+	if dirty || len(fresh) != len(cached.Entries) {
+		r.writeManifest(diagramType, location, manifest{FormatVersion: manifestFormatVersion, Entries: fresh})
+	}
+
+	return listing, nil
+}
+
+// skipReason returns the message of an error without its context
+func skipReason(err error) string {
+	if smErr, ok := err.(*models.StateMachineError); ok {
+		return smErr.Message
+	}
+	return err.Error()
+}
+
+// manifestEntryFor builds the manifest entry of a diagram from its sidecar,
+// falling back to the file modification time as ReadDiagram does
+func (r *FileSystemRepository) manifestEntryFor(diagramType smmodels.DiagramType, name, version string, location models.Location, info, metaInfo fs.FileInfo) manifestEntry {
+	entry := manifestEntry{
+		Size:        info.Size(),
+		FileModTime: info.ModTime(),
+		MetaSize:    -1,
+		CreatedAt:   info.ModTime(),
+		ModifiedAt:  info.ModTime(),
+	}
+	if metaInfo == nil {
+		return entry
+	}
+
+	entry.MetaSize = metaInfo.Size()
+	entry.MetaModTime = metaInfo.ModTime()
+	metadata, found, err := r.readSidecar(diagramType, name, version, location)
+	if err != nil {
+		r.logger.WithError(err).Warn("Failed to read metadata sidecar, using file timestamps")
+	}
+	if found {
+		entry.CreatedAt = metadata.CreatedAt
+		entry.ModifiedAt = metadata.ModifiedAt
+	}
+	return entry
+}
+
+// readManifest loads the manifest of a location and reports whether it was
+// usable; a missing, unreadable or outdated manifest is treated as empty
+func (r *FileSystemRepository) readManifest(diagramType smmodels.DiagramType, location models.Location) (manifest, bool) {
+	empty := manifest{FormatVersion: manifestFormatVersion, Entries: map[string]manifestEntry{}}
+
+	manifestPath := r.pathManager.GetManifestPathWithDiagramType(location, diagramType)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return empty, false
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil || m.FormatVersion != manifestFormatVersion || m.Entries == nil {
+		r.logger.WithField("manifestPath", manifestPath).Debug("Rebuilding unreadable or outdated manifest")
+		return empty, false
+	}
+	return m, true
+}
+
+// writeManifest stores the manifest of a location. The manifest is only a
+// cache, so failures are logged rather than failing the listing.
+func (r *FileSystemRepository) writeManifest(diagramType smmodels.DiagramType, location models.Location, m manifest) {
+	manifestPath := r.pathManager.GetManifestPathWithDiagramType(location, diagramType)
+	opLogger := r.logger.WithField("manifestPath", manifestPath)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		opLogger.WithError(err).Warn("Failed to encode manifest")
+		return
+	}
+	if err := r.CreateDirectory(filepath.Dir(manifestPath)); err != nil {
+		opLogger.WithError(err).Warn("Failed to create manifest directory")
+		return
+	}
+	if err := r.files.writeFile(manifestPath, data, 0644); err != nil {
+		opLogger.WithError(err).Warn("Failed to write manifest")
+	}
+}
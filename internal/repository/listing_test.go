@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestFileSystemRepository_ListEntries(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	created := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	for _, version := range []string{"1.0.0", "2.0.0"} {
+		diag := helper.CreateTestDiagram("door", version, models.LocationFileInProgress)
+		diag.DiagramType = smmodels.DiagramTypePUML
+		diag.Metadata.CreatedAt = created
+		if err := helper.repo.WriteDiagram(diag); err != nil {
+			t.Fatalf("WriteDiagram() error = %v", err)
+		}
+	}
+
+	locationPath := helper.repo.pathManager.GetLocationWithDiagramTypePath(models.LocationFileInProgress, smmodels.DiagramTypePUML)
+	junk := map[string]string{
+		"notes.txt":                  "not a diagram",
+		"door.puml":                  "missing version",
+		"huge-1.0.0.puml":            strings.Repeat("x", int(helper.repo.config.MaxFileSize)+1),
+		".door-1.0.0.puml.tmp-12345": "left by an interrupted write",
+	}
+	for name, content := range junk {
+		if err := os.WriteFile(filepath.Join(locationPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing, err := helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ListEntries() error = %v", err)
+	}
+
+	if len(listing.Entries) != 2 || listing.Entries[0].Version != "1.0.0" || listing.Entries[1].Version != "2.0.0" {
+		t.Fatalf("ListEntries() entries = %+v, want both versions of door", listing.Entries)
+	}
+	entry := listing.Entries[0]
+	content := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress).Content
+	if entry.Size != int64(len(content)) || !entry.CreatedAt.Equal(created) || entry.Location != models.LocationFileInProgress {
+		t.Errorf("ListEntries() entry = %+v, want size %d created %v", entry, len(content), created)
+	}
+
+	// Temp files and sidecars are internal; everything else is reported
+	skipped := map[string]string{}
+	for _, s := range listing.Skipped {
+		skipped[filepath.Base(s.Path)] = s.Reason
+	}
+	if len(skipped) != 3 || skipped["notes.txt"] == "" || skipped["door.puml"] == "" || !strings.Contains(skipped["huge-1.0.0.puml"], "size") {
+		t.Errorf("ListEntries() skipped = %+v, want notes.txt, door.puml and huge-1.0.0.puml", listing.Skipped)
+	}
+
+	missing, err := helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileProducts)
+	if err != nil || len(missing.Entries) != 0 || len(missing.Skipped) != 0 {
+		t.Errorf("ListEntries() of an empty location = %+v, %v, want empty", missing, err)
+	}
+}
+
+func TestFileSystemRepository_ListEntries_Manifest(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	if _, err := helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileInProgress); err != nil {
+		t.Fatalf("ListEntries() error = %v", err)
+	}
+
+	manifestPath := helper.repo.pathManager.GetManifestPathWithDiagramType(models.LocationFileInProgress, smmodels.DiagramTypePUML)
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatalf("manifest was not written: %v", err)
+	}
+
+	// Entries matching the files on disk are served from the manifest
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	cachedAt := time.Date(2001, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := m.Entries["door-1.0.0.puml"]
+	entry.CreatedAt = cachedAt
+	m.Entries["door-1.0.0.puml"] = entry
+	data, _ = json.Marshal(m)
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	listing, _ := helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileInProgress)
+	if len(listing.Entries) != 1 || !listing.Entries[0].CreatedAt.Equal(cachedAt) {
+		t.Errorf("ListEntries() = %+v, want the manifest entry to be used", listing.Entries)
+	}
+
+	// A metadata change makes the entry stale, so it is rebuilt from the sidecar
+	metadata := models.Metadata{CreatedAt: time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC), ModifiedAt: time.Now()}
+	if err := helper.repo.WriteMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, metadata); err != nil {
+		t.Fatalf("WriteMetadata() error = %v", err)
+	}
+	listing, _ = helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileInProgress)
+	if len(listing.Entries) != 1 || !listing.Entries[0].CreatedAt.Equal(metadata.CreatedAt) {
+		t.Errorf("ListEntries() after a metadata change = %+v, want the new creation time", listing.Entries)
+	}
+
+	// Deleted diagrams leave the manifest, and a corrupt manifest is rebuilt
+	if err := os.WriteFile(manifestPath, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := helper.repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress); err != nil {
+		t.Fatalf("DeleteDiagram() error = %v", err)
+	}
+	listing, err = helper.repo.ListEntries(smmodels.DiagramTypePUML, models.LocationFileInProgress)
+	if err != nil || len(listing.Entries) != 0 {
+		t.Errorf("ListEntries() after delete = %+v, %v, want no entries", listing, err)
+	}
+	data, _ = os.ReadFile(manifestPath)
+	var rebuilt manifest
+	if err := json.Unmarshal(data, &rebuilt); err != nil || len(rebuilt.Entries) != 0 {
+		t.Errorf("manifest after delete = %s, want a rebuilt empty manifest", data)
+	}
+}
@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+const (
+	// lockRetryInterval is how often a contended lock is retried
+	lockRetryInterval = 20 * time.Millisecond
+)
+
+// lockOwner is written to a lock file by its exclusive holder. It only names
+// the holder in timeout errors; whether a lock is held is decided by the OS.
+type lockOwner struct {
+	PID        int       `json:"pid"`
+	Host       string    `json:"host"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+}
+
+// newLockOwner describes a lock about to be taken by this process
+func newLockOwner() lockOwner {
+	host, _ := os.Hostname()
+	return lockOwner{
+		PID:        os.Getpid(),
+		Host:       host,
+		AcquiredAt: time.Now().UTC(),
+	}
+}
+
+// fileLock is an advisory lock held on an open lock file. The OS releases it
+// when the file is closed, including when the holding process dies.
+type fileLock struct {
+	file      *os.File
+	exclusive bool
+	remove    bool // the lock file is removed on release
+}
+
+// LockDiagram takes the cross-process lock of a state-machine diagram. It waits
+// up to Config.LockTimeout while another process holds the diagram or the root.
+//
+// Diagram lockers share the root lock, so a root locker waits for all of them
+// and no diagram can be locked while the root is.
+func (r *FileSystemRepository) LockDiagram(diagramType smmodels.DiagramType, name, version string) (func(), error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name)
+	}
+
+	lockPath := r.pathManager.GetLockPathWithDiagramType(name, version, diagramType)
+	deadline := time.Now().Add(r.config.LockTimeout)
+
+	root, err := r.acquireLock(r.pathManager.GetRootLockPath(), false, deadline)
+	if err != nil {
+		return nil, err
+	}
+	diagram, err := r.acquireLock(lockPath, true, deadline)
+	if err != nil {
+		r.releaseLock(root)
+		return nil, err
+	}
+	return r.unlocker(diagram, root), nil
+}
+
+// LockRoot takes the cross-process lock of the whole root directory. Once it
+// is taken no diagram lock can be; it waits up to Config.LockTimeout for the
+// diagram locks already held to be released.
+func (r *FileSystemRepository) LockRoot() (func(), error) {
+	root, err := r.acquireLock(r.pathManager.GetRootLockPath(), true, time.Now().Add(r.config.LockTimeout))
+	if err != nil {
+		return nil, err
+	}
+	return r.unlocker(root), nil
+}
+
+// acquireLock takes an advisory lock on lockPath, creating the file if needed,
+// and retries a contended lock until deadline. Exclusive locks record their
+// holder in the file and remove it on release, except for the root lock whose
+// file is shared with diagram lockers and so is never removed.
+func (r *FileSystemRepository) acquireLock(lockPath string, exclusive bool, deadline time.Time) (*fileLock, error) {
+	if err := r.CreateDirectory(filepath.Dir(lockPath)); err != nil {
+		return nil, err
+	}
+	persistent := lockPath == r.pathManager.GetRootLockPath()
+
+	for {
+		f, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to open lock file", err).
+				WithContext("lockPath", lockPath)
+		}
+
+		granted, err := tryLockFile(f, exclusive)
+		if err != nil {
+			f.Close()
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to lock file", err).
+				WithContext("lockPath", lockPath)
+		}
+		if granted {
+			// The previous holder may have removed the file after it was opened
+			// here; a lock on a removed file guards nothing, so start over
+			if sameFile(f, lockPath) {
+				if exclusive {
+					r.recordOwner(f)
+				}
+				return &fileLock{file: f, exclusive: exclusive, remove: exclusive && !persistent}, nil
+			}
+			unlockFile(f)
+			f.Close()
+			continue
+		}
+		f.Close()
+
+		if !time.Now().Before(deadline) {
+			return nil, r.lockTimeoutError(lockPath)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// sameFile reports whether the open file f is still the file at path
+func sameFile(f *os.File, path string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(opened, current)
+}
+
+// recordOwner writes this process into a lock file it holds exclusively
+func (r *FileSystemRepository) recordOwner(f *os.File) {
+	data, err := json.Marshal(newLockOwner())
+	if err == nil {
+		if err = f.Truncate(0); err == nil {
+			_, err = f.WriteAt(data, 0)
+		}
+	}
+	if err != nil {
+		// The owner only names the holder in timeout errors; the lock is held regardless
+		r.logger.WithField("lockPath", f.Name()).WithError(err).Debug("Failed to record lock owner")
+	}
+}
+
+// lockFileUnused reports whether no process holds lockPath, removing the file
+// when remove is set. The lock is taken for the check, so a file is only ever
+// removed while nobody else can be granted it.
+func (r *FileSystemRepository) lockFileUnused(lockPath string, remove bool) (bool, error) {
+	f, err := os.OpenFile(lockPath, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to open lock file", err).
+			WithContext("lockPath", lockPath)
+	}
+
+	granted, err := tryLockFile(f, true)
+	if err != nil {
+		f.Close()
+		return false, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to lock file", err).
+			WithContext("lockPath", lockPath)
+	}
+	if !granted {
+		f.Close()
+		return false, nil
+	}
+
+	unused := sameFile(f, lockPath)
+	if unused && remove && removeWhileLocked {
+		err = removeLockFile(lockPath)
+	}
+	unlockFile(f)
+	f.Close()
+	// Where an open file cannot be removed, removal waits until it is closed and
+	// fails if another process has opened it meanwhile, leaving it to that holder
+	if unused && remove && !removeWhileLocked {
+		err = removeLockFile(lockPath)
+	}
+	if err != nil {
+		return true, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to remove lock file", err).
+			WithContext("lockPath", lockPath)
+	}
+	return unused, nil
+}
+
+// removeLockFile removes a lock file, treating a missing one as removed
+func removeLockFile(lockPath string) error {
+	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// releaseLock releases a held lock. A lock file is removed before it is
+// unlocked, so a waiter either gets the removed file, notices and retries, or
+// creates a fresh one. Where an open file cannot be removed it is removed once
+// closed instead; that fails harmlessly when a waiter has already opened it,
+// and a waiter that opened it before the removal retries on the fresh file.
+func (r *FileSystemRepository) releaseLock(lock *fileLock) {
+	lockPath := lock.file.Name()
+	switch {
+	case lock.remove && removeWhileLocked:
+		if err := removeLockFile(lockPath); err != nil {
+			r.logger.WithField("lockPath", lockPath).WithError(err).Debug("Failed to remove lock file")
+		}
+	case lock.exclusive && !lock.remove:
+		lock.file.Truncate(0)
+	}
+	if err := unlockFile(lock.file); err != nil {
+		r.logger.WithField("lockPath", lockPath).WithError(err).Warn("Failed to unlock lock file")
+	}
+	if err := lock.file.Close(); err != nil {
+		r.logger.WithField("lockPath", lockPath).WithError(err).Error("Failed to release lock")
+	}
+	if lock.remove && !removeWhileLocked {
+		if err := removeLockFile(lockPath); err != nil {
+			r.logger.WithField("lockPath", lockPath).WithError(err).Debug("Lock file left in place for its next holder")
+		}
+	}
+}
+
+// unlocker returns a function releasing held locks in order; calls after the first do nothing
+func (r *FileSystemRepository) unlocker(locks ...*fileLock) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, lock := range locks {
+				r.releaseLock(lock)
+			}
+		})
+	}
+}
+
+// lockTimeoutError reports a lock that was not released in time, naming its holder when known
+func (r *FileSystemRepository) lockTimeoutError(lockPath string) error {
+	err := models.NewStateMachineError(models.ErrorTypeTimeout, "timed out waiting for lock", nil).
+		WithContext("lockPath", lockPath).
+		WithContext("timeout", r.config.LockTimeout.String())
+
+	var owner lockOwner
+	if data, readErr := os.ReadFile(lockPath); readErr == nil && json.Unmarshal(data, &owner) == nil {
+		err = err.WithContext("holderPID", owner.PID).
+			WithContext("holderHost", owner.Host).
+			WithContext("heldSince", owner.AcquiredAt)
+	}
+	return err
+}
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// writeLockFile plants a lock file as if another process held it
+func writeLockFile(t *testing.T, lockPath string, owner lockOwner) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lockPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSystemRepository_LockDiagram(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	unlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() error = %v", err)
+	}
+
+	// Locks are not reentrant, so a second acquisition behaves like another process
+	if _, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("LockDiagram() of a held lock error = %v, want timeout", err)
+	}
+
+	// Other diagrams are not affected
+	other, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "2.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() of another diagram error = %v", err)
+	}
+	other()
+
+	unlock()
+	unlock() // releasing twice is harmless
+	again, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() after unlock error = %v", err)
+	}
+	again()
+}
+
+func TestFileSystemRepository_LockDiagram_WaitsForRelease(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+	helper.repo.config.LockTimeout = 5 * time.Second
+
+	unlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() error = %v", err)
+	}
+	time.AfterFunc(50*time.Millisecond, unlock)
+
+	start := time.Now()
+	second, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() while held error = %v, want it to wait", err)
+	}
+	second()
+	if waited := time.Since(start); waited < 40*time.Millisecond {
+		t.Errorf("LockDiagram() returned after %v, before the lock was released", waited)
+	}
+}
+
+func TestFileSystemRepository_LockDiagram_MutualExclusion(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+	helper.repo.config.LockTimeout = 30 * time.Second
+
+	// Lock files are removed on release, so waiters keep racing for fresh files
+	var active, maxActive int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				unlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+				if err != nil {
+					t.Errorf("LockDiagram() error = %v", err)
+					return
+				}
+				if n := atomic.AddInt32(&active, 1); n > atomic.LoadInt32(&maxActive) {
+					atomic.StoreInt32(&maxActive, n)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&active, -1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if maxActive != 1 {
+		t.Errorf("lock held by %d goroutines at once, want 1", maxActive)
+	}
+}
+
+func TestFileSystemRepository_LockRoot(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diagramUnlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() error = %v", err)
+	}
+	if _, err := helper.repo.LockRoot(); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("LockRoot() while a diagram is locked error = %v, want timeout", err)
+	}
+	// The failed attempt must not leave the root locked
+	other, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "2.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() after a failed LockRoot() error = %v", err)
+	}
+	other()
+	diagramUnlock()
+
+	rootUnlock, err := helper.repo.LockRoot()
+	if err != nil {
+		t.Fatalf("LockRoot() error = %v", err)
+	}
+	if _, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("LockDiagram() while the root is locked error = %v, want timeout", err)
+	}
+	rootUnlock()
+
+	diagramUnlock, err = helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() after the root was unlocked error = %v", err)
+	}
+	diagramUnlock()
+}
+
+func TestFileSystemRepository_Lock_LeftBehind(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	// A lock file whose holder died is no longer locked by the OS
+	host, _ := os.Hostname()
+	lockPath := helper.repo.pathManager.GetLockPathWithDiagramType("door", "1.0.0", smmodels.DiagramTypePUML)
+	writeLockFile(t, lockPath, lockOwner{PID: 1 << 30, Host: host, AcquiredAt: time.Now()})
+	unlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() over a left-behind lock file error = %v", err)
+	}
+
+	var owner lockOwner
+	if data, err := os.ReadFile(lockPath); err != nil || json.Unmarshal(data, &owner) != nil || owner.PID != os.Getpid() {
+		t.Errorf("lock file owner = %+v, want this process", owner)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("diagram lock file kept after release: %v", err)
+	}
+}
+
+// lockHelperEnv names the root directory a helper process locks a diagram in
+const lockHelperEnv = "GO_UML_LOCK_HELPER_ROOT"
+
+// TestLockHelperProcess is run as a separate process by
+// TestFileSystemRepository_Lock_HolderDies; it holds a lock until it is killed
+func TestLockHelperProcess(t *testing.T) {
+	root := os.Getenv(lockHelperEnv)
+	if root == "" {
+		t.Skip("only runs as a helper process")
+	}
+	config := models.DefaultConfig()
+	config.RootDirectory = root
+	if _, err := NewFileSystemRepository(config).LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestFileSystemRepository_Lock_HolderDies(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+	helper.repo.config.LockTimeout = 100 * time.Millisecond
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+	cmd.Env = append(os.Environ(), lockHelperEnv+"="+helper.tempDir)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	// Log lines come first; wait for the helper to report the lock
+	scanner := bufio.NewScanner(stdout)
+	var lines []string
+	for scanner.Scan() && scanner.Text() != "locked" {
+		lines = append(lines, scanner.Text())
+	}
+	if scanner.Text() != "locked" {
+		t.Fatalf("helper process output = %q, want it to hold the lock", lines)
+	}
+
+	// The lock is held by another process and names it
+	_, err = helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Fatalf("LockDiagram() held by another process error = %v, want timeout", err)
+	}
+	if pid := err.(*models.StateMachineError).Context["holderPID"]; pid != cmd.Process.Pid {
+		t.Errorf("holderPID = %v, want %d", pid, cmd.Process.Pid)
+	}
+	if _, err := helper.repo.LockRoot(); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("LockRoot() while another process holds a diagram error = %v, want timeout", err)
+	}
+
+	// The OS releases the lock of a process that dies without unlocking
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Wait()
+	helper.repo.config.LockTimeout = 5 * time.Second
+	unlock, err := helper.repo.LockDiagram(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("LockDiagram() after the holder died error = %v", err)
+	}
+	unlock()
+}
+
+func TestMoveNoReplace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.puml")
+	dst := filepath.Join(dir, "dst.puml")
+	if err := os.WriteFile(src, []byte("source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := moveNoReplace(src, dst); !os.IsExist(err) {
+		t.Fatalf("moveNoReplace() onto an existing file error = %v, want exists", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "existing" {
+		t.Errorf("destination = %q, want it untouched", data)
+	}
+
+	if err := os.Remove(dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := moveNoReplace(src, dst); err != nil {
+		t.Fatalf("moveNoReplace() error = %v", err)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Errorf("source still exists after move: %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "source" {
+		t.Errorf("destination = %q, want the moved content", data)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package repository
+
+import (
+	"os"
+	"sync"
+)
+
+// removeWhileLocked reports whether a lock file can be removed while it is
+// still open and locked
+const removeWhileLocked = true
+
+// fileLocks tracks the locks of this process by lock file path. This platform
+// has no advisory file locks, so locks only coordinate within one process.
+var fileLocks = struct {
+	sync.Mutex
+	shared    map[string]int
+	exclusive map[string]bool
+	held      map[*os.File]string
+}{shared: map[string]int{}, exclusive: map[string]bool{}, held: map[*os.File]string{}}
+
+// tryLockFile takes a lock on f without waiting and reports whether it was granted
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+
+	path := f.Name()
+	if fileLocks.exclusive[path] || (exclusive && fileLocks.shared[path] > 0) {
+		return false, nil
+	}
+	if exclusive {
+		fileLocks.exclusive[path] = true
+	} else {
+		fileLocks.shared[path]++
+	}
+	fileLocks.held[f] = path
+	return true, nil
+}
+
+// unlockFile releases the lock taken on f
+func unlockFile(f *os.File) error {
+	fileLocks.Lock()
+	defer fileLocks.Unlock()
+
+	path, ok := fileLocks.held[f]
+	if !ok {
+		return nil
+	}
+	delete(fileLocks.held, f)
+	if fileLocks.exclusive[path] {
+		delete(fileLocks.exclusive, path)
+	} else if fileLocks.shared[path]--; fileLocks.shared[path] == 0 {
+		delete(fileLocks.shared, path)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package repository
+
+import (
+	"os"
+	"syscall"
+)
+
+// removeWhileLocked reports whether a lock file can be removed while it is
+// still open and locked
+const removeWhileLocked = true
+
+// tryLockFile takes an advisory lock on f without waiting and reports whether
+// it was granted. Shared locks can be held together; an exclusive lock excludes
+// every other lock, including ones taken through another open file in this process.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how|syscall.LOCK_NB)
+		switch err {
+		case nil:
+			return true, nil
+		case syscall.EINTR:
+			continue
+		case syscall.EWOULDBLOCK:
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+}
+
+// unlockFile releases the lock taken on f
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
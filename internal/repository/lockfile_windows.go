@@ -0,0 +1,63 @@
+//go:build windows
+
+package repository
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	// lockfileFailImmediately makes LockFileEx return instead of waiting
+	lockfileFailImmediately = 0x1
+
+	// lockfileExclusiveLock requests an exclusive rather than a shared lock
+	lockfileExclusiveLock = 0x2
+
+	// errorLockViolation is returned when another handle holds a conflicting lock
+	errorLockViolation syscall.Errno = 33
+)
+
+// removeWhileLocked reports whether a lock file can be removed while it is
+// still open and locked. Windows refuses to delete a file with open handles.
+const removeWhileLocked = false
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockRegion returns the byte range that is locked. It lies far beyond the
+// owner record so other processes can still read who holds the lock.
+func lockRegion() *syscall.Overlapped {
+	return &syscall.Overlapped{Offset: 0, OffsetHigh: 0x7fffffff}
+}
+
+// tryLockFile takes an advisory lock on f without waiting and reports whether
+// it was granted. Shared locks can be held together; an exclusive lock excludes
+// every other lock, including ones taken through another handle in this process.
+func tryLockFile(f *os.File, exclusive bool) (bool, error) {
+	flags := uintptr(lockfileFailImmediately)
+	if exclusive {
+		flags |= lockfileExclusiveLock
+	}
+	r, _, err := procLockFileEx.Call(f.Fd(), flags, 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r != 0 {
+		return true, nil
+	}
+	if err == errorLockViolation || err == syscall.ERROR_IO_PENDING {
+		return false, nil
+	}
+	return false, err
+}
+
+// unlockFile releases the lock taken on f
+func unlockFile(f *os.File) error {
+	r, _, err := procUnlockFileEx.Call(f.Fd(), 0, 1, 0, uintptr(unsafe.Pointer(lockRegion())))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,696 @@
+package repository
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// memoryKey identifies a stored state-machine diagram
+type memoryKey struct {
+	diagramType smmodels.DiagramType
+	name        string
+	version     string
+	location    models.Location
+}
+
+// historyKey identifies the backups and revisions of a state-machine diagram,
+// which are kept across locations
+type historyKey struct {
+	diagramType smmodels.DiagramType
+	name        string
+	version     string
+}
+
+// memoryEntry is the stored content and metadata of a state-machine diagram
+type memoryEntry struct {
+	content  string
+	metadata models.Metadata
+}
+
+// memoryBackup is a stored backup
+type memoryBackup struct {
+	backup   models.Backup
+	content  string
+	metadata models.Metadata
+}
+
+// memoryRevision is a stored revision
+type memoryRevision struct {
+	revision models.Revision
+	content  string
+}
+
+// memoryState is everything a MemoryRepository stores
+type memoryState struct {
+	diagrams  map[memoryKey]memoryEntry
+	backups   map[historyKey][]memoryBackup
+	revisions map[historyKey][]memoryRevision
+	checksums map[historyKey]models.ProductChecksum
+	dirs      map[string]bool
+}
+
+// newMemoryState creates an empty state
+func newMemoryState() memoryState {
+	return memoryState{
+		diagrams:  make(map[memoryKey]memoryEntry),
+		backups:   make(map[historyKey][]memoryBackup),
+		revisions: make(map[historyKey][]memoryRevision),
+		checksums: make(map[historyKey]models.ProductChecksum),
+		dirs:      make(map[string]bool),
+	}
+}
+
+// clone returns a deep copy of the state
+func (s memoryState) clone() memoryState {
+	c := newMemoryState()
+	for key, entry := range s.diagrams {
+		entry.metadata = cloneMetadata(entry.metadata)
+		c.diagrams[key] = entry
+	}
+	for key, backups := range s.backups {
+		copied := slices.Clone(backups)
+		for i := range copied {
+			copied[i].metadata = cloneMetadata(copied[i].metadata)
+		}
+		c.backups[key] = copied
+	}
+	for key, revisions := range s.revisions {
+		c.revisions[key] = slices.Clone(revisions)
+	}
+	for key, checksum := range s.checksums {
+		checksum.Signature = slices.Clone(checksum.Signature)
+		c.checksums[key] = checksum
+	}
+	for dir := range s.dirs {
+		c.dirs[dir] = true
+	}
+	return c
+}
+
+// cloneMetadata copies metadata so that callers cannot change stored tags
+func cloneMetadata(metadata models.Metadata) models.Metadata {
+	metadata.Tags = slices.Clone(metadata.Tags)
+	return metadata
+}
+
+// cloneChecksum returns a copy of checksum that shares no signature bytes, or nil
+func cloneChecksum(checksum *models.ProductChecksum) *models.ProductChecksum {
+	if checksum == nil {
+		return nil
+	}
+	clone := *checksum
+	clone.Signature = slices.Clone(checksum.Signature)
+	return &clone
+}
+
+// MemorySnapshot is a point-in-time copy of the content of a MemoryRepository
+type MemorySnapshot struct {
+	state memoryState
+	taken time.Time
+}
+
+// TakenAt returns when the snapshot was taken
+func (s *MemorySnapshot) TakenAt() time.Time {
+	return s.taken
+}
+
+// MemoryRepository implements the Repository interface in memory. It applies
+// the same name, version and size rules as FileSystemRepository and returns the
+// same error types, and it also keeps metadata, backups and revision history.
+// It is safe for concurrent use.
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	pathManager *models.PathManager
+	config      *models.Config
+	state       memoryState
+}
+
+// NewMemoryRepository creates an empty MemoryRepository. The root directory of
+// the configuration only scopes the paths accepted by CreateDirectory.
+func NewMemoryRepository(config *models.Config) *MemoryRepository {
+	if config == nil {
+		config = models.DefaultConfig()
+	}
+
+	return &MemoryRepository{
+		pathManager: models.NewPathManager(config.RootDirectory),
+		config:      config,
+		state:       newMemoryState(),
+	}
+}
+
+// Snapshot returns a copy of everything stored in the repository
+func (r *MemoryRepository) Snapshot() *MemorySnapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return &MemorySnapshot{state: r.state.clone(), taken: time.Now()}
+}
+
+// Restore replaces everything stored in the repository with the content of a
+// snapshot. The snapshot is copied, so it can be restored again later.
+func (r *MemoryRepository) Restore(snapshot *MemorySnapshot) error {
+	if snapshot == nil {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "snapshot cannot be nil", nil)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.state = snapshot.state.clone()
+	return nil
+}
+
+// validateKey applies the name and version rules of FileSystemRepository
+func (r *MemoryRepository) validateKey(name, version string, location models.Location) error {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return err
+	}
+	if version == "" {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name).
+			WithContext("location", location.String())
+	}
+	return nil
+}
+
+// notFound reports a missing state-machine diagram
+func notFound(name, version string, location models.Location) error {
+	return models.NewStateMachineError(models.ErrorTypeFileNotFound, "state-machine diagram file not found", nil).
+		WithContext("name", name).
+		WithContext("version", version).
+		WithContext("location", location.String())
+}
+
+// ReadDiagram reads a state-machine diagram from memory
+func (r *MemoryRepository) ReadDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+	if err := r.validateKey(name, version, location); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.state.diagrams[memoryKey{diagramType, name, version, location}]
+	if !ok {
+		return nil, notFound(name, version, location)
+	}
+	if int64(len(entry.content)) > r.config.MaxFileSize {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "file size exceeds maximum allowed", nil).
+			WithContext("fileSize", len(entry.content)).
+			WithContext("maxSize", r.config.MaxFileSize)
+	}
+
+	return &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     entry.content,
+		References:  []models.Reference{},
+		Location:    location,
+		DiagramType: diagramType,
+		Metadata:    cloneMetadata(entry.metadata),
+	}, nil
+}
+
+// WriteDiagram stores a state-machine diagram, replacing any existing content
+func (r *MemoryRepository) WriteDiagram(diag *models.StateMachineDiagram) error {
+	if diag == nil {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "state-machine diagram cannot be nil", nil)
+	}
+	if err := r.validateKey(diag.Name, diag.Version, diag.Location); err != nil {
+		return err
+	}
+	if int64(len(diag.Content)) > r.config.MaxFileSize {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "content size exceeds maximum allowed", nil).
+			WithContext("contentSize", len(diag.Content)).
+			WithContext("maxSize", r.config.MaxFileSize)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := memoryKey{diag.DiagramType, diag.Name, diag.Version, diag.Location}
+
+	// Keep the stored metadata, such as the author and creation time, for
+	// every field the caller does not supply
+	metadata := diag.Metadata
+	if existing, ok := r.state.diagrams[key]; ok {
+		metadata = metadata.Merge(existing.metadata)
+	}
+	metadata = cloneMetadata(metadata)
+	now := time.Now()
+	if metadata.CreatedAt.IsZero() {
+		metadata.CreatedAt = now
+	}
+	if metadata.ModifiedAt.IsZero() {
+		metadata.ModifiedAt = now
+	}
+
+	r.state.diagrams[key] = memoryEntry{content: diag.Content, metadata: metadata}
+	r.addDirectory(r.pathManager.GetLocationWithDiagramTypePath(diag.Location, diag.DiagramType))
+	return nil
+}
+
+// Exists checks if a state-machine diagram exists
+func (r *MemoryRepository) Exists(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+	if err := r.validateKey(name, version, location); err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.state.diagrams[memoryKey{diagramType, name, version, location}]
+	return ok, nil
+}
+
+// CreateDirectory records a directory and its parents
+func (r *MemoryRepository) CreateDirectory(path string) error {
+	if err := r.pathManager.ValidatePath(path); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addDirectory(path)
+	return nil
+}
+
+// addDirectory records a directory and its parents; the caller must hold the write lock
+func (r *MemoryRepository) addDirectory(path string) {
+	for dir := filepath.Clean(path); !r.state.dirs[dir]; dir = filepath.Dir(dir) {
+		r.state.dirs[dir] = true
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+}
+
+// DirectoryExists checks if a directory was created, explicitly or by a write
+func (r *MemoryRepository) DirectoryExists(path string) (bool, error) {
+	if err := r.pathManager.ValidatePath(path); err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.state.dirs[filepath.Clean(path)], nil
+}
+
+// MoveDiagram moves a state-machine diagram and its metadata from one location to another
+func (r *MemoryRepository) MoveDiagram(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return err
+	}
+	if version == "" {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "version is required for move operation", nil).
+			WithContext("name", name)
+	}
+	if from == to {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "source and destination locations cannot be the same", nil).
+			WithContext("from", from.String()).
+			WithContext("to", to.String())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	source := memoryKey{diagramType, name, version, from}
+	dest := memoryKey{diagramType, name, version, to}
+
+	entry, ok := r.state.diagrams[source]
+	if !ok {
+		return models.NewStateMachineError(models.ErrorTypeFileNotFound, "source state-machine diagram file not found", nil).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", from.String())
+	}
+	if _, exists := r.state.diagrams[dest]; exists {
+		return models.NewStateMachineError(models.ErrorTypeFileConflict, "destination file already exists", nil).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", to.String())
+	}
+
+	r.state.diagrams[dest] = entry
+	delete(r.state.diagrams, source)
+	r.addDirectory(r.pathManager.GetLocationWithDiagramTypePath(to, diagramType))
+	return nil
+}
+
+// DeleteDiagram deletes a state-machine diagram and its metadata
+func (r *MemoryRepository) DeleteDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+	if err := r.validateKey(name, version, location); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := memoryKey{diagramType, name, version, location}
+	if _, ok := r.state.diagrams[key]; !ok {
+		return notFound(name, version, location)
+	}
+	delete(r.state.diagrams, key)
+	return nil
+}
+
+// ListDiagrams lists all state-machine diagrams in a location in file name
+// order, as FileSystemRepository does
+func (r *MemoryRepository) ListDiagrams(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+	r.mu.RLock()
+	var keys []memoryKey
+	for key := range r.state.diagrams {
+		if key.diagramType == diagramType && key.location == location {
+			keys = append(keys, key)
+		}
+	}
+	r.mu.RUnlock()
+
+	fileName := func(key memoryKey) string {
+		return fmt.Sprintf("%s-%s%s", key.name, key.version, models.PlantUMLExtension)
+	}
+	sort.Slice(keys, func(i, j int) bool { return fileName(keys[i]) < fileName(keys[j]) })
+
+	diagrams := []models.StateMachineDiagram{}
+	for _, key := range keys {
+		diag, err := r.ReadDiagram(key.diagramType, key.name, key.version, key.location)
+		if err != nil {
+			// Skip state-machine diagrams that can't be read, but continue processing others
+			continue
+		}
+		diagrams = append(diagrams, *diag)
+	}
+	return diagrams, nil
+}
+
+// ListBlocks returns the @startuml blocks of a state-machine diagram
+func (r *MemoryRepository) ListBlocks(diagramType smmodels.DiagramType, name, version string, location models.Location) ([]models.Block, error) {
+	diag, err := r.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.SplitBlocks(diag.Content), nil
+}
+
+// ReadBlock reads a single @startuml block of a state-machine diagram by id
+func (r *MemoryRepository) ReadBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.Block, error) {
+	if blockID == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "block id cannot be empty", nil).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+
+	diag, err := r.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := models.FindBlock(diag.Content, blockID)
+	if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "block not found in state-machine diagram").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("block", blockID)
+	}
+
+	return block, nil
+}
+
+// ReadMetadata returns the metadata of a state-machine diagram
+func (r *MemoryRepository) ReadMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Metadata, error) {
+	if err := r.validateKey(name, version, location); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.state.diagrams[memoryKey{diagramType, name, version, location}]
+	if !ok {
+		return nil, notFound(name, version, location)
+	}
+	metadata := cloneMetadata(entry.metadata)
+	return &metadata, nil
+}
+
+// WriteMetadata replaces the metadata of an existing state-machine diagram
+func (r *MemoryRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	if err := r.validateKey(name, version, location); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := memoryKey{diagramType, name, version, location}
+	entry, ok := r.state.diagrams[key]
+	if !ok {
+		return notFound(name, version, location)
+	}
+	entry.metadata = cloneMetadata(metadata)
+	r.state.diagrams[key] = entry
+	return nil
+}
+
+// CreateBackup snapshots the content and metadata of a state-machine diagram
+func (r *MemoryRepository) CreateBackup(diagramType smmodels.DiagramType, name, version string, location models.Location, reason string) (*models.Backup, error) {
+	if err := r.validateKey(name, version, location); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entry, ok := r.state.diagrams[memoryKey{diagramType, name, version, location}]
+	if !ok {
+		return nil, notFound(name, version, location)
+	}
+
+	// IDs use the format of FileSystemRepository and stay unique and ordered
+	key := historyKey{diagramType, name, version}
+	existing := r.state.backups[key]
+	now := time.Now().UTC()
+	if n := len(existing); n > 0 && !now.After(existing[n-1].backup.CreatedAt) {
+		now = existing[n-1].backup.CreatedAt.Add(time.Nanosecond)
+	}
+
+	backup := models.Backup{
+		ID:        now.Format(backupIDFormat),
+		Name:      name,
+		Version:   version,
+		Location:  location,
+		Reason:    reason,
+		CreatedAt: now,
+		Size:      int64(len(entry.content)),
+	}
+	if checksum, ok := r.state.checksums[key]; ok && location == models.LocationFileProducts {
+		backup.Checksum = cloneChecksum(&checksum)
+	}
+	r.state.backups[key] = append(existing, memoryBackup{backup: backup, content: entry.content, metadata: cloneMetadata(entry.metadata)})
+	backup.Checksum = cloneChecksum(backup.Checksum)
+	return &backup, nil
+}
+
+// ListBackups returns the backups of a state-machine diagram, oldest first
+func (r *MemoryRepository) ListBackups(diagramType smmodels.DiagramType, name, version string) ([]models.Backup, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	backups := []models.Backup{}
+	for _, stored := range r.state.backups[historyKey{diagramType, name, version}] {
+		backup := stored.backup
+		backup.Checksum = cloneChecksum(backup.Checksum)
+		backups = append(backups, backup)
+	}
+	return backups, nil
+}
+
+// ReadBackup returns the diagram stored in a backup together with the backup description
+func (r *MemoryRepository) ReadBackup(diagramType smmodels.DiagramType, name, version, id string) (*models.StateMachineDiagram, *models.Backup, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, nil, err
+	}
+	if !backupIDRegex.MatchString(id) {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "invalid backup id", nil).
+			WithContext("backupID", id)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, stored := range r.state.backups[historyKey{diagramType, name, version}] {
+		if stored.backup.ID != id {
+			continue
+		}
+		backup := stored.backup
+		backup.Checksum = cloneChecksum(backup.Checksum)
+		diag := &models.StateMachineDiagram{
+			Name:        name,
+			Version:     version,
+			Content:     stored.content,
+			Location:    backup.Location,
+			DiagramType: diagramType,
+			Metadata:    cloneMetadata(stored.metadata),
+		}
+		return diag, &backup, nil
+	}
+	return nil, nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "backup not found", nil).
+		WithContext("backupID", id)
+}
+
+// PruneBackups deletes the oldest backups of a state-machine diagram so that at
+// most keep remain. A keep of 0 or less keeps every backup.
+func (r *MemoryRepository) PruneBackups(diagramType smmodels.DiagramType, name, version string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := historyKey{diagramType, name, version}
+	if backups := r.state.backups[key]; len(backups) > keep {
+		r.state.backups[key] = slices.Clone(backups[len(backups)-keep:])
+	}
+	return nil
+}
+
+// AddRevision records the content of a state-machine diagram as its next revision
+func (r *MemoryRepository) AddRevision(diag *models.StateMachineDiagram, author, message string) (*models.Revision, error) {
+	if diag == nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "state-machine diagram cannot be nil", nil)
+	}
+	if err := r.pathManager.ValidateName(diag.Name); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := historyKey{diag.DiagramType, diag.Name, diag.Version}
+	revision := models.Revision{
+		Number:    len(r.state.revisions[key]) + 1,
+		Name:      diag.Name,
+		Version:   diag.Version,
+		CreatedAt: time.Now().UTC(),
+		Author:    author,
+		Message:   message,
+		Size:      int64(len(diag.Content)),
+	}
+	r.state.revisions[key] = append(r.state.revisions[key], memoryRevision{revision: revision, content: diag.Content})
+	return &revision, nil
+}
+
+// ListRevisions returns the revisions of a state-machine diagram, oldest first
+func (r *MemoryRepository) ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]models.Revision, error) {
+	if err := r.validateKey(name, version, models.LocationFileInProgress); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions := []models.Revision{}
+	for _, stored := range r.state.revisions[historyKey{diagramType, name, version}] {
+		revisions = append(revisions, stored.revision)
+	}
+	return revisions, nil
+}
+
+// ReadRevision returns the content of a revision as an in-progress diagram
+// together with the revision description
+func (r *MemoryRepository) ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*models.StateMachineDiagram, *models.Revision, error) {
+	if err := r.validateKey(name, version, models.LocationFileInProgress); err != nil {
+		return nil, nil, err
+	}
+	if number < 1 {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "revision numbers start at 1", nil).
+			WithContext("revision", number)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	revisions := r.state.revisions[historyKey{diagramType, name, version}]
+	if number > len(revisions) {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "revision not found", nil).
+			WithContext("revision", number)
+	}
+
+	stored := revisions[number-1]
+	revision := stored.revision
+	diag := &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     stored.content,
+		Location:    models.LocationFileInProgress,
+		DiagramType: diagramType,
+		Metadata: models.Metadata{
+			ModifiedAt: revision.CreatedAt,
+			Author:     revision.Author,
+		},
+	}
+	return diag, &revision, nil
+}
+
+// WriteChecksum records the checksum of a products diagram, replacing any earlier one
+func (r *MemoryRepository) WriteChecksum(diagramType smmodels.DiagramType, name, version string, checksum models.ProductChecksum) error {
+	if err := r.validateKey(name, version, models.LocationFileProducts); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	checksum.Signature = slices.Clone(checksum.Signature)
+	r.state.checksums[historyKey{diagramType, name, version}] = checksum
+	return nil
+}
+
+// ReadChecksum returns the checksum recorded for a products diagram
+func (r *MemoryRepository) ReadChecksum(diagramType smmodels.DiagramType, name, version string) (*models.ProductChecksum, error) {
+	if err := r.validateKey(name, version, models.LocationFileProducts); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checksum, ok := r.state.checksums[historyKey{diagramType, name, version}]
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "no checksum recorded", nil).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	checksum.Signature = slices.Clone(checksum.Signature)
+	return &checksum, nil
+}
+
+// DeleteChecksum removes the checksum of a products diagram, if any
+func (r *MemoryRepository) DeleteChecksum(diagramType smmodels.DiagramType, name, version string) error {
+	if err := r.validateKey(name, version, models.LocationFileProducts); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.state.checksums, historyKey{diagramType, name, version})
+	return nil
+}
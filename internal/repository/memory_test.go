@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// newTestMemoryRepository creates a memory repository with a small size limit
+func newTestMemoryRepository() *MemoryRepository {
+	return NewMemoryRepository(&models.Config{
+		RootDirectory: ".go-uml-statemachine-parsers",
+		MaxFileSize:   1024,
+	})
+}
+
+// memoryTestDiagram creates an in-progress diagram for memory repository tests
+func memoryTestDiagram(name, version string) *models.StateMachineDiagram {
+	return &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     "@startuml\n[*] --> Closed\n@enduml",
+		Location:    models.LocationFileInProgress,
+		DiagramType: smmodels.DiagramTypePUML,
+		Metadata:    models.Metadata{Author: "jane", Tags: []string{"door"}},
+	}
+}
+
+func TestMemoryRepository_ReadWrite(t *testing.T) {
+	repo := newTestMemoryRepository()
+	diag := memoryTestDiagram("door", "1.0.0")
+
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	// Stored metadata must not share memory with the caller
+	diag.Metadata.Tags[0] = "changed"
+
+	got, err := repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadDiagram() error = %v", err)
+	}
+	if got.Content != diag.Content || got.Metadata.Author != "jane" || got.Metadata.Tags[0] != "door" {
+		t.Errorf("ReadDiagram() = %+v", got)
+	}
+	if got.Metadata.CreatedAt.IsZero() || got.Metadata.ModifiedAt.IsZero() {
+		t.Errorf("ReadDiagram() timestamps were not set: %+v", got.Metadata)
+	}
+
+	// Rewriting without metadata keeps the stored fields and creation time
+	created := got.Metadata.CreatedAt
+	diag.Metadata = models.Metadata{}
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	got, _ = repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if !got.Metadata.CreatedAt.Equal(created) || got.Metadata.Author != "jane" || len(got.Metadata.Tags) != 1 || got.Metadata.Tags[0] != "door" {
+		t.Errorf("Metadata after a rewrite without metadata = %+v, want the stored fields kept", got.Metadata)
+	}
+
+	if exists, err := repo.Exists(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil || exists {
+		t.Errorf("Exists() in products = %v, %v, want false", exists, err)
+	}
+	if exists, _ := repo.DirectoryExists(".go-uml-statemachine-parsers/in-progress/puml"); !exists {
+		t.Error("DirectoryExists() of the written location = false, want true")
+	}
+}
+
+func TestMemoryRepository_Errors(t *testing.T) {
+	repo := newTestMemoryRepository()
+
+	tests := []struct {
+		name    string
+		run     func() error
+		errType models.ErrorType
+	}{
+		{"nil diagram", func() error { return repo.WriteDiagram(nil) }, models.ErrorTypeValidation},
+		{"invalid name", func() error {
+			return repo.WriteDiagram(memoryTestDiagram("../door", "1.0.0"))
+		}, models.ErrorTypeValidation},
+		{"missing version", func() error {
+			return repo.WriteDiagram(memoryTestDiagram("door", ""))
+		}, models.ErrorTypeValidation},
+		{"content too large", func() error {
+			diag := memoryTestDiagram("door", "1.0.0")
+			diag.Content = strings.Repeat("x", 2048)
+			return repo.WriteDiagram(diag)
+		}, models.ErrorTypeFileSystem},
+		{"read missing", func() error {
+			_, err := repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+			return err
+		}, models.ErrorTypeFileNotFound},
+		{"delete missing", func() error {
+			return repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+		}, models.ErrorTypeFileNotFound},
+		{"move missing", func() error {
+			return repo.MoveDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts)
+		}, models.ErrorTypeFileNotFound},
+		{"move in place", func() error {
+			return repo.MoveDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.LocationFileInProgress)
+		}, models.ErrorTypeValidation},
+		{"directory outside root", func() error { return repo.CreateDirectory("../elsewhere") }, models.ErrorTypeValidation},
+		{"restore nil snapshot", func() error { return repo.Restore(nil) }, models.ErrorTypeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.run(); models.GetErrorType(err) != tt.errType {
+				t.Errorf("error = %v, want type %v", err, tt.errType)
+			}
+		})
+	}
+}
+
+func TestMemoryRepository_MoveAndList(t *testing.T) {
+	repo := newTestMemoryRepository()
+	for _, version := range []string{"2.0.0", "1.0.0"} {
+		if err := repo.WriteDiagram(memoryTestDiagram("door", version)); err != nil {
+			t.Fatalf("WriteDiagram() error = %v", err)
+		}
+	}
+
+	diagrams, err := repo.ListDiagrams(smmodels.DiagramTypePUML, models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ListDiagrams() error = %v", err)
+	}
+	if len(diagrams) != 2 || diagrams[0].Version != "1.0.0" || diagrams[1].Version != "2.0.0" {
+		t.Fatalf("ListDiagrams() = %+v, want both versions in file name order", diagrams)
+	}
+
+	if err := repo.MoveDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts); err != nil {
+		t.Fatalf("MoveDiagram() error = %v", err)
+	}
+	moved, err := repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts)
+	if err != nil || moved.Metadata.Author != "jane" {
+		t.Errorf("ReadDiagram() after move = %+v, %v, want the metadata to move too", moved, err)
+	}
+
+	// Moving onto an existing diagram is a conflict and changes nothing
+	if err := repo.WriteDiagram(memoryTestDiagram("door", "1.0.0")); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	err = repo.MoveDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts)
+	if models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Errorf("MoveDiagram() onto an existing diagram error = %v, want conflict", err)
+	}
+
+	if err := repo.DeleteDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteDiagram() error = %v", err)
+	}
+	if diagrams, _ := repo.ListDiagrams(smmodels.DiagramTypePUML, models.LocationFileProducts); len(diagrams) != 0 {
+		t.Errorf("ListDiagrams() after delete = %+v, want none", diagrams)
+	}
+}
+
+func TestMemoryRepository_SnapshotRestore(t *testing.T) {
+	repo := newTestMemoryRepository()
+	diag := memoryTestDiagram("door", "1.0.0")
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	if _, err := repo.AddRevision(diag, "jane", "Created"); err != nil {
+		t.Fatalf("AddRevision() error = %v", err)
+	}
+
+	snapshot := repo.Snapshot()
+
+	diag.Content = "@startuml\n[*] --> Open\n@enduml"
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	if _, err := repo.AddRevision(diag, "jane", "Opened"); err != nil {
+		t.Fatalf("AddRevision() error = %v", err)
+	}
+	if err := repo.WriteDiagram(memoryTestDiagram("window", "1.0.0")); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	// Restoring twice proves the snapshot is not changed by later writes
+	for i := 0; i < 2; i++ {
+		if err := repo.Restore(snapshot); err != nil {
+			t.Fatalf("Restore() error = %v", err)
+		}
+		got, err := repo.ReadDiagram(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+		if err != nil || !strings.Contains(got.Content, "Closed") {
+			t.Errorf("ReadDiagram() after restore = %+v, %v, want the snapshot content", got, err)
+		}
+		if exists, _ := repo.Exists(smmodels.DiagramTypePUML, "window", "1.0.0", models.LocationFileInProgress); exists {
+			t.Error("diagram written after the snapshot still exists after restore")
+		}
+		if revisions, _ := repo.ListRevisions(smmodels.DiagramTypePUML, "door", "1.0.0"); len(revisions) != 1 {
+			t.Errorf("ListRevisions() after restore = %+v, want one revision", revisions)
+		}
+
+		if err := repo.WriteMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.Metadata{Tags: []string{"changed"}}); err != nil {
+			t.Fatalf("WriteMetadata() error = %v", err)
+		}
+	}
+}
+
+func TestMemoryRepository_BackupsAndRevisions(t *testing.T) {
+	repo := newTestMemoryRepository()
+	diag := memoryTestDiagram("door", "1.0.0")
+	if err := repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	first, err := repo.CreateBackup(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.BackupReasonUpdate)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	second, err := repo.CreateBackup(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.BackupReasonDelete)
+	if err != nil {
+		t.Fatalf("CreateBackup() error = %v", err)
+	}
+	if !backupIDRegex.MatchString(first.ID) || first.ID >= second.ID {
+		t.Errorf("backup IDs = %q, %q, want valid and ordered", first.ID, second.ID)
+	}
+
+	restored, backup, err := repo.ReadBackup(smmodels.DiagramTypePUML, "door", "1.0.0", first.ID)
+	if err != nil || restored.Content != diag.Content || backup.Reason != models.BackupReasonUpdate {
+		t.Errorf("ReadBackup() = %+v, %+v, %v", restored, backup, err)
+	}
+	if _, _, err := repo.ReadBackup(smmodels.DiagramTypePUML, "door", "1.0.0", "latest"); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadBackup() with an invalid id error = %v, want validation", err)
+	}
+
+	if err := repo.PruneBackups(smmodels.DiagramTypePUML, "door", "1.0.0", 1); err != nil {
+		t.Fatalf("PruneBackups() error = %v", err)
+	}
+	if backups, _ := repo.ListBackups(smmodels.DiagramTypePUML, "door", "1.0.0"); len(backups) != 1 || backups[0].ID != second.ID {
+		t.Errorf("ListBackups() after prune = %+v, want only the newest", backups)
+	}
+
+	revision, err := repo.AddRevision(diag, "jane", "Created")
+	if err != nil || revision.Number != 1 {
+		t.Fatalf("AddRevision() = %+v, %v, want revision 1", revision, err)
+	}
+	if _, _, err := repo.ReadRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 2); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadRevision() of a missing revision error = %v, want not found", err)
+	}
+	if _, _, err := repo.ReadRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 0); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadRevision(0) error = %v, want validation", err)
+	}
+}
+
+func TestMemoryRepository_Interfaces(t *testing.T) {
+	var repo models.Repository = NewMemoryRepository(nil)
+
+	if _, ok := repo.(models.BlockRepository); !ok {
+		t.Error("MemoryRepository does not implement BlockRepository")
+	}
+	if _, ok := repo.(models.MetadataRepository); !ok {
+		t.Error("MemoryRepository does not implement MetadataRepository")
+	}
+	if _, ok := repo.(models.BackupRepository); !ok {
+		t.Error("MemoryRepository does not implement BackupRepository")
+	}
+	if _, ok := repo.(models.RevisionRepository); !ok {
+		t.Error("MemoryRepository does not implement RevisionRepository")
+	}
+}
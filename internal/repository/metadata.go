@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// metadataSidecar is the on-disk format of a {name}-{version}.meta.json file
+type metadataSidecar struct {
+	Author      string     `json:"author,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Description string     `json:"description,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	ModifiedAt  time.Time  `json:"modifiedAt"`
+	PromotedAt  *time.Time `json:"promotedAt,omitempty"`
+	PromotedBy  string     `json:"promotedBy,omitempty"`
+}
+
+// newMetadataSidecar converts metadata to its on-disk format
+func newMetadataSidecar(metadata models.Metadata) metadataSidecar {
+	sidecar := metadataSidecar{
+		Author:      metadata.Author,
+		Tags:        metadata.Tags,
+		Description: metadata.Description,
+		CreatedAt:   metadata.CreatedAt,
+		ModifiedAt:  metadata.ModifiedAt,
+		PromotedBy:  metadata.PromotedBy,
+	}
+	if !metadata.PromotedAt.IsZero() {
+		promotedAt := metadata.PromotedAt
+		sidecar.PromotedAt = &promotedAt
+	}
+	return sidecar
+}
+
+// metadata converts the on-disk format back to metadata
+func (m metadataSidecar) metadata() models.Metadata {
+	metadata := models.Metadata{
+		Author:      m.Author,
+		Tags:        m.Tags,
+		Description: m.Description,
+		CreatedAt:   m.CreatedAt,
+		ModifiedAt:  m.ModifiedAt,
+		PromotedBy:  m.PromotedBy,
+	}
+	if m.PromotedAt != nil {
+		metadata.PromotedAt = *m.PromotedAt
+	}
+	return metadata
+}
+
+// ReadMetadata reads the metadata sidecar of a state-machine diagram. Diagrams
+// without a sidecar report the file modification time as both timestamps.
+func (r *FileSystemRepository) ReadMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Metadata, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name).
+			WithContext("location", location.String())
+	}
+
+	filePath := r.pathManager.GetDiagramFilePathWithDiagramType(name, version, location, diagramType)
+	fileInfo, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "state-machine diagram file not found", err).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("filePath", filePath)
+	} else if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to get file info").
+			WithContext("filePath", filePath)
+	}
+
+	metadata, found, err := r.readSidecar(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		metadata = models.Metadata{CreatedAt: fileInfo.ModTime(), ModifiedAt: fileInfo.ModTime()}
+	}
+	return &metadata, nil
+}
+
+// WriteMetadata replaces the metadata sidecar of an existing state-machine diagram
+func (r *FileSystemRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return err
+	}
+	if version == "" {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name).
+			WithContext("location", location.String())
+	}
+
+	filePath := r.pathManager.GetDiagramFilePathWithDiagramType(name, version, location, diagramType)
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return models.NewStateMachineError(models.ErrorTypeFileNotFound, "state-machine diagram file not found", nil).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("filePath", filePath)
+	}
+
+	return r.writeSidecar(diagramType, name, version, location, metadata)
+}
+
+// readSidecar loads a metadata sidecar and reports whether one exists
+func (r *FileSystemRepository) readSidecar(diagramType smmodels.DiagramType, name, version string, location models.Location) (models.Metadata, bool, error) {
+	metaPath := r.pathManager.GetMetadataFilePathWithDiagramType(name, version, location, diagramType)
+
+	data, err := os.ReadFile(metaPath)
+	if os.IsNotExist(err) {
+		return models.Metadata{}, false, nil
+	} else if err != nil {
+		return models.Metadata{}, false, models.WrapError(err, models.ErrorTypeFileSystem, "failed to read metadata file").
+			WithContext("metaPath", metaPath)
+	}
+
+	var sidecar metadataSidecar
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return models.Metadata{}, false, models.NewStateMachineError(models.ErrorTypeCorruption, "failed to parse metadata file", err).
+			WithContext("metaPath", metaPath)
+	}
+	return sidecar.metadata(), true, nil
+}
+
+// writeSidecar stores metadata next to its diagram
+func (r *FileSystemRepository) writeSidecar(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	metaPath := r.pathManager.GetMetadataFilePathWithDiagramType(name, version, location, diagramType)
+
+	data, err := json.MarshalIndent(newMetadataSidecar(metadata), "", "  ")
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to encode metadata", err).
+			WithContext("metaPath", metaPath)
+	}
+
+	if err := r.files.writeFile(metaPath, data, 0644); err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to write metadata file", err).
+			WithContext("metaPath", metaPath)
+	}
+	return nil
+}
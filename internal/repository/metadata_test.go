@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestFileSystemRepository_MetadataSidecar(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	created := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	diag := helper.CreateTestDiagram("meta", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	diag.Metadata = models.Metadata{
+		CreatedAt:   created,
+		ModifiedAt:  created,
+		Author:      "jane",
+		Tags:        []string{"auth", "core"},
+		Description: "Login flow",
+	}
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+
+	read, err := helper.repo.ReadDiagram(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadDiagram() error = %v", err)
+	}
+	if !reflect.DeepEqual(read.Metadata, diag.Metadata) {
+		t.Errorf("Metadata = %+v, want %+v", read.Metadata, diag.Metadata)
+	}
+
+	// Rewriting without a creation time keeps the stored one
+	read.Metadata.CreatedAt = time.Time{}
+	read.Metadata.ModifiedAt = time.Time{}
+	read.Metadata.Author = "john"
+	if err := helper.repo.WriteDiagram(read); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	metadata, err := helper.repo.ReadMetadata(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if !metadata.CreatedAt.Equal(created) || metadata.Author != "john" || !metadata.ModifiedAt.After(created) {
+		t.Errorf("Metadata after rewrite = %+v", metadata)
+	}
+
+	// An update built without reading the diagram first keeps the stored metadata
+	update := helper.CreateTestDiagram("meta", "1.0.0", models.LocationFileInProgress)
+	update.DiagramType = smmodels.DiagramTypePUML
+	update.Content = "@startuml\n[*] --> Updated\n@enduml"
+	update.Metadata = models.Metadata{}
+	if err := helper.repo.WriteDiagram(update); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	metadata, err = helper.repo.ReadMetadata(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if metadata.Author != "john" || !reflect.DeepEqual(metadata.Tags, []string{"auth", "core"}) || metadata.Description != "Login flow" || !metadata.CreatedAt.Equal(created) {
+		t.Errorf("Metadata after an update without metadata = %+v, want the stored fields kept", metadata)
+	}
+
+	// The sidecar follows the diagram when it moves
+	if err := helper.repo.MoveDiagram(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileInProgress, models.LocationFileProducts); err != nil {
+		t.Fatalf("MoveDiagram() error = %v", err)
+	}
+	promoted := time.Date(2024, 4, 1, 9, 30, 0, 0, time.UTC)
+	metadata.PromotedAt = promoted
+	metadata.PromotedBy = "release-bot"
+	if err := helper.repo.WriteMetadata(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileProducts, *metadata); err != nil {
+		t.Fatalf("WriteMetadata() error = %v", err)
+	}
+	moved, err := helper.repo.ReadDiagram(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileProducts)
+	if err != nil {
+		t.Fatalf("ReadDiagram() after move error = %v", err)
+	}
+	if moved.Metadata.Author != "john" || !moved.Metadata.PromotedAt.Equal(promoted) || moved.Metadata.PromotedBy != "release-bot" {
+		t.Errorf("Metadata after move = %+v", moved.Metadata)
+	}
+
+	// Deleting the diagram removes the sidecar
+	if err := helper.repo.DeleteDiagram(smmodels.DiagramTypePUML, "meta", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteDiagram() error = %v", err)
+	}
+	metaPath := helper.repo.pathManager.GetMetadataFilePathWithDiagramType("meta", "1.0.0", models.LocationFileProducts, smmodels.DiagramTypePUML)
+	if _, err := os.Stat(metaPath); !os.IsNotExist(err) {
+		t.Errorf("Metadata sidecar should be removed, stat error = %v", err)
+	}
+}
+
+func TestFileSystemRepository_ReadMetadata_NoSidecar(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("plain", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	if err := helper.repo.WriteDiagram(diag); err != nil {
+		t.Fatalf("WriteDiagram() error = %v", err)
+	}
+	metaPath := helper.repo.pathManager.GetMetadataFilePathWithDiagramType("plain", "1.0.0", models.LocationFileInProgress, smmodels.DiagramTypePUML)
+	if err := os.Remove(metaPath); err != nil {
+		t.Fatalf("Failed to remove sidecar: %v", err)
+	}
+
+	metadata, err := helper.repo.ReadMetadata(smmodels.DiagramTypePUML, "plain", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadMetadata() error = %v", err)
+	}
+	if metadata.CreatedAt.IsZero() || !metadata.CreatedAt.Equal(metadata.ModifiedAt) {
+		t.Errorf("Metadata without sidecar = %+v, want file timestamps", metadata)
+	}
+
+	if _, err := helper.repo.ReadMetadata(smmodels.DiagramTypePUML, "absent", "1.0.0", models.LocationFileInProgress); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadMetadata() of missing diagram error = %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// revisionRecord is the on-disk description of a revision. The content is
+// stored in {number}.puml and the record in {number}.json, with the number
+// zero-padded to six digits.
+type revisionRecord struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Author    string    `json:"author,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Size      int64     `json:"size"`
+}
+
+// revisionFileName returns the base name of the files of a revision
+func revisionFileName(number int) string {
+	return fmt.Sprintf("%06d", number)
+}
+
+// AddRevision records the content of a state-machine diagram as its next revision
+func (r *FileSystemRepository) AddRevision(diag *models.StateMachineDiagram, author, message string) (*models.Revision, error) {
+	if diag == nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "state-machine diagram cannot be nil", nil)
+	}
+
+	if err := r.pathManager.ValidateName(diag.Name); err != nil {
+		return nil, err
+	}
+
+	historyDir := r.pathManager.GetHistoryPathWithDiagramType(diag.Name, diag.Version, diag.DiagramType)
+	numbers, err := revisionNumbers(historyDir)
+	if err != nil {
+		return nil, err
+	}
+	number := 1
+	if len(numbers) > 0 {
+		number = numbers[len(numbers)-1] + 1
+	}
+
+	if err := r.CreateDirectory(historyDir); err != nil {
+		return nil, err
+	}
+
+	contentPath := filepath.Join(historyDir, revisionFileName(number)+models.PlantUMLExtension)
+	if err := r.files.writeFile(contentPath, []byte(diag.Content), 0644); err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to write revision").
+			WithContext("name", diag.Name).
+			WithContext("version", diag.Version).
+			WithContext("revision", number)
+	}
+
+	record := revisionRecord{
+		CreatedAt: time.Now().UTC(),
+		Author:    author,
+		Message:   message,
+		Size:      int64(len(diag.Content)),
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to encode revision record", err).
+			WithContext("revision", number)
+	}
+	recordPath := filepath.Join(historyDir, revisionFileName(number)+".json")
+	if err := r.files.writeFile(recordPath, data, 0644); err != nil {
+		os.Remove(contentPath)
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to write revision record").
+			WithContext("name", diag.Name).
+			WithContext("version", diag.Version).
+			WithContext("revision", number)
+	}
+
+	return record.revision(number, diag.Name, diag.Version), nil
+}
+
+// revision converts a record to its public form
+func (rec revisionRecord) revision(number int, name, version string) *models.Revision {
+	return &models.Revision{
+		Number:    number,
+		Name:      name,
+		Version:   version,
+		CreatedAt: rec.CreatedAt,
+		Author:    rec.Author,
+		Message:   rec.Message,
+		Size:      rec.Size,
+	}
+}
+
+// ListRevisions returns the revisions of a state-machine diagram, oldest first
+func (r *FileSystemRepository) ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]models.Revision, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name)
+	}
+
+	historyDir := r.pathManager.GetHistoryPathWithDiagramType(name, version, diagramType)
+	numbers, err := revisionNumbers(historyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []models.Revision{}
+	for _, number := range numbers {
+		record, err := r.readRevisionRecord(historyDir, number)
+		if err != nil {
+			// Skip unreadable records rather than hiding the rest of the history
+			r.logger.WithError(err).Warn("Skipping unreadable revision record")
+			continue
+		}
+		revisions = append(revisions, *record.revision(number, name, version))
+	}
+
+	return revisions, nil
+}
+
+// revisionNumbers returns the numbers of the revision records in historyDir in
+// ascending order, including records that cannot be parsed
+func revisionNumbers(historyDir string) ([]int, error) {
+	entries, err := os.ReadDir(historyDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read history directory", err).
+			WithContext("historyDir", historyDir)
+	}
+
+	var numbers []int
+	for _, entry := range entries {
+		base, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		number, err := strconv.Atoi(base)
+		if err != nil || number < 1 || base != revisionFileName(number) {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// ReadRevision returns the content of a revision as an in-progress diagram
+// together with the revision description
+func (r *FileSystemRepository) ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*models.StateMachineDiagram, *models.Revision, error) {
+	if err := r.pathManager.ValidateName(name); err != nil {
+		return nil, nil, err
+	}
+	if version == "" {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "version is required for all state-machine diagrams", nil).
+			WithContext("name", name)
+	}
+	if number < 1 {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "revision numbers start at 1", nil).
+			WithContext("revision", number)
+	}
+
+	historyDir := r.pathManager.GetHistoryPathWithDiagramType(name, version, diagramType)
+	record, err := r.readRevisionRecord(historyDir, number)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	contentPath := filepath.Join(historyDir, revisionFileName(number)+models.PlantUMLExtension)
+	content, err := os.ReadFile(contentPath)
+	if err != nil {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeCorruption, "revision content is missing", err).
+			WithContext("revision", number).
+			WithContext("revisionPath", contentPath)
+	}
+
+	diag := &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     string(content),
+		Location:    models.LocationFileInProgress,
+		DiagramType: diagramType,
+		Metadata: models.Metadata{
+			ModifiedAt: record.CreatedAt,
+			Author:     record.Author,
+		},
+	}
+	return diag, record.revision(number, name, version), nil
+}
+
+// readRevisionRecord loads the record of a revision
+func (r *FileSystemRepository) readRevisionRecord(historyDir string, number int) (revisionRecord, error) {
+	recordPath := filepath.Join(historyDir, revisionFileName(number)+".json")
+	data, err := os.ReadFile(recordPath)
+	if os.IsNotExist(err) {
+		return revisionRecord{}, models.NewStateMachineError(models.ErrorTypeFileNotFound, "revision not found", err).
+			WithContext("revision", number)
+	} else if err != nil {
+		return revisionRecord{}, models.NewStateMachineError(models.ErrorTypeFileSystem, "failed to read revision record", err).
+			WithContext("recordPath", recordPath)
+	}
+
+	var record revisionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return revisionRecord{}, models.NewStateMachineError(models.ErrorTypeCorruption, "failed to parse revision record", err).
+			WithContext("recordPath", recordPath)
+	}
+	return record, nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestFileSystemRepository_Revisions(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	diag := helper.CreateTestDiagram("door", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	original := diag.Content
+
+	first, err := helper.repo.AddRevision(diag, "jane", "Created")
+	if err != nil {
+		t.Fatalf("AddRevision() error = %v", err)
+	}
+	if first.Number != 1 || first.Author != "jane" || first.Message != "Created" || first.Size != int64(len(original)) {
+		t.Errorf("AddRevision() = %+v", first)
+	}
+
+	diag.Content = "@startuml\n[*] --> Open\n@enduml"
+	second, err := helper.repo.AddRevision(diag, "joe", "")
+	if err != nil {
+		t.Fatalf("AddRevision() error = %v", err)
+	}
+	if second.Number != 2 {
+		t.Errorf("AddRevision() number = %d, want 2", second.Number)
+	}
+
+	revisions, err := helper.repo.ListRevisions(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 || revisions[0].Number != 1 || revisions[1].Number != 2 || revisions[1].Author != "joe" {
+		t.Fatalf("ListRevisions() = %+v, want the two revisions oldest first", revisions)
+	}
+
+	read, revision, err := helper.repo.ReadRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 1)
+	if err != nil {
+		t.Fatalf("ReadRevision() error = %v", err)
+	}
+	if read.Content != original || read.Location != models.LocationFileInProgress || read.Metadata.Author != "jane" || revision.Number != 1 {
+		t.Errorf("ReadRevision() = %+v, %+v", read, revision)
+	}
+}
+
+func TestFileSystemRepository_Revisions_Errors(t *testing.T) {
+	helper := NewTestHelper(t)
+	defer helper.Cleanup()
+
+	revisions, err := helper.repo.ListRevisions(smmodels.DiagramTypePUML, "none", "1.0.0")
+	if err != nil || len(revisions) != 0 {
+		t.Errorf("ListRevisions() without history = %v, %v, want empty", revisions, err)
+	}
+
+	if _, _, err := helper.repo.ReadRevision(smmodels.DiagramTypePUML, "none", "1.0.0", 0); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadRevision(0) error = %v, want validation error", err)
+	}
+	if _, _, err := helper.repo.ReadRevision(smmodels.DiagramTypePUML, "none", "", 1); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ReadRevision() without version error = %v, want validation error", err)
+	}
+	if _, _, err := helper.repo.ReadRevision(smmodels.DiagramTypePUML, "none", "1.0.0", 1); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadRevision() of a missing revision error = %v, want file not found", err)
+	}
+
+	// A corrupt record is skipped by listing, reported by reading and still
+	// counted when numbering the next revision
+	historyDir := helper.repo.pathManager.GetHistoryPathWithDiagramType("none", "1.0.0", smmodels.DiagramTypePUML)
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(historyDir, "000001.json"), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if revisions, err := helper.repo.ListRevisions(smmodels.DiagramTypePUML, "none", "1.0.0"); err != nil || len(revisions) != 0 {
+		t.Errorf("ListRevisions() with a corrupt record = %v, %v, want empty", revisions, err)
+	}
+	if _, _, err := helper.repo.ReadRevision(smmodels.DiagramTypePUML, "none", "1.0.0", 1); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadRevision() of a corrupt record error = %v, want corruption", err)
+	}
+
+	diag := helper.CreateTestDiagram("none", "1.0.0", models.LocationFileInProgress)
+	diag.DiagramType = smmodels.DiagramTypePUML
+	revision, err := helper.repo.AddRevision(diag, "", "")
+	if err != nil {
+		t.Fatalf("AddRevision() error = %v", err)
+	}
+	if revision.Number != 2 {
+		t.Errorf("AddRevision() number = %d, want 2", revision.Number)
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockBackupRepository adds in-memory backups to mockRepository
+type mockBackupRepository struct {
+	mockRepository
+	backups []models.Backup
+	content map[string]string // backup ID -> content
+	pruned  int
+	current string // content returned when a backup is created
+}
+
+func (m *mockBackupRepository) CreateBackup(diagramType smmodels.DiagramType, name, version string, location models.Location, reason string) (*models.Backup, error) {
+	backup := models.Backup{ID: string(rune('a' + len(m.backups))), Name: name, Version: version, Location: location, Reason: reason}
+	m.backups = append(m.backups, backup)
+	m.content[backup.ID] = m.current
+	return &backup, nil
+}
+
+func (m *mockBackupRepository) ListBackups(diagramType smmodels.DiagramType, name, version string) ([]models.Backup, error) {
+	return m.backups, nil
+}
+
+func (m *mockBackupRepository) ReadBackup(diagramType smmodels.DiagramType, name, version, id string) (*models.StateMachineDiagram, *models.Backup, error) {
+	for _, backup := range m.backups {
+		if backup.ID == id {
+			diag := &models.StateMachineDiagram{Name: name, Version: version, Content: m.content[id], Location: backup.Location, DiagramType: diagramType}
+			return diag, &backup, nil
+		}
+	}
+	return nil, nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "backup not found", nil)
+}
+
+func (m *mockBackupRepository) PruneBackups(diagramType smmodels.DiagramType, name, version string, keep int) error {
+	if keep > 0 && len(m.backups) > keep {
+		m.pruned += len(m.backups) - keep
+		m.backups = m.backups[len(m.backups)-keep:]
+	}
+	return nil
+}
+
+// newBackupService returns a service with backups enabled over a repository
+// where the in-progress diagram exists and products is empty
+func newBackupService(retention int) (models.DiagramService, *mockBackupRepository) {
+	repo := &mockBackupRepository{content: map[string]string{}, current: "@startuml\n[*] --> Idle\n@enduml"}
+	inProducts := false
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		return (location == models.LocationFileProducts) == inProducts, nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		return &models.StateMachineDiagram{Name: name, Version: version, Content: repo.current, Location: location}, nil
+	}
+	repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+		repo.current = diag.Content
+		return nil
+	}
+	repo.moveStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+		inProducts = to == models.LocationFileProducts
+		return nil
+	}
+
+	config := models.DefaultConfig()
+	config.BackupEnabled = true
+	config.BackupRetention = retention
+	return NewService(repo, &mockValidator{}, config), repo
+}
+
+func TestService_Backups_TakenBeforeChanges(t *testing.T) {
+	svc, repo := newBackupService(0)
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> Open\n@enduml", Location: models.LocationFileInProgress}
+	if err := svc.UpdateInProgressFile(diag); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	if err := svc.DeleteFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+
+	want := []struct {
+		reason   string
+		location models.Location
+		content  string
+	}{
+		{models.BackupReasonUpdate, models.LocationFileInProgress, "@startuml\n[*] --> Idle\n@enduml"},
+		{models.BackupReasonPromote, models.LocationFileInProgress, "@startuml\n[*] --> Open\n@enduml"},
+		{models.BackupReasonDelete, models.LocationFileProducts, "@startuml\n[*] --> Open\n@enduml"},
+	}
+	if len(repo.backups) != len(want) {
+		t.Fatalf("backups = %+v, want %d", repo.backups, len(want))
+	}
+	for i, w := range want {
+		b := repo.backups[i]
+		if b.Reason != w.reason || b.Location != w.location || repo.content[b.ID] != w.content {
+			t.Errorf("backup %d = %+v with %q, want %s of %s with %q", i, b, repo.content[b.ID], w.reason, w.location, w.content)
+		}
+	}
+}
+
+func TestService_Backups_Disabled(t *testing.T) {
+	svc, repo := newBackupService(0)
+	svc.(*service).config.BackupEnabled = false
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> Open\n@enduml", Location: models.LocationFileInProgress}
+	if err := svc.UpdateInProgressFile(diag); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+	if len(repo.backups) != 0 {
+		t.Errorf("backups = %+v, want none when disabled", repo.backups)
+	}
+}
+
+func TestService_Backups_Retention(t *testing.T) {
+	svc, repo := newBackupService(2)
+
+	for _, state := range []string{"A", "B", "C", "D"} {
+		diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> " + state + "\n@enduml", Location: models.LocationFileInProgress}
+		if err := svc.UpdateInProgressFile(diag); err != nil {
+			t.Fatalf("UpdateInProgressFile() error = %v", err)
+		}
+	}
+
+	backups, err := svc.ListBackups(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListBackups() error = %v", err)
+	}
+	if len(backups) != 2 || repo.pruned != 2 {
+		t.Errorf("ListBackups() = %+v after pruning %d, want the 2 newest", backups, repo.pruned)
+	}
+}
+
+func TestService_RestoreBackup(t *testing.T) {
+	svc, repo := newBackupService(0)
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> Open\n@enduml", Location: models.LocationFileInProgress}
+	if err := svc.UpdateInProgressFile(diag); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+
+	restored, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", repo.backups[0].ID)
+	if err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if restored.Content != "@startuml\n[*] --> Idle\n@enduml" || repo.current != restored.Content {
+		t.Errorf("RestoreBackup() content = %q, repository has %q", restored.Content, repo.current)
+	}
+	// The overwritten content is itself backed up
+	if last := repo.backups[len(repo.backups)-1]; last.Reason != models.BackupReasonRestore || repo.content[last.ID] != "@startuml\n[*] --> Open\n@enduml" {
+		t.Errorf("last backup = %+v, want a restore backup of the overwritten content", last)
+	}
+
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", "missing"); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("RestoreBackup() of a missing backup error = %v, want file not found", err)
+	}
+
+	// Nothing may be restored over a product
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", repo.backups[0].ID); models.GetErrorType(err) != models.ErrorTypeDirectoryConflict {
+		t.Errorf("RestoreBackup() over a product error = %v, want directory conflict", err)
+	}
+}
+
+func TestService_Backups_Unsupported(t *testing.T) {
+	svc := NewService(&mockRepository{}, &mockValidator{}, nil)
+
+	if _, err := svc.ListBackups(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("ListBackups() error = %v, want configuration error", err)
+	}
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", "a"); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("RestoreBackup() error = %v, want configuration error", err)
+	}
+}
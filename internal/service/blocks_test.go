@@ -0,0 +1,101 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+const multiBlockContent = "@startuml login\n[*] --> Idle\n@enduml\n\n@startuml logout\nDone\n@enduml"
+
+func newMultiBlockRepository() *mockRepository {
+	return &mockRepository{
+		readStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+			return &models.StateMachineDiagram{
+				Name:     name,
+				Version:  version,
+				Content:  multiBlockContent,
+				Location: location,
+			}, nil
+		},
+	}
+}
+
+func TestService_ListBlocks(t *testing.T) {
+	svc := NewService(newMultiBlockRepository(), &mockValidator{}, nil)
+
+	blocks, err := svc.ListBlocks(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ListBlocks() error = %v", err)
+	}
+	if len(blocks) != 2 || blocks[0].ID != "login" || blocks[1].ID != "logout" {
+		t.Errorf("ListBlocks() = %+v", blocks)
+	}
+
+	if _, err := svc.ListBlocks(smmodels.DiagramTypePUML, "", "1.0.0", models.LocationFileInProgress); err == nil {
+		t.Error("ListBlocks() with empty name should fail")
+	}
+}
+
+func TestService_ReadBlock(t *testing.T) {
+	tests := []struct {
+		name        string
+		blockID     string
+		wantErr     bool
+		wantErrType models.ErrorType
+		wantStart   int
+	}{
+		{name: "named block", blockID: "logout", wantStart: 5},
+		{name: "missing block", blockID: "nope", wantErr: true, wantErrType: models.ErrorTypeFileNotFound},
+		{name: "empty block id", blockID: "", wantErr: true, wantErrType: models.ErrorTypeValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := NewService(newMultiBlockRepository(), &mockValidator{}, nil)
+
+			block, err := svc.ReadBlock(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress, tt.blockID)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected error but got none")
+				}
+				if models.GetErrorType(err) != tt.wantErrType {
+					t.Errorf("Error type = %v, want %v", models.GetErrorType(err), tt.wantErrType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadBlock() error = %v", err)
+			}
+			if block.StartLine != tt.wantStart {
+				t.Errorf("StartLine = %d, want %d", block.StartLine, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestService_ValidateBlock(t *testing.T) {
+	validator := &mockValidator{
+		validateFunc: func(diag *models.StateMachineDiagram, strictness models.ValidationStrictness) (*models.ValidationResult, error) {
+			if diag.Content != "@startuml logout\nDone\n@enduml" {
+				t.Errorf("Validated unexpected content %q", diag.Content)
+			}
+			result := &models.ValidationResult{IsValid: true}
+			result.AddWarning("NO_INITIAL_STATE", "no initial state", 1, 1)
+			return result, nil
+		},
+	}
+	svc := NewService(newMultiBlockRepository(), validator, nil)
+
+	result, err := svc.ValidateBlock(smmodels.DiagramTypePUML, "multi", "1.0.0", models.LocationFileInProgress, "logout")
+	if err != nil {
+		t.Fatalf("ValidateBlock() error = %v", err)
+	}
+	if len(result.Warnings) != 1 {
+		t.Fatalf("Warnings = %+v, want 1", result.Warnings)
+	}
+	if result.Warnings[0].Line != 5 || result.Warnings[0].Context["block"] != "logout" {
+		t.Errorf("Warning = %+v, want line 5 in block logout", result.Warnings[0])
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockChangeLogRepository adds a fixed change history to mockRepository
+type mockChangeLogRepository struct {
+	mockRepository
+	changes []models.Change
+}
+
+func (m *mockChangeLogRepository) ListChanges(diagramType smmodels.DiagramType, name, version string) ([]models.Change, error) {
+	var changes []models.Change
+	for _, change := range m.changes {
+		if change.Name == name && change.Version == version {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func TestService_ListChanges(t *testing.T) {
+	repo := &mockChangeLogRepository{changes: []models.Change{
+		{ID: "a1", Operation: models.ChangeWrite, Name: "door", Version: "1.0.0", Location: models.LocationFileInProgress},
+		{ID: "b2", Operation: models.ChangeWrite, Name: "window", Version: "1.0.0", Location: models.LocationFileInProgress},
+		{ID: "c3", Operation: models.ChangeMove, Name: "door", Version: "1.0.0", FromLocation: models.LocationFileInProgress, Location: models.LocationFileProducts},
+	}}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	changes, err := svc.ListChanges(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListChanges() error = %v", err)
+	}
+	if len(changes) != 2 || changes[0].ID != "a1" || changes[1].ID != "c3" {
+		t.Errorf("ListChanges() = %+v, want the two changes of door", changes)
+	}
+
+	if _, err := svc.ListChanges(smmodels.DiagramTypePUML, "door", ""); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ListChanges() with empty version error = %v, want validation error", err)
+	}
+
+	unsupported := NewService(&mockRepository{}, &mockValidator{}, nil)
+	if _, err := unsupported.ListChanges(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("ListChanges() error = %v, want configuration error", err)
+	}
+}
@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockChecksumRepository holds one diagram per location and stores checksums
+type mockChecksumRepository struct {
+	mockRepository
+	content   map[models.Location]string
+	checksums map[string]models.ProductChecksum
+}
+
+func (m *mockChecksumRepository) WriteChecksum(diagramType smmodels.DiagramType, name, version string, checksum models.ProductChecksum) error {
+	m.checksums[name+"-"+version] = checksum
+	return nil
+}
+
+func (m *mockChecksumRepository) ReadChecksum(diagramType smmodels.DiagramType, name, version string) (*models.ProductChecksum, error) {
+	checksum, ok := m.checksums[name+"-"+version]
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "no checksum recorded", nil)
+	}
+	return &checksum, nil
+}
+
+func (m *mockChecksumRepository) DeleteChecksum(diagramType smmodels.DiagramType, name, version string) error {
+	delete(m.checksums, name+"-"+version)
+	return nil
+}
+
+// newChecksumRepository returns a repository holding an in-progress diagram
+func newChecksumRepository() *mockChecksumRepository {
+	repo := &mockChecksumRepository{
+		content:   map[models.Location]string{models.LocationFileInProgress: "@startuml\n[*] --> Idle\n@enduml"},
+		checksums: map[string]models.ProductChecksum{},
+	}
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		_, ok := repo.content[location]
+		return ok, nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		content, ok := repo.content[location]
+		if !ok {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "not found", nil)
+		}
+		return &models.StateMachineDiagram{Name: name, Version: version, Content: content, Location: location, DiagramType: diagramType}, nil
+	}
+	repo.moveStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+		repo.content[to] = repo.content[from]
+		delete(repo.content, from)
+		return nil
+	}
+	repo.deleteStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+		delete(repo.content, location)
+		return nil
+	}
+	return repo
+}
+
+func TestService_ProductChecksum(t *testing.T) {
+	repo := newChecksumRepository()
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	checksum, ok := repo.checksums["door-1.0.0"]
+	if !ok || checksum.Digest != models.ContentDigest(repo.content[models.LocationFileProducts]) || len(checksum.Signature) != 0 {
+		t.Fatalf("recorded checksum = %+v, want the unsigned digest of the promoted content", checksum)
+	}
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Errorf("ReadFile() of an untouched product error = %v", err)
+	}
+
+	// Content changed behind the service's back
+	repo.content[models.LocationFileProducts] += "\n' tampered"
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadFile() of a tampered product error = %v, want corruption", err)
+	}
+
+	// Deleting the product drops its checksum
+	if err := svc.DeleteFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if _, ok := repo.checksums["door-1.0.0"]; ok {
+		t.Error("checksum kept after the product was deleted")
+	}
+}
+
+func TestService_ProductSignature(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := models.DefaultConfig()
+	config.KeyProvider = &models.StaticKeyProvider{KeyID: "release", PrivateKey: key}
+
+	repo := newChecksumRepository()
+	svc := NewService(repo, &mockValidator{}, config)
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	checksum := repo.checksums["door-1.0.0"]
+	if checksum.KeyID != "release" || len(checksum.Signature) != ed25519.SignatureSize {
+		t.Fatalf("recorded checksum = %+v, want a signature by the release key", checksum)
+	}
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Errorf("ReadFile() of a signed product error = %v", err)
+	}
+
+	// A digest recomputed for new content does not carry a valid signature
+	repo.content[models.LocationFileProducts] += "\n' tampered"
+	checksum.Digest = models.ContentDigest(repo.content[models.LocationFileProducts])
+	repo.checksums["door-1.0.0"] = checksum
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadFile() with a forged digest error = %v, want corruption", err)
+	}
+
+	// With a key provider every product must be signed
+	delete(repo.checksums, "door-1.0.0")
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadFile() without a checksum error = %v, want corruption", err)
+	}
+
+	// A key the provider does not know cannot verify anything
+	other := NewService(repo, &mockValidator{}, &models.Config{KeyProvider: &models.StaticKeyProvider{KeyID: "other"}})
+	repo.checksums["door-1.0.0"] = checksum
+	if _, err := other.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("ReadFile() with an unknown key error = %v, want corruption", err)
+	}
+}
+
+// mockSealedBackupRepository adds backups that keep the products checksum to mockChecksumRepository
+type mockSealedBackupRepository struct {
+	*mockChecksumRepository
+	backups []models.Backup
+	content map[string]string // backup ID -> content
+}
+
+func (m *mockSealedBackupRepository) CreateBackup(diagramType smmodels.DiagramType, name, version string, location models.Location, reason string) (*models.Backup, error) {
+	backup := models.Backup{ID: string(rune('a' + len(m.backups))), Name: name, Version: version, Location: location, Reason: reason}
+	if checksum, ok := m.checksums[name+"-"+version]; ok && location == models.LocationFileProducts {
+		backup.Checksum = &checksum
+	}
+	m.backups = append(m.backups, backup)
+	m.content[backup.ID] = m.mockChecksumRepository.content[location]
+	return &backup, nil
+}
+
+func (m *mockSealedBackupRepository) ListBackups(diagramType smmodels.DiagramType, name, version string) ([]models.Backup, error) {
+	return m.backups, nil
+}
+
+func (m *mockSealedBackupRepository) ReadBackup(diagramType smmodels.DiagramType, name, version, id string) (*models.StateMachineDiagram, *models.Backup, error) {
+	for _, backup := range m.backups {
+		if backup.ID == id {
+			diag := &models.StateMachineDiagram{Name: name, Version: version, Content: m.content[id], Location: backup.Location, DiagramType: diagramType}
+			return diag, &backup, nil
+		}
+	}
+	return nil, nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "backup not found", nil)
+}
+
+func (m *mockSealedBackupRepository) PruneBackups(diagramType smmodels.DiagramType, name, version string, keep int) error {
+	return nil
+}
+
+func TestService_RestoreBackup_VerifiesProductChecksum(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := models.DefaultConfig()
+	config.KeyProvider = &models.StaticKeyProvider{KeyID: "release", PrivateKey: key}
+	config.BackupEnabled = true
+
+	repo := &mockSealedBackupRepository{mockChecksumRepository: newChecksumRepository(), content: map[string]string{}}
+	repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+		repo.mockChecksumRepository.content[diag.Location] = diag.Content
+		return nil
+	}
+	svc := NewService(repo, &mockValidator{}, config)
+
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	sealed := repo.checksums["door-1.0.0"]
+	if err := svc.DeleteFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	backup := repo.backups[len(repo.backups)-1]
+	if backup.Checksum == nil || backup.Checksum.Digest != sealed.Digest {
+		t.Fatalf("products backup checksum = %+v, want %+v", backup.Checksum, sealed)
+	}
+
+	// Backup content changed behind the service's back is refused
+	original := repo.content[backup.ID]
+	repo.content[backup.ID] = original + "\n' tampered"
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", backup.ID); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("RestoreBackup() of tampered content error = %v, want corruption", err)
+	}
+
+	// So is a digest recomputed for the new content, which the signature does not cover
+	forged := *backup.Checksum
+	forged.Digest = models.ContentDigest(repo.content[backup.ID])
+	repo.backups[len(repo.backups)-1].Checksum = &forged
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", backup.ID); models.GetErrorType(err) != models.ErrorTypeCorruption {
+		t.Errorf("RestoreBackup() with a forged digest error = %v, want corruption", err)
+	}
+	if _, ok := repo.mockChecksumRepository.content[models.LocationFileProducts]; ok {
+		t.Fatal("refused restore wrote the products diagram")
+	}
+	if _, ok := repo.checksums["door-1.0.0"]; ok {
+		t.Fatal("refused restore recorded a checksum")
+	}
+
+	// The untouched backup is restored with the signature it was promoted with
+	repo.content[backup.ID] = original
+	repo.backups[len(repo.backups)-1].Checksum = backup.Checksum
+	if _, err := svc.RestoreBackup(smmodels.DiagramTypePUML, "door", "1.0.0", backup.ID); err != nil {
+		t.Fatalf("RestoreBackup() error = %v", err)
+	}
+	if restored := repo.checksums["door-1.0.0"]; restored.Digest != sealed.Digest || !bytes.Equal(restored.Signature, sealed.Signature) {
+		t.Errorf("restored checksum = %+v, want the original %+v", restored, sealed)
+	}
+	if _, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Errorf("ReadFile() of the restored product error = %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockExpander is a validator that also supports include expansion
+type mockExpander struct {
+	mockValidator
+	expandFunc func(diag *models.StateMachineDiagram) (*models.Expansion, *models.ValidationResult, error)
+}
+
+func (m *mockExpander) ExpandIncludes(diag *models.StateMachineDiagram) (*models.Expansion, *models.ValidationResult, error) {
+	return m.expandFunc(diag)
+}
+
+func newExpandRepository() *mockRepository {
+	return &mockRepository{
+		readStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+			return &models.StateMachineDiagram{
+				Name:     name,
+				Version:  version,
+				Content:  "@startuml\n!include products/leaf-1.0.0/leaf-1.0.0.puml\n@enduml",
+				Location: location,
+			}, nil
+		},
+	}
+}
+
+// newTestExpander inlines two lines for the include on line 2 and reports a warning
+func newTestExpander(validate func(diag *models.StateMachineDiagram, strictness models.ValidationStrictness) (*models.ValidationResult, error)) *mockExpander {
+	return &mockExpander{
+		mockValidator: mockValidator{validateFunc: validate},
+		expandFunc: func(diag *models.StateMachineDiagram) (*models.Expansion, *models.ValidationResult, error) {
+			expanded := *diag
+			expanded.Content = "@startuml\nLeafA --> LeafB\nLeafB --> Bad State\n@enduml"
+
+			expansion := &models.Expansion{
+				Diagram:  &expanded,
+				Includes: []models.Reference{{Name: "leaf", Version: "1.0.0", Type: models.ReferenceTypeProduct}},
+			}
+			expansion.SourceMap.Add(1, true)
+			expansion.SourceMap.Add(2, false)
+			expansion.SourceMap.Add(2, false)
+			expansion.SourceMap.Add(3, true)
+
+			result := &models.ValidationResult{IsValid: true}
+			result.AddWarning("INCLUDE_NOTE", "expansion note", 2, 1)
+			return expansion, result, nil
+		},
+	}
+}
+
+func TestService_ExpandFile(t *testing.T) {
+	svc := NewService(newExpandRepository(), newTestExpander(nil), nil)
+
+	expansion, result, err := svc.ExpandFile(smmodels.DiagramTypePUML, "root", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ExpandFile() error = %v", err)
+	}
+	if len(expansion.Includes) != 1 || expansion.Diagram.Content != "@startuml\nLeafA --> LeafB\nLeafB --> Bad State\n@enduml" {
+		t.Errorf("ExpandFile() expansion = %+v", expansion)
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("ExpandFile() result = %+v", result)
+	}
+
+	if _, _, err := svc.ExpandFile(smmodels.DiagramTypePUML, "", "1.0.0", models.LocationFileInProgress); err == nil {
+		t.Error("ExpandFile() with empty name should fail")
+	}
+}
+
+func TestService_ExpandFile_UnsupportedValidator(t *testing.T) {
+	svc := NewService(newExpandRepository(), &mockValidator{}, nil)
+
+	_, _, err := svc.ExpandFile(smmodels.DiagramTypePUML, "root", "1.0.0", models.LocationFileInProgress)
+	if models.GetErrorType(err) != models.ErrorTypeReferenceResolution {
+		t.Errorf("ExpandFile() error = %v, want ErrorTypeReferenceResolution", err)
+	}
+}
+
+func TestService_ValidateExpandedFile(t *testing.T) {
+	expander := newTestExpander(func(diag *models.StateMachineDiagram, strictness models.ValidationStrictness) (*models.ValidationResult, error) {
+		if diag.Content != "@startuml\nLeafA --> LeafB\nLeafB --> Bad State\n@enduml" {
+			t.Errorf("Validated unexpected content %q", diag.Content)
+		}
+		result := &models.ValidationResult{IsValid: true}
+		result.AddErrorWithSpan("INVALID_STATE_NAME", "bad name", models.Span{StartLine: 3, StartColumn: 11, EndLine: 3, EndColumn: 20})
+		return result, nil
+	})
+	svc := NewService(newExpandRepository(), expander, nil)
+
+	result, err := svc.ValidateExpandedFile(smmodels.DiagramTypePUML, "root", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ValidateExpandedFile() error = %v", err)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0].Line != 2 || result.Errors[0].Column != 1 {
+		t.Errorf("Errors = %+v, want one error on the !include line", result.Errors)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != "INCLUDE_NOTE" {
+		t.Errorf("Warnings = %+v, want the expansion warning", result.Warnings)
+	}
+	if result.IsValid {
+		t.Error("Result should be invalid")
+	}
+}
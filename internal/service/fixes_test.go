@@ -0,0 +1,128 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestService_ApplyFixes(t *testing.T) {
+	missingEnd := models.Fix{
+		Code: "MISSING_END",
+		Edits: []models.TextEdit{
+			{Span: models.PointSpan(2, 13), NewText: "\n@enduml"},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		inputName   string
+		inputVer    string
+		fixes       []models.Fix
+		setupMock   func(*mockRepository, *mockValidator, *string)
+		wantErr     bool
+		wantErrType models.ErrorType
+		wantContent string
+	}{
+		{
+			name:      "fix applied and re-validated",
+			inputName: "test-diag",
+			inputVer:  "1.0.0",
+			fixes:     []models.Fix{missingEnd},
+			setupMock: func(repo *mockRepository, validator *mockValidator, written *string) {
+				repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+					if location != models.LocationFileInProgress {
+						t.Errorf("Expected in-progress location but got %v", location)
+					}
+					return &models.StateMachineDiagram{
+						Name:     name,
+						Version:  version,
+						Content:  "@startuml\n[*] --> Idle",
+						Location: location,
+					}, nil
+				}
+				repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+					*written = diag.Content
+					return nil
+				}
+				validator.validateFunc = func(diag *models.StateMachineDiagram, strictness models.ValidationStrictness) (*models.ValidationResult, error) {
+					if diag.Content != "@startuml\n[*] --> Idle\n@enduml" {
+						t.Errorf("Validated unexpected content %q", diag.Content)
+					}
+					return &models.ValidationResult{IsValid: true}, nil
+				}
+			},
+			wantContent: "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:        "empty name",
+			inputName:   "",
+			inputVer:    "1.0.0",
+			setupMock:   func(repo *mockRepository, validator *mockValidator, written *string) {},
+			wantErr:     true,
+			wantErrType: models.ErrorTypeValidation,
+		},
+		{
+			name:      "read failure",
+			inputName: "test-diag",
+			inputVer:  "1.0.0",
+			fixes:     []models.Fix{missingEnd},
+			setupMock: func(repo *mockRepository, validator *mockValidator, written *string) {
+				repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+					return nil, errors.New("not found")
+				}
+			},
+			wantErr:     true,
+			wantErrType: models.ErrorTypeFileNotFound,
+		},
+		{
+			name:      "fix out of range",
+			inputName: "test-diag",
+			inputVer:  "1.0.0",
+			fixes: []models.Fix{{Code: "BAD", Edits: []models.TextEdit{
+				{Span: models.PointSpan(9, 1), NewText: "x"},
+			}}},
+			setupMock: func(repo *mockRepository, validator *mockValidator, written *string) {
+				repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+					return &models.StateMachineDiagram{Name: name, Version: version, Content: "@startuml", Location: location}, nil
+				}
+				repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+					t.Error("WriteDiagram should not be called when fixes fail")
+					return nil
+				}
+			},
+			wantErr:     true,
+			wantErrType: models.ErrorTypeValidation,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepository{}
+			validator := &mockValidator{}
+			var written string
+			tt.setupMock(repo, validator, &written)
+
+			svc := NewService(repo, validator, nil)
+			result, err := svc.ApplyFixes(smmodels.DiagramTypePUML, tt.inputName, tt.inputVer, tt.fixes)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ApplyFixes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if models.GetErrorType(err) != tt.wantErrType {
+					t.Errorf("ApplyFixes() error type = %v, want %v", models.GetErrorType(err), tt.wantErrType)
+				}
+				return
+			}
+			if result == nil || !result.IsValid {
+				t.Error("ApplyFixes() should return the re-validation result")
+			}
+			if written != tt.wantContent {
+				t.Errorf("ApplyFixes() wrote %q, want %q", written, tt.wantContent)
+			}
+		})
+	}
+}
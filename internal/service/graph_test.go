@@ -0,0 +1,57 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestService_BuildDependencyGraph(t *testing.T) {
+	repo := &mockRepository{
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			if location == models.LocationFileInProgress {
+				return []models.StateMachineDiagram{{Name: "app", Version: "1.0.0", Location: location}}, nil
+			}
+			return []models.StateMachineDiagram{{Name: "base", Version: "1.0.0", Location: location}}, nil
+		},
+	}
+	validator := &mockValidator{
+		validateReferencesFunc: func(diag *models.StateMachineDiagram) (*models.ValidationResult, error) {
+			if diag.Name == "app" {
+				diag.References = []models.Reference{{Name: "base", Version: "1.0.0", Type: models.ReferenceTypeProduct}}
+			}
+			return &models.ValidationResult{IsValid: true}, nil
+		},
+	}
+	svc := NewService(repo, validator, nil)
+
+	graph, err := svc.BuildDependencyGraph(smmodels.DiagramTypePUML)
+	if err != nil {
+		t.Fatalf("BuildDependencyGraph() error = %v", err)
+	}
+
+	base := models.GraphNode{Name: "base", Version: "1.0.0", Location: models.LocationFileProducts}
+	dependents := graph.Dependents(base)
+	if len(dependents) != 1 || dependents[0].Name != "app" || dependents[0].Location != models.LocationFileInProgress {
+		t.Errorf("Dependents() = %v, want the in-progress app", dependents)
+	}
+	if len(graph.Nodes()) != 2 {
+		t.Errorf("Nodes() = %v, want 2 nodes", graph.Nodes())
+	}
+}
+
+func TestService_BuildDependencyGraph_ListError(t *testing.T) {
+	repo := &mockRepository{
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			return nil, errors.New("disk failure")
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	_, err := svc.BuildDependencyGraph(smmodels.DiagramTypePUML)
+	if models.GetErrorType(err) != models.ErrorTypeFileSystem {
+		t.Errorf("BuildDependencyGraph() error = %v, want ErrorTypeFileSystem", err)
+	}
+}
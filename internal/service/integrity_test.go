@@ -0,0 +1,60 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockIntegrityRepository adds a fixed integrity check to mockRepository
+type mockIntegrityRepository struct {
+	mockRepository
+	findings []models.Finding
+	options  models.CheckOptions
+}
+
+func (m *mockIntegrityRepository) CheckIntegrity(options models.CheckOptions) (*models.CheckReport, error) {
+	m.options = options
+	findings := make([]models.Finding, len(m.findings))
+	for i, finding := range m.findings {
+		if options.Repair != models.RepairNone {
+			finding.Action = models.RepairActionRemoved
+		}
+		findings[i] = finding
+	}
+	return &models.CheckReport{Findings: findings, FilesChecked: 3}, nil
+}
+
+func TestService_CheckIntegrity(t *testing.T) {
+	repo := &mockIntegrityRepository{findings: []models.Finding{
+		{Type: models.FindingDuplicateVersion, Path: "in-progress/puml/door-1.0.0.puml", Name: "door", Version: "1.0.0"},
+	}}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	report, err := svc.CheckIntegrity(models.CheckOptions{})
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	if len(report.Findings) != 1 || report.FilesChecked != 3 {
+		t.Errorf("CheckIntegrity() = %+v, want the repository report", report)
+	}
+
+	// A repair drops the search indexes so removed diagrams stop matching
+	s := svc.(*service)
+	s.indexes[smmodels.DiagramTypePUML] = models.NewSearchIndex()
+	if _, err := svc.CheckIntegrity(models.CheckOptions{Repair: models.RepairFix}); err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	if repo.options.Repair != models.RepairFix {
+		t.Errorf("repository options = %+v, want the repair mode passed through", repo.options)
+	}
+	if s.indexedSearch(smmodels.DiagramTypePUML) != nil {
+		t.Error("search index kept after a repair")
+	}
+
+	unsupported := NewService(&mockRepository{}, &mockValidator{}, nil)
+	if _, err := unsupported.CheckIntegrity(models.CheckOptions{}); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("CheckIntegrity() error = %v, want configuration error", err)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockLockRepository records the locks taken through it
+type mockLockRepository struct {
+	mockRepository
+	locks   []string // "root" or "name-version" of every acquisition
+	held    int
+	lockErr error
+}
+
+func (m *mockLockRepository) LockDiagram(diagramType smmodels.DiagramType, name, version string) (func(), error) {
+	return m.lock(name + "-" + version)
+}
+
+func (m *mockLockRepository) LockRoot() (func(), error) {
+	return m.lock("root")
+}
+
+func (m *mockLockRepository) lock(key string) (func(), error) {
+	if m.lockErr != nil {
+		return nil, m.lockErr
+	}
+	m.locks = append(m.locks, key)
+	m.held++
+	return func() { m.held-- }, nil
+}
+
+func TestService_Locks(t *testing.T) {
+	repo := &mockLockRepository{}
+	inProducts := false
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		return version == "1.0.0" && (location == models.LocationFileProducts) == inProducts, nil
+	}
+	repo.moveStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+		inProducts = to == models.LocationFileProducts
+		return nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		return &models.StateMachineDiagram{Name: name, Version: version, Content: "@startuml\n[*] --> Idle\n@enduml", Location: location}, nil
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> Open\n@enduml", Location: models.LocationFileInProgress}
+	if err := svc.UpdateInProgressFile(diag); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "door", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	if _, err := svc.CreateFile(smmodels.DiagramTypePUML, "door", "2.0.0", diag.Content, models.LocationFileInProgress); err != nil {
+		t.Fatalf("CreateFile() error = %v", err)
+	}
+
+	// Products deletions check every dependent, so they lock the whole root
+	if _, err := svc.DeleteFileWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileProducts, models.DeleteOptions{}); err != nil {
+		t.Fatalf("DeleteFileWithOptions() of a product error = %v", err)
+	}
+
+	want := []string{"door-1.0.0", "door-1.0.0", "door-2.0.0", "root"}
+	if len(repo.locks) != len(want) {
+		t.Fatalf("locks = %v, want %v", repo.locks, want)
+	}
+	for i := range want {
+		if repo.locks[i] != want[i] {
+			t.Errorf("locks[%d] = %q, want %q", i, repo.locks[i], want[i])
+		}
+	}
+	if repo.held != 0 {
+		t.Errorf("%d lock(s) still held after the operations returned", repo.held)
+	}
+}
+
+func TestService_Locks_Timeout(t *testing.T) {
+	repo := &mockLockRepository{lockErr: models.NewStateMachineError(models.ErrorTypeTimeout, "timed out waiting for lock", nil)}
+	writes := 0
+	repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+		writes++
+		return nil
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: "@startuml\n[*] --> Open\n@enduml", Location: models.LocationFileInProgress}
+	if err := svc.UpdateInProgressFile(diag); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("UpdateInProgressFile() error = %v, want timeout", err)
+	}
+	if _, err := svc.CreateFile(smmodels.DiagramTypePUML, "door", "1.0.0", diag.Content, models.LocationFileInProgress); models.GetErrorType(err) != models.ErrorTypeTimeout {
+		t.Errorf("CreateFile() error = %v, want timeout", err)
+	}
+	if writes != 0 {
+		t.Errorf("%d write(s) made without the lock", writes)
+	}
+}
@@ -406,3 +406,47 @@ func TestService_PromoteToProductsFile_IntegrationWorkflow(t *testing.T) {
 		t.Errorf("Expected diagram to be in products state, got %s", diagramState)
 	}
 }
+
+// mockMetadataRepository adds metadata persistence to mockRepository
+type mockMetadataRepository struct {
+	mockRepository
+	metadata map[string]models.Metadata
+}
+
+func (m *mockMetadataRepository) ReadMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Metadata, error) {
+	metadata := m.metadata[name+"-"+version+"-"+location.String()]
+	return &metadata, nil
+}
+
+func (m *mockMetadataRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	m.metadata[name+"-"+version+"-"+location.String()] = metadata
+	return nil
+}
+
+func TestService_PromoteToProductsFileWithOptions_RecordsPromotion(t *testing.T) {
+	repo := &mockMetadataRepository{metadata: map[string]models.Metadata{
+		"test-diag-1.0.0-products": {Author: "jane"},
+	}}
+
+	inProducts := false
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		return (location == models.LocationFileProducts) == inProducts, nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		return &models.StateMachineDiagram{Name: name, Version: version, Content: "@startuml\n[*] --> Idle\n@enduml", Location: location}, nil
+	}
+	repo.moveStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+		inProducts = to == models.LocationFileProducts
+		return nil
+	}
+
+	svc := NewService(repo, &mockValidator{}, nil)
+	if err := svc.PromoteToProductsFileWithOptions(smmodels.DiagramTypePUML, "test-diag", "1.0.0", models.PromoteOptions{Actor: "release-bot"}); err != nil {
+		t.Fatalf("PromoteToProductsFileWithOptions() error = %v", err)
+	}
+
+	metadata := repo.metadata["test-diag-1.0.0-products"]
+	if metadata.PromotedBy != "release-bot" || metadata.PromotedAt.IsZero() || metadata.Author != "jane" {
+		t.Errorf("Metadata = %+v, want promotion recorded and author kept", metadata)
+	}
+}
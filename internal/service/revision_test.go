@@ -0,0 +1,132 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mockRevisionRepository adds in-memory revision history to mockRepository
+type mockRevisionRepository struct {
+	mockRepository
+	revisions []models.Revision
+	content   []string // content of each revision, indexed by number - 1
+	current   string   // content of the in-progress diagram
+}
+
+func (m *mockRevisionRepository) AddRevision(diag *models.StateMachineDiagram, author, message string) (*models.Revision, error) {
+	revision := models.Revision{Number: len(m.revisions) + 1, Name: diag.Name, Version: diag.Version, Author: author, Message: message}
+	m.revisions = append(m.revisions, revision)
+	m.content = append(m.content, diag.Content)
+	return &revision, nil
+}
+
+func (m *mockRevisionRepository) ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]models.Revision, error) {
+	return m.revisions, nil
+}
+
+func (m *mockRevisionRepository) ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*models.StateMachineDiagram, *models.Revision, error) {
+	if number < 1 || number > len(m.revisions) {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "revision not found", nil)
+	}
+	diag := &models.StateMachineDiagram{Name: name, Version: version, Content: m.content[number-1], Location: models.LocationFileInProgress, DiagramType: diagramType}
+	return diag, &m.revisions[number-1], nil
+}
+
+// newRevisionService returns a service over a repository with revision history
+// where only the in-progress diagram exists
+func newRevisionService() (models.DiagramService, *mockRevisionRepository) {
+	repo := &mockRevisionRepository{}
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		return location == models.LocationFileInProgress && repo.current != "", nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		if repo.current == "" {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "not found", nil)
+		}
+		return &models.StateMachineDiagram{Name: name, Version: version, Content: repo.current, Location: location, DiagramType: diagramType}, nil
+	}
+	repo.writeStateMachineFunc = func(diag *models.StateMachineDiagram) error {
+		repo.current = diag.Content
+		return nil
+	}
+	return NewService(repo, &mockValidator{}, nil), repo
+}
+
+func TestService_Revisions(t *testing.T) {
+	svc, repo := newRevisionService()
+
+	v1 := "@startuml\n[*] --> Idle\n@enduml"
+	v2 := "@startuml\n[*] --> Idle\nIdle --> Active : start\n@enduml"
+	if _, err := svc.CreateFileWithMetadata(smmodels.DiagramTypePUML, "door", "1.0.0", v1, models.LocationFileInProgress, models.Metadata{Author: "jane"}); err != nil {
+		t.Fatalf("CreateFileWithMetadata() error = %v", err)
+	}
+
+	diag := &models.StateMachineDiagram{Name: "door", Version: "1.0.0", Content: v2, Location: models.LocationFileInProgress, DiagramType: smmodels.DiagramTypePUML}
+	if err := svc.UpdateInProgressFileWithOptions(diag, models.UpdateOptions{Author: "joe", Message: "Add start"}); err != nil {
+		t.Fatalf("UpdateInProgressFileWithOptions() error = %v", err)
+	}
+
+	revisions, err := svc.ListRevisions(smmodels.DiagramTypePUML, "door", "1.0.0")
+	if err != nil {
+		t.Fatalf("ListRevisions() error = %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("ListRevisions() = %+v, want 2 revisions", revisions)
+	}
+	if revisions[0].Author != "jane" || revisions[0].Message != "Created" {
+		t.Errorf("first revision = %+v, want jane's creation", revisions[0])
+	}
+	if revisions[1].Author != "joe" || revisions[1].Message != "Add start" {
+		t.Errorf("second revision = %+v, want joe's update", revisions[1])
+	}
+
+	diff, err := svc.DiffRevisions(smmodels.DiagramTypePUML, "door", "1.0.0", 1, 2)
+	if err != nil {
+		t.Fatalf("DiffRevisions() error = %v", err)
+	}
+	if inserted, deleted := diff.Stats(); inserted != 1 || deleted != 0 {
+		t.Errorf("DiffRevisions() stats = +%d -%d, want +1 -0", inserted, deleted)
+	}
+	if !strings.Contains(diff.Unified(3), "+Idle --> Active : start") {
+		t.Errorf("DiffRevisions() unified = %q", diff.Unified(3))
+	}
+
+	reverted, err := svc.RevertToRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 1, models.UpdateOptions{Author: "joe"})
+	if err != nil {
+		t.Fatalf("RevertToRevision() error = %v", err)
+	}
+	if reverted.Content != v1 || repo.current != v1 {
+		t.Errorf("RevertToRevision() content = %q, want revision 1", repo.current)
+	}
+	if len(repo.revisions) != 3 || repo.revisions[2].Message != "Reverted to revision 1" || repo.content[2] != v1 {
+		t.Errorf("revert revision = %+v, want a new revision with revision 1's content", repo.revisions)
+	}
+
+	if _, _, err := svc.ReadRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 9); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("ReadRevision() of a missing revision error = %v, want file not found", err)
+	}
+}
+
+func TestService_Revisions_Errors(t *testing.T) {
+	svc, _ := newRevisionService()
+
+	if _, err := svc.ListRevisions(smmodels.DiagramTypePUML, "", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("ListRevisions() with empty name error = %v, want validation error", err)
+	}
+
+	// Reverting needs both the revision and the in-progress diagram
+	if _, err := svc.RevertToRevision(smmodels.DiagramTypePUML, "door", "1.0.0", 1, models.UpdateOptions{}); models.GetErrorType(err) != models.ErrorTypeFileNotFound {
+		t.Errorf("RevertToRevision() without history error = %v, want file not found", err)
+	}
+
+	unsupported := NewService(&mockRepository{}, &mockValidator{}, nil)
+	if _, err := unsupported.ListRevisions(smmodels.DiagramTypePUML, "door", "1.0.0"); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("ListRevisions() error = %v, want configuration error", err)
+	}
+	if _, err := unsupported.DiffRevisions(smmodels.DiagramTypePUML, "door", "1.0.0", 1, 2); models.GetErrorType(err) != models.ErrorTypeConfiguration {
+		t.Errorf("DiffRevisions() error = %v, want configuration error", err)
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// mapRepository returns a mock repository backed by files and counts ListDiagrams calls
+func mapRepository(files map[models.GraphNode]models.StateMachineDiagram, lists *int) *mockRepository {
+	key := func(name, version string, location models.Location) models.GraphNode {
+		return models.GraphNode{Name: name, Version: version, Location: location}
+	}
+	return &mockRepository{
+		readStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+			diag, ok := files[key(name, version, location)]
+			if !ok {
+				return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound, "not found", nil)
+			}
+			return &diag, nil
+		},
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			*lists++
+			var diagrams []models.StateMachineDiagram
+			for node, diag := range files {
+				if node.Location == location {
+					diagrams = append(diagrams, diag)
+				}
+			}
+			return diagrams, nil
+		},
+		existsFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+			_, ok := files[key(name, version, location)]
+			return ok, nil
+		},
+		writeStateMachineFunc: func(diag *models.StateMachineDiagram) error {
+			files[key(diag.Name, diag.Version, diag.Location)] = *diag
+			return nil
+		},
+		moveStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
+			diag := files[key(name, version, from)]
+			delete(files, key(name, version, from))
+			diag.Location = to
+			files[key(name, version, to)] = diag
+			return nil
+		},
+		deleteStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+			delete(files, key(name, version, location))
+			return nil
+		},
+	}
+}
+
+func TestService_SearchFiles_Incremental(t *testing.T) {
+	files := map[models.GraphNode]models.StateMachineDiagram{
+		{Name: "checkout", Version: "1.0.0", Location: models.LocationFileProducts}: {
+			Name: "checkout", Version: "1.0.0", Location: models.LocationFileProducts, DiagramType: smmodels.DiagramTypePUML,
+			Content: "@startuml\n[*] --> Pending\nPending --> Cancelled : PaymentTimeout\n@enduml",
+		},
+	}
+	lists := 0
+	svc := NewService(mapRepository(files, &lists), &mockValidator{}, nil)
+	query := models.SearchQuery{Term: "PaymentTimeout", Kinds: []models.SearchKind{models.SearchKindEvent}, Exact: true}
+
+	// search returns the files of the hits
+	search := func() []models.GraphNode {
+		t.Helper()
+		hits, err := svc.SearchFiles(smmodels.DiagramTypePUML, query)
+		if err != nil {
+			t.Fatalf("SearchFiles() error = %v", err)
+		}
+		var nodes []models.GraphNode
+		for _, hit := range hits {
+			nodes = append(nodes, hit.File)
+		}
+		return nodes
+	}
+
+	if got := search(); len(got) != 1 || got[0].Name != "checkout" {
+		t.Fatalf("SearchFiles() = %v, want checkout", got)
+	}
+
+	_, err := svc.CreateFile(smmodels.DiagramTypePUML, "refund", "1.0.0", "@startuml\n[*] --> Open\nOpen --> Closed : PaymentTimeout\n@enduml", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("CreateFile() error = %v", err)
+	}
+	if got := search(); len(got) != 2 {
+		t.Fatalf("SearchFiles() after create = %v, want 2 hits", got)
+	}
+
+	if err := svc.PromoteToProductsFile(smmodels.DiagramTypePUML, "refund", "1.0.0"); err != nil {
+		t.Fatalf("PromoteToProductsFile() error = %v", err)
+	}
+	got := search()
+	if len(got) != 2 || got[1].Name != "refund" || got[1].Location != models.LocationFileProducts {
+		t.Fatalf("SearchFiles() after promote = %v, want refund in products", got)
+	}
+	if lists != 2 {
+		t.Errorf("ListDiagrams called %d times, want 2 for the initial build only", lists)
+	}
+
+	if err := svc.DeleteFile(smmodels.DiagramTypePUML, "checkout", "1.0.0", models.LocationFileProducts); err != nil {
+		t.Fatalf("DeleteFile() error = %v", err)
+	}
+	if got := search(); len(got) != 1 || got[0].Name != "refund" {
+		t.Fatalf("SearchFiles() after delete = %v, want only refund", got)
+	}
+}
+
+func TestService_SearchFiles_UpdateInProgress(t *testing.T) {
+	files := map[models.GraphNode]models.StateMachineDiagram{}
+	lists := 0
+	svc := NewService(mapRepository(files, &lists), &mockValidator{}, nil)
+
+	diag, err := svc.CreateFile(smmodels.DiagramTypePUML, "door", "1.0.0", "@startuml\n[*] --> Closed\n@enduml", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("CreateFile() error = %v", err)
+	}
+	if _, err := svc.SearchFiles(smmodels.DiagramTypePUML, models.SearchQuery{Term: "Closed"}); err != nil {
+		t.Fatalf("SearchFiles() error = %v", err)
+	}
+
+	diag.Content = "@startuml\n[*] --> Locked\n@enduml"
+	if err := svc.UpdateInProgressFile(diag); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+
+	state := []models.SearchKind{models.SearchKindState}
+	if hits, _ := svc.SearchFiles(smmodels.DiagramTypePUML, models.SearchQuery{Term: "Closed", Kinds: state}); len(hits) != 0 {
+		t.Errorf("SearchFiles(Closed) = %+v, want no hits after update", hits)
+	}
+	hits, _ := svc.SearchFiles(smmodels.DiagramTypePUML, models.SearchQuery{Term: "Locked", Kinds: state})
+	if len(hits) != 1 || hits[0].Line != 2 || hits[0].Column != 9 {
+		t.Errorf("SearchFiles(Locked) = %+v, want one hit at 2:9", hits)
+	}
+}
@@ -2,7 +2,9 @@ package service
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,6 +23,9 @@ type service struct {
 	logger    *logging.Logger
 	mu        sync.RWMutex
 	cachemu   sync.RWMutex
+
+	searchmu sync.Mutex
+	indexes  map[smmodels.DiagramType]*models.SearchIndex // built on first search, then kept current
 }
 
 func newService(repo models.Repository, validator models.Validator, config *models.Config) *service {
@@ -52,6 +57,7 @@ func newService(repo models.Repository, validator models.Validator, config *mode
 		validator: validator,
 		config:    config,
 		logger:    logger,
+		indexes:   make(map[smmodels.DiagramType]*models.SearchIndex),
 	}
 
 	svc.logger.Info("DiagramService initialized successfully")
@@ -133,6 +139,12 @@ func NewServiceWithEnvOverrides(repo models.Repository, validator models.Validat
 
 // CreateFile creates a new state-machine diagram with the specified parameters
 func (s *service) CreateFile(diagramType smmodels.DiagramType, name, version string, content string, location models.Location) (*models.StateMachineDiagram, error) {
+	return s.CreateFileWithMetadata(diagramType, name, version, content, location, models.Metadata{})
+}
+
+// CreateFileWithMetadata creates a new state-machine diagram and stores the given
+// author, tags and description with it. Timestamps are set by the service.
+func (s *service) CreateFileWithMetadata(diagramType smmodels.DiagramType, name, version string, content string, location models.Location, metadata models.Metadata) (*models.StateMachineDiagram, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -181,6 +193,13 @@ func (s *service) CreateFile(diagramType smmodels.DiagramType, name, version str
 
 	opLogger.Debug("Input validation passed")
 
+	unlock, err := s.lockDiagram(diagramType, name, version, "CreateFile")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to lock state-machine diagram")
+		return nil, err
+	}
+	defer unlock()
+
 	// Check if state-machine diagram already exists
 	opLogger.Debug("Checking if state-machine diagram already exists")
 	exists, err := s.repo.Exists(diagramType, name, version, location)
@@ -251,8 +270,11 @@ func (s *service) CreateFile(diagramType smmodels.DiagramType, name, version str
 		Location:    location,
 		DiagramType: diagramType,
 		Metadata: models.Metadata{
-			CreatedAt:  time.Now(),
-			ModifiedAt: time.Now(),
+			CreatedAt:   time.Now(),
+			ModifiedAt:  time.Now(),
+			Author:      metadata.Author,
+			Tags:        metadata.Tags,
+			Description: metadata.Description,
 		},
 	}
 
@@ -271,6 +293,9 @@ func (s *service) CreateFile(diagramType smmodels.DiagramType, name, version str
 		opLogger.WithError(wrappedErr).Error("Failed to write state-machine diagram to disk")
 		return nil, wrappedErr
 	}
+	diag.Token = models.ContentToken(diag.Content)
+	s.indexDiagram(diag)
+	s.recordRevision(diag, metadata.Author, "Created")
 
 	opLogger.Info("State-machine diagram created successfully")
 	return diag, nil
@@ -327,12 +352,29 @@ func (s *service) ReadFile(diagramType smmodels.DiagramType, name, version strin
 		return nil, wrappedErr
 	}
 
+	if location == models.LocationFileProducts {
+		if err := s.verifyProduct(diag, "ReadFile"); err != nil {
+			opLogger.WithError(err).Error("Products state-machine diagram failed verification")
+			return nil, err
+		}
+	}
+
+	diag.Token = models.ContentToken(diag.Content)
+
 	opLogger.WithField("contentLength", len(diag.Content)).Info("State-machine diagram read successfully")
 	return diag, nil
 }
 
 // UpdateInProgressFile modifies an existing state-machine diagram only in the in-progress directory structure
 func (s *service) UpdateInProgressFile(diag *models.StateMachineDiagram) error {
+	return s.UpdateInProgressFileWithOptions(diag, models.UpdateOptions{})
+}
+
+// UpdateInProgressFileWithOptions modifies an existing in-progress state-machine
+// diagram and records the new content as a revision with the given author and message.
+// When opts.ExpectedToken, or failing that diag.Token, is set the update fails with
+// ErrorTypeFileConflict if the stored content was changed since it was read.
+func (s *service) UpdateInProgressFileWithOptions(diag *models.StateMachineDiagram, opts models.UpdateOptions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -354,6 +396,12 @@ func (s *service) UpdateInProgressFile(diag *models.StateMachineDiagram) error {
 			fmt.Sprintf("location must be %s", models.LocationFileInProgress.String()), nil)
 	}
 
+	unlock, err := s.lockDiagram(diag.DiagramType, diag.Name, diag.Version, "UpdateInProgressFile")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Check if state-machine diagram exists
 	exists, err := s.repo.Exists(diag.DiagramType, diag.Name, diag.Version, diag.Location)
 	if err != nil {
@@ -371,6 +419,19 @@ func (s *service) UpdateInProgressFile(diag *models.StateMachineDiagram) error {
 			WithContext("location", diag.Location.String())
 	}
 
+	expectedToken := opts.ExpectedToken
+	if expectedToken == "" {
+		expectedToken = diag.Token
+	}
+	if err := s.checkToken(diag.DiagramType, diag.Name, diag.Version, diag.Location, expectedToken, "UpdateInProgressFile"); err != nil {
+		return err
+	}
+
+	// Snapshot the current content before it is overwritten
+	if err := s.backupDiagram(diag.DiagramType, diag.Name, diag.Version, diag.Location, models.BackupReasonUpdate); err != nil {
+		return err
+	}
+
 	// Update the modified timestamp
 	diag.Metadata.ModifiedAt = time.Now()
 
@@ -382,50 +443,219 @@ func (s *service) UpdateInProgressFile(diag *models.StateMachineDiagram) error {
 			WithContext("version", diag.Version).
 			WithContext("location", diag.Location.String())
 	}
+	diag.Token = models.ContentToken(diag.Content)
+	s.indexDiagram(diag)
+	s.recordRevision(diag, opts.Author, opts.Message)
 
 	return nil
 }
 
-// DeleteFile removes a state-machine diagram by name, version, and location
+// DeleteFile deletes a state-machine diagram. Products files that other
+// diagrams reference are not deleted; use DeleteFileWithOptions to force it.
 func (s *service) DeleteFile(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+	_, err := s.DeleteFileWithOptions(diagramType, name, version, location, models.DeleteOptions{})
+	return err
+}
+
+// DeleteFileWithOptions deletes a state-machine diagram. A products file with
+// dependents is only deleted when opts.Force is set, and the dependents left
+// broken are logged and returned. A set opts.ExpectedToken must match the
+// stored content.
+func (s *service) DeleteFileWithOptions(diagramType smmodels.DiagramType, name, version string, location models.Location, opts models.DeleteOptions) (*models.DeleteResult, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Validate input parameters
 	if name == "" {
-		return models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
 	}
 	if version == "" {
-		return models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
 	}
 
 	// Check if state-machine diagram exists
 	exists, err := s.repo.Exists(diagramType, name, version, location)
 	if err != nil {
-		return models.NewStateMachineError(models.ErrorTypeFileSystem,
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
 			"failed to check if state-machine diagram exists", err).
 			WithContext("name", name).
 			WithContext("version", version).
 			WithContext("location", location.String())
 	}
 	if !exists {
-		return models.NewStateMachineError(models.ErrorTypeFileNotFound,
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound,
 			"state-machine diagram does not exist", nil).
 			WithContext("name", name).
 			WithContext("version", version).
 			WithContext("location", location.String())
 	}
 
+	// Locks are only taken for a diagram that exists, so invalid requests leave
+	// no lock files behind. Deleting a product is checked against every diagram
+	// that could reference it, so it locks the whole root rather than the
+	// diagram alone.
+	var unlock func()
+	if location == models.LocationFileProducts {
+		unlock, err = s.lockRoot("DeleteFile")
+	} else {
+		unlock, err = s.lockDiagram(diagramType, name, version, "DeleteFile")
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	if err := s.checkToken(diagramType, name, version, location, opts.ExpectedToken, "DeleteFile"); err != nil {
+		return nil, err
+	}
+
+	broken, err := s.checkDependents(diagramType, name, version, location, opts.Force, "DeleteFile")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.backupDiagram(diagramType, name, version, location, models.BackupReasonDelete); err != nil {
+		return nil, err
+	}
+
 	// Delete the state-machine diagram from repository
 	if err := s.repo.DeleteDiagram(diagramType, name, version, location); err != nil {
-		return models.NewStateMachineError(models.ErrorTypeFileSystem,
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
 			"failed to delete state-machine diagram", err).
 			WithContext("name", name).
 			WithContext("version", version).
 			WithContext("location", location.String())
 	}
+	s.unindexDiagram(diagramType, name, version, location)
+	if location == models.LocationFileProducts {
+		s.unsealProduct(diagramType, name, version)
+	}
 
-	return nil
+	return &models.DeleteResult{
+		Deleted: models.GraphNode{Name: name, Version: version, Location: location},
+		Broken:  broken,
+	}, nil
+}
+
+// checkDependents guards operations that remove a diagram from its location.
+// It fails with ErrorTypeDependencyConflict when other diagrams reference the
+// diagram, unless force is set, in which case it logs and returns the diagrams
+// that will be left with broken references.
+func (s *service) checkDependents(diagramType smmodels.DiagramType, name, version string, location models.Location, force bool, operation string) ([]models.GraphNode, error) {
+	// References always target products
+	if location != models.LocationFileProducts {
+		return nil, nil
+	}
+
+	graph, err := s.buildDependencyGraph(diagramType)
+	if err != nil {
+		return nil, err
+	}
+
+	node := models.GraphNode{Name: name, Version: version, Location: location}
+	broken := graph.BrokenBy(node)
+	if len(broken) == 0 {
+		return nil, nil
+	}
+
+	dependents := make([]string, len(broken))
+	for i, dependent := range broken {
+		dependents[i] = dependent.String()
+	}
+
+	if !force {
+		return nil, models.NewStateMachineError(models.ErrorTypeDependencyConflict,
+			"state-machine diagram is referenced by other diagrams", nil).
+			WithOperation(operation).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("dependents", dependents)
+	}
+
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation": operation,
+		"name":      name,
+		"version":   version,
+	})
+	opLogger.Warnf("Forced removal breaks references in %d diagram(s): %s", len(broken), strings.Join(dependents, ", "))
+
+	return broken, nil
+}
+
+// lockDiagram takes the cross-process lock of a diagram when the repository
+// provides one. The caller must hold s.mu and call the returned function once done.
+func (s *service) lockDiagram(diagramType smmodels.DiagramType, name, version, operation string) (func(), error) {
+	lockRepo, ok := s.repo.(models.LockRepository)
+	if !ok {
+		return func() {}, nil
+	}
+
+	unlock, err := lockRepo.LockDiagram(diagramType, name, version)
+	if err != nil {
+		return nil, models.WrapError(err, models.GetErrorType(err), "failed to lock state-machine diagram").
+			WithOperation(operation).
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	return unlock, nil
+}
+
+// lockRoot takes the cross-process lock of the whole repository when it provides
+// one. The caller must hold s.mu and call the returned function once done.
+func (s *service) lockRoot(operation string) (func(), error) {
+	lockRepo, ok := s.repo.(models.LockRepository)
+	if !ok {
+		return func() {}, nil
+	}
+
+	unlock, err := lockRepo.LockRoot()
+	if err != nil {
+		return nil, models.WrapError(err, models.GetErrorType(err), "failed to lock repository").
+			WithOperation(operation).
+			WithComponent("service")
+	}
+	return unlock, nil
+}
+
+// checkToken fails with ErrorTypeFileConflict when expected is set and the
+// stored content of a diagram no longer has that token
+func (s *service) checkToken(diagramType smmodels.DiagramType, name, version string, location models.Location, expected, operation string) error {
+	if expected == "" {
+		return nil
+	}
+
+	current, err := s.repo.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return models.WrapError(err, models.ErrorTypeFileSystem, "failed to read state-machine diagram to check its token").
+			WithOperation(operation).
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+	return tokenConflict(current, expected, operation)
+}
+
+// tokenConflict compares expected, when set, with the token of current
+func tokenConflict(current *models.StateMachineDiagram, expected, operation string) error {
+	if expected == "" {
+		return nil
+	}
+
+	token := models.ContentToken(current.Content)
+	if token == expected {
+		return nil
+	}
+	return models.NewStateMachineError(models.ErrorTypeFileConflict,
+		"state-machine diagram was changed since it was read", nil).
+		WithOperation(operation).
+		WithComponent("service").
+		WithContext("name", current.Name).
+		WithContext("version", current.Version).
+		WithContext("location", current.Location.String()).
+		WithContext("expectedToken", expected).
+		WithContext("currentToken", token)
 }
 
 // CloseCache closes the cache connection if one exists
@@ -455,6 +685,13 @@ func (s *service) CloseCache() error {
 
 // PromoteToProductsFile moves a state-machine diagram from in-progress to products
 func (s *service) PromoteToProductsFile(diagramType smmodels.DiagramType, name, version string) error {
+	return s.PromoteToProductsFileWithOptions(diagramType, name, version, models.PromoteOptions{})
+}
+
+// PromoteToProductsFileWithOptions moves a state-machine diagram from in-progress
+// to products and records when and by whom it was promoted. A set
+// opts.ExpectedToken must match the in-progress content.
+func (s *service) PromoteToProductsFileWithOptions(diagramType smmodels.DiagramType, name, version string, opts models.PromoteOptions) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -513,6 +750,17 @@ func (s *service) PromoteToProductsFile(diagramType smmodels.DiagramType, name,
 		return err
 	}
 
+	// Hold the diagram lock from the conflict checks through the move, so no
+	// other process can create or change either copy in between. It is taken
+	// once the diagram is known to exist, so invalid requests leave no lock
+	// files behind; the read in step 3 fails if it was removed meanwhile.
+	unlock, err := s.lockDiagram(diagramType, name, version, "PromoteToProductsFile")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to lock state-machine diagram")
+		return err
+	}
+	defer unlock()
+
 	// Step 2: Check if there's already a state-machine diagram with the same name in products
 	productExists, err := s.repo.Exists(diagramType, name, version, models.LocationFileProducts)
 	if err != nil {
@@ -554,6 +802,10 @@ func (s *service) PromoteToProductsFile(diagramType smmodels.DiagramType, name,
 		opLogger.WithError(wrapped).Error("failed to read state-machine diagram for validation")
 		return wrapped
 	}
+	if err := tokenConflict(diagram, opts.ExpectedToken, "PromoteToProductsFile"); err != nil {
+		opLogger.WithError(err).Error("state-machine diagram was changed since it was read")
+		return err
+	}
 
 	// Step 4: Validate the state-machine diagram with in-progress strictness (errors and warnings)
 	validationResult, err := s.validator.Validate(diagram, models.StrictnessInProgress)
@@ -586,147 +838,804 @@ func (s *service) PromoteToProductsFile(diagramType smmodels.DiagramType, name,
 		return err
 	}
 
-	// Step 6: Perform atomic move operation with rollback capability
+	// Step 6: Snapshot the in-progress file before it moves
+	if err := s.backupDiagram(diagramType, name, version, models.LocationFileInProgress, models.BackupReasonPromote); err != nil {
+		opLogger.WithError(err).Error("failed to back up state-machine diagram before promotion")
+		return err
+	}
+
+	// Step 7: Record the checksum before the move, so products never holds an unverifiable copy
+	if err := s.sealProduct(diagramType, name, version, diagram.Content, "PromoteToProductsFile"); err != nil {
+		opLogger.WithError(err).Error("failed to record checksum of state-machine diagram")
+		return err
+	}
+
+	// Step 8: Perform atomic move operation with rollback capability
 	err = s.performAtomicPromotion(diagramType, name, version)
 	if err != nil {
+		s.unsealProduct(diagramType, name, version)
 		return err
 	}
+	s.moveIndexedDiagram(diagramType, name, version, models.LocationFileInProgress, models.LocationFileProducts)
+
+	// Step 9: Record the promotion in the metadata; the promotion itself has already succeeded
+	if err := s.recordPromotion(diagramType, name, version, opts.Actor); err != nil {
+		opLogger.WithError(err).Warn("Failed to record promotion metadata")
+	}
 
 	return nil
 }
 
-// PromoteToCache moves a state-machine diagram from products to the cache
-func (s *service) PromoteToCache(diagramType smmodels.DiagramType, name, version string) error {
-	s.cachemu.Lock()
-	defer s.cachemu.Unlock()
-
-	// Create operation logger with context
-	opLogger := s.logger.WithFields(map[string]any{
-		"operation":   "PromoteToCache",
-		"diagramType": diagramType.String(),
-		"name":        name,
-		"version":     version,
-	})
-
-	// Validate input parameters
-	if name == "" {
-		err := models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil).
-			WithOperation("PromoteToCache").
-			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String())
-		opLogger.WithError(err).Error("Validation failed: empty name")
-		return err
+// recordPromotion stamps the metadata of a promoted diagram when the repository persists metadata
+func (s *service) recordPromotion(diagramType smmodels.DiagramType, name, version, actor string) error {
+	metaRepo, ok := s.repo.(models.MetadataRepository)
+	if !ok {
+		return nil
 	}
-	if version == "" {
-		err := models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil).
-			WithOperation("PromoteToCache").
-			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String())
-		opLogger.WithError(err).Error("Validation failed: empty version")
+
+	metadata, err := metaRepo.ReadMetadata(diagramType, name, version, models.LocationFileProducts)
+	if err != nil {
 		return err
 	}
+	metadata.PromotedAt = time.Now()
+	metadata.PromotedBy = actor
 
-	// validate cache before proceeding. Cache is optional, so there is not a need to return
-	// an error
-	if s.cache == nil {
-		opLogger.Warn("Cache is not initialized - no promotion will occur")
+	return metaRepo.WriteMetadata(diagramType, name, version, models.LocationFileProducts, *metadata)
+}
+
+// sealProduct records the checksum of a diagram entering products, signed when
+// a key provider is configured. Repositories that cannot store checksums are skipped.
+func (s *service) sealProduct(diagramType smmodels.DiagramType, name, version, content, operation string) error {
+	checksumRepo, ok := s.repo.(models.ChecksumRepository)
+	if !ok {
 		return nil
 	}
 
-	// build the file name
-	fileName, err := models.BuildFileName(diagramType, name, version)
-	if err != nil {
-		wrapped := models.NewStateMachineError(models.ErrorTypeValidation,
-			"failed to build state-machine diagram file name", err).
-			WithOperation("PromoteToCache").
-			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
-			WithContext("name", name).
-			WithContext("version", version)
-		opLogger.WithError(wrapped).Error("failed to build state-machine diagram file name")
-		return wrapped
+	checksum := models.ProductChecksum{Digest: models.ContentDigest(content), CreatedAt: time.Now()}
+	if s.config.KeyProvider != nil {
+		keyID, key, err := s.config.KeyProvider.SigningKey()
+		if err != nil {
+			return models.WrapError(err, models.ErrorTypeConfiguration, "failed to get signing key").
+				WithOperation(operation).
+				WithComponent("service")
+		}
+		checksum.KeyID = keyID
+		checksum.Signature = ed25519.Sign(key, checksum.SignedMessage(diagramType, name, version))
 	}
+	return s.writeChecksum(checksumRepo, diagramType, name, version, checksum, operation)
+}
 
-	opLogger.Info("fileName = " + fileName)
-
-	// check if state-machine diagram exists in products
-	exists, err := s.repo.Exists(diagramType, name, version, models.LocationFileProducts)
-	if err != nil {
-		wrapped := models.NewStateMachineError(models.ErrorTypeFileSystem,
-			"failed to check if state-machine diagram exists in products", err).
-			WithOperation("PromoteToCache").
+// writeChecksum records checksum as the checksum of a products diagram
+func (s *service) writeChecksum(checksumRepo models.ChecksumRepository, diagramType smmodels.DiagramType, name, version string, checksum models.ProductChecksum, operation string) error {
+	if err := checksumRepo.WriteChecksum(diagramType, name, version, checksum); err != nil {
+		return models.WrapError(err, models.GetErrorType(err), "failed to record checksum").
+			WithOperation(operation).
 			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
 			WithContext("name", name).
 			WithContext("version", version)
-		opLogger.WithError(wrapped).Error("failed to check if state-machine diagram exists in products")
-		return wrapped
 	}
-	if !exists {
-		wrapped := models.NewStateMachineError(models.ErrorTypeFileNotFound,
-			"state-machine diagram does not exist in products", nil).
-			WithOperation("PromoteToCache").
-			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
-			WithContext("name", name).
-			WithContext("version", version)
-		opLogger.WithError(wrapped).Error("state-machine diagram does not exist in products")
-		return wrapped
+	return nil
+}
+
+// unsealProduct removes the checksum of a diagram that left products or never
+// reached it. A leftover checksum is replaced by the next promotion, so a
+// failure is logged rather than returned.
+func (s *service) unsealProduct(diagramType smmodels.DiagramType, name, version string) {
+	checksumRepo, ok := s.repo.(models.ChecksumRepository)
+	if !ok {
+		return
 	}
+	if err := checksumRepo.DeleteChecksum(diagramType, name, version); err != nil {
+		s.logger.WithError(err).Warn("Failed to remove checksum of state-machine diagram")
+	}
+}
 
-	// check if state-machine diagram exists already in cache. There could be
-	// another error returned besides "not found".
-	ctx := context.Background()
+// verifyProduct checks a products diagram against the checksum recorded when
+// it was promoted and fails with ErrorTypeCorruption on a mismatch. Without a
+// key provider, diagrams promoted before checksums were recorded pass and
+// signatures are not checked; with one, every products diagram must carry a
+// valid signature.
+func (s *service) verifyProduct(diag *models.StateMachineDiagram, operation string) error {
+	checksumRepo, ok := s.repo.(models.ChecksumRepository)
+	if !ok {
+		return nil
+	}
 
-	_, err = s.cache.GetDiagram(ctx, diagramType, fileName)
-	if err == nil { // GetDiagram succeeded with content returned
-		err := models.NewStateMachineError(models.ErrorTypeValidation,
-			"diagram already exists in cache", nil).
-			WithOperation("PromoteToCache").
-			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
-			WithContext("name", name).
-			WithContext("version", version)
-		opLogger.WithError(err).Error("diagram already exists in cache")
-		return err
-	} else if !cache.IsNotFoundError(err) { // there was another cache error
-		wrapped := models.NewStateMachineError(models.ErrorTypeValidation,
-			"failed to get diagram from cache", err).
-			WithOperation("PromoteToCache").
+	checksum, err := checksumRepo.ReadChecksum(diag.DiagramType, diag.Name, diag.Version)
+	if models.GetErrorType(err) == models.ErrorTypeFileNotFound {
+		checksum = nil
+	} else if err != nil {
+		return models.WrapError(err, models.GetErrorType(err), "failed to read checksum").
+			WithOperation(operation).
 			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
-			WithContext("name", name).
-			WithContext("version", version)
-		opLogger.WithError(wrapped).Error("failed to get diagram from cache")
-		return wrapped
+			WithContext("name", diag.Name).
+			WithContext("version", diag.Version)
 	}
+	return s.checkChecksum(diag, checksum, operation)
+}
 
-	// read the state-machine diagram for validation
-	diag, err := s.repo.ReadDiagram(diagramType, name, version, models.LocationFileProducts)
-	if err != nil {
-		wrapped := models.NewStateMachineError(models.ErrorTypeFileSystem,
-			"failed to read state-machine diagram for validation", err).
-			WithOperation("PromoteToCache").
+// checkChecksum checks the content of a products diagram against checksum,
+// which is nil when none was recorded, with the rules of verifyProduct
+func (s *service) checkChecksum(diag *models.StateMachineDiagram, checksum *models.ProductChecksum, operation string) error {
+	corrupt := func(message string, cause error) error {
+		return models.NewStateMachineError(models.ErrorTypeCorruption, message, cause).
+			WithOperation(operation).
 			WithComponent("service").
-			WithSeverity(models.ErrorSeverityHigh).
-			WithContext("diagramType", diagramType.String()).
-			WithContext("name", name).
-			WithContext("version", version)
-		opLogger.WithError(wrapped).Error("failed to read state-machine diagram for validation")
-		return wrapped
+			WithSeverity(models.ErrorSeverityCritical).
+			WithContext("name", diag.Name).
+			WithContext("version", diag.Version)
 	}
+	keys := s.config.KeyProvider
 
-	opLogger.WithField("contentLength", len(diag.Content)).Info("State-machine diagram read successfully")
+	if checksum == nil {
+		if keys != nil {
+			return corrupt("products state-machine diagram has no recorded checksum", nil)
+		}
+		return nil
+	}
 
-	// validate the state-machine diagram with in-progress strictness (errors and warnings)
-	validationResult, err := s.validator.Validate(diag, models.StrictnessProducts)
+	if models.ContentDigest(diag.Content) != checksum.Digest {
+		return corrupt("products state-machine diagram does not match its recorded digest", nil)
+	}
+	if keys == nil {
+		return nil
+	}
+	if len(checksum.Signature) == 0 {
+		return corrupt("products state-machine diagram is not signed", nil)
+	}
+
+	key, err := keys.PublicKey(checksum.KeyID)
+	if err != nil {
+		return corrupt("products state-machine diagram is signed with an unknown key", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return models.NewStateMachineError(models.ErrorTypeConfiguration, "key provider returned an invalid public key", nil).
+			WithOperation(operation).
+			WithComponent("service").
+			WithContext("keyID", checksum.KeyID)
+	}
+	if !ed25519.Verify(key, checksum.SignedMessage(diag.DiagramType, diag.Name, diag.Version), checksum.Signature) {
+		return corrupt("products state-machine diagram signature is invalid", nil)
+	}
+	return nil
+}
+
+// recordRevision adds the content of an in-progress diagram to its revision
+// history when the repository keeps one. The content has already been saved,
+// so a failure is logged rather than returned.
+func (s *service) recordRevision(diag *models.StateMachineDiagram, author, message string) {
+	if diag.Location != models.LocationFileInProgress {
+		return
+	}
+	revisionRepo, ok := s.repo.(models.RevisionRepository)
+	if !ok {
+		return
+	}
+
+	if author == "" {
+		author = diag.Metadata.Author
+	}
+	revision, err := revisionRepo.AddRevision(diag, author, message)
+	if err != nil {
+		s.logger.WithFields(map[string]any{
+			"operation": "recordRevision",
+			"name":      diag.Name,
+			"version":   diag.Version,
+		}).WithError(err).Warn("Failed to record revision")
+		return
+	}
+	s.logger.WithFields(map[string]any{
+		"operation": "recordRevision",
+		"name":      diag.Name,
+		"version":   diag.Version,
+		"revision":  revision.Number,
+	}).Debug("Revision recorded")
+}
+
+// revisionRepository returns the repository as a RevisionRepository or an error for operation
+func (s *service) revisionRepository(operation string) (models.RevisionRepository, error) {
+	revisionRepo, ok := s.repo.(models.RevisionRepository)
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration,
+			"repository does not support revision history", nil).
+			WithOperation(operation).
+			WithComponent("service")
+	}
+	return revisionRepo, nil
+}
+
+// ListRevisions returns the revision history of an in-progress state-machine diagram, oldest first
+func (s *service) ListRevisions(diagramType smmodels.DiagramType, name, version string) ([]models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	revisionRepo, err := s.revisionRepository("ListRevisions")
+	if err != nil {
+		return nil, err
+	}
+
+	revisions, err := revisionRepo.ListRevisions(diagramType, name, version)
+	if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to list revisions").
+			WithOperation("ListRevisions").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	return revisions, nil
+}
+
+// ReadRevision returns the content of one revision of an in-progress state-machine diagram
+func (s *service) ReadRevision(diagramType smmodels.DiagramType, name, version string, number int) (*models.StateMachineDiagram, *models.Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readRevision(diagramType, name, version, number, "ReadRevision")
+}
+
+// readRevision reads a revision; the caller must hold s.mu
+func (s *service) readRevision(diagramType smmodels.DiagramType, name, version string, number int, operation string) (*models.StateMachineDiagram, *models.Revision, error) {
+	if name == "" {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	revisionRepo, err := s.revisionRepository(operation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diag, revision, err := revisionRepo.ReadRevision(diagramType, name, version, number)
+	if err != nil {
+		return nil, nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read revision").
+			WithOperation(operation).
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("revision", number)
+	}
+	return diag, revision, nil
+}
+
+// DiffRevisions returns the line diff from one revision of an in-progress
+// state-machine diagram to another
+func (s *service) DiffRevisions(diagramType smmodels.DiagramType, name, version string, from, to int) (*models.Diff, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	fromDiag, _, err := s.readRevision(diagramType, name, version, from, "DiffRevisions")
+	if err != nil {
+		return nil, err
+	}
+	toDiag, _, err := s.readRevision(diagramType, name, version, to, "DiffRevisions")
+	if err != nil {
+		return nil, err
+	}
+
+	return models.DiffContent(
+		fmt.Sprintf("%s-%s revision %d", name, version, from),
+		fmt.Sprintf("%s-%s revision %d", name, version, to),
+		fromDiag.Content, toDiag.Content), nil
+}
+
+// RevertToRevision replaces the content of an in-progress state-machine diagram
+// with that of an earlier revision. The revert is recorded as a new revision, so
+// no history is lost; opts.Message defaults to "Reverted to revision N".
+func (s *service) RevertToRevision(diagramType smmodels.DiagramType, name, version string, number int, opts models.UpdateOptions) (*models.StateMachineDiagram, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation":   "RevertToRevision",
+		"diagramType": diagramType.String(),
+		"name":        name,
+		"version":     version,
+		"revision":    number,
+	})
+
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	// The revision is read under the diagram lock like every other read of a change
+	unlock, err := s.lockDiagram(diagramType, name, version, "RevertToRevision")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to lock state-machine diagram")
+		return nil, err
+	}
+	defer unlock()
+
+	revisionDiag, _, err := s.readRevision(diagramType, name, version, number, "RevertToRevision")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to read revision")
+		return nil, err
+	}
+
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, models.LocationFileInProgress)
+	if err != nil {
+		wrapped := models.WrapError(err, models.ErrorTypeFileNotFound,
+			"failed to read in-progress state-machine diagram").
+			WithOperation("RevertToRevision").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("Failed to read in-progress state-machine diagram")
+		return nil, wrapped
+	}
+	if err := tokenConflict(diag, opts.ExpectedToken, "RevertToRevision"); err != nil {
+		opLogger.WithError(err).Error("State-machine diagram was changed since it was read")
+		return nil, err
+	}
+
+	if err := s.backupDiagram(diagramType, name, version, models.LocationFileInProgress, models.BackupReasonUpdate); err != nil {
+		return nil, err
+	}
+
+	diag.Content = revisionDiag.Content
+	diag.Metadata.ModifiedAt = time.Now()
+	if err := s.repo.WriteDiagram(diag); err != nil {
+		wrapped := models.WrapError(err, models.ErrorTypeFileSystem, "failed to write reverted state-machine diagram").
+			WithOperation("RevertToRevision").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("Failed to write reverted state-machine diagram")
+		return nil, wrapped
+	}
+	diag.Token = models.ContentToken(diag.Content)
+	s.indexDiagram(diag)
+
+	message := opts.Message
+	if message == "" {
+		message = fmt.Sprintf("Reverted to revision %d", number)
+	}
+	s.recordRevision(diag, opts.Author, message)
+
+	opLogger.Info("State-machine diagram reverted")
+	return diag, nil
+}
+
+// ListChanges returns the version control history of a state-machine diagram
+// across both locations, oldest first
+func (s *service) ListChanges(diagramType smmodels.DiagramType, name, version string) ([]models.Change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	changeRepo, ok := s.repo.(models.ChangeLogRepository)
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration,
+			"repository does not record a change history", nil).
+			WithOperation("ListChanges").
+			WithComponent("service")
+	}
+
+	changes, err := changeRepo.ListChanges(diagramType, name, version)
+	if err != nil {
+		return nil, models.WrapError(err, models.GetErrorType(err), "failed to list changes").
+			WithOperation("ListChanges").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	return changes, nil
+}
+
+// CheckIntegrity scans the repository for inconsistencies and repairs them as
+// options.Repair selects. The root lock is held throughout, so no write runs
+// while files are inspected or moved.
+func (s *service) CheckIntegrity(options models.CheckOptions) (*models.CheckReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkRepo, ok := s.repo.(models.IntegrityRepository)
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration,
+			"repository does not support integrity checks", nil).
+			WithOperation("CheckIntegrity").
+			WithComponent("service")
+	}
+
+	unlock, err := s.lockRoot("CheckIntegrity")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	report, err := checkRepo.CheckIntegrity(options)
+	if err != nil {
+		return nil, models.WrapError(err, models.GetErrorType(err), "failed to check repository integrity").
+			WithOperation("CheckIntegrity").
+			WithComponent("service")
+	}
+
+	repaired := 0
+	for _, finding := range report.Findings {
+		if finding.Action != models.RepairActionNone {
+			repaired++
+		}
+	}
+	// Repairs remove diagrams behind the search indexes' back, so they are rebuilt
+	if repaired > 0 {
+		s.searchmu.Lock()
+		s.indexes = make(map[smmodels.DiagramType]*models.SearchIndex)
+		s.searchmu.Unlock()
+	}
+
+	if len(report.Findings) > 0 {
+		s.logger.WithFields(map[string]any{
+			"operation": "CheckIntegrity",
+			"findings":  len(report.Findings),
+			"repaired":  repaired,
+		}).Warn("Repository integrity check found inconsistencies")
+	}
+	return report, nil
+}
+
+// backupDiagram snapshots a diagram before it is overwritten, deleted or moved
+// when backups are enabled, then prunes its backups to the configured retention.
+// Failing to take the snapshot fails the operation; failing to prune does not.
+func (s *service) backupDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location, reason string) error {
+	if !s.config.BackupEnabled {
+		return nil
+	}
+
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation":   "backupDiagram",
+		"diagramType": diagramType.String(),
+		"name":        name,
+		"version":     version,
+		"location":    location.String(),
+		"reason":      reason,
+	})
+
+	backupRepo, ok := s.repo.(models.BackupRepository)
+	if !ok {
+		opLogger.Warn("Backups are enabled but the repository does not support them")
+		return nil
+	}
+
+	backup, err := backupRepo.CreateBackup(diagramType, name, version, location, reason)
+	if err != nil {
+		return models.WrapError(err, models.ErrorTypeFileSystem, "failed to back up state-machine diagram").
+			WithOperation("backupDiagram").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+	opLogger.WithField("backupID", backup.ID).Debug("State-machine diagram backed up")
+
+	if err := backupRepo.PruneBackups(diagramType, name, version, s.config.BackupRetention); err != nil {
+		opLogger.WithError(err).Warn("Failed to prune old backups")
+	}
+	return nil
+}
+
+// ListBackups returns the backups of a state-machine diagram, oldest first
+func (s *service) ListBackups(diagramType smmodels.DiagramType, name, version string) ([]models.Backup, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	backupRepo, ok := s.repo.(models.BackupRepository)
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration,
+			"repository does not support backups", nil).
+			WithOperation("ListBackups").
+			WithComponent("service")
+	}
+
+	backups, err := backupRepo.ListBackups(diagramType, name, version)
+	if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to list backups").
+			WithOperation("ListBackups").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	return backups, nil
+}
+
+// RestoreBackup writes the content and metadata of a backup back to the location
+// it was taken from. An in-progress file is overwritten, and is backed up first
+// when backups are enabled. A products file is only restored when it no longer
+// exists, since products are never modified in place.
+func (s *service) RestoreBackup(diagramType smmodels.DiagramType, name, version, backupID string) (*models.StateMachineDiagram, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation":   "RestoreBackup",
+		"diagramType": diagramType.String(),
+		"name":        name,
+		"version":     version,
+		"backupID":    backupID,
+	})
+
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	backupRepo, ok := s.repo.(models.BackupRepository)
+	if !ok {
+		return nil, models.NewStateMachineError(models.ErrorTypeConfiguration,
+			"repository does not support backups", nil).
+			WithOperation("RestoreBackup").
+			WithComponent("service")
+	}
+
+	unlock, err := s.lockDiagram(diagramType, name, version, "RestoreBackup")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to lock state-machine diagram")
+		return nil, err
+	}
+	defer unlock()
+
+	diag, backup, err := backupRepo.ReadBackup(diagramType, name, version, backupID)
+	if err != nil {
+		wrapped := models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read backup").
+			WithOperation("RestoreBackup").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("backupID", backupID)
+		opLogger.WithError(wrapped).Error("Failed to read backup")
+		return nil, wrapped
+	}
+
+	// A products file of the same name and version blocks either kind of restore
+	productExists, err := s.repo.Exists(diagramType, name, version, models.LocationFileProducts)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to check products directory for conflicts", err).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	if productExists {
+		err := models.NewStateMachineError(models.ErrorTypeDirectoryConflict,
+			"cannot restore: state-machine diagram exists in products", nil).
+			WithOperation("RestoreBackup").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", backup.Location.String()).
+			WithContext("backupID", backupID)
+		opLogger.WithError(err).Warn("Restore conflicts with existing product")
+		return nil, err
+	}
+
+	if backup.Location == models.LocationFileInProgress {
+		exists, err := s.repo.Exists(diagramType, name, version, backup.Location)
+		if err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+				"failed to check if state-machine diagram exists", err).
+				WithContext("name", name).
+				WithContext("version", version).
+				WithContext("location", backup.Location.String())
+		}
+		if exists {
+			if err := s.backupDiagram(diagramType, name, version, backup.Location, models.BackupReasonRestore); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// A products backup must still match the checksum it was taken with, and
+	// the restored diagram gets that checksum back rather than a fresh seal
+	if backup.Location == models.LocationFileProducts {
+		if checksumRepo, ok := s.repo.(models.ChecksumRepository); ok {
+			if err := s.checkChecksum(diag, backup.Checksum, "RestoreBackup"); err != nil {
+				err = models.WrapError(err, models.GetErrorType(err), "cannot restore: backup does not match its recorded checksum").
+					WithOperation("RestoreBackup").
+					WithComponent("service").
+					WithContext("backupID", backupID)
+				opLogger.WithError(err).Error("Refusing to restore unverified products backup")
+				return nil, err
+			}
+
+			var sealErr error
+			if backup.Checksum != nil {
+				sealErr = s.writeChecksum(checksumRepo, diagramType, name, version, *backup.Checksum, "RestoreBackup")
+			} else {
+				sealErr = s.sealProduct(diagramType, name, version, diag.Content, "RestoreBackup")
+			}
+			if sealErr != nil {
+				opLogger.WithError(sealErr).Error("Failed to record checksum of restored state-machine diagram")
+				return nil, sealErr
+			}
+		}
+	}
+
+	diag.Metadata.ModifiedAt = time.Now()
+	if err := s.repo.WriteDiagram(diag); err != nil {
+		if backup.Location == models.LocationFileProducts {
+			s.unsealProduct(diagramType, name, version)
+		}
+		wrapped := models.WrapError(err, models.ErrorTypeFileSystem, "failed to restore state-machine diagram").
+			WithOperation("RestoreBackup").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("backupID", backupID)
+		opLogger.WithError(wrapped).Error("Failed to write restored state-machine diagram")
+		return nil, wrapped
+	}
+	diag.Token = models.ContentToken(diag.Content)
+	s.indexDiagram(diag)
+	s.recordRevision(diag, "", "Restored backup "+backupID)
+
+	opLogger.WithField("location", backup.Location.String()).Info("State-machine diagram restored from backup")
+	return diag, nil
+}
+
+// PromoteToCache moves a state-machine diagram from products to the cache
+func (s *service) PromoteToCache(diagramType smmodels.DiagramType, name, version string) error {
+	s.cachemu.Lock()
+	defer s.cachemu.Unlock()
+
+	// Create operation logger with context
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation":   "PromoteToCache",
+		"diagramType": diagramType.String(),
+		"name":        name,
+		"version":     version,
+	})
+
+	// Validate input parameters
+	if name == "" {
+		err := models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String())
+		opLogger.WithError(err).Error("Validation failed: empty name")
+		return err
+	}
+	if version == "" {
+		err := models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String())
+		opLogger.WithError(err).Error("Validation failed: empty version")
+		return err
+	}
+
+	// validate cache before proceeding. Cache is optional, so there is not a need to return
+	// an error
+	if s.cache == nil {
+		opLogger.Warn("Cache is not initialized - no promotion will occur")
+		return nil
+	}
+
+	// build the file name
+	fileName, err := models.BuildFileName(diagramType, name, version)
+	if err != nil {
+		wrapped := models.NewStateMachineError(models.ErrorTypeValidation,
+			"failed to build state-machine diagram file name", err).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("failed to build state-machine diagram file name")
+		return wrapped
+	}
+
+	opLogger.Info("fileName = " + fileName)
+
+	// check if state-machine diagram exists in products
+	exists, err := s.repo.Exists(diagramType, name, version, models.LocationFileProducts)
+	if err != nil {
+		wrapped := models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to check if state-machine diagram exists in products", err).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("failed to check if state-machine diagram exists in products")
+		return wrapped
+	}
+	if !exists {
+		wrapped := models.NewStateMachineError(models.ErrorTypeFileNotFound,
+			"state-machine diagram does not exist in products", nil).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("state-machine diagram does not exist in products")
+		return wrapped
+	}
+
+	// check if state-machine diagram exists already in cache. There could be
+	// another error returned besides "not found".
+	ctx := context.Background()
+
+	_, err = s.cache.GetDiagram(ctx, diagramType, fileName)
+	if err == nil { // GetDiagram succeeded with content returned
+		err := models.NewStateMachineError(models.ErrorTypeValidation,
+			"diagram already exists in cache", nil).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(err).Error("diagram already exists in cache")
+		return err
+	} else if !cache.IsNotFoundError(err) { // there was another cache error
+		wrapped := models.NewStateMachineError(models.ErrorTypeValidation,
+			"failed to get diagram from cache", err).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("failed to get diagram from cache")
+		return wrapped
+	}
+
+	// read the state-machine diagram for validation
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, models.LocationFileProducts)
+	if err != nil {
+		wrapped := models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to read state-machine diagram for validation", err).
+			WithOperation("PromoteToCache").
+			WithComponent("service").
+			WithSeverity(models.ErrorSeverityHigh).
+			WithContext("diagramType", diagramType.String()).
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("failed to read state-machine diagram for validation")
+		return wrapped
+	}
+
+	opLogger.WithField("contentLength", len(diag.Content)).Info("State-machine diagram read successfully")
+
+	// never cache a products diagram that was changed after its promotion
+	if err := s.verifyProduct(diag, "PromoteToCache"); err != nil {
+		opLogger.WithError(err).Error("Products state-machine diagram failed verification")
+		return err
+	}
+
+	// validate the state-machine diagram with in-progress strictness (errors and warnings)
+	validationResult, err := s.validator.Validate(diag, models.StrictnessProducts)
 	if err != nil {
 		wrapped := models.NewStateMachineError(models.ErrorTypeValidation,
 			"failed to validate state-machine diagram", err).
@@ -806,45 +1715,295 @@ func (s *service) performAtomicPromotion(diagramType smmodels.DiagramType, name,
 			WithContext("version", version)
 	}
 
-	// Check that it no longer exists in in-progress
-	inProgressExists, err := s.repo.Exists(diagramType, name, version, models.LocationFileInProgress)
+	// Check that it no longer exists in in-progress
+	inProgressExists, err := s.repo.Exists(diagramType, name, version, models.LocationFileInProgress)
+	if err != nil {
+		// Attempt rollback - move back to in-progress
+		s.attemptRollback(diagramType, name, version)
+		return models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to verify promotion: cannot check in-progress directory", err).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+	if inProgressExists {
+		// Attempt rollback - move back to in-progress (though it's already there)
+		// This indicates a partial failure where the move didn't complete properly
+		return models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"promotion verification failed: state-machine diagram still exists in in-progress after move", nil).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+
+	return nil
+}
+
+// attemptRollback attempts to rollback a failed promotion by moving the state-machine diagram back to in-progress
+func (s *service) attemptRollback(diagramType smmodels.DiagramType, name, version string) {
+	// This is a best-effort rollback - we don't return errors from here
+	// as we're already in an error state
+	rollbackErr := s.repo.MoveDiagram(diagramType, name, version, models.LocationFileProducts, models.LocationFileInProgress)
+	if rollbackErr != nil {
+		// Log the rollback failure but don't return it - the original error is more important
+		// In a real implementation, this would be logged to a proper logging system
+		// For now, we'll just ignore the rollback error
+	}
+}
+
+// ValidateFile validates a state-machine diagram with the specified strictness level
+func (s *service) ValidateFile(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Validate input parameters
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	// Read the state-machine diagram from repository
+	diagram, err := s.repo.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound,
+			"failed to read state-machine diagram for validation", err).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+
+	// Determine validation strictness based on location
+	strictness := models.StrictnessInProgress
+	if location == models.LocationFileProducts {
+		strictness = models.StrictnessProducts
+	}
+
+	// Validate the state-machine diagram using the validator
+	validationResult, err := s.validator.Validate(diagram, strictness)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation,
+			"validation failed", err).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("strictness", strictness.String())
+	}
+
+	return validationResult, nil
+}
+
+// ApplyFixes applies the chosen validation fixes to an in-progress state-machine diagram,
+// writes the result and returns a fresh in-progress validation of the updated content
+func (s *service) ApplyFixes(diagramType smmodels.DiagramType, name, version string, fixes []models.Fix) (*models.ValidationResult, error) {
+	return s.ApplyFixesWithOptions(diagramType, name, version, fixes, models.UpdateOptions{})
+}
+
+// ApplyFixesWithOptions applies fixes like ApplyFixes and records the revision with
+// the given author and message. When opts.ExpectedToken is set the fixes are only
+// applied if the content still has that token, so offsets computed from an earlier
+// read never land in a file that has changed since.
+func (s *service) ApplyFixesWithOptions(diagramType smmodels.DiagramType, name, version string, fixes []models.Fix, opts models.UpdateOptions) (*models.ValidationResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Create operation logger with context
+	opLogger := s.logger.WithFields(map[string]any{
+		"operation":   "ApplyFixes",
+		"diagramType": diagramType.String(),
+		"name":        name,
+		"version":     version,
+		"fixes":       len(fixes),
+	})
+
+	// Validate input parameters
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	unlock, err := s.lockDiagram(diagramType, name, version, "ApplyFixes")
+	if err != nil {
+		opLogger.WithError(err).Error("Failed to lock state-machine diagram")
+		return nil, err
+	}
+	defer unlock()
+
+	// Read the in-progress state-machine diagram
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, models.LocationFileInProgress)
+	if err != nil {
+		wrapped := models.WrapError(err, models.ErrorTypeFileNotFound,
+			"failed to read state-machine diagram for fixing").
+			WithOperation("ApplyFixes").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("Failed to read state-machine diagram")
+		return nil, wrapped
+	}
+	if err := tokenConflict(diag, opts.ExpectedToken, "ApplyFixes"); err != nil {
+		opLogger.WithError(err).Error("State-machine diagram was changed since it was read")
+		return nil, err
+	}
+
+	// Apply the fixes to the content
+	content, err := models.ApplyFixes(diag.Content, fixes)
+	if err != nil {
+		wrapped := models.WrapError(err, models.ErrorTypeValidation, "failed to apply fixes").
+			WithOperation("ApplyFixes").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version)
+		opLogger.WithError(wrapped).Error("Failed to apply fixes")
+		return nil, wrapped
+	}
+
+	// Write the fixed content back if anything changed
+	if content != diag.Content {
+		if err := s.backupDiagram(diagramType, name, version, models.LocationFileInProgress, models.BackupReasonUpdate); err != nil {
+			return nil, err
+		}
+		diag.Content = content
+		diag.Metadata.ModifiedAt = time.Now()
+		if err := s.repo.WriteDiagram(diag); err != nil {
+			wrapped := models.WrapError(err, models.ErrorTypeFileSystem, "failed to write fixed state-machine diagram").
+				WithOperation("ApplyFixes").
+				WithComponent("service").
+				WithSeverity(models.ErrorSeverityHigh).
+				WithContext("name", name).
+				WithContext("version", version)
+			opLogger.WithError(wrapped).Error("Failed to write fixed state-machine diagram")
+			return nil, wrapped
+		}
+		s.indexDiagram(diag)
+		message := opts.Message
+		if message == "" {
+			message = fmt.Sprintf("Applied %d fixes", len(fixes))
+		}
+		s.recordRevision(diag, opts.Author, message)
+	}
+
+	// Re-validate the updated content
+	validationResult, err := s.validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation,
+			"validation failed", err).
+			WithContext("name", name).
+			WithContext("version", version)
+	}
+
+	opLogger.WithFields(map[string]any{
+		"errors":   len(validationResult.Errors),
+		"warnings": len(validationResult.Warnings),
+	}).Info("Fixes applied successfully")
+
+	return validationResult, nil
+}
+
+// ListBlocks returns the @startuml blocks of a state-machine diagram file
+func (s *service) ListBlocks(diagramType smmodels.DiagramType, name, version string, location models.Location) ([]models.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Validate input parameters
+	if name == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	if blockRepo, ok := s.repo.(models.BlockRepository); ok {
+		blocks, err := blockRepo.ListBlocks(diagramType, name, version, location)
+		if err != nil {
+			return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to list blocks").
+				WithOperation("ListBlocks").
+				WithComponent("service").
+				WithContext("name", name).
+				WithContext("version", version).
+				WithContext("location", location.String())
+		}
+		return blocks, nil
+	}
+
+	// Fall back to splitting the content for repositories without block support
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read state-machine diagram").
+			WithOperation("ListBlocks").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+	return models.SplitBlocks(diag.Content), nil
+}
+
+// ReadBlock returns a single @startuml block of a state-machine diagram file by id
+func (s *service) ReadBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.Block, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.readBlock(diagramType, name, version, location, blockID)
+}
+
+// ValidateBlock validates a single @startuml block of a state-machine diagram file.
+// Findings use whole-file line numbers and carry the block id in their context.
+func (s *service) ValidateBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	block, err := s.readBlock(diagramType, name, version, location, blockID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine validation strictness based on location
+	strictness := models.StrictnessInProgress
+	if location == models.LocationFileProducts {
+		strictness = models.StrictnessProducts
+	}
+
+	blockDiag := &models.StateMachineDiagram{
+		Name:        name,
+		Version:     version,
+		Content:     block.Content,
+		Location:    location,
+		DiagramType: diagramType,
+	}
+
+	validationResult, err := s.validator.Validate(blockDiag, strictness)
 	if err != nil {
-		// Attempt rollback - move back to in-progress
-		s.attemptRollback(diagramType, name, version)
-		return models.NewStateMachineError(models.ErrorTypeFileSystem,
-			"failed to verify promotion: cannot check in-progress directory", err).
-			WithContext("name", name).
-			WithContext("version", version)
-	}
-	if inProgressExists {
-		// Attempt rollback - move back to in-progress (though it's already there)
-		// This indicates a partial failure where the move didn't complete properly
-		return models.NewStateMachineError(models.ErrorTypeFileSystem,
-			"promotion verification failed: state-machine diagram still exists in in-progress after move", nil).
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation,
+			"validation failed", err).
 			WithContext("name", name).
-			WithContext("version", version)
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("block", blockID)
 	}
 
-	return nil
-}
-
-// attemptRollback attempts to rollback a failed promotion by moving the state-machine diagram back to in-progress
-func (s *service) attemptRollback(diagramType smmodels.DiagramType, name, version string) {
-	// This is a best-effort rollback - we don't return errors from here
-	// as we're already in an error state
-	rollbackErr := s.repo.MoveDiagram(diagramType, name, version, models.LocationFileProducts, models.LocationFileInProgress)
-	if rollbackErr != nil {
-		// Log the rollback failure but don't return it - the original error is more important
-		// In a real implementation, this would be logged to a proper logging system
-		// For now, we'll just ignore the rollback error
+	// Map findings back to their position in the file
+	validationResult.ShiftLines(block.StartLine - 1)
+	for i := range validationResult.Errors {
+		if validationResult.Errors[i].Context == nil {
+			validationResult.Errors[i].Context = make(map[string]any)
+		}
+		validationResult.Errors[i].Context["block"] = block.ID
+	}
+	for i := range validationResult.Warnings {
+		if validationResult.Warnings[i].Context == nil {
+			validationResult.Warnings[i].Context = make(map[string]any)
+		}
+		validationResult.Warnings[i].Context["block"] = block.ID
 	}
-}
 
-// ValidateFile validates a state-machine diagram with the specified strictness level
-func (s *service) ValidateFile(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.ValidationResult, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	return validationResult, nil
+}
 
+// readBlock reads a block through the repository, falling back to splitting the
+// file content when the repository has no block support. Callers hold s.mu.
+func (s *service) readBlock(diagramType smmodels.DiagramType, name, version string, location models.Location, blockID string) (*models.Block, error) {
 	// Validate input parameters
 	if name == "" {
 		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
@@ -852,35 +2011,45 @@ func (s *service) ValidateFile(diagramType smmodels.DiagramType, name, version s
 	if version == "" {
 		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
 	}
+	if blockID == "" {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation, "block id cannot be empty", nil)
+	}
 
-	// Read the state-machine diagram from repository
-	diagram, err := s.repo.ReadDiagram(diagramType, name, version, location)
+	if blockRepo, ok := s.repo.(models.BlockRepository); ok {
+		block, err := blockRepo.ReadBlock(diagramType, name, version, location, blockID)
+		if err != nil {
+			return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read block").
+				WithOperation("ReadBlock").
+				WithComponent("service").
+				WithContext("name", name).
+				WithContext("version", version).
+				WithContext("location", location.String()).
+				WithContext("block", blockID)
+		}
+		return block, nil
+	}
+
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, location)
 	if err != nil {
-		return nil, models.NewStateMachineError(models.ErrorTypeFileNotFound,
-			"failed to read state-machine diagram for validation", err).
+		return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read state-machine diagram").
+			WithOperation("ReadBlock").
+			WithComponent("service").
 			WithContext("name", name).
 			WithContext("version", version).
 			WithContext("location", location.String())
 	}
 
-	// Determine validation strictness based on location
-	strictness := models.StrictnessInProgress
-	if location == models.LocationFileProducts {
-		strictness = models.StrictnessProducts
-	}
-
-	// Validate the state-machine diagram using the validator
-	validationResult, err := s.validator.Validate(diagram, strictness)
+	block, err := models.FindBlock(diag.Content, blockID)
 	if err != nil {
-		return nil, models.NewStateMachineError(models.ErrorTypeValidation,
-			"validation failed", err).
+		return nil, models.WrapError(err, models.ErrorTypeFileNotFound, "block not found in state-machine diagram").
+			WithOperation("ReadBlock").
+			WithComponent("service").
 			WithContext("name", name).
 			WithContext("version", version).
 			WithContext("location", location.String()).
-			WithContext("strictness", strictness.String())
+			WithContext("block", blockID)
 	}
-
-	return validationResult, nil
+	return block, nil
 }
 
 // ListAllFiles lists all state-machine diagrams in the specified location
@@ -899,6 +2068,230 @@ func (s *service) ListAllFiles(diagramType smmodels.DiagramType, location models
 	return diagrams, nil
 }
 
+// ListFileEntries lists the state-machine diagrams in a location without their
+// content. Repositories that cannot list entries directly fall back to
+// ListDiagrams, in which case nothing is reported as skipped.
+func (s *service) ListFileEntries(diagramType smmodels.DiagramType, location models.Location) (*models.DiagramListing, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if listingRepo, ok := s.repo.(models.ListingRepository); ok {
+		listing, err := listingRepo.ListEntries(diagramType, location)
+		if err != nil {
+			return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to list state-machine diagrams").
+				WithOperation("ListFileEntries").
+				WithComponent("service").
+				WithContext("location", location.String())
+		}
+		if len(listing.Skipped) > 0 {
+			s.logger.WithFields(map[string]any{
+				"operation": "ListFileEntries",
+				"location":  location.String(),
+				"skipped":   len(listing.Skipped),
+			}).Warn("Some files could not be listed as state-machine diagrams")
+		}
+		return listing, nil
+	}
+
+	diagrams, err := s.repo.ListDiagrams(diagramType, location)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to list state-machine diagrams", err).
+			WithContext("location", location.String())
+	}
+
+	listing := &models.DiagramListing{Entries: []models.DiagramEntry{}, Skipped: []models.SkippedFile{}}
+	for _, diag := range diagrams {
+		listing.Entries = append(listing.Entries, models.DiagramEntry{
+			Name:        diag.Name,
+			Version:     diag.Version,
+			Location:    diag.Location,
+			DiagramType: diag.DiagramType,
+			Size:        int64(len(diag.Content)),
+			CreatedAt:   diag.Metadata.CreatedAt,
+			ModifiedAt:  diag.Metadata.ModifiedAt,
+		})
+	}
+	return listing, nil
+}
+
+// QueryFiles searches the state-machine diagrams of a diagram type by name,
+// version and metadata and returns one sorted page of the matches
+func (s *service) QueryFiles(diagramType smmodels.DiagramType, query models.Query) (*models.QueryResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Filtering on listed entries avoids reading the content of every diagram
+	if listingRepo, ok := s.repo.(models.ListingRepository); ok {
+		metadataRepo, hasMetadata := s.repo.(models.MetadataRepository)
+		if hasMetadata || (query.Author == "" && len(query.Tags) == 0) {
+			return s.queryEntries(listingRepo, metadataRepo, diagramType, query)
+		}
+	}
+
+	var diagrams []models.StateMachineDiagram
+	for _, location := range query.SearchLocations() {
+		listed, err := s.repo.ListDiagrams(diagramType, location)
+		if err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+				"failed to list state-machine diagrams", err).
+				WithContext("location", location.String())
+		}
+		diagrams = append(diagrams, listed...)
+	}
+
+	return query.Apply(diagrams)
+}
+
+// queryEntries runs a query over listed entries and reads only the diagrams on
+// the returned page. Metadata is read only when the query filters on author or tags.
+func (s *service) queryEntries(listingRepo models.ListingRepository, metadataRepo models.MetadataRepository, diagramType smmodels.DiagramType, query models.Query) (*models.QueryResult, error) {
+	needsMetadata := query.Author != "" || len(query.Tags) > 0
+
+	var candidates []models.StateMachineDiagram
+	for _, location := range query.SearchLocations() {
+		listing, err := listingRepo.ListEntries(diagramType, location)
+		if err != nil {
+			return nil, models.WrapError(err, models.ErrorTypeFileSystem, "failed to list state-machine diagrams").
+				WithOperation("QueryFiles").
+				WithComponent("service").
+				WithContext("location", location.String())
+		}
+
+		for _, entry := range listing.Entries {
+			diag := models.StateMachineDiagram{
+				Name:        entry.Name,
+				Version:     entry.Version,
+				Location:    entry.Location,
+				DiagramType: entry.DiagramType,
+				Metadata:    models.Metadata{CreatedAt: entry.CreatedAt, ModifiedAt: entry.ModifiedAt},
+			}
+			if needsMetadata {
+				metadata, err := metadataRepo.ReadMetadata(diagramType, entry.Name, entry.Version, entry.Location)
+				if err != nil {
+					return nil, models.WrapError(err, models.GetErrorType(err), "failed to read metadata for query").
+						WithOperation("QueryFiles").
+						WithComponent("service").
+						WithContext("name", entry.Name).
+						WithContext("version", entry.Version)
+				}
+				diag.Metadata.Author, diag.Metadata.Tags = metadata.Author, metadata.Tags
+			}
+			candidates = append(candidates, diag)
+		}
+	}
+
+	result, err := query.Apply(candidates)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range result.Diagrams {
+		diag, err := s.repo.ReadDiagram(diagramType, entry.Name, entry.Version, entry.Location)
+		if err != nil {
+			return nil, models.WrapError(err, models.GetErrorType(err), "failed to read state-machine diagram for query").
+				WithOperation("QueryFiles").
+				WithComponent("service").
+				WithContext("name", entry.Name).
+				WithContext("version", entry.Version).
+				WithContext("location", entry.Location.String())
+		}
+		result.Diagrams[i] = *diag
+	}
+	return result, nil
+}
+
+// SearchFiles searches the content, state names, event names and note text of
+// the state-machine diagrams of a diagram type. The search index is built from
+// the repository on first use and updated as files are created, updated,
+// deleted and promoted through the service.
+func (s *service) SearchFiles(diagramType smmodels.DiagramType, query models.SearchQuery) ([]models.SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index, err := s.searchIndex(diagramType)
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(query)
+}
+
+// searchIndex returns the search index of a diagram type, building it on first use
+func (s *service) searchIndex(diagramType smmodels.DiagramType) (*models.SearchIndex, error) {
+	s.searchmu.Lock()
+	defer s.searchmu.Unlock()
+
+	if index, ok := s.indexes[diagramType]; ok {
+		return index, nil
+	}
+
+	index := models.NewSearchIndex()
+	for _, location := range []models.Location{models.LocationFileInProgress, models.LocationFileProducts} {
+		diagrams, err := s.repo.ListDiagrams(diagramType, location)
+		if err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+				"failed to list state-machine diagrams for indexing", err).
+				WithContext("location", location.String())
+		}
+		for _, diag := range diagrams {
+			index.Add(diag)
+		}
+	}
+
+	s.logger.WithFields(map[string]any{
+		"operation":   "SearchFiles",
+		"diagramType": diagramType.String(),
+		"diagrams":    index.Len(),
+	}).Debug("Search index built")
+
+	s.indexes[diagramType] = index
+	return index, nil
+}
+
+// indexedSearch returns the search index of a diagram type if it has been built
+func (s *service) indexedSearch(diagramType smmodels.DiagramType) *models.SearchIndex {
+	s.searchmu.Lock()
+	defer s.searchmu.Unlock()
+	return s.indexes[diagramType]
+}
+
+// indexDiagram adds or replaces a diagram in its search index
+func (s *service) indexDiagram(diag *models.StateMachineDiagram) {
+	if index := s.indexedSearch(diag.DiagramType); index != nil {
+		index.Add(*diag)
+	}
+}
+
+// unindexDiagram removes a diagram from its search index
+func (s *service) unindexDiagram(diagramType smmodels.DiagramType, name, version string, location models.Location) {
+	if index := s.indexedSearch(diagramType); index != nil {
+		index.Remove(name, version, location)
+	}
+}
+
+// moveIndexedDiagram re-indexes a diagram that moved between locations. If the
+// moved diagram cannot be read the index is dropped and rebuilt on the next search.
+func (s *service) moveIndexedDiagram(diagramType smmodels.DiagramType, name, version string, from, to models.Location) {
+	index := s.indexedSearch(diagramType)
+	if index == nil {
+		return
+	}
+
+	index.Remove(name, version, from)
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, to)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to re-index moved state-machine diagram")
+		s.searchmu.Lock()
+		delete(s.indexes, diagramType)
+		s.searchmu.Unlock()
+		return
+	}
+	index.Add(*diag)
+}
+
 // ResolveFileReferences resolves all references in a state-machine diagram
 func (s *service) ResolveFileReferences(diag *models.StateMachineDiagram) error {
 	s.mu.RLock()
@@ -955,6 +2348,13 @@ func (s *service) resolveReference(diag *models.StateMachineDiagram, ref *models
 			WithContext("reference_type", ref.Type.String())
 	}
 
+	// Version ranges resolve to the highest matching product version
+	if ref.IsRange() {
+		if err := s.resolveConstraint(diag.DiagramType, ref); err != nil {
+			return err
+		}
+	}
+
 	// For product references, check if the referenced state-machine diagram exists in products
 	exists, err := s.repo.Exists(diag.DiagramType, ref.Name, ref.Version, models.LocationFileProducts)
 	if err != nil {
@@ -976,6 +2376,170 @@ func (s *service) resolveReference(diag *models.StateMachineDiagram, ref *models
 	return nil
 }
 
+// resolveConstraint sets the version of a range reference to the highest
+// product version that satisfies its constraint
+func (s *service) resolveConstraint(diagramType smmodels.DiagramType, ref *models.Reference) error {
+	constraint, err := models.ParseConstraint(ref.Constraint)
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeValidation,
+			"invalid reference version constraint", err).
+			WithContext("reference_name", ref.Name).
+			WithContext("reference_constraint", ref.Constraint)
+	}
+
+	versions, err := models.ListVersions(s.repo, diagramType, models.LocationFileProducts, ref.Name)
+	if err != nil {
+		return models.NewStateMachineError(models.ErrorTypeFileSystem,
+			"failed to list product versions", err).
+			WithContext("reference_name", ref.Name)
+	}
+
+	version, ok := constraint.Latest(versions)
+	if !ok {
+		return models.NewStateMachineError(models.ErrorTypeReferenceResolution,
+			"no product version satisfies reference constraint", nil).
+			WithContext("reference_name", ref.Name).
+			WithContext("reference_constraint", ref.Constraint)
+	}
+
+	ref.Version = version
+	return nil
+}
+
+// BuildDependencyGraph builds the reference graph of every state-machine diagram
+// of a diagram type, in progress and in products
+func (s *service) BuildDependencyGraph(diagramType smmodels.DiagramType) (*models.DependencyGraph, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.buildDependencyGraph(diagramType)
+}
+
+// buildDependencyGraph builds the reference graph without taking the service lock
+func (s *service) buildDependencyGraph(diagramType smmodels.DiagramType) (*models.DependencyGraph, error) {
+	var diagrams []models.StateMachineDiagram
+	for _, location := range []models.Location{models.LocationFileInProgress, models.LocationFileProducts} {
+		listed, err := s.repo.ListDiagrams(diagramType, location)
+		if err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeFileSystem,
+				"failed to list state-machine diagrams", err).
+				WithContext("location", location.String())
+		}
+		diagrams = append(diagrams, listed...)
+	}
+
+	// Parse references from content where the repository did not provide them
+	for i := range diagrams {
+		if len(diagrams[i].References) > 0 {
+			continue
+		}
+		if _, err := s.validator.ValidateReferences(&diagrams[i]); err != nil {
+			return nil, models.NewStateMachineError(models.ErrorTypeValidation,
+				"failed to parse references from content", err).
+				WithContext("name", diagrams[i].Name).
+				WithContext("version", diagrams[i].Version)
+		}
+	}
+
+	return models.NewDependencyGraph(diagrams), nil
+}
+
+// ExpandFile reads a state-machine diagram and inlines its includes transitively.
+// The returned result lists includes that could not be expanded.
+func (s *service) ExpandFile(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Expansion, *models.ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, expansion, result, err := s.expandFile(diagramType, name, version, location)
+	return expansion, result, err
+}
+
+// ValidateExpandedFile validates a state-machine diagram with its includes inlined,
+// so that the complete machine is checked. Findings in included content are
+// reported on the !include line that brought it in.
+func (s *service) ValidateExpandedFile(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.ValidationResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	original, expansion, expansionResult, err := s.expandFile(diagramType, name, version, location)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine validation strictness based on location
+	strictness := models.StrictnessInProgress
+	if location == models.LocationFileProducts {
+		strictness = models.StrictnessProducts
+	}
+
+	validationResult, err := s.validator.Validate(expansion.Diagram, strictness)
+	if err != nil {
+		return nil, models.NewStateMachineError(models.ErrorTypeValidation,
+			"validation failed", err).
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String()).
+			WithContext("strictness", strictness.String())
+	}
+
+	// Report findings against the original file and add expansion problems
+	expansion.SourceMap.MapResult(validationResult, original.Content)
+	validationResult.Errors = append(validationResult.Errors, expansionResult.Errors...)
+	validationResult.Warnings = append(validationResult.Warnings, expansionResult.Warnings...)
+	validationResult.IsValid = len(validationResult.Errors) == 0
+
+	return validationResult, nil
+}
+
+// expandFile reads a diagram and expands it with the validator, returning the
+// original diagram alongside the expansion. Callers hold s.mu.
+func (s *service) expandFile(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, *models.Expansion, *models.ValidationResult, error) {
+	// Validate input parameters
+	if name == "" {
+		return nil, nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "name cannot be empty", nil)
+	}
+	if version == "" {
+		return nil, nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "version cannot be empty", nil)
+	}
+
+	expander, ok := s.validator.(models.IncludeExpander)
+	if !ok {
+		return nil, nil, nil, models.NewStateMachineError(models.ErrorTypeReferenceResolution,
+			"validator does not support include expansion", nil).
+			WithOperation("ExpandFile").
+			WithComponent("service")
+	}
+
+	diag, err := s.repo.ReadDiagram(diagramType, name, version, location)
+	if err != nil {
+		return nil, nil, nil, models.WrapError(err, models.ErrorTypeFileNotFound, "failed to read state-machine diagram").
+			WithOperation("ExpandFile").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+
+	expansion, result, err := expander.ExpandIncludes(diag)
+	if err != nil {
+		return nil, nil, nil, models.WrapError(err, models.ErrorTypeReferenceResolution, "failed to expand includes").
+			WithOperation("ExpandFile").
+			WithComponent("service").
+			WithContext("name", name).
+			WithContext("version", version).
+			WithContext("location", location.String())
+	}
+
+	s.logger.WithFields(map[string]any{
+		"operation": "ExpandFile",
+		"name":      name,
+		"version":   version,
+		"includes":  len(expansion.Includes),
+	}).Debug("Includes expanded")
+
+	return diag, expansion, result, nil
+}
+
 // buildProductReferencePath builds the path for a product reference
 func (s *service) buildProductReferencePath(diagramType smmodels.DiagramType, name, version string) string {
 	// Product references are in the format: products/{diagramType}/{name}-{version}/{name}-{version}.puml
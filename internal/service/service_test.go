@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
@@ -1911,3 +1912,259 @@ func TestServiceFactoryFunctions(t *testing.T) {
 		}
 	})
 }
+
+func TestService_ResolveFileReferences_VersionRange(t *testing.T) {
+	repo := &mockRepository{
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			return []models.StateMachineDiagram{
+				{Name: "auth-diag", Version: "1.2.0"},
+				{Name: "auth-diag", Version: "1.7.3"},
+				{Name: "auth-diag", Version: "2.0.0"},
+				{Name: "other-diag", Version: "1.9.0"},
+			}, nil
+		},
+		existsFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+			return name == "auth-diag" && location == models.LocationFileProducts, nil
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	diag := &models.StateMachineDiagram{
+		Name:        "test-diag",
+		Version:     "1.0.0",
+		DiagramType: smmodels.DiagramTypePUML,
+		References: []models.Reference{
+			{Name: "auth-diag", Constraint: "^1.2", Type: models.ReferenceTypeProduct},
+		},
+	}
+	if err := svc.ResolveFileReferences(diag); err != nil {
+		t.Fatalf("ResolveFileReferences() error = %v", err)
+	}
+	if got := diag.References[0].Version; got != "1.7.3" {
+		t.Errorf("Resolved version = %q, want 1.7.3", got)
+	}
+	if got := diag.References[0].Path; got != "products\\puml\\auth-diag-1.7.3\\auth-diag-1.7.3.puml" {
+		t.Errorf("Path = %q", got)
+	}
+
+	diag.References = []models.Reference{{Name: "auth-diag", Constraint: "~3.0", Type: models.ReferenceTypeProduct}}
+	err := svc.ResolveFileReferences(diag)
+	if models.GetErrorType(err) != models.ErrorTypeReferenceResolution {
+		t.Errorf("ResolveFileReferences() error = %v, want ErrorTypeReferenceResolution", err)
+	}
+}
+
+// mockListingRepository lists fixed entries without reading content
+type mockListingRepository struct {
+	mockRepository
+	entries []models.DiagramEntry
+}
+
+func (m *mockListingRepository) ListEntries(diagramType smmodels.DiagramType, location models.Location) (*models.DiagramListing, error) {
+	return &models.DiagramListing{Entries: m.entries}, nil
+}
+
+func TestService_ResolveFileReferences_VersionRange_ListsEntries(t *testing.T) {
+	repo := &mockListingRepository{entries: []models.DiagramEntry{
+		{Name: "auth-diag", Version: "1.2.0"},
+		{Name: "auth-diag", Version: "1.7.3"},
+		{Name: "other-diag", Version: "1.9.0"},
+	}}
+	repo.listDiagramsFunc = func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+		t.Error("ListDiagrams() called although the repository lists entries")
+		return nil, nil
+	}
+	repo.existsFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+		return true, nil
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	diag := &models.StateMachineDiagram{
+		Name:        "test-diag",
+		Version:     "1.0.0",
+		DiagramType: smmodels.DiagramTypePUML,
+		References:  []models.Reference{{Name: "auth-diag", Constraint: "^1.2", Type: models.ReferenceTypeProduct}},
+	}
+	if err := svc.ResolveFileReferences(diag); err != nil {
+		t.Fatalf("ResolveFileReferences() error = %v", err)
+	}
+	if got := diag.References[0].Version; got != "1.7.3" {
+		t.Errorf("Resolved version = %q, want 1.7.3", got)
+	}
+}
+
+func TestService_DeleteFile_Dependents(t *testing.T) {
+	deleted := 0
+	repo := &mockRepository{
+		existsFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) (bool, error) {
+			return true, nil
+		},
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			if location == models.LocationFileInProgress {
+				return []models.StateMachineDiagram{{
+					Name: "app", Version: "1.0.0", Location: location,
+					References: []models.Reference{{Name: "base", Version: "1.0.0", Type: models.ReferenceTypeProduct}},
+				}}, nil
+			}
+			return []models.StateMachineDiagram{{Name: "base", Version: "1.0.0", Location: location}}, nil
+		},
+		deleteStateMachineFunc: func(diagramType smmodels.DiagramType, name, version string, location models.Location) error {
+			deleted++
+			return nil
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	err := svc.DeleteFile(smmodels.DiagramTypePUML, "base", "1.0.0", models.LocationFileProducts)
+	if models.GetErrorType(err) != models.ErrorTypeDependencyConflict {
+		t.Fatalf("DeleteFile() error = %v, want ErrorTypeDependencyConflict", err)
+	}
+	dependents, _ := err.(*models.StateMachineError).Context["dependents"].([]string)
+	if len(dependents) != 1 || dependents[0] != "app-1.0.0 (in-progress)" {
+		t.Errorf("dependents context = %v", dependents)
+	}
+	if deleted != 0 {
+		t.Error("DeleteFile() should not delete a referenced product")
+	}
+
+	res, err := svc.DeleteFileWithOptions(smmodels.DiagramTypePUML, "base", "1.0.0", models.LocationFileProducts, models.DeleteOptions{Force: true})
+	if err != nil {
+		t.Fatalf("DeleteFileWithOptions() error = %v", err)
+	}
+	if deleted != 1 || len(res.Broken) != 1 || res.Broken[0].Name != "app" {
+		t.Errorf("DeleteFileWithOptions() = %+v, deleted %d times", res, deleted)
+	}
+
+	// In-progress files cannot be referenced
+	if err := svc.DeleteFile(smmodels.DiagramTypePUML, "app", "1.0.0", models.LocationFileInProgress); err != nil {
+		t.Errorf("DeleteFile() in-progress error = %v", err)
+	}
+}
+
+func TestService_CreateFileWithMetadata(t *testing.T) {
+	var written *models.StateMachineDiagram
+	repo := &mockRepository{
+		writeStateMachineFunc: func(diag *models.StateMachineDiagram) error {
+			written = diag
+			return nil
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	metadata := models.Metadata{Author: "jane", Tags: []string{"auth"}, Description: "Login flow"}
+	diag, err := svc.CreateFileWithMetadata(smmodels.DiagramTypePUML, "login", "1.0.0", "@startuml\n@enduml", models.LocationFileInProgress, metadata)
+	if err != nil {
+		t.Fatalf("CreateFileWithMetadata() error = %v", err)
+	}
+	if written != diag {
+		t.Fatal("CreateFileWithMetadata() should write the returned diagram")
+	}
+	if diag.Metadata.Author != "jane" || len(diag.Metadata.Tags) != 1 || diag.Metadata.Description != "Login flow" {
+		t.Errorf("Metadata = %+v", diag.Metadata)
+	}
+	if diag.Metadata.CreatedAt.IsZero() || diag.Metadata.ModifiedAt.IsZero() {
+		t.Error("CreateFileWithMetadata() should set timestamps")
+	}
+}
+
+func TestService_QueryFiles(t *testing.T) {
+	var listed []models.Location
+	repo := &mockRepository{
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			listed = append(listed, location)
+			return []models.StateMachineDiagram{
+				{Name: "auth", Version: "1.0.0", Location: location, Metadata: models.Metadata{Tags: []string{"auth"}}},
+				{Name: "billing", Version: "1.0.0", Location: location},
+			}, nil
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	result, err := svc.QueryFiles(smmodels.DiagramTypePUML, models.Query{Tags: []string{"auth"}})
+	if err != nil {
+		t.Fatalf("QueryFiles() error = %v", err)
+	}
+	if len(listed) != 2 || result.Total != 2 {
+		t.Errorf("QueryFiles() listed %v and matched %d, want both locations and 2 matches", listed, result.Total)
+	}
+
+	if _, err := svc.QueryFiles(smmodels.DiagramTypePUML, models.Query{NameGlob: "[bad"}); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("QueryFiles() error = %v, want validation error", err)
+	}
+}
+
+// mockQueryRepository lists entries and metadata and counts content reads
+type mockQueryRepository struct {
+	mockListingRepository
+	metadata map[string]models.Metadata
+	reads    []string
+}
+
+func (m *mockQueryRepository) ReadMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.Metadata, error) {
+	metadata := m.metadata[name]
+	return &metadata, nil
+}
+
+func (m *mockQueryRepository) WriteMetadata(diagramType smmodels.DiagramType, name, version string, location models.Location, metadata models.Metadata) error {
+	return nil
+}
+
+func TestService_QueryFiles_ReadsOnlyThePage(t *testing.T) {
+	repo := &mockQueryRepository{metadata: map[string]models.Metadata{
+		"auth":     {Tags: []string{"auth"}},
+		"auth-mfa": {Tags: []string{"auth"}},
+	}}
+	for _, name := range []string{"auth", "auth-mfa", "billing"} {
+		repo.entries = append(repo.entries, models.DiagramEntry{Name: name, Version: "1.0.0", Location: models.LocationFileProducts})
+	}
+	repo.listDiagramsFunc = func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+		t.Error("ListDiagrams() called although the repository lists entries")
+		return nil, nil
+	}
+	repo.readStateMachineFunc = func(diagramType smmodels.DiagramType, name, version string, location models.Location) (*models.StateMachineDiagram, error) {
+		repo.reads = append(repo.reads, name)
+		return &models.StateMachineDiagram{Name: name, Version: version, Location: location, Content: "@startuml\n@enduml"}, nil
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	query := models.Query{Locations: []models.Location{models.LocationFileProducts}, Tags: []string{"auth"}, Limit: 1}
+	result, err := svc.QueryFiles(smmodels.DiagramTypePUML, query)
+	if err != nil {
+		t.Fatalf("QueryFiles() error = %v", err)
+	}
+	if result.Total != 2 || len(result.Diagrams) != 1 || result.Diagrams[0].Name != "auth" || result.Diagrams[0].Content == "" {
+		t.Errorf("QueryFiles() = %+v, want the first of two tagged diagrams with content", result)
+	}
+	if len(repo.reads) != 1 {
+		t.Errorf("content read for %v, want only the diagram on the page", repo.reads)
+	}
+}
+
+func TestService_ListFileEntries_FallsBackToListDiagrams(t *testing.T) {
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	repo := &mockRepository{
+		listDiagramsFunc: func(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
+			return []models.StateMachineDiagram{{
+				Name:        "door",
+				Version:     "1.0.0",
+				Content:     "@startuml\n@enduml",
+				Location:    location,
+				DiagramType: diagramType,
+				Metadata:    models.Metadata{ModifiedAt: modified},
+			}}, nil
+		},
+	}
+	svc := NewService(repo, &mockValidator{}, nil)
+
+	listing, err := svc.ListFileEntries(smmodels.DiagramTypePUML, models.LocationFileProducts)
+	if err != nil {
+		t.Fatalf("ListFileEntries() error = %v", err)
+	}
+	if len(listing.Entries) != 1 || listing.Entries[0].Size != int64(len("@startuml\n@enduml")) ||
+		!listing.Entries[0].ModifiedAt.Equal(modified) || listing.Entries[0].Location != models.LocationFileProducts {
+		t.Errorf("ListFileEntries() = %+v, want the listed diagram without content", listing.Entries)
+	}
+	if listing.Skipped == nil {
+		t.Error("ListFileEntries() Skipped = nil, want an empty slice")
+	}
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestService_Update_TokenConflict(t *testing.T) {
+	svc, repo := newRevisionService()
+	repo.current = "@startuml\n[*] --> Idle\n@enduml"
+
+	first, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	second, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if first.Token == "" || first.Token != second.Token {
+		t.Fatalf("ReadFile() tokens = %q, %q, want equal non-empty tokens", first.Token, second.Token)
+	}
+
+	first.Content = "@startuml\n[*] --> Open\n@enduml"
+	if err := svc.UpdateInProgressFile(first); err != nil {
+		t.Fatalf("UpdateInProgressFile() error = %v", err)
+	}
+	if first.Token != models.ContentToken(first.Content) {
+		t.Errorf("Token after update = %q, want the token of the new content", first.Token)
+	}
+
+	// The second editor read the file before the first one saved it
+	second.Content = "@startuml\n[*] --> Closed\n@enduml"
+	if err := svc.UpdateInProgressFile(second); models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Fatalf("UpdateInProgressFile() with a stale token error = %v, want file conflict", err)
+	}
+	if repo.current != first.Content {
+		t.Errorf("stored content = %q, want the first editor's content", repo.current)
+	}
+
+	// The first editor can keep saving with the token returned by the update
+	first.Content = "@startuml\n[*] --> Opening\n@enduml"
+	if err := svc.UpdateInProgressFile(first); err != nil {
+		t.Errorf("UpdateInProgressFile() after an update error = %v", err)
+	}
+
+	// An explicit token takes precedence and an empty one skips the check
+	second.Token = ""
+	err = svc.UpdateInProgressFileWithOptions(second, models.UpdateOptions{ExpectedToken: models.ContentToken("stale")})
+	if models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Errorf("UpdateInProgressFileWithOptions() with a stale expected token error = %v, want file conflict", err)
+	}
+	if err := svc.UpdateInProgressFile(second); err != nil {
+		t.Errorf("UpdateInProgressFile() without a token error = %v", err)
+	}
+}
+
+func TestService_DeleteAndPromote_TokenConflict(t *testing.T) {
+	svc, repo := newRevisionService()
+	repo.current = "@startuml\n[*] --> Idle\n@enduml"
+	stale := models.ContentToken("@startuml\n[*] --> Open\n@enduml")
+
+	_, err := svc.DeleteFileWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.DeleteOptions{ExpectedToken: stale})
+	if models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Errorf("DeleteFileWithOptions() with a stale token error = %v, want file conflict", err)
+	}
+
+	err = svc.PromoteToProductsFileWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", models.PromoteOptions{ExpectedToken: stale})
+	if models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Errorf("PromoteToProductsFileWithOptions() with a stale token error = %v, want file conflict", err)
+	}
+
+	current := models.ContentToken(repo.current)
+	if _, err := svc.DeleteFileWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress, models.DeleteOptions{ExpectedToken: current}); err != nil {
+		t.Errorf("DeleteFileWithOptions() with the current token error = %v", err)
+	}
+}
+
+func TestService_ApplyFixes_TokenConflict(t *testing.T) {
+	svc, repo := newRevisionService()
+	repo.current = "@startuml\n[*] --> Idle"
+
+	read, err := svc.ReadFile(smmodels.DiagramTypePUML, "door", "1.0.0", models.LocationFileInProgress)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	fixes := []models.Fix{{Code: "MISSING_END", Edits: []models.TextEdit{{Span: models.PointSpan(2, 13), NewText: "\n@enduml"}}}}
+
+	// The file changed after the fixes were computed, so their offsets no longer apply
+	repo.current = "@startuml\n' note\n[*] --> Idle"
+	_, err = svc.ApplyFixesWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", fixes, models.UpdateOptions{ExpectedToken: read.Token})
+	if models.GetErrorType(err) != models.ErrorTypeFileConflict {
+		t.Fatalf("ApplyFixesWithOptions() with a stale token error = %v, want file conflict", err)
+	}
+	if repo.current != "@startuml\n' note\n[*] --> Idle" {
+		t.Errorf("stored content = %q, want it untouched", repo.current)
+	}
+
+	repo.current = read.Content
+	if _, err := svc.ApplyFixesWithOptions(smmodels.DiagramTypePUML, "door", "1.0.0", fixes, models.UpdateOptions{ExpectedToken: read.Token}); err != nil {
+		t.Fatalf("ApplyFixesWithOptions() with a current token error = %v", err)
+	}
+	if repo.current != "@startuml\n[*] --> Idle\n@enduml" {
+		t.Errorf("stored content = %q, want the fixed content", repo.current)
+	}
+}
@@ -0,0 +1,113 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// invalidStateNameChars matches runs of characters not allowed in state names
+var invalidStateNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// fixMissingStart builds a fix that inserts @startuml at the top of the content
+func (v *PlantUMLValidator) fixMissingStart() models.Fix {
+	return models.Fix{
+		Code:        "MISSING_START",
+		Description: "Insert @startuml at the beginning of the file",
+		Edits: []models.TextEdit{
+			{Span: models.PointSpan(1, 1), NewText: "@startuml\n"},
+		},
+	}
+}
+
+// fixMissingEnd builds a fix that appends @enduml to the content
+func (v *PlantUMLValidator) fixMissingEnd(lines sourceLines) models.Fix {
+	lastLine := len(lines)
+	lastText := lines.text(lastLine)
+
+	newText := "\n@enduml"
+	if strings.TrimSpace(lastText) == "" {
+		newText = "@enduml"
+	}
+
+	return models.Fix{
+		Code:        "MISSING_END",
+		Description: "Append @enduml at the end of the file",
+		Edits: []models.TextEdit{
+			{Span: models.PointSpan(lastLine, lines.column(lastLine, len(lastText))), NewText: newText},
+		},
+	}
+}
+
+// fixInitialState builds a fix that adds an initial transition to firstState
+// directly after the @startuml line
+func (v *PlantUMLValidator) fixInitialState(lines sourceLines, startTagLine int, firstState string) (models.Fix, bool) {
+	if firstState == "" {
+		return models.Fix{}, false
+	}
+	if !v.isValidStateName(firstState) {
+		sanitized, ok := v.sanitizeStateName(firstState)
+		if !ok {
+			return models.Fix{}, false
+		}
+		firstState = sanitized
+	}
+
+	text := lines.text(startTagLine)
+	return models.Fix{
+		Code:        "NO_INITIAL_STATE",
+		Description: fmt.Sprintf("Add initial transition to '%s'", firstState),
+		Edits: []models.TextEdit{
+			{
+				Span:    models.PointSpan(startTagLine, lines.column(startTagLine, len(text))),
+				NewText: "\n[*] --> " + firstState,
+			},
+		},
+	}, true
+}
+
+// fixStateName builds a fix that replaces an invalid state name at span with a valid one
+func (v *PlantUMLValidator) fixStateName(span models.Span, stateName string) (models.Fix, bool) {
+	sanitized, ok := v.sanitizeStateName(stateName)
+	if !ok {
+		return models.Fix{}, false
+	}
+
+	return models.Fix{
+		Code:        "INVALID_STATE_NAME",
+		Description: fmt.Sprintf("Rename state '%s' to '%s'", stateName, sanitized),
+		Edits: []models.TextEdit{
+			{Span: span, NewText: sanitized},
+		},
+	}, true
+}
+
+// fixReferencePath builds a fix that replaces a mismatched include path with the expected one
+func (v *PlantUMLValidator) fixReferencePath(span models.Span, expectedPath string) models.Fix {
+	return models.Fix{
+		Code:        "INCORRECT_REFERENCE_PATH",
+		Description: fmt.Sprintf("Change include path to '%s'", expectedPath),
+		Edits: []models.TextEdit{
+			{Span: span, NewText: expectedPath},
+		},
+	}
+}
+
+// sanitizeStateName converts a name into one that follows state naming conventions,
+// replacing spaces and other invalid characters with underscores
+func (v *PlantUMLValidator) sanitizeStateName(stateName string) (string, bool) {
+	name := strings.Trim(stateName, `"`)
+	name = invalidStateNameChars.ReplaceAllString(strings.TrimSpace(name), "_")
+	name = strings.Trim(name, "_")
+	if name == "" {
+		return "", false
+	}
+
+	if name[0] >= '0' && name[0] <= '9' || name[0] == '-' {
+		name = "_" + name
+	}
+
+	return name, v.isValidStateName(name)
+}
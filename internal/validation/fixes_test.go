@@ -0,0 +1,156 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// applyFixesFor validates content, applies every fix offered for code and returns the new content
+func applyFixesFor(t *testing.T, v *PlantUMLValidator, content, code string) string {
+	t.Helper()
+
+	diag := &models.StateMachineDiagram{Name: "test", Version: "1.0.0", Content: content}
+	result, err := v.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	var fixes []models.Fix
+	for _, fix := range result.Fixes() {
+		if fix.Code == code {
+			fixes = append(fixes, fix)
+		}
+	}
+	if len(fixes) == 0 {
+		t.Fatalf("Expected a %s fix", code)
+	}
+
+	fixed, err := models.ApplyFixes(content, fixes)
+	if err != nil {
+		t.Fatalf("ApplyFixes() error = %v", err)
+	}
+	return fixed
+}
+
+func TestPlantUMLValidator_Fixes(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	tests := []struct {
+		name    string
+		code    string
+		content string
+		want    string
+	}{
+		{
+			name:    "missing end",
+			code:    "MISSING_END",
+			content: "@startuml\n[*] --> Idle",
+			want:    "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:    "missing end after trailing newline",
+			code:    "MISSING_END",
+			content: "@startuml\n[*] --> Idle\n",
+			want:    "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:    "missing start",
+			code:    "MISSING_START",
+			content: "[*] --> Idle\n@enduml",
+			want:    "@startuml\n[*] --> Idle\n@enduml",
+		},
+		{
+			name:    "missing initial state",
+			code:    "NO_INITIAL_STATE",
+			content: "@startuml\nIdle --> Active\n@enduml",
+			want:    "@startuml\n[*] --> Idle\nIdle --> Active\n@enduml",
+		},
+		{
+			name:    "state name with spaces",
+			code:    "INVALID_STATE_NAME",
+			content: "@startuml\n[*] --> Waiting For Input\nWaiting For Input --> Done : ok\n@enduml",
+			want:    "@startuml\n[*] --> Waiting_For_Input\nWaiting_For_Input --> Done : ok\n@enduml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := applyFixesFor(t, validator, tt.content, tt.code)
+			if got != tt.want {
+				t.Errorf("fixed content = %q, want %q", got, tt.want)
+			}
+
+			// The fixed content should no longer report the finding
+			diag := &models.StateMachineDiagram{Name: "test", Version: "1.0.0", Content: got}
+			result, err := validator.Validate(diag, models.StrictnessInProgress)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			for _, e := range result.Errors {
+				if e.Code == tt.code {
+					t.Errorf("%s still reported after fix", tt.code)
+				}
+			}
+			for _, w := range result.Warnings {
+				if w.Code == tt.code {
+					t.Errorf("%s still reported after fix", tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestPlantUMLValidator_ReferencePathFix(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	content := "@startuml\n!include products/auth-1.0.0/auth-1.0.1.puml\n[*] --> Idle\n@enduml"
+	diag := &models.StateMachineDiagram{Name: "test", Version: "1.0.0", Content: content}
+
+	result, err := validator.ValidateReferences(diag)
+	if err != nil {
+		t.Fatalf("ValidateReferences() error = %v", err)
+	}
+
+	var fixes []models.Fix
+	for _, w := range result.Warnings {
+		if w.Code == "INCORRECT_REFERENCE_PATH" {
+			fixes = append(fixes, w.Fixes...)
+		}
+	}
+	if len(fixes) != 1 {
+		t.Fatalf("Expected 1 INCORRECT_REFERENCE_PATH fix, got %d", len(fixes))
+	}
+
+	fixed, err := models.ApplyFixes(content, fixes)
+	if err != nil {
+		t.Fatalf("ApplyFixes() error = %v", err)
+	}
+	want := "@startuml\n!include products/auth-1.0.0/auth-1.0.0.puml\n[*] --> Idle\n@enduml"
+	if fixed != want {
+		t.Errorf("fixed content = %q, want %q", fixed, want)
+	}
+}
+
+func TestPlantUMLValidator_SanitizeStateName(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	tests := []struct {
+		input  string
+		want   string
+		wantOK bool
+	}{
+		{"Bad State", "Bad_State", true},
+		{`"Quoted Name"`, "Quoted_Name", true},
+		{"2fa check", "_2fa_check", true},
+		{"a.b.c", "a_b_c", true},
+		{"!!!", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := validator.sanitizeStateName(tt.input)
+		if ok != tt.wantOK || got != tt.want {
+			t.Errorf("sanitizeStateName(%q) = (%q, %v), want (%q, %v)", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
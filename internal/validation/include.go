@@ -0,0 +1,246 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// defaultMaxIncludeDepth is the include nesting limit used when none is configured
+const defaultMaxIncludeDepth = 10
+
+// includeExpander holds the state of a single ExpandIncludes call
+type includeExpander struct {
+	v        *PlantUMLValidator
+	root     *models.StateMachineDiagram
+	result   *models.ValidationResult
+	lines    []string
+	expanded *models.Expansion
+	included map[string]bool
+}
+
+// ExpandIncludes inlines the product references of a state-machine diagram,
+// following includes of included diagrams up to the configured depth.
+// "!include path" inlines the first @startuml block of the referenced file,
+// "!include path!id" the block with that id, and "!includesub path!name" the
+// lines between "!startsub name" and "!endsub". Circular references are
+// detected with the same checks used by ResolveFileReferences and are left
+// unexpanded. Problems are reported in the returned result against the
+// !include line of the root diagram.
+func (v *PlantUMLValidator) ExpandIncludes(diag *models.StateMachineDiagram) (*models.Expansion, *models.ValidationResult, error) {
+	if diag == nil {
+		return nil, nil, models.NewStateMachineError(models.ErrorTypeValidation, "state-machine diagram cannot be nil", nil).
+			WithOperation("ExpandIncludes").
+			WithComponent("validator")
+	}
+
+	result := &models.ValidationResult{
+		Errors:   []models.ValidationError{},
+		Warnings: []models.ValidationWarning{},
+		IsValid:  true,
+	}
+
+	expandedDiag := *diag
+	expandedDiag.References = nil
+	x := &includeExpander{
+		v:        v,
+		root:     diag,
+		result:   result,
+		expanded: &models.Expansion{Diagram: &expandedDiag},
+		included: make(map[string]bool),
+	}
+
+	if v.repository == nil && productRefRegex.MatchString(diag.Content) {
+		result.AddWarning("NO_REPOSITORY", "Cannot expand includes without repository", 1, 1)
+	}
+
+	source := newSourceLines(diag.Content)
+	for i, line := range source {
+		lineNum := i + 1
+		ref, ok := v.parseIncludeLine(line, lineNum)
+		if !ok || v.repository == nil {
+			x.emit(line, lineNum, true)
+			continue
+		}
+
+		span := source.lineSpan(lineNum)
+		if !x.acyclic(ref, span) {
+			x.emit(line, lineNum, true)
+			continue
+		}
+		x.inline(ref, line, lineNum, 1, span, map[string]bool{includeKey(diag.Name, diag.Version, diag.Location): true})
+	}
+
+	expandedDiag.Content = strings.Join(x.lines, "\n")
+	return x.expanded, result, nil
+}
+
+// parseIncludeLine parses a single line as a product reference
+func (v *PlantUMLValidator) parseIncludeLine(line string, lineNum int) (models.Reference, bool) {
+	refs, err := v.parseReferences(line)
+	if err != nil || len(refs) == 0 {
+		return models.Reference{}, false
+	}
+	ref := refs[0]
+	ref.Line = lineNum
+	return ref, true
+}
+
+// acyclic runs the circular-reference checks for a top-level include and reports
+// whether it is safe to expand
+func (x *includeExpander) acyclic(ref models.Reference, span models.Span) bool {
+	if found, err := x.v.resolveConstraint(x.root.DiagramType, &ref); err != nil || !found {
+		// Reported when the include is inlined
+		return true
+	}
+
+	target, err := x.v.repository.ReadDiagram(x.root.DiagramType, ref.Name, ref.Version, models.LocationFileProducts)
+	if err != nil {
+		// Reported when the include is inlined
+		return true
+	}
+
+	before := len(x.result.Errors)
+	x.v.checkCircularReference(ref, target, x.root, span, x.result, make(map[string]bool))
+	return len(x.result.Errors) == before
+}
+
+// inline appends the part of the referenced diagram selected by ref, expanding
+// its own includes. Every inlined line maps to rootLine; when the include cannot
+// be expanded its directive text is kept.
+func (x *includeExpander) inline(ref models.Reference, text string, rootLine, depth int, span models.Span, chain map[string]bool) {
+	maxDepth := x.v.maxIncludeDepth
+	if depth > maxDepth {
+		x.result.AddErrorWithSpan("INCLUDE_DEPTH_EXCEEDED",
+			fmt.Sprintf("Include of '%s-%s' exceeds the maximum include depth of %d", ref.Name, ref.Version, maxDepth), span)
+		x.emit(text, rootLine, depth == 1)
+		return
+	}
+
+	found, err := x.v.resolveConstraint(x.root.DiagramType, &ref)
+	if err != nil || !found {
+		x.result.AddErrorWithSpan("UNSATISFIED_VERSION_CONSTRAINT",
+			fmt.Sprintf("No product version of '%s' satisfies '%s'", ref.Name, ref.Constraint), span)
+		x.emit(text, rootLine, depth == 1)
+		return
+	}
+
+	target, err := x.v.repository.ReadDiagram(x.root.DiagramType, ref.Name, ref.Version, models.LocationFileProducts)
+	if err != nil {
+		x.result.AddErrorWithSpan("PRODUCT_REFERENCE_NOT_FOUND",
+			fmt.Sprintf("Product reference '%s-%s' not found", ref.Name, ref.Version), span)
+		x.emit(text, rootLine, depth == 1)
+		return
+	}
+
+	key := includeKey(target.Name, target.Version, target.Location)
+	if chain[key] {
+		// Defensive: cycles are normally caught by acyclic before expanding
+		x.result.AddErrorWithSpan("CIRCULAR_REFERENCE",
+			fmt.Sprintf("Circular reference detected: '%s' is already being included", ref.Name), span)
+		x.emit(text, rootLine, depth == 1)
+		return
+	}
+
+	body, ok := includedPart(target.Content, ref)
+	if !ok {
+		x.result.AddErrorWithSpan("INCLUDE_PART_NOT_FOUND",
+			fmt.Sprintf("Part '%s' not found in '%s-%s'", ref.Sub, ref.Name, ref.Version), span)
+		x.emit(text, rootLine, depth == 1)
+		return
+	}
+
+	refKey := fmt.Sprintf("%s!%s", key, ref.Sub)
+	if !x.included[refKey] {
+		x.included[refKey] = true
+		x.expanded.Includes = append(x.expanded.Includes, ref)
+	}
+
+	chain[key] = true
+	defer delete(chain, key)
+
+	for _, line := range body {
+		if nested, ok := x.v.parseIncludeLine(line, 0); ok {
+			x.inline(nested, line, rootLine, depth+1, span, chain)
+			continue
+		}
+		x.emit(line, rootLine, false)
+	}
+}
+
+// emit appends an expanded line and its origin
+func (x *includeExpander) emit(text string, rootLine int, verbatim bool) {
+	x.lines = append(x.lines, text)
+	x.expanded.SourceMap.Add(rootLine, verbatim)
+}
+
+// includedPart returns the lines of content selected by an include reference,
+// without @startuml/@enduml tags and sub-part markers
+func includedPart(content string, ref models.Reference) ([]string, bool) {
+	var selected string
+	switch {
+	case ref.SubPart:
+		if ref.Sub == "" {
+			return nil, false
+		}
+		part, ok := subPart(content, ref.Sub)
+		if !ok {
+			return nil, false
+		}
+		selected = part
+
+	case ref.Sub != "":
+		block, err := models.FindBlock(content, ref.Sub)
+		if err != nil {
+			return nil, false
+		}
+		selected = block.Content
+
+	default:
+		selected = content
+		if blocks := models.SplitBlocks(content); len(blocks) > 0 {
+			selected = blocks[0].Content
+		}
+	}
+
+	var body []string
+	for _, line := range newSourceLines(selected) {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "@startuml") || strings.HasPrefix(trimmed, "@enduml") ||
+			strings.HasPrefix(trimmed, "!startsub") || strings.HasPrefix(trimmed, "!endsub") {
+			continue
+		}
+		body = append(body, line)
+	}
+	return body, true
+}
+
+// subPart returns the lines between every "!startsub name" and its "!endsub"
+func subPart(content, name string) (string, bool) {
+	var part []string
+	found := false
+	inPart := false
+
+	for _, line := range newSourceLines(content) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "!startsub"):
+			if strings.TrimSpace(strings.TrimPrefix(trimmed, "!startsub")) == name {
+				inPart = true
+				found = true
+			}
+		case strings.HasPrefix(trimmed, "!endsub"):
+			inPart = false
+		case inPart:
+			part = append(part, line)
+		}
+	}
+
+	return strings.Join(part, "\n"), found
+}
+
+// includeKey identifies a diagram on an include chain
+func includeKey(name, version string, location models.Location) string {
+	return fmt.Sprintf("%s-%s-%s", name, version, location.String())
+}
@@ -0,0 +1,248 @@
+package validation
+
+import (
+	"testing"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// newIncludeRepository returns a repository holding products used by the include tests
+func newIncludeRepository() *MockRepository {
+	repo := NewMockRepository()
+	add := func(name, content string) {
+		repo.AddStateMachine(&models.StateMachineDiagram{
+			Name:        name,
+			Version:     "1.0.0",
+			Content:     content,
+			Location:    models.LocationFileProducts,
+			DiagramType: smmodels.DiagramTypePUML,
+		})
+	}
+
+	add("leaf", "@startuml\nLeafA --> LeafB\n@enduml")
+	add("middle", "@startuml\n!include products/leaf-1.0.0/leaf-1.0.0.puml\nMiddle --> LeafA\n@enduml")
+	add("multi", "@startuml first\nFirst --> One\n@enduml\n@startuml second\nSecond --> Two\n@enduml")
+	add("parts", "@startuml\n!startsub CORE\nCore --> Ready\n!endsub\nNotIncluded --> X\n!startsub CORE\nReady --> Core\n!endsub\n@enduml")
+	add("loop-a", "@startuml\n!include products/loop-b-1.0.0/loop-b-1.0.0.puml\n@enduml")
+	add("loop-b", "@startuml\n!include products/loop-a-1.0.0/loop-a-1.0.0.puml\n@enduml")
+	return repo
+}
+
+func TestPlantUMLValidator_ExpandIncludes(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      string
+		maxDepth     int
+		want         string
+		wantSources  []int
+		wantIncludes int
+		wantErrors   []string
+	}{
+		{
+			name:         "transitive include",
+			content:      "@startuml\n[*] --> Root\n!include products/middle-1.0.0/middle-1.0.0.puml\n@enduml",
+			want:         "@startuml\n[*] --> Root\nLeafA --> LeafB\nMiddle --> LeafA\n@enduml",
+			wantSources:  []int{1, 2, 3, 3, 4},
+			wantIncludes: 2,
+		},
+		{
+			name:         "include block by id",
+			content:      "@startuml\n!include products/multi-1.0.0/multi-1.0.0.puml!second\n@enduml",
+			want:         "@startuml\nSecond --> Two\n@enduml",
+			wantSources:  []int{1, 2, 3},
+			wantIncludes: 1,
+		},
+		{
+			name:         "first block by default",
+			content:      "@startuml\n!include products/multi-1.0.0/multi-1.0.0.puml\n@enduml",
+			want:         "@startuml\nFirst --> One\n@enduml",
+			wantSources:  []int{1, 2, 3},
+			wantIncludes: 1,
+		},
+		{
+			name:         "includesub",
+			content:      "@startuml\n!includesub products/parts-1.0.0/parts-1.0.0.puml!CORE\n@enduml",
+			want:         "@startuml\nCore --> Ready\nReady --> Core\n@enduml",
+			wantSources:  []int{1, 2, 2, 3},
+			wantIncludes: 1,
+		},
+		{
+			name:        "missing sub-part",
+			content:     "@startuml\n!includesub products/parts-1.0.0/parts-1.0.0.puml!NOPE\n@enduml",
+			want:        "@startuml\n!includesub products/parts-1.0.0/parts-1.0.0.puml!NOPE\n@enduml",
+			wantSources: []int{1, 2, 3},
+			wantErrors:  []string{"INCLUDE_PART_NOT_FOUND"},
+		},
+		{
+			name:         "version range",
+			content:      "@startuml\n!include products/leaf-^1.0/leaf-^1.0.puml\n@enduml",
+			want:         "@startuml\nLeafA --> LeafB\n@enduml",
+			wantSources:  []int{1, 2, 3},
+			wantIncludes: 1,
+		},
+		{
+			name:        "unsatisfied version range",
+			content:     "@startuml\n!include products/leaf-^2.0/leaf-^2.0.puml\n@enduml",
+			want:        "@startuml\n!include products/leaf-^2.0/leaf-^2.0.puml\n@enduml",
+			wantSources: []int{1, 2, 3},
+			wantErrors:  []string{"UNSATISFIED_VERSION_CONSTRAINT"},
+		},
+		{
+			name:        "missing product",
+			content:     "@startuml\n!include products/absent-1.0.0/absent-1.0.0.puml\n@enduml",
+			want:        "@startuml\n!include products/absent-1.0.0/absent-1.0.0.puml\n@enduml",
+			wantSources: []int{1, 2, 3},
+			wantErrors:  []string{"PRODUCT_REFERENCE_NOT_FOUND"},
+		},
+		{
+			name:        "cycle is detected and left unexpanded",
+			content:     "@startuml\n!include products/loop-a-1.0.0/loop-a-1.0.0.puml\n@enduml",
+			want:        "@startuml\n!include products/loop-a-1.0.0/loop-a-1.0.0.puml\n@enduml",
+			wantSources: []int{1, 2, 3},
+			wantErrors:  []string{"CIRCULAR_REFERENCE"},
+		},
+		{
+			name:         "depth limit",
+			content:      "@startuml\n!include products/middle-1.0.0/middle-1.0.0.puml\n@enduml",
+			maxDepth:     1,
+			want:         "@startuml\n!include products/leaf-1.0.0/leaf-1.0.0.puml\nMiddle --> LeafA\n@enduml",
+			wantSources:  []int{1, 2, 2, 3},
+			wantIncludes: 1,
+			wantErrors:   []string{"INCLUDE_DEPTH_EXCEEDED"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewPlantUMLValidatorWithRepository(newIncludeRepository()).WithMaxIncludeDepth(tt.maxDepth)
+			diag := &models.StateMachineDiagram{
+				Name:        "root",
+				Version:     "1.0.0",
+				Content:     tt.content,
+				Location:    models.LocationFileInProgress,
+				DiagramType: smmodels.DiagramTypePUML,
+			}
+
+			expansion, result, err := validator.ExpandIncludes(diag)
+			if err != nil {
+				t.Fatalf("ExpandIncludes() error = %v", err)
+			}
+
+			if expansion.Diagram.Content != tt.want {
+				t.Errorf("Content = %q, want %q", expansion.Diagram.Content, tt.want)
+			}
+			if diag.Content != tt.content {
+				t.Error("ExpandIncludes() should not modify the input diagram")
+			}
+
+			for i, want := range tt.wantSources {
+				if got := expansion.SourceMap.SourceLine(i + 1); got != want {
+					t.Errorf("SourceLine(%d) = %d, want %d", i+1, got, want)
+				}
+			}
+			if len(expansion.Includes) != tt.wantIncludes {
+				t.Errorf("Includes = %+v, want %d", expansion.Includes, tt.wantIncludes)
+			}
+
+			var codes []string
+			for _, e := range result.Errors {
+				codes = append(codes, e.Code)
+				if e.Line != 2 {
+					t.Errorf("Error %s reported on line %d, want 2", e.Code, e.Line)
+				}
+			}
+			if len(codes) != len(tt.wantErrors) {
+				t.Fatalf("Errors = %v, want %v", codes, tt.wantErrors)
+			}
+			for i := range codes {
+				if codes[i] != tt.wantErrors[i] {
+					t.Errorf("Errors = %v, want %v", codes, tt.wantErrors)
+				}
+			}
+		})
+	}
+}
+
+func TestPlantUMLValidator_ExpandIncludes_NoRepository(t *testing.T) {
+	validator := NewPlantUMLValidator()
+	content := "@startuml\n!include products/leaf-1.0.0/leaf-1.0.0.puml\n@enduml"
+
+	expansion, result, err := validator.ExpandIncludes(&models.StateMachineDiagram{Name: "root", Version: "1.0.0", Content: content})
+	if err != nil {
+		t.Fatalf("ExpandIncludes() error = %v", err)
+	}
+	if expansion.Diagram.Content != content {
+		t.Errorf("Content = %q, want unchanged", expansion.Diagram.Content)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != "NO_REPOSITORY" {
+		t.Errorf("Warnings = %+v, want NO_REPOSITORY", result.Warnings)
+	}
+
+	if _, _, err := validator.ExpandIncludes(nil); err == nil {
+		t.Error("ExpandIncludes(nil) should fail")
+	}
+}
+
+func TestPlantUMLValidator_ExpandedValidation(t *testing.T) {
+	validator := NewPlantUMLValidatorWithRepository(newIncludeRepository())
+	diag := &models.StateMachineDiagram{
+		Name:        "root",
+		Version:     "1.0.0",
+		Content:     "@startuml\n[*] --> LeafA\n!include products/leaf-1.0.0/leaf-1.0.0.puml\nLeafB --> Bad State\n@enduml",
+		DiagramType: smmodels.DiagramTypePUML,
+	}
+
+	expansion, _, err := validator.ExpandIncludes(diag)
+	if err != nil {
+		t.Fatalf("ExpandIncludes() error = %v", err)
+	}
+
+	result, err := validator.Validate(expansion.Diagram, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	expansion.SourceMap.MapResult(result, diag.Content)
+
+	if len(result.Warnings) != 1 {
+		t.Fatalf("Warnings = %+v, want 1", result.Warnings)
+	}
+	want := models.Span{StartLine: 4, StartColumn: 11, EndLine: 4, EndColumn: 20}
+	if result.Warnings[0].Span() != want {
+		t.Errorf("Warning span = %+v, want %+v", result.Warnings[0].Span(), want)
+	}
+	if len(result.Warnings[0].Fixes) != 1 {
+		t.Errorf("Warning on a root line should keep its fix, got %+v", result.Warnings[0].Fixes)
+	}
+}
+
+func TestParseReferences_IncludeForms(t *testing.T) {
+	validator := NewPlantUMLValidator()
+	content := "!include products/a-1.0.0/a-1.0.0.puml\n" +
+		"!include products/b-1.0.0/b-1.0.0.puml!login\n" +
+		"!includesub products/c-1.0.0/c-1.0.0.puml!CORE"
+
+	refs, err := validator.parseReferences(content)
+	if err != nil {
+		t.Fatalf("parseReferences() error = %v", err)
+	}
+	if len(refs) != 3 {
+		t.Fatalf("parseReferences() = %+v, want 3 references", refs)
+	}
+
+	want := []struct {
+		name    string
+		sub     string
+		subPart bool
+		path    string
+	}{
+		{"a", "", false, "products/a-1.0.0/a-1.0.0.puml"},
+		{"b", "login", false, "products/b-1.0.0/b-1.0.0.puml"},
+		{"c", "CORE", true, "products/c-1.0.0/c-1.0.0.puml"},
+	}
+	for i, w := range want {
+		if refs[i].Name != w.name || refs[i].Sub != w.sub || refs[i].SubPart != w.subPart || refs[i].Path != w.path {
+			t.Errorf("refs[%d] = %+v, want %+v", i, refs[i], w)
+		}
+	}
+}
@@ -5,21 +5,32 @@ import (
 	"regexp"
 	"strings"
 
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/logging"
 	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/preprocessor"
 )
 
+// productRefRegex matches product references:
+// !include products/{name}-{version}/{name}-{version}.puml[!id] and
+// !includesub products/{name}-{version}/{name}-{version}.puml!{sub-part}
+// where {version} is an exact version or a version constraint such as ^1.2
+var productRefRegex = regexp.MustCompile(`!(include|includesub)\s+products/([a-zA-Z_][a-zA-Z0-9_-]*)-([a-zA-Z0-9_.^~*<>=,-]+)/([a-zA-Z_][a-zA-Z0-9_-]*)-([a-zA-Z0-9_.^~*<>=,-]+)\.puml(?:!([a-zA-Z0-9_.-]+))?`)
+
 // PlantUMLValidator implements the Validator interface for PlantUML syntax validation
 type PlantUMLValidator struct {
-	repository models.Repository // Optional repository for reference resolution
-	logger     *logging.Logger
+	repository          models.Repository // Optional repository for reference resolution
+	allowMultipleBlocks bool              // Validate each @startuml block independently
+	maxIncludeDepth     int               // Nesting limit for ExpandIncludes
+	logger              *logging.Logger
 }
 
 // NewPlantUMLValidator creates a new PlantUML validator instance
 func NewPlantUMLValidator() *PlantUMLValidator {
 	logger := logging.NewDefaultLogger().WithField("component", "PlantUMLValidator")
 	return &PlantUMLValidator{
-		logger: logger,
+		maxIncludeDepth: defaultMaxIncludeDepth,
+		logger:          logger,
 	}
 }
 
@@ -27,9 +38,27 @@ func NewPlantUMLValidator() *PlantUMLValidator {
 func NewPlantUMLValidatorWithRepository(repo models.Repository) *PlantUMLValidator {
 	logger := logging.NewDefaultLogger().WithField("component", "PlantUMLValidator")
 	return &PlantUMLValidator{
-		repository: repo,
-		logger:     logger,
+		repository:      repo,
+		maxIncludeDepth: defaultMaxIncludeDepth,
+		logger:          logger,
+	}
+}
+
+// WithMultipleBlocks enables or disables support for files holding several
+// @startuml blocks and returns the validator
+func (v *PlantUMLValidator) WithMultipleBlocks(allow bool) *PlantUMLValidator {
+	v.allowMultipleBlocks = allow
+	return v
+}
+
+// WithMaxIncludeDepth sets how deeply ExpandIncludes follows nested includes
+// and returns the validator. Values below 1 restore the default depth.
+func (v *PlantUMLValidator) WithMaxIncludeDepth(depth int) *PlantUMLValidator {
+	if depth < 1 {
+		depth = defaultMaxIncludeDepth
 	}
+	v.maxIncludeDepth = depth
+	return v
 }
 
 // Validate validates a state-machine diagram according to the specified strictness level
@@ -40,11 +69,25 @@ func (v *PlantUMLValidator) Validate(diag *models.StateMachineDiagram, strictnes
 		IsValid:  true,
 	}
 
-	// Validate PlantUML structure
-	v.validatePlantUMLStructure(diag.Content, result)
+	// Expand preprocessor directives so that validation sees the final diagram text
+	pre := preprocessor.Process(diag.Content)
+
+	if v.allowMultipleBlocks {
+		// Validate each @startuml block on its own
+		v.validateBlocks(pre.Content, result)
+	} else {
+		// Validate PlantUML structure
+		v.validatePlantUMLStructure(pre.Content, result)
 
-	// Validate state-machine diagram syntax
-	v.validateStateMachineSyntax(diag.Content, result)
+		// Validate state-machine diagram syntax
+		v.validateStateMachineSyntax(pre.Content, result)
+	}
+
+	// Report findings against the original lines
+	if pre.Changed() {
+		pre.SourceMap.MapResult(result, diag.Content)
+	}
+	v.addPreprocessorDiagnostics(result, pre, diag.Content)
 
 	// Apply strictness filtering
 	v.applyStrictnessFiltering(result, strictness)
@@ -83,30 +126,34 @@ func (v *PlantUMLValidator) parseReferences(content string) ([]models.Reference,
 	var references []models.Reference
 	lines := strings.Split(content, "\n")
 
-	// Regular expression for product references: !include products/{name}-{version}/{name}-{version}.puml
-	productRefRegex := regexp.MustCompile(`!include\s+products/([a-zA-Z_][a-zA-Z0-9_-]*)-([a-zA-Z0-9_.-]+)/([a-zA-Z_][a-zA-Z0-9_-]*)-([a-zA-Z0-9_.-]+)\.puml`)
-
-	for _, line := range lines {
+	for i, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 
 		// Check for product references
 		if matches := productRefRegex.FindStringSubmatch(trimmedLine); matches != nil {
-			if len(matches) >= 5 {
-				dirName := matches[1]
-				dirVersion := matches[2]
-				fileName := matches[3]
-				fileVersion := matches[4]
-
-				// Validate that directory name matches file name and versions match
-				if dirName == fileName && dirVersion == fileVersion {
-					reference := models.Reference{
-						Name:    dirName,
-						Version: dirVersion,
-						Type:    models.ReferenceTypeProduct,
-						Path:    fmt.Sprintf("products/%s-%s/%s-%s.puml", dirName, dirVersion, fileName, fileVersion),
-					}
-					references = append(references, reference)
+			if len(matches) >= 7 {
+				dirName := matches[2]
+				dirVersion := matches[3]
+				fileName := matches[4]
+				fileVersion := matches[5]
+
+				// The directory part identifies the reference. When the file part does not
+				// match it, the path is kept as written so validation can flag and fix it.
+				reference := models.Reference{
+					Name:    dirName,
+					Version: dirVersion,
+					Type:    models.ReferenceTypeProduct,
+					Path:    fmt.Sprintf("products/%s-%s/%s-%s.puml", dirName, dirVersion, fileName, fileVersion),
+					Line:    i + 1,
+					Sub:     matches[6],
+					SubPart: matches[1] == "includesub",
+				}
+				// A version range is resolved to a concrete version against the repository
+				if models.IsVersionConstraint(dirVersion) {
+					reference.Constraint = dirVersion
+					reference.Version = ""
 				}
+				references = append(references, reference)
 			}
 		}
 
@@ -123,12 +170,22 @@ func (v *PlantUMLValidator) parseReferences(content string) ([]models.Reference,
 	return references, nil
 }
 
+// referenceSpan returns the span of the !include line a reference was parsed from
+func (v *PlantUMLValidator) referenceSpan(ref models.Reference, diag *models.StateMachineDiagram) models.Span {
+	if ref.Line < 1 {
+		return models.PointSpan(1, 1)
+	}
+	return newSourceLines(diag.Content).lineSpan(ref.Line)
+}
+
 // validateReference validates a single reference
 func (v *PlantUMLValidator) validateReference(ref models.Reference, diag *models.StateMachineDiagram, result *models.ValidationResult) {
+	span := v.referenceSpan(ref, diag)
+
 	// Validate reference name
 	if !v.isValidStateName(ref.Name) {
-		result.AddError("INVALID_REFERENCE_NAME",
-			fmt.Sprintf("Reference name '%s' is invalid", ref.Name), 1, 1)
+		result.AddErrorWithSpan("INVALID_REFERENCE_NAME",
+			fmt.Sprintf("Reference name '%s' is invalid", ref.Name), span)
 		return
 	}
 
@@ -137,39 +194,61 @@ func (v *PlantUMLValidator) validateReference(ref models.Reference, diag *models
 	case models.ReferenceTypeProduct:
 		v.validateProductReference(ref, diag, result)
 	default:
-		result.AddError("UNKNOWN_REFERENCE_TYPE",
-			fmt.Sprintf("Unknown reference type for '%s'", ref.Name), 1, 1)
+		result.AddErrorWithSpan("UNKNOWN_REFERENCE_TYPE",
+			fmt.Sprintf("Unknown reference type for '%s'", ref.Name), span)
 	}
 }
 
 // validateProductReference validates a product reference
 func (v *PlantUMLValidator) validateProductReference(ref models.Reference, diag *models.StateMachineDiagram, result *models.ValidationResult) {
+	span := v.referenceSpan(ref, diag)
+
+	// Version ranges are checked for syntax only; they are resolved with the repository
+	if ref.IsRange() {
+		if _, err := models.ParseConstraint(ref.Constraint); err != nil {
+			result.AddErrorWithSpan("INVALID_VERSION_CONSTRAINT",
+				fmt.Sprintf("Product reference '%s' has invalid version constraint '%s'", ref.Name, ref.Constraint), span)
+			return
+		}
+		v.validateReferencePath(ref, ref.Constraint, diag, span, result)
+		return
+	}
+
 	// Product references must have a version
 	if ref.Version == "" {
-		result.AddError("MISSING_REFERENCE_VERSION",
-			fmt.Sprintf("Product reference '%s' must have a version", ref.Name), 1, 1)
+		result.AddErrorWithSpan("MISSING_REFERENCE_VERSION",
+			fmt.Sprintf("Product reference '%s' must have a version", ref.Name), span)
 		return
 	}
 
 	// Validate version format (basic semantic versioning check)
 	if !v.isValidVersion(ref.Version) {
-		result.AddError("INVALID_REFERENCE_VERSION",
-			fmt.Sprintf("Product reference '%s' has invalid version '%s'", ref.Name, ref.Version), 1, 1)
+		result.AddErrorWithSpan("INVALID_REFERENCE_VERSION",
+			fmt.Sprintf("Product reference '%s' has invalid version '%s'", ref.Name, ref.Version), span)
 		return
 	}
 
 	// Check for self-reference
 	if ref.Name == diag.Name && ref.Version == diag.Version {
-		result.AddError("SELF_REFERENCE",
-			"State-machine diagram cannot reference itself", 1, 1)
+		result.AddErrorWithSpan("SELF_REFERENCE",
+			"State-machine diagram cannot reference itself", span)
 		return
 	}
 
-	// Validate path format
-	expectedPath := fmt.Sprintf("products/%s-%s/%s-%s.puml", ref.Name, ref.Version, ref.Name, ref.Version)
+	v.validateReferencePath(ref, ref.Version, diag, span, result)
+}
+
+// validateReferencePath checks that both parts of a reference path use the same name and version
+func (v *PlantUMLValidator) validateReferencePath(ref models.Reference, version string, diag *models.StateMachineDiagram, span models.Span, result *models.ValidationResult) {
+	expectedPath := fmt.Sprintf("products/%s-%s/%s-%s.puml", ref.Name, version, ref.Name, version)
 	if ref.Path != expectedPath {
-		result.AddWarning("INCORRECT_REFERENCE_PATH",
-			fmt.Sprintf("Reference path '%s' should be '%s'", ref.Path, expectedPath), 1, 1)
+		var fixes []models.Fix
+		if ref.Line > 0 {
+			pathSpan := newSourceLines(diag.Content).tokenSpan(ref.Line, ref.Path, 0)
+			fixes = append(fixes, v.fixReferencePath(pathSpan, expectedPath))
+		}
+		result.AddWarningWithSpan("INCORRECT_REFERENCE_PATH",
+			fmt.Sprintf("Reference path '%s' should be '%s'", ref.Path, expectedPath), span, fixes...)
 	}
 }
 
@@ -180,6 +259,30 @@ func (v *PlantUMLValidator) isValidVersion(version string) bool {
 	return versionRegex.MatchString(version)
 }
 
+// resolveConstraint sets the version of a range reference to the highest product
+// version satisfying its constraint and reports whether one was found
+func (v *PlantUMLValidator) resolveConstraint(diagramType smmodels.DiagramType, ref *models.Reference) (bool, error) {
+	if !ref.IsRange() {
+		return true, nil
+	}
+
+	constraint, err := models.ParseConstraint(ref.Constraint)
+	if err != nil {
+		return false, err
+	}
+
+	versions, err := models.ListVersions(v.repository, diagramType, models.LocationFileProducts, ref.Name)
+	if err != nil {
+		return false, err
+	}
+
+	version, ok := constraint.Latest(versions)
+	if ok {
+		ref.Version = version
+	}
+	return ok, nil
+}
+
 // ResolveFileReferences resolves and validates reference accessibility
 func (v *PlantUMLValidator) ResolveFileReferences(diag *models.StateMachineDiagram) (*models.ValidationResult, error) {
 	result := &models.ValidationResult{
@@ -204,73 +307,88 @@ func (v *PlantUMLValidator) ResolveFileReferences(diag *models.StateMachineDiagr
 		diag.References = references
 	}
 
-	// Resolve each reference
-	for _, ref := range diag.References {
-		v.resolveReference(ref, diag, result)
+	// Resolve each reference, recording the version chosen for version ranges
+	for i := range diag.References {
+		v.resolveReference(&diag.References[i], diag, result)
 	}
 
 	return result, nil
 }
 
 // resolveReference resolves a single reference and checks its accessibility
-func (v *PlantUMLValidator) resolveReference(ref models.Reference, diag *models.StateMachineDiagram, result *models.ValidationResult) {
+func (v *PlantUMLValidator) resolveReference(ref *models.Reference, diag *models.StateMachineDiagram, result *models.ValidationResult) {
 	var targetLocation models.Location
 	var checkVersion string
 
+	span := v.referenceSpan(*ref, diag)
+
 	// Determine target location and version based on reference type
 	switch ref.Type {
 	case models.ReferenceTypeProduct:
+		found, err := v.resolveConstraint(diag.DiagramType, ref)
+		if err != nil {
+			result.AddWarningWithSpan("REFERENCE_CHECK_ERROR",
+				fmt.Sprintf("Failed to resolve version constraint of reference '%s': %v", ref.Name, err), span)
+			return
+		}
+		if !found {
+			result.AddErrorWithSpan("UNSATISFIED_VERSION_CONSTRAINT",
+				fmt.Sprintf("No product version of '%s' satisfies '%s'", ref.Name, ref.Constraint), span)
+			return
+		}
 		targetLocation = models.LocationFileProducts
 		checkVersion = ref.Version
 	default:
-		result.AddError("UNKNOWN_REFERENCE_TYPE",
-			fmt.Sprintf("Cannot resolve unknown reference type for '%s'", ref.Name), 1, 1)
+		result.AddErrorWithSpan("UNKNOWN_REFERENCE_TYPE",
+			fmt.Sprintf("Cannot resolve unknown reference type for '%s'", ref.Name), span)
 		return
 	}
 
 	// Check if the referenced state-machine diagram exists
 	exists, err := v.repository.Exists(diag.DiagramType, ref.Name, checkVersion, targetLocation)
 	if err != nil {
-		result.AddWarning("REFERENCE_CHECK_ERROR",
-			fmt.Sprintf("Failed to check existence of reference '%s': %v", ref.Name, err), 1, 1)
+		result.AddWarningWithSpan("REFERENCE_CHECK_ERROR",
+			fmt.Sprintf("Failed to check existence of reference '%s': %v", ref.Name, err), span)
 		return
 	}
 
 	if !exists {
-		result.AddError("PRODUCT_REFERENCE_NOT_FOUND",
-			fmt.Sprintf("Product reference '%s-%s' not found", ref.Name, ref.Version), 1, 1)
+		result.AddErrorWithSpan("PRODUCT_REFERENCE_NOT_FOUND",
+			fmt.Sprintf("Product reference '%s-%s' not found", ref.Name, ref.Version), span)
 		return
 	}
 
 	// Try to read the referenced state-machine diagram to ensure it's accessible
 	referencedDiag, err := v.repository.ReadDiagram(diag.DiagramType, ref.Name, checkVersion, targetLocation)
 	if err != nil {
-		result.AddWarning("REFERENCE_READ_ERROR",
-			fmt.Sprintf("Referenced state-machine diagram '%s' exists but cannot be read: %v", ref.Name, err), 1, 1)
+		result.AddWarningWithSpan("REFERENCE_READ_ERROR",
+			fmt.Sprintf("Referenced state-machine diagram '%s' exists but cannot be read: %v", ref.Name, err), span)
 		return
 	}
 
 	// Additional validation: check for circular references
-	v.checkCircularReference(ref, referencedDiag, diag, result, make(map[string]bool))
+	v.checkCircularReference(*ref, referencedDiag, diag, span, result, make(map[string]bool))
 }
 
-// checkCircularReference detects circular references between state-machine diagrams
-func (v *PlantUMLValidator) checkCircularReference(ref models.Reference, referencedDiag *models.StateMachineDiagram, originalDiag *models.StateMachineDiagram, result *models.ValidationResult, visited map[string]bool) {
+// checkCircularReference detects circular references between state-machine diagrams.
+// Findings are reported at span, the !include line in the original diagram that
+// starts the chain being followed.
+func (v *PlantUMLValidator) checkCircularReference(ref models.Reference, referencedDiag *models.StateMachineDiagram, originalDiag *models.StateMachineDiagram, span models.Span, result *models.ValidationResult, visited map[string]bool) {
 	// Create a unique key for the referenced state-machine diagram
 	refKey := fmt.Sprintf("%s-%s-%s", referencedDiag.Name, referencedDiag.Version, referencedDiag.Location.String())
 	originalKey := fmt.Sprintf("%s-%s-%s", originalDiag.Name, originalDiag.Version, originalDiag.Location.String())
 
 	// Check if we've already visited this reference (circular reference detected)
 	if visited[refKey] {
-		result.AddError("CIRCULAR_REFERENCE",
-			fmt.Sprintf("Circular reference detected: '%s' references '%s'", originalDiag.Name, ref.Name), 1, 1)
+		result.AddErrorWithSpan("CIRCULAR_REFERENCE",
+			fmt.Sprintf("Circular reference detected: '%s' references '%s'", originalDiag.Name, ref.Name), span)
 		return
 	}
 
 	// Check if the referenced state-machine diagram references back to the original
 	if refKey == originalKey {
-		result.AddError("DIRECT_CIRCULAR_REFERENCE",
-			fmt.Sprintf("Direct circular reference: '%s' references itself", ref.Name), 1, 1)
+		result.AddErrorWithSpan("DIRECT_CIRCULAR_REFERENCE",
+			fmt.Sprintf("Direct circular reference: '%s' references itself", ref.Name), span)
 		return
 	}
 
@@ -295,6 +413,9 @@ func (v *PlantUMLValidator) checkCircularReference(ref models.Reference, referen
 
 		switch nestedRef.Type {
 		case models.ReferenceTypeProduct:
+			if found, err := v.resolveConstraint(originalDiag.DiagramType, &nestedRef); err != nil || !found {
+				continue // Skip ranges nothing satisfies
+			}
 			targetLocation = models.LocationFileProducts
 			checkVersion = nestedRef.Version
 		default:
@@ -308,7 +429,7 @@ func (v *PlantUMLValidator) checkCircularReference(ref models.Reference, referen
 		}
 
 		// Recursively check for circular references
-		v.checkCircularReference(nestedRef, nestedReferencedDiag, originalDiag, result, visited)
+		v.checkCircularReference(nestedRef, nestedReferencedDiag, originalDiag, span, result, visited)
 	}
 
 	// Remove from visited when we're done with this branch
@@ -317,7 +438,7 @@ func (v *PlantUMLValidator) checkCircularReference(ref models.Reference, referen
 
 // validatePlantUMLStructure validates the basic PlantUML start/end tags
 func (v *PlantUMLValidator) validatePlantUMLStructure(content string, result *models.ValidationResult) {
-	lines := strings.Split(content, "\n")
+	lines := newSourceLines(content)
 
 	var startFound, endFound bool
 	var startLine, endLine int
@@ -328,7 +449,7 @@ func (v *PlantUMLValidator) validatePlantUMLStructure(content string, result *mo
 
 		if strings.HasPrefix(trimmedLine, "@startuml") {
 			if startFound {
-				result.AddError("DUPLICATE_START", "Multiple @startuml tags found", i+1, 1)
+				result.AddErrorWithSpan("DUPLICATE_START", "Multiple @startuml tags found", lines.lineSpan(i+1))
 			} else {
 				startFound = true
 				startLine = i + 1
@@ -337,7 +458,7 @@ func (v *PlantUMLValidator) validatePlantUMLStructure(content string, result *mo
 
 		if strings.HasPrefix(trimmedLine, "@enduml") {
 			if endFound {
-				result.AddError("DUPLICATE_END", "Multiple @enduml tags found", i+1, 1)
+				result.AddErrorWithSpan("DUPLICATE_END", "Multiple @enduml tags found", lines.lineSpan(i+1))
 			} else {
 				endFound = true
 				endLine = i + 1
@@ -347,21 +468,77 @@ func (v *PlantUMLValidator) validatePlantUMLStructure(content string, result *mo
 
 	// Validate structure requirements
 	if !startFound {
-		result.AddError("MISSING_START", "Missing @startuml tag", 1, 1)
+		result.AddErrorWithSpan("MISSING_START", "Missing @startuml tag", models.PointSpan(1, 1),
+			v.fixMissingStart())
 	}
 
 	if !endFound {
-		result.AddError("MISSING_END", "Missing @enduml tag", len(lines), 1)
+		lastLine := len(lines)
+		result.AddErrorWithSpan("MISSING_END", "Missing @enduml tag",
+			models.PointSpan(lastLine, lines.column(lastLine, len(lines.text(lastLine)))),
+			v.fixMissingEnd(lines))
 	}
 
 	if startFound && endFound && startLine >= endLine {
-		result.AddError("INVALID_ORDER", "@startuml must come before @enduml", startLine, 1)
+		result.AddErrorWithSpan("INVALID_ORDER", "@startuml must come before @enduml", lines.lineSpan(startLine))
+	}
+}
+
+// validateBlocks validates every @startuml block of a multi-block file independently.
+// Findings keep whole-file line numbers and carry the block id in their context.
+func (v *PlantUMLValidator) validateBlocks(content string, result *models.ValidationResult) {
+	blocks := models.SplitBlocks(content)
+	if len(blocks) == 0 {
+		// Nothing to split; report the usual structure errors for the whole file
+		v.validatePlantUMLStructure(content, result)
+		v.validateStateMachineSyntax(content, result)
+		return
+	}
+
+	lines := newSourceLines(content)
+
+	// Report @enduml tags that do not close a block
+	inBlock := make(map[int]bool)
+	for _, block := range blocks {
+		for line := block.StartLine; line <= block.EndLine; line++ {
+			inBlock[line] = true
+		}
+	}
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "@enduml") && !inBlock[i+1] {
+			result.AddErrorWithSpan("UNMATCHED_END", "@enduml tag without a matching @startuml", lines.lineSpan(i+1))
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		if seen[block.ID] {
+			result.AddErrorWithSpan("DUPLICATE_BLOCK_ID", fmt.Sprintf("Duplicate block id '%s'", block.ID),
+				lines.lineSpan(block.StartLine))
+			result.Errors[len(result.Errors)-1].Context["block"] = block.ID
+		}
+		seen[block.ID] = true
+
+		blockResult := &models.ValidationResult{IsValid: true}
+		v.validatePlantUMLStructure(block.Content, blockResult)
+		v.validateStateMachineSyntax(block.Content, blockResult)
+		blockResult.ShiftLines(block.StartLine - 1)
+
+		for _, err := range blockResult.Errors {
+			err.Context["block"] = block.ID
+			result.Errors = append(result.Errors, err)
+			result.IsValid = false
+		}
+		for _, warning := range blockResult.Warnings {
+			warning.Context["block"] = block.ID
+			result.Warnings = append(result.Warnings, warning)
+		}
 	}
 }
 
 // validateStateMachineSyntax validates state-machine diagram specific syntax
 func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *models.ValidationResult) {
-	lines := strings.Split(content, "\n")
+	lines := newSourceLines(content)
 
 	// Regular expressions for state-machine diagram syntax
 	transitionRegex := regexp.MustCompile(`^(.+)\s*-->\s*(.+)$`)
@@ -370,11 +547,31 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 
 	var inPlantUML bool
 	var hasInitialState bool
+	var startTagLine, endTagLine int
+	var firstState string
 	states := make(map[string]bool)
 
+	// addState records a state, remembering the first one seen for the initial state fix
+	addState := func(stateName string) {
+		if firstState == "" && stateName != "[*]" {
+			firstState = stateName
+		}
+		states[stateName] = true
+	}
+
+	// warnStateName reports an invalid state name at span, offering a rename when possible
+	warnStateName := func(stateName string, span models.Span) {
+		var fixes []models.Fix
+		if fix, ok := v.fixStateName(span, stateName); ok {
+			fixes = append(fixes, fix)
+		}
+		result.AddWarningWithSpan("INVALID_STATE_NAME", "State name should follow naming conventions", span, fixes...)
+	}
+
 	for i, line := range lines {
 		trimmedLine := strings.TrimSpace(line)
 		lineNum := i + 1
+		lead := strings.Index(line, trimmedLine) // byte offset of trimmedLine within line
 
 		// Skip empty lines and comments
 		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "'") {
@@ -384,10 +581,16 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 		// Track PlantUML boundaries
 		if strings.HasPrefix(trimmedLine, "@startuml") {
 			inPlantUML = true
+			if startTagLine == 0 {
+				startTagLine = lineNum
+			}
 			continue
 		}
 		if strings.HasPrefix(trimmedLine, "@enduml") {
 			inPlantUML = false
+			if endTagLine == 0 {
+				endTagLine = lineNum
+			}
 			continue
 		}
 
@@ -402,11 +605,12 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 			matches := initialStateRegex.FindStringSubmatch(trimmedLine)
 			if len(matches) > 1 {
 				stateName := v.extractStateName(strings.TrimSpace(matches[1]))
-				states[stateName] = true
+				addState(stateName)
 
 				// Validate state name (only the core state name, not labels)
 				if !v.isValidStateName(stateName) {
-					result.AddWarning("INVALID_STATE_NAME", "State name should follow naming conventions", lineNum, 1)
+					arrow := lead + strings.Index(trimmedLine, "-->")
+					warnStateName(stateName, lines.tokenSpan(lineNum, stateName, arrow+len("-->")))
 				}
 			}
 			continue
@@ -417,11 +621,11 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 			matches := finalStateRegex.FindStringSubmatch(trimmedLine)
 			if len(matches) > 1 {
 				stateName := v.extractStateName(strings.TrimSpace(matches[1]))
-				states[stateName] = true
+				addState(stateName)
 
 				// Validate state name (only the core state name, not labels)
 				if !v.isValidStateName(stateName) {
-					result.AddWarning("INVALID_STATE_NAME", "State name should follow naming conventions", lineNum, 1)
+					warnStateName(stateName, lines.tokenSpan(lineNum, stateName, lead))
 				}
 			}
 			continue
@@ -434,15 +638,16 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 				fromState := v.extractStateName(strings.TrimSpace(matches[1]))
 				toState := v.extractStateName(strings.TrimSpace(matches[2]))
 
-				states[fromState] = true
-				states[toState] = true
+				addState(fromState)
+				addState(toState)
 
 				// Validate state names (only the core state names, not labels)
 				if !v.isValidStateName(fromState) {
-					result.AddWarning("INVALID_STATE_NAME", "State name should follow naming conventions", lineNum, 1)
+					warnStateName(fromState, lines.tokenSpan(lineNum, fromState, lead))
 				}
 				if !v.isValidStateName(toState) {
-					result.AddWarning("INVALID_STATE_NAME", "State name should follow naming conventions", lineNum, 1)
+					arrow := lead + strings.LastIndex(trimmedLine, "-->")
+					warnStateName(toState, lines.tokenSpan(lineNum, toState, arrow+len("-->")))
 				}
 			}
 			continue
@@ -451,7 +656,7 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 		// Check for standalone state definitions
 		coreStateName := v.extractStateName(trimmedLine)
 		if v.isValidStateName(coreStateName) {
-			states[coreStateName] = true
+			addState(coreStateName)
 			continue
 		}
 
@@ -461,24 +666,28 @@ func (v *PlantUMLValidator) validateStateMachineSyntax(content string, result *m
 		}
 
 		// If we reach here, the line might contain invalid syntax
-		result.AddWarning("UNKNOWN_SYNTAX", "Line contains unrecognized PlantUML syntax", lineNum, 1)
+		result.AddWarningWithSpan("UNKNOWN_SYNTAX", "Line contains unrecognized PlantUML syntax", lines.lineSpan(lineNum))
 	}
 
 	// Validate state-machine diagram requirements - only check if we found PlantUML tags
-	var foundStartTag bool
-	for _, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "@startuml") {
-			foundStartTag = true
-			break
-		}
-	}
+	foundStartTag := startTagLine > 0
 
 	if foundStartTag && !hasInitialState {
-		result.AddWarning("NO_INITIAL_STATE", "State-machine diagram should have an initial state transition", 1, 1)
+		var fixes []models.Fix
+		if fix, ok := v.fixInitialState(lines, startTagLine, firstState); ok {
+			fixes = append(fixes, fix)
+		}
+		result.AddWarningWithSpan("NO_INITIAL_STATE", "State-machine diagram should have an initial state transition",
+			lines.lineSpan(startTagLine), fixes...)
 	}
 
 	if foundStartTag && len(states) == 0 {
-		result.AddError("NO_STATES", "State-machine diagram must contain at least one state", 1, 1)
+		bodyEnd := endTagLine
+		if bodyEnd < startTagLine {
+			bodyEnd = len(lines)
+		}
+		result.AddErrorWithSpan("NO_STATES", "State-machine diagram must contain at least one state",
+			lines.rangeSpan(startTagLine, bodyEnd))
 	}
 }
 
@@ -516,6 +725,8 @@ func (v *PlantUMLValidator) isKnownPlantUMLConstruct(line string) bool {
 		"skinparam",
 		"!define",
 		"!include",
+		"!theme",
+		"!pragma",
 		"scale",
 		"left to right direction",
 		"top to bottom direction",
@@ -552,11 +763,14 @@ func (v *PlantUMLValidator) applyStrictnessFiltering(result *models.ValidationRe
 			} else {
 				// Convert non-critical errors to warnings
 				warning := models.ValidationWarning{
-					Code:    err.Code,
-					Message: fmt.Sprintf("(Converted from error) %s", err.Message),
-					Line:    err.Line,
-					Column:  err.Column,
-					Context: err.Context,
+					Code:      err.Code,
+					Message:   fmt.Sprintf("(Converted from error) %s", err.Message),
+					Line:      err.Line,
+					Column:    err.Column,
+					EndLine:   err.EndLine,
+					EndColumn: err.EndColumn,
+					Context:   err.Context,
+					Fixes:     err.Fixes,
 				}
 				convertedWarnings = append(convertedWarnings, warning)
 			}
@@ -590,6 +804,10 @@ func (v *PlantUMLValidator) isCriticalError(code string) bool {
 		"INVALID_ORDER":   true,
 		"NO_STATES":       true,
 
+		// Multi-block structural errors
+		"UNMATCHED_END":      true,
+		"DUPLICATE_BLOCK_ID": true,
+
 		// Reference errors that break functionality
 		"SELF_REFERENCE":            true,
 		"DIRECT_CIRCULAR_REFERENCE": true,
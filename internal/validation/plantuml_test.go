@@ -482,10 +482,17 @@ func (m *MockRepository) Exists(diagramType smmodels.DiagramType, name, version
 	return m.existsMap[key], nil
 }
 
-// Implement other Repository methods as no-ops for testing
 func (m *MockRepository) ListDiagrams(diagramType smmodels.DiagramType, location models.Location) ([]models.StateMachineDiagram, error) {
-	return nil, nil
+	var diagrams []models.StateMachineDiagram
+	for _, diag := range m.diagrams {
+		if diag.DiagramType == diagramType && diag.Location == location {
+			diagrams = append(diagrams, *diag)
+		}
+	}
+	return diagrams, nil
 }
+
+// Implement other Repository methods as no-ops for testing
 func (m *MockRepository) WriteDiagram(diag *models.StateMachineDiagram) error { return nil }
 func (m *MockRepository) MoveDiagram(diagramType smmodels.DiagramType, name, version string, from, to models.Location) error {
 	return nil
@@ -997,3 +1004,462 @@ func TestPlantUMLValidator_StrictnessUnknownLevel(t *testing.T) {
 		t.Errorf("Expected 1 error for unknown strictness level, got %d", len(result.Errors))
 	}
 }
+
+func TestPlantUMLValidator_FindingSpans(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	diag := &models.StateMachineDiagram{
+		Name:    "test",
+		Version: "1.0.0",
+		Content: "@startuml\n  Idle --> Bad State\n@enduml",
+	}
+
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	var invalidName, noInitial *models.ValidationWarning
+	for i := range result.Warnings {
+		switch result.Warnings[i].Code {
+		case "INVALID_STATE_NAME":
+			invalidName = &result.Warnings[i]
+		case "NO_INITIAL_STATE":
+			noInitial = &result.Warnings[i]
+		}
+	}
+
+	if invalidName == nil {
+		t.Fatal("Expected INVALID_STATE_NAME warning")
+	}
+	wantName := models.Span{StartLine: 2, StartColumn: 12, EndLine: 2, EndColumn: 21}
+	if got := invalidName.Span(); got != wantName {
+		t.Errorf("INVALID_STATE_NAME span = %+v, want %+v", got, wantName)
+	}
+
+	if noInitial == nil {
+		t.Fatal("Expected NO_INITIAL_STATE warning")
+	}
+	wantInitial := models.Span{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 10}
+	if got := noInitial.Span(); got != wantInitial {
+		t.Errorf("NO_INITIAL_STATE span = %+v, want %+v", got, wantInitial)
+	}
+}
+
+func TestPlantUMLValidator_NoStatesSpan(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	diag := &models.StateMachineDiagram{
+		Name:    "test",
+		Version: "1.0.0",
+		Content: "' header\n@startuml\n@enduml",
+	}
+
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	for _, e := range result.Errors {
+		if e.Code == "NO_STATES" {
+			want := models.Span{StartLine: 2, StartColumn: 1, EndLine: 3, EndColumn: 8}
+			if got := e.Span(); got != want {
+				t.Errorf("NO_STATES span = %+v, want %+v", got, want)
+			}
+			return
+		}
+	}
+	t.Error("Expected NO_STATES error")
+}
+
+func TestPlantUMLValidator_ReferenceErrorSpans(t *testing.T) {
+	mockRepo := NewMockRepository()
+	validator := NewPlantUMLValidatorWithRepository(mockRepo)
+
+	diag := &models.StateMachineDiagram{
+		Name:    "test",
+		Version: "1.0.0",
+		Content: `@startuml
+[*] --> Idle
+    !include products/missing-service-1.0.0/missing-service-1.0.0.puml
+@enduml`,
+	}
+
+	result, err := validator.ResolveFileReferences(diag)
+	if err != nil {
+		t.Fatalf("ResolveFileReferences() error = %v", err)
+	}
+
+	if len(result.Errors) != 1 {
+		t.Fatalf("Expected 1 error, got %d", len(result.Errors))
+	}
+	want := models.Span{StartLine: 3, StartColumn: 5, EndLine: 3, EndColumn: 71}
+	if got := result.Errors[0].Span(); got != want {
+		t.Errorf("PRODUCT_REFERENCE_NOT_FOUND span = %+v, want %+v", got, want)
+	}
+}
+
+func TestPlantUMLValidator_CircularReferenceSpan(t *testing.T) {
+	mockRepo := NewMockRepository()
+	validator := NewPlantUMLValidatorWithRepository(mockRepo)
+
+	diagA := &models.StateMachineDiagram{
+		Name:     "service-a",
+		Version:  "1.0.0",
+		Location: models.LocationFileProducts,
+		Content: `@startuml
+[*] --> StateA
+!include products/service-b-1.0.0/service-b-1.0.0.puml
+@enduml`,
+	}
+	diagB := &models.StateMachineDiagram{
+		Name:     "service-b",
+		Version:  "1.0.0",
+		Location: models.LocationFileProducts,
+		Content: `@startuml
+!include products/service-a-1.0.0/service-a-1.0.0.puml
+[*] --> StateB
+@enduml`,
+	}
+	mockRepo.AddStateMachine(diagA)
+	mockRepo.AddStateMachine(diagB)
+
+	result, err := validator.ResolveFileReferences(diagA)
+	if err != nil {
+		t.Fatalf("ResolveFileReferences() error = %v", err)
+	}
+
+	found := false
+	for _, e := range result.Errors {
+		if e.Code == "CIRCULAR_REFERENCE" || e.Code == "DIRECT_CIRCULAR_REFERENCE" {
+			found = true
+			if e.Line != 3 {
+				t.Errorf("%s line = %d, want 3", e.Code, e.Line)
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected circular reference error")
+	}
+}
+
+func TestPlantUMLValidator_MultipleBlocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		allow      bool
+		content    string
+		wantErrors map[string]string // code -> block id ("" when not block-scoped)
+		wantValid  bool
+	}{
+		{
+			name:       "duplicate start rejected by default",
+			allow:      false,
+			content:    "@startuml a\n[*] --> A\n@enduml\n@startuml b\n[*] --> B\n@enduml",
+			wantErrors: map[string]string{"DUPLICATE_START": "", "DUPLICATE_END": ""},
+			wantValid:  false,
+		},
+		{
+			name:       "independent valid blocks",
+			allow:      true,
+			content:    "@startuml a\n[*] --> A\n@enduml\n\n@startuml b\n[*] --> B\n@enduml",
+			wantErrors: map[string]string{},
+			wantValid:  true,
+		},
+		{
+			name:       "unterminated block reported for its own block",
+			allow:      true,
+			content:    "@startuml a\n[*] --> A\n@startuml b\n[*] --> B\n@enduml",
+			wantErrors: map[string]string{"MISSING_END": "a"},
+			wantValid:  false,
+		},
+		{
+			name:       "duplicate block id",
+			allow:      true,
+			content:    "@startuml a\n[*] --> A\n@enduml\n@startuml a\n[*] --> B\n@enduml",
+			wantErrors: map[string]string{"DUPLICATE_BLOCK_ID": "a"},
+			wantValid:  false,
+		},
+		{
+			name:       "stray enduml",
+			allow:      true,
+			content:    "@startuml\n[*] --> A\n@enduml\n@enduml",
+			wantErrors: map[string]string{"UNMATCHED_END": ""},
+			wantValid:  false,
+		},
+		{
+			name:       "no blocks falls back to whole-file checks",
+			allow:      true,
+			content:    "[*] --> A",
+			wantErrors: map[string]string{"MISSING_START": "", "MISSING_END": ""},
+			wantValid:  false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validator := NewPlantUMLValidator().WithMultipleBlocks(tt.allow)
+			diag := &models.StateMachineDiagram{Name: "multi", Version: "1.0.0", Content: tt.content}
+
+			result, err := validator.Validate(diag, models.StrictnessInProgress)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if result.IsValid != tt.wantValid {
+				t.Errorf("IsValid = %v, want %v (errors: %+v)", result.IsValid, tt.wantValid, result.Errors)
+			}
+			if len(result.Errors) != len(tt.wantErrors) {
+				t.Fatalf("Errors = %+v, want codes %v", result.Errors, tt.wantErrors)
+			}
+			for _, e := range result.Errors {
+				wantBlock, ok := tt.wantErrors[e.Code]
+				if !ok {
+					t.Errorf("Unexpected error %s", e.Code)
+					continue
+				}
+				gotBlock, _ := e.Context["block"].(string)
+				if gotBlock != wantBlock {
+					t.Errorf("Error %s block = %q, want %q", e.Code, gotBlock, wantBlock)
+				}
+			}
+		})
+	}
+}
+
+func TestPlantUMLValidator_MultipleBlocksFileLines(t *testing.T) {
+	validator := NewPlantUMLValidator().WithMultipleBlocks(true)
+	diag := &models.StateMachineDiagram{
+		Name:    "multi",
+		Version: "1.0.0",
+		Content: "@startuml a\n[*] --> A\n@enduml\n@startuml b\nB\n@enduml",
+	}
+
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(result.Warnings) != 1 || result.Warnings[0].Code != "NO_INITIAL_STATE" {
+		t.Fatalf("Warnings = %+v, want one NO_INITIAL_STATE", result.Warnings)
+	}
+
+	warning := result.Warnings[0]
+	if warning.Line != 4 || warning.Context["block"] != "b" {
+		t.Errorf("Warning at line %d in block %v, want line 4 in block b", warning.Line, warning.Context["block"])
+	}
+
+	// The attached fix must apply to the whole file
+	fixed, err := models.ApplyFixes(diag.Content, warning.Fixes)
+	if err != nil {
+		t.Fatalf("ApplyFixes() error = %v", err)
+	}
+	want := "@startuml a\n[*] --> A\n@enduml\n@startuml b\n[*] --> B\nB\n@enduml"
+	if fixed != want {
+		t.Errorf("ApplyFixes() = %q, want %q", fixed, want)
+	}
+}
+
+func TestPlantUMLValidator_Preprocessor(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	content := `@startuml
+!$initial = "Idle"
+!procedure $edge($from, $to)
+$from --> $to
+!endprocedure
+!if %true()
+[*] --> $initial
+!else
+this would be unknown syntax
+!endif
+!foreach $s in ["Active", "Done"]
+$edge($initial, $s)
+!endfor
+@enduml`
+
+	diag := &models.StateMachineDiagram{Name: "pre", Version: "1.0.0", Content: content}
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(result.Errors) != 0 || len(result.Warnings) != 0 {
+		t.Errorf("Expected no findings, got errors %+v warnings %+v", result.Errors, result.Warnings)
+	}
+}
+
+func TestPlantUMLValidator_PreprocessorSourceMap(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	content := "@startuml\n" + // 1
+		"!$bad = \"Bad State\"\n" + // 2
+		"!procedure $edge($to)\n" + // 3
+		"Idle --> $to\n" + // 4
+		"!endprocedure\n" + // 5
+		"[*] --> Idle\n" + // 6
+		"$edge($bad)\n" + // 7
+		"Idle --> Also Bad\n" + // 8
+		"@enduml" // 9
+
+	diag := &models.StateMachineDiagram{Name: "pre", Version: "1.0.0", Content: content}
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if len(result.Warnings) != 2 {
+		t.Fatalf("Warnings = %+v, want 2 INVALID_STATE_NAME", result.Warnings)
+	}
+
+	// Expanded line: whole call line, fix dropped
+	expanded := result.Warnings[0]
+	wantExpanded := models.Span{StartLine: 7, StartColumn: 1, EndLine: 7, EndColumn: 12}
+	if expanded.Span() != wantExpanded {
+		t.Errorf("Expanded warning span = %+v, want %+v", expanded.Span(), wantExpanded)
+	}
+	if len(expanded.Fixes) != 0 {
+		t.Errorf("Expanded warning should not carry fixes, got %+v", expanded.Fixes)
+	}
+
+	// Verbatim line: exact token span and fix on the original line
+	verbatim := result.Warnings[1]
+	wantVerbatim := models.Span{StartLine: 8, StartColumn: 10, EndLine: 8, EndColumn: 18}
+	if verbatim.Span() != wantVerbatim {
+		t.Errorf("Verbatim warning span = %+v, want %+v", verbatim.Span(), wantVerbatim)
+	}
+	if len(verbatim.Fixes) != 1 || verbatim.Fixes[0].Edits[0].Span != wantVerbatim {
+		t.Errorf("Verbatim warning fixes = %+v", verbatim.Fixes)
+	}
+}
+
+func TestPlantUMLValidator_PreprocessorDiagnostics(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	diag := &models.StateMachineDiagram{
+		Name:    "pre",
+		Version: "1.0.0",
+		Content: "@startuml\n!if $undefined == 1\n[*] --> Idle\n@enduml",
+	}
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	codes := make(map[string]int)
+	for _, e := range result.Errors {
+		codes[e.Code] = e.Line
+	}
+	if line, ok := codes["UNTERMINATED_DIRECTIVE"]; !ok || line != 2 {
+		t.Errorf("Expected UNTERMINATED_DIRECTIVE on line 2, got %+v", result.Errors)
+	}
+	if line, ok := codes["PREPROCESSOR_ERROR"]; !ok || line != 2 {
+		t.Errorf("Expected PREPROCESSOR_ERROR on line 2, got %+v", result.Errors)
+	}
+	if result.IsValid {
+		t.Error("Result should be invalid")
+	}
+}
+
+func TestPlantUMLValidator_ResolveFileReferences_VersionRange(t *testing.T) {
+	mockRepo := NewMockRepository()
+	for _, version := range []string{"1.2.0", "1.4.1", "1.10.0", "2.0.0"} {
+		mockRepo.AddStateMachine(&models.StateMachineDiagram{
+			Name:     "auth-service",
+			Version:  version,
+			Location: models.LocationFileProducts,
+			Content:  "@startuml\n[*] --> AuthIdle\n@enduml",
+		})
+	}
+	validator := NewPlantUMLValidatorWithRepository(mockRepo)
+
+	tests := []struct {
+		name        string
+		version     string
+		wantVersion string
+		wantError   string
+	}{
+		{name: "caret range", version: "^1.2", wantVersion: "1.10.0"},
+		{name: "tilde range", version: "~1.4.0", wantVersion: "1.4.1"},
+		{name: "wildcard", version: "2.x", wantVersion: "2.0.0"},
+		{name: "unsatisfied", version: "^3.0", wantError: "UNSATISFIED_VERSION_CONSTRAINT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag := &models.StateMachineDiagram{
+				Name:    "test",
+				Version: "1.0.0",
+				Content: fmt.Sprintf("@startuml\n!include products/auth-service-%[1]s/auth-service-%[1]s.puml\n[*] --> Idle\n@enduml", tt.version),
+			}
+
+			result, err := validator.ResolveFileReferences(diag)
+			if err != nil {
+				t.Fatalf("ResolveFileReferences() error = %v", err)
+			}
+			if len(diag.References) != 1 {
+				t.Fatalf("Expected 1 reference, got %d", len(diag.References))
+			}
+			ref := diag.References[0]
+			if ref.Constraint != tt.version {
+				t.Errorf("Constraint = %q, want %q", ref.Constraint, tt.version)
+			}
+
+			if tt.wantError != "" {
+				if len(result.Errors) != 1 || result.Errors[0].Code != tt.wantError || result.Errors[0].Line != 2 {
+					t.Errorf("Errors = %+v, want %s on line 2", result.Errors, tt.wantError)
+				}
+				return
+			}
+			if result.HasErrors() {
+				t.Errorf("Unexpected errors: %+v", result.Errors)
+			}
+			if ref.Version != tt.wantVersion {
+				t.Errorf("Resolved version = %q, want %q", ref.Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestPlantUMLValidator_ValidateReferences_VersionRange(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	tests := []struct {
+		name      string
+		include   string
+		wantError string
+		wantWarn  string
+	}{
+		{name: "valid range", include: "products/auth-^1.2/auth-^1.2.puml"},
+		{name: "invalid range", include: "products/auth-^1.x.y/auth-^1.x.y.puml", wantError: "INVALID_VERSION_CONSTRAINT"},
+		{name: "mismatched path", include: "products/auth-^1.2/auth-^1.3.puml", wantWarn: "INCORRECT_REFERENCE_PATH"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diag := &models.StateMachineDiagram{
+				Name:    "test",
+				Version: "1.0.0",
+				Content: "@startuml\n!include " + tt.include + "\n[*] --> Idle\n@enduml",
+			}
+
+			result, err := validator.ValidateReferences(diag)
+			if err != nil {
+				t.Fatalf("ValidateReferences() error = %v", err)
+			}
+
+			var errs, warns []string
+			for _, e := range result.Errors {
+				errs = append(errs, e.Code)
+			}
+			for _, w := range result.Warnings {
+				warns = append(warns, w.Code)
+			}
+			if (tt.wantError == "") != (len(errs) == 0) || (tt.wantError != "" && errs[0] != tt.wantError) {
+				t.Errorf("Errors = %v, want %q", errs, tt.wantError)
+			}
+			if (tt.wantWarn == "") != (len(warns) == 0) || (tt.wantWarn != "" && warns[0] != tt.wantWarn) {
+				t.Errorf("Warnings = %v, want %q", warns, tt.wantWarn)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package validation
+
+import (
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/preprocessor"
+)
+
+// addPreprocessorDiagnostics reports problems found while expanding directives
+func (v *PlantUMLValidator) addPreprocessorDiagnostics(result *models.ValidationResult, pre *preprocessor.Result, original string) {
+	lines := newSourceLines(original)
+	for _, d := range pre.Diagnostics {
+		result.AddErrorWithSpan(d.Code, d.Message, lines.lineSpan(d.Line))
+	}
+}
@@ -0,0 +1,81 @@
+package validation
+
+import "sort"
+
+// Rule describes a validation finding code produced by the PlantUML validator
+type Rule struct {
+	Code            string
+	Name            string
+	Description     string
+	DefaultSeverity string // "error" or "warning"
+}
+
+// rules is the catalog of codes the PlantUMLValidator can report
+var rules = map[string]Rule{
+	// PlantUML structure
+	"MISSING_START":   {Name: "MissingStart", Description: "The file has no @startuml tag.", DefaultSeverity: "error"},
+	"MISSING_END":     {Name: "MissingEnd", Description: "The file has no @enduml tag.", DefaultSeverity: "error"},
+	"DUPLICATE_START": {Name: "DuplicateStart", Description: "The file has more than one @startuml tag.", DefaultSeverity: "error"},
+	"DUPLICATE_END":   {Name: "DuplicateEnd", Description: "The file has more than one @enduml tag.", DefaultSeverity: "error"},
+	"INVALID_ORDER":   {Name: "InvalidOrder", Description: "@startuml must come before @enduml.", DefaultSeverity: "error"},
+
+	// Multi-block files
+	"UNMATCHED_END":      {Name: "UnmatchedEnd", Description: "An @enduml tag does not close a @startuml block.", DefaultSeverity: "error"},
+	"DUPLICATE_BLOCK_ID": {Name: "DuplicateBlockID", Description: "Two @startuml blocks in the file share the same id.", DefaultSeverity: "error"},
+
+	// State-machine syntax
+	"NO_STATES":          {Name: "NoStates", Description: "The state-machine diagram does not contain any states.", DefaultSeverity: "error"},
+	"NO_INITIAL_STATE":   {Name: "NoInitialState", Description: "The state-machine diagram has no [*] --> initial transition.", DefaultSeverity: "warning"},
+	"INVALID_STATE_NAME": {Name: "InvalidStateName", Description: "State names must start with a letter or underscore and contain only letters, digits, underscores or hyphens.", DefaultSeverity: "warning"},
+	"UNKNOWN_SYNTAX":     {Name: "UnknownSyntax", Description: "The line contains unrecognized PlantUML syntax.", DefaultSeverity: "warning"},
+
+	// Preprocessor
+	"PREPROCESSOR_ERROR":     {Name: "PreprocessorError", Description: "A preprocessor directive or expression could not be evaluated.", DefaultSeverity: "error"},
+	"UNTERMINATED_DIRECTIVE": {Name: "UnterminatedDirective", Description: "A preprocessor block such as !if or !foreach is missing its closing directive.", DefaultSeverity: "error"},
+	"UNMATCHED_DIRECTIVE":    {Name: "UnmatchedDirective", Description: "A closing preprocessor directive such as !endif has no opening directive.", DefaultSeverity: "error"},
+
+	// References
+	"REFERENCE_PARSE_ERROR":          {Name: "ReferenceParseError", Description: "References could not be parsed from the content.", DefaultSeverity: "error"},
+	"INVALID_REFERENCE_NAME":         {Name: "InvalidReferenceName", Description: "The referenced state-machine diagram name is invalid.", DefaultSeverity: "error"},
+	"UNKNOWN_REFERENCE_TYPE":         {Name: "UnknownReferenceType", Description: "The reference type is not supported.", DefaultSeverity: "error"},
+	"MISSING_REFERENCE_VERSION":      {Name: "MissingReferenceVersion", Description: "Product references must include a version.", DefaultSeverity: "error"},
+	"INVALID_REFERENCE_VERSION":      {Name: "InvalidReferenceVersion", Description: "The referenced version is not a valid semantic version.", DefaultSeverity: "error"},
+	"INVALID_VERSION_CONSTRAINT":     {Name: "InvalidVersionConstraint", Description: "The referenced version range is not a valid semantic version constraint.", DefaultSeverity: "error"},
+	"SELF_REFERENCE":                 {Name: "SelfReference", Description: "A state-machine diagram cannot reference itself.", DefaultSeverity: "error"},
+	"INCORRECT_REFERENCE_PATH":       {Name: "IncorrectReferencePath", Description: "The include path does not match the products/{name}-{version}/{name}-{version}.puml convention.", DefaultSeverity: "warning"},
+	"NO_REPOSITORY":                  {Name: "NoRepository", Description: "References cannot be resolved without a repository.", DefaultSeverity: "warning"},
+	"REFERENCE_CHECK_ERROR":          {Name: "ReferenceCheckError", Description: "The existence of a referenced state-machine diagram could not be checked.", DefaultSeverity: "warning"},
+	"PRODUCT_REFERENCE_NOT_FOUND":    {Name: "ProductReferenceNotFound", Description: "The referenced product state-machine diagram does not exist.", DefaultSeverity: "error"},
+	"UNSATISFIED_VERSION_CONSTRAINT": {Name: "UnsatisfiedVersionConstraint", Description: "No product version satisfies the referenced version range.", DefaultSeverity: "error"},
+	"REFERENCE_READ_ERROR":           {Name: "ReferenceReadError", Description: "The referenced state-machine diagram exists but cannot be read.", DefaultSeverity: "warning"},
+	"CIRCULAR_REFERENCE":             {Name: "CircularReference", Description: "The reference chain loops back to a diagram already being included.", DefaultSeverity: "error"},
+	"DIRECT_CIRCULAR_REFERENCE":      {Name: "DirectCircularReference", Description: "A referenced diagram includes the referencing diagram directly.", DefaultSeverity: "error"},
+
+	// Include expansion
+	"INCLUDE_DEPTH_EXCEEDED": {Name: "IncludeDepthExceeded", Description: "Nested includes exceed the configured maximum include depth.", DefaultSeverity: "error"},
+	"INCLUDE_PART_NOT_FOUND": {Name: "IncludePartNotFound", Description: "The block or sub-part named by an include does not exist in the referenced diagram.", DefaultSeverity: "error"},
+}
+
+// LookupRule returns the rule for a finding code
+func LookupRule(code string) (Rule, bool) {
+	rule, ok := rules[code]
+	if !ok {
+		return Rule{}, false
+	}
+	rule.Code = code
+	return rule, true
+}
+
+// Rules returns every known rule sorted by code
+func Rules() []Rule {
+	result := make([]Rule, 0, len(rules))
+	for code := range rules {
+		rule, _ := LookupRule(code)
+		result = append(result, rule)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Code < result[j].Code
+	})
+	return result
+}
@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestLookupRule(t *testing.T) {
+	rule, ok := LookupRule("MISSING_END")
+	if !ok {
+		t.Fatal("LookupRule(MISSING_END) should find a rule")
+	}
+	if rule.Code != "MISSING_END" || rule.DefaultSeverity != "error" || rule.Description == "" {
+		t.Errorf("LookupRule(MISSING_END) = %+v", rule)
+	}
+
+	if _, ok := LookupRule("NOT_A_RULE"); ok {
+		t.Error("LookupRule(NOT_A_RULE) should not find a rule")
+	}
+}
+
+func TestRules_Sorted(t *testing.T) {
+	all := Rules()
+	if len(all) == 0 {
+		t.Fatal("Rules() should not be empty")
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i-1].Code >= all[i].Code {
+			t.Errorf("Rules() not sorted: %s before %s", all[i-1].Code, all[i].Code)
+		}
+	}
+}
+
+func TestRules_CoverValidatorFindings(t *testing.T) {
+	validator := NewPlantUMLValidator()
+
+	diag := &models.StateMachineDiagram{
+		Name:    "test",
+		Version: "1.0.0",
+		Content: "Bad State --> ???\n@enduml\n@enduml",
+	}
+
+	result, err := validator.Validate(diag, models.StrictnessInProgress)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	for _, e := range result.Errors {
+		if _, ok := LookupRule(e.Code); !ok {
+			t.Errorf("No rule registered for error code %s", e.Code)
+		}
+	}
+	for _, w := range result.Warnings {
+		if _, ok := LookupRule(w.Code); !ok {
+			t.Errorf("No rule registered for warning code %s", w.Code)
+		}
+	}
+}
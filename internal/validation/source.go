@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// sourceLines provides span calculations over the lines of PlantUML content
+type sourceLines []string
+
+// newSourceLines splits content into lines, dropping carriage returns so that
+// columns are the same for LF and CRLF files
+func newSourceLines(content string) sourceLines {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return sourceLines(lines)
+}
+
+// text returns the raw text of a 1-based line, or "" when out of range
+func (s sourceLines) text(line int) string {
+	if line < 1 || line > len(s) {
+		return ""
+	}
+	return s[line-1]
+}
+
+// column converts a byte offset within a line to a 1-based character column
+func (s sourceLines) column(line, offset int) int {
+	return utf8.RuneCountInString(s.text(line)[:offset]) + 1
+}
+
+// lineSpan returns the span of a line excluding leading and trailing whitespace
+func (s sourceLines) lineSpan(line int) models.Span {
+	text := s.text(line)
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return models.PointSpan(line, 1)
+	}
+
+	start := strings.Index(text, trimmed)
+	return models.Span{
+		StartLine:   line,
+		StartColumn: s.column(line, start),
+		EndLine:     line,
+		EndColumn:   s.column(line, start+len(trimmed)),
+	}
+}
+
+// tokenSpan returns the span of the first occurrence of token at or after the
+// byte offset from. It falls back to the whole line when the token is not found.
+func (s sourceLines) tokenSpan(line int, token string, from int) models.Span {
+	text := s.text(line)
+	if token == "" || from > len(text) {
+		return s.lineSpan(line)
+	}
+
+	idx := strings.Index(text[from:], token)
+	if idx == -1 {
+		return s.lineSpan(line)
+	}
+
+	start := from + idx
+	return models.Span{
+		StartLine:   line,
+		StartColumn: s.column(line, start),
+		EndLine:     line,
+		EndColumn:   s.column(line, start+len(token)),
+	}
+}
+
+// rangeSpan returns a span from the first non-blank character of startLine to
+// the last non-blank character of endLine
+func (s sourceLines) rangeSpan(startLine, endLine int) models.Span {
+	start := s.lineSpan(startLine)
+	end := s.lineSpan(endLine)
+	return models.Span{
+		StartLine:   start.StartLine,
+		StartColumn: start.StartColumn,
+		EndLine:     end.EndLine,
+		EndColumn:   end.EndColumn,
+	}
+}
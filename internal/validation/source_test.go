@@ -0,0 +1,67 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+func TestSourceLines_LineSpan(t *testing.T) {
+	lines := newSourceLines("@startuml\r\n   Idle --> Active  \n\n@enduml")
+
+	tests := []struct {
+		name string
+		line int
+		want models.Span
+	}{
+		{"crlf line", 1, models.Span{StartLine: 1, StartColumn: 1, EndLine: 1, EndColumn: 10}},
+		{"indented line", 2, models.Span{StartLine: 2, StartColumn: 4, EndLine: 2, EndColumn: 19}},
+		{"blank line", 3, models.PointSpan(3, 1)},
+		{"out of range", 10, models.PointSpan(10, 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lines.lineSpan(tt.line); got != tt.want {
+				t.Errorf("lineSpan(%d) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSourceLines_TokenSpan(t *testing.T) {
+	lines := newSourceLines("  Idle --> Idle : tick")
+
+	got := lines.tokenSpan(1, "Idle", 8)
+	want := models.Span{StartLine: 1, StartColumn: 12, EndLine: 1, EndColumn: 16}
+	if got != want {
+		t.Errorf("tokenSpan() = %+v, want %+v", got, want)
+	}
+
+	// Missing tokens fall back to the whole line
+	got = lines.tokenSpan(1, "Missing", 0)
+	want = lines.lineSpan(1)
+	if got != want {
+		t.Errorf("tokenSpan() fallback = %+v, want %+v", got, want)
+	}
+}
+
+func TestSourceLines_MultiByteColumns(t *testing.T) {
+	lines := newSourceLines("État --> Prêt")
+
+	got := lines.tokenSpan(1, "Prêt", 0)
+	want := models.Span{StartLine: 1, StartColumn: 10, EndLine: 1, EndColumn: 14}
+	if got != want {
+		t.Errorf("tokenSpan() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSourceLines_RangeSpan(t *testing.T) {
+	lines := newSourceLines("@startuml\n[*] --> Idle\n  @enduml")
+
+	got := lines.rangeSpan(1, 3)
+	want := models.Span{StartLine: 1, StartColumn: 1, EndLine: 3, EndColumn: 10}
+	if got != want {
+		t.Errorf("rangeSpan() = %+v, want %+v", got, want)
+	}
+}
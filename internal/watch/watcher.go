@@ -0,0 +1,367 @@
+// Package watch polls the repository root directory for state-machine diagram
+// files created, modified, deleted or moved by tools outside this module.
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/logging"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+const (
+	// DefaultInterval is how often the tree is scanned when Options.Interval is zero
+	DefaultInterval = time.Second
+
+	// DefaultDebounce is how long a file must stay unchanged when Options.Debounce is zero
+	DefaultDebounce = 500 * time.Millisecond
+
+	// eventBuffer is the capacity of the channel returned by Events
+	eventBuffer = 64
+)
+
+// EventType identifies what happened to a diagram file
+type EventType string
+
+const (
+	EventCreated  EventType = "created"
+	EventModified EventType = "modified"
+	EventDeleted  EventType = "deleted"
+	EventMoved    EventType = "moved"
+)
+
+// Event describes one settled change to a diagram file
+type Event struct {
+	Type         EventType
+	DiagramType  smmodels.DiagramType
+	Name         string
+	Version      string
+	Location     models.Location
+	Path         string
+	FromName     string          // set for moves; the name before the move
+	FromVersion  string          // set for moves; the version before the move
+	FromLocation models.Location // set for moves; the location before the move
+	FromPath     string          // set for moves; the path before the move
+	Size         int64           // zero for deletions
+	ModTime      time.Time       // zero for deletions
+}
+
+// Options controls what a Watcher scans and how often
+type Options struct {
+	Interval     time.Duration          // time between scans; zero uses DefaultInterval
+	Debounce     time.Duration          // quiet time before a change is delivered; zero uses DefaultDebounce, negative delivers on the next scan
+	DiagramTypes []smmodels.DiagramType // empty watches PlantUML diagrams
+	Locations    []models.Location      // empty watches in-progress and products
+}
+
+// Watcher detects changes to diagram files by comparing periodic scans of the
+// location directories. It needs no platform notification support, so it also
+// works on network and container file systems.
+type Watcher struct {
+	pathManager *models.PathManager
+	options     Options
+	logger      *logging.Logger
+
+	// readDir lists a location directory; tests replace it to inject failures
+	readDir func(name string) ([]os.DirEntry, error)
+}
+
+// NewWatcher creates a watcher for the root directory of config
+func NewWatcher(config *models.Config, options Options) *Watcher {
+	if config == nil {
+		config = models.DefaultConfig()
+	}
+	if options.Interval <= 0 {
+		options.Interval = DefaultInterval
+	}
+	if options.Debounce == 0 {
+		options.Debounce = DefaultDebounce
+	} else if options.Debounce < 0 {
+		options.Debounce = 0
+	}
+	if len(options.DiagramTypes) == 0 {
+		options.DiagramTypes = []smmodels.DiagramType{smmodels.DiagramTypePUML}
+	}
+	if len(options.Locations) == 0 {
+		options.Locations = []models.Location{models.LocationFileInProgress, models.LocationFileProducts}
+	}
+
+	loggerConfig := &logging.LoggerConfig{
+		Level:        logging.LogLevelInfo,
+		Prefix:       "[Watcher]",
+		EnableCaller: true,
+	}
+	if config.EnableDebugLogging {
+		loggerConfig.Level = logging.LogLevelDebug
+	}
+	logger, err := logging.NewLogger(loggerConfig)
+	if err != nil {
+		logger = logging.NewDefaultLogger()
+		logger.Warn("Failed to create watcher logger, using default")
+	}
+
+	return &Watcher{
+		pathManager: models.NewPathManager(config.RootDirectory),
+		options:     options,
+		logger:      logger,
+		readDir:     os.ReadDir,
+	}
+}
+
+// Run scans the tree every interval and calls handler for each settled change
+// until ctx is done. Files present when Run starts do not produce events.
+// Handler calls are made one at a time from the calling goroutine.
+func (w *Watcher) Run(ctx context.Context, handler func(Event)) error {
+	if handler == nil {
+		return models.NewStateMachineError(models.ErrorTypeValidation, "event handler cannot be nil", nil).
+			WithComponent("watcher").
+			WithOperation("Run")
+	}
+
+	p := newPoller(w)
+	ticker := time.NewTicker(w.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			for _, event := range p.poll(now) {
+				if ctx.Err() != nil {
+					return nil
+				}
+				handler(event)
+			}
+		}
+	}
+}
+
+// Events starts watching in the background and returns a channel carrying
+// each settled change. The channel is closed once ctx is done.
+func (w *Watcher) Events(ctx context.Context) <-chan Event {
+	events := make(chan Event, eventBuffer)
+	go func() {
+		defer close(events)
+		w.Run(ctx, func(event Event) {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	return events
+}
+
+// fileKey identifies a diagram file within the watched tree
+type fileKey struct {
+	diagramType smmodels.DiagramType
+	location    models.Location
+	fileName    string
+}
+
+// fileState is what a scan records about a diagram file
+type fileState struct {
+	name    string
+	version string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// equal reports whether two scans saw the same file content
+func (s fileState) equal(other fileState) bool {
+	return s.size == other.size && s.modTime.Equal(other.modTime)
+}
+
+// poller holds the scans of one Run
+type poller struct {
+	watcher *Watcher
+
+	// reported is the tree as last delivered to the handler, current the latest scan
+	reported map[fileKey]fileState
+	current  map[fileKey]fileState
+
+	// changedAt is when the scanned state of a key last changed, for keys not yet delivered
+	changedAt map[fileKey]time.Time
+}
+
+// newPoller takes the baseline scan changes are reported against
+func newPoller(w *Watcher) *poller {
+	current := w.scan(nil)
+	reported := make(map[fileKey]fileState, len(current))
+	for key, state := range current {
+		reported[key] = state
+	}
+	return &poller{
+		watcher:   w,
+		reported:  reported,
+		current:   current,
+		changedAt: make(map[fileKey]time.Time),
+	}
+}
+
+// poll rescans the tree and returns the changes that have been quiet for the debounce time
+func (p *poller) poll(now time.Time) []Event {
+	next := p.watcher.scan(p.current)
+	for key, state := range next {
+		if previous, ok := p.current[key]; !ok || !previous.equal(state) {
+			p.changedAt[key] = now
+		}
+	}
+	for key := range p.current {
+		if _, ok := next[key]; !ok {
+			p.changedAt[key] = now
+		}
+	}
+	p.current = next
+
+	// Settled keys are compared with what was last reported, so a file created
+	// and removed again within the debounce time produces no event at all
+	var created, deleted, events []Event
+	for key, changed := range p.changedAt {
+		if now.Sub(changed) < p.watcher.options.Debounce {
+			continue
+		}
+		delete(p.changedAt, key)
+
+		before, existed := p.reported[key]
+		after, exists := p.current[key]
+		switch {
+		case existed && exists && !before.equal(after):
+			events = append(events, newEvent(EventModified, key, after))
+		case !existed && exists:
+			created = append(created, newEvent(EventCreated, key, after))
+		case existed && !exists:
+			deleted = append(deleted, newEvent(EventDeleted, key, before))
+		}
+		if exists {
+			p.reported[key] = after
+		} else {
+			delete(p.reported, key)
+		}
+	}
+
+	byPath := func(events []Event) {
+		sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	}
+	byPath(created)
+	byPath(deleted)
+	events = append(events, pairMoves(created, deleted)...)
+	byPath(events)
+	return events
+}
+
+// newEvent builds an event for a file as it was last scanned. Deletions keep
+// the last size and modification time until pairMoves has matched them.
+func newEvent(eventType EventType, key fileKey, state fileState) Event {
+	return Event{
+		Type:        eventType,
+		DiagramType: key.diagramType,
+		Name:        state.name,
+		Version:     state.version,
+		Location:    key.location,
+		Path:        state.path,
+		Size:        state.size,
+		ModTime:     state.modTime,
+	}
+}
+
+// pairMoves turns a deletion and a creation of the same file into a move.
+// A file counts as the same when it keeps its name and version in another
+// location, or, for renames, when size and modification time match exactly,
+// which a rename preserves on every common file system.
+func pairMoves(created, deleted []Event) []Event {
+	var events []Event
+	used := make([]bool, len(created))
+
+	for _, gone := range deleted {
+		match := -1
+		for i, event := range created {
+			if used[i] || event.DiagramType != gone.DiagramType {
+				continue
+			}
+			if event.Name == gone.Name && event.Version == gone.Version && event.Location != gone.Location {
+				match = i
+				break
+			}
+			if match < 0 && event.Size == gone.Size && event.ModTime.Equal(gone.ModTime) {
+				match = i
+			}
+		}
+		if match < 0 {
+			gone.Size, gone.ModTime = 0, time.Time{}
+			events = append(events, gone)
+			continue
+		}
+
+		used[match] = true
+		moved := created[match]
+		moved.Type = EventMoved
+		moved.FromName, moved.FromVersion = gone.Name, gone.Version
+		moved.FromLocation, moved.FromPath = gone.Location, gone.Path
+		events = append(events, moved)
+	}
+
+	for i, event := range created {
+		if !used[i] {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// scan records every diagram file in the watched locations. A directory that
+// cannot be read keeps its files from previous and is logged, so a transient
+// error is not reported as every file in it being deleted.
+func (w *Watcher) scan(previous map[fileKey]fileState) map[fileKey]fileState {
+	files := make(map[fileKey]fileState)
+	for _, diagramType := range w.options.DiagramTypes {
+		for _, location := range w.options.Locations {
+			dir := w.pathManager.GetLocationWithDiagramTypePath(location, diagramType)
+			entries, err := w.readDir(dir)
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				w.logger.WithField("directory", dir).WithError(err).Warn("Failed to scan directory")
+				for key, state := range previous {
+					if key.diagramType == diagramType && key.location == location {
+						files[key] = state
+					}
+				}
+				continue
+			}
+
+			for _, entry := range entries {
+				fileName := entry.Name()
+				// Hidden files include the temporary files of atomic writes
+				if entry.IsDir() || strings.HasPrefix(fileName, ".") {
+					continue
+				}
+				pathInfo, err := w.pathManager.ParseFileName(diagramType, fileName)
+				if err != nil {
+					continue
+				}
+				info, err := entry.Info()
+				if err != nil {
+					// Removed between listing and stat; the next scan reports it
+					continue
+				}
+				files[fileKey{diagramType: diagramType, location: location, fileName: fileName}] = fileState{
+					name:    pathInfo.Name,
+					version: pathInfo.Version,
+					path:    filepath.Join(dir, fileName),
+					size:    info.Size(),
+					modTime: info.ModTime(),
+				}
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,175 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	smmodels "github.com/kengibson1111/go-uml-statemachine-models/models"
+	"github.com/kengibson1111/go-uml-statemachine-parsers/internal/models"
+)
+
+// writeDiagram creates or overwrites a diagram file below root
+func writeDiagram(t *testing.T, root, rel, content string) string {
+	t.Helper()
+	path := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestWatcher returns a watcher over a temporary root directory
+func newTestWatcher(t *testing.T, debounce time.Duration) (*Watcher, string) {
+	t.Helper()
+	root := t.TempDir()
+	config := models.DefaultConfig()
+	config.RootDirectory = root
+	return NewWatcher(config, Options{Interval: 10 * time.Millisecond, Debounce: debounce}), root
+}
+
+func TestPoller_Events(t *testing.T) {
+	w, root := newTestWatcher(t, 100*time.Millisecond)
+	existing := writeDiagram(t, root, "in-progress/puml/door-1.0.0.puml", "@startuml\n@enduml")
+	p := newPoller(w)
+	start := time.Now()
+
+	if events := p.poll(start); len(events) != 0 {
+		t.Fatalf("poll() of an unchanged tree = %+v, want no events", events)
+	}
+
+	// A new file is held back until it has been quiet for the debounce time
+	created := writeDiagram(t, root, "in-progress/puml/gate-1.0.0.puml", "@startuml\n@enduml")
+	writeDiagram(t, root, "in-progress/puml/gate-1.0.0.meta.json", `{"author":"jane"}`)
+	writeDiagram(t, root, "in-progress/puml/.gate-1.0.0.puml.tmp-1", "partial")
+	if events := p.poll(start.Add(10 * time.Millisecond)); len(events) != 0 {
+		t.Fatalf("poll() within the debounce time = %+v, want no events", events)
+	}
+	events := p.poll(start.Add(200 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventCreated || events[0].Name != "gate" || events[0].Path != created {
+		t.Fatalf("poll() after the debounce time = %+v, want gate created", events)
+	}
+
+	// Writes are reported once the file has settled
+	if err := os.WriteFile(existing, []byte("@startuml\n[*] --> Closed\n@enduml"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p.poll(start.Add(300 * time.Millisecond))
+	events = p.poll(start.Add(500 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventModified || events[0].Name != "door" {
+		t.Fatalf("poll() after an edit = %+v, want door modified", events)
+	}
+
+	// Moving a file between locations keeps its name and version
+	moved := filepath.Join(root, "products/puml/door-1.0.0.puml")
+	if err := os.MkdirAll(filepath.Dir(moved), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(existing, moved); err != nil {
+		t.Fatal(err)
+	}
+	p.poll(start.Add(600 * time.Millisecond))
+	events = p.poll(start.Add(800 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventMoved || events[0].Location != models.LocationFileProducts ||
+		events[0].FromLocation != models.LocationFileInProgress || events[0].FromPath != existing {
+		t.Fatalf("poll() after a move = %+v, want door moved to products", events)
+	}
+
+	// A rename within a location is matched by size and modification time
+	renamed := filepath.Join(root, "in-progress/puml/gate-1.1.0.puml")
+	if err := os.Rename(created, renamed); err != nil {
+		t.Fatal(err)
+	}
+	p.poll(start.Add(900 * time.Millisecond))
+	events = p.poll(start.Add(1100 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventMoved || events[0].Version != "1.1.0" || events[0].FromVersion != "1.0.0" {
+		t.Fatalf("poll() after a rename = %+v, want gate moved to 1.1.0", events)
+	}
+
+	if err := os.Remove(moved); err != nil {
+		t.Fatal(err)
+	}
+	p.poll(start.Add(1200 * time.Millisecond))
+	events = p.poll(start.Add(1400 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventDeleted || events[0].Path != moved || events[0].Size != 0 {
+		t.Fatalf("poll() after a delete = %+v, want door deleted", events)
+	}
+
+	// A file that comes and goes within the debounce time is never reported
+	short := writeDiagram(t, root, "in-progress/puml/fence-1.0.0.puml", "@startuml\n@enduml")
+	p.poll(start.Add(1500 * time.Millisecond))
+	if err := os.Remove(short); err != nil {
+		t.Fatal(err)
+	}
+	p.poll(start.Add(1550 * time.Millisecond))
+	if events := p.poll(start.Add(1700 * time.Millisecond)); len(events) != 0 {
+		t.Errorf("poll() after a short-lived file = %+v, want no events", events)
+	}
+}
+
+func TestWatcher_Events(t *testing.T) {
+	w, root := newTestWatcher(t, -1)
+	ctx, cancel := context.WithCancel(context.Background())
+	events := w.Events(ctx)
+
+	// Give Run time to take its baseline scan before the file appears
+	time.Sleep(30 * time.Millisecond)
+	writeDiagram(t, root, "products/puml/door-1.0.0.puml", "@startuml\n@enduml")
+
+	select {
+	case event := <-events:
+		if event.Type != EventCreated || event.Location != models.LocationFileProducts || event.Name != "door" {
+			t.Errorf("event = %+v, want door created in products", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered for a new file")
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatcher_Run_NilHandler(t *testing.T) {
+	w, _ := newTestWatcher(t, 0)
+	if err := w.Run(context.Background(), nil); models.GetErrorType(err) != models.ErrorTypeValidation {
+		t.Errorf("Run() without a handler error = %v, want validation error", err)
+	}
+}
+
+func TestPoller_UnreadableLocation(t *testing.T) {
+	w, root := newTestWatcher(t, -1)
+	writeDiagram(t, root, "in-progress/puml/door-1.0.0.puml", "@startuml\n@enduml")
+	writeDiagram(t, root, "products/puml/gate-1.0.0.puml", "@startuml\n@enduml")
+	p := newPoller(w)
+	start := time.Now()
+
+	// The products directory fails to list while in-progress keeps working
+	products := w.pathManager.GetLocationWithDiagramTypePath(models.LocationFileProducts, smmodels.DiagramTypePUML)
+	w.readDir = func(name string) ([]os.DirEntry, error) {
+		if name == products {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EACCES}
+		}
+		return os.ReadDir(name)
+	}
+	created := writeDiagram(t, root, "in-progress/puml/fence-1.0.0.puml", "@startuml\n@enduml")
+	events := p.poll(start.Add(10 * time.Millisecond))
+	if len(events) != 1 || events[0].Type != EventCreated || events[0].Path != created {
+		t.Fatalf("poll() with products unreadable = %+v, want only fence created", events)
+	}
+	if events := p.poll(start.Add(20 * time.Millisecond)); len(events) != 0 {
+		t.Fatalf("poll() with products still unreadable = %+v, want no events", events)
+	}
+
+	// Once readable again, only real changes to products are reported
+	w.readDir = os.ReadDir
+	if events := p.poll(start.Add(30 * time.Millisecond)); len(events) != 0 {
+		t.Fatalf("poll() with products readable again = %+v, want no events", events)
+	}
+}